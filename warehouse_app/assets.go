@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Font Awesome lives in static/vendor so the UI works without internet
+// access; fetch_fontawesome.sh downloads it and the result is committed.
+const fontAwesomeCSS = "vendor/fontawesome/css/all.min.css"
+
+//go:generate sh fetch_fontawesome.sh
+
+//go:embed static
+var embeddedStatic embed.FS
+
+// staticHandler serves the web UI. Embedded assets are hashed once; with a
+// dev directory every request reads the file from disk and is not cached.
+type staticHandler struct {
+	files fs.FS
+	dev   bool
+
+	mu    sync.Mutex
+	cache map[string]staticFile
+}
+
+type staticFile struct {
+	content []byte
+	hash    string
+}
+
+func newStaticHandler(dir string) http.Handler {
+	if dir != "" {
+		return &staticHandler{files: os.DirFS(dir), dev: true}
+	}
+	files, _ := fs.Sub(embeddedStatic, "static")
+	if _, err := fs.Stat(files, fontAwesomeCSS); err != nil {
+		slog.Warn("Font Awesome is not embedded, icons will be missing; run go generate", "path", "static/"+fontAwesomeCSS)
+	}
+	return &staticHandler{files: files, cache: make(map[string]staticFile)}
+}
+
+var assetReference = regexp.MustCompile(`(href|src)="(/[^"?#]+\.(?:css|js))"`)
+
+func (h *staticHandler) load(name string) (staticFile, error) {
+	if !h.dev {
+		h.mu.Lock()
+		f, ok := h.cache[name]
+		h.mu.Unlock()
+		if ok {
+			return f, nil
+		}
+	}
+	content, err := fs.ReadFile(h.files, name)
+	if err != nil {
+		return staticFile{}, err
+	}
+	// Pages link assets with ?v=<hash> so the assets themselves can be
+	// cached for a long time.
+	if !h.dev && strings.HasSuffix(name, ".html") {
+		content = assetReference.ReplaceAllFunc(content, func(m []byte) []byte {
+			parts := assetReference.FindSubmatch(m)
+			asset, err := h.load(strings.TrimPrefix(string(parts[2]), "/"))
+			if err != nil {
+				return m
+			}
+			return []byte(string(parts[1]) + `="` + string(parts[2]) + "?v=" + asset.hash + `"`)
+		})
+	}
+	sum := sha256.Sum256(content)
+	f := staticFile{content: content, hash: hex.EncodeToString(sum[:8])}
+	if !h.dev {
+		h.mu.Lock()
+		h.cache[name] = f
+		h.mu.Unlock()
+	}
+	return f, nil
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" || strings.HasSuffix(r.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	}
+	f, err := h.load(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) || isDirError(h.files, name) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case h.dev, strings.HasSuffix(name, ".html"):
+		w.Header().Set("Cache-Control", "no-cache")
+	case r.URL.Query().Get("v") == f.hash:
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	default:
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+	}
+	w.Header().Set("ETag", `"`+f.hash+`"`)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(f.content))
+}
+
+func isDirError(files fs.FS, name string) bool {
+	info, err := fs.Stat(files, name)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"io/fs"
+	"testing"
+)
+
+// The UI has no CDN fallback, so a build without Font Awesome has no icons.
+func TestFontAwesomeEmbedded(t *testing.T) {
+	files, err := fs.Sub(embeddedStatic, "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{fontAwesomeCSS, "vendor/fontawesome/LICENSE.txt", "vendor/fontawesome/webfonts/fa-solid-900.woff2"} {
+		if _, err := fs.Stat(files, name); err != nil {
+			t.Errorf("static/%s is not embedded; run go generate and commit static/vendor/fontawesome: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	assignmentOut      = "Выдано"
+	assignmentReturned = "Возвращено"
+)
+
+// Assignment is a check-out of an item to a warehouse or site, a crew and a
+// responsible person. While it is open the item's warehouse is the one it
+// was checked out to.
+type Assignment struct {
+	ID                  int    `json:"id"`
+	EquipmentID         int    `json:"equipment_id"`
+	EquipmentName       string `json:"equipment_name"`
+	InventoryNumber     string `json:"inventory_number"`
+	FromWarehouseID     int    `json:"from_warehouse_id"`
+	FromWarehouseName   string `json:"from_warehouse_name"`
+	WarehouseID         int    `json:"warehouse_id"`
+	WarehouseName       string `json:"warehouse_name"`
+	Site                string `json:"site"`
+	Crew                string `json:"crew"`
+	Responsible         string `json:"responsible"`
+	Status              string `json:"status"`
+	CheckedOutAt        string `json:"checked_out_at"`
+	DueDate             string `json:"due_date"`
+	ReturnedAt          string `json:"returned_at"`
+	ReturnWarehouseID   int    `json:"return_warehouse_id,omitempty"`
+	ReturnWarehouseName string `json:"return_warehouse_name,omitempty"`
+	Note                string `json:"note"`
+	CreatedAt           string `json:"created_at"`
+}
+
+// EquipmentLocation is where an item was at the end of a day, with the
+// assignments it was under at any time that day.
+type EquipmentLocation struct {
+	EquipmentID     int          `json:"equipment_id"`
+	EquipmentName   string       `json:"equipment_name"`
+	InventoryNumber string       `json:"inventory_number"`
+	WarehouseID     int          `json:"warehouse_id"`
+	WarehouseName   string       `json:"warehouse_name"`
+	Assignments     []Assignment `json:"assignments"`
+}
+
+var assignmentListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":             intField,
+		"equipment_id":   intField,
+		"warehouse_id":   intField,
+		"responsible":    textField,
+		"crew":           textField,
+		"status":         textField,
+		"checked_out_at": dateField,
+		"due_date":       dateField,
+		"returned_at":    dateField,
+	},
+	DefaultSort: "-checked_out_at,-id",
+}
+
+func getAssignments(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r, assignmentListSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		assignments, total, err := store.Assignments.List(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, ListPage{Items: assignments, Total: total, NextCursor: q.nextCursor(assignments, total)})
+	}
+}
+
+func checkOutEquipment(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req NewAssignment
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		items, _, err := store.Equipment.List(ctx, byID(req.EquipmentID))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if len(items) > 0 && items[0].Status == equipmentWrittenOff {
+			writeError(w, r, newAPIError(http.StatusConflict, "equipment_written_off", items[0].Name))
+			return
+		}
+		req.CheckedOutAt = normalizeTimestamp(req.CheckedOutAt)
+
+		// Assignments of an item follow one another without overlapping.
+		last, _, err := store.Assignments.List(ctx, ListQuery{
+			Filters: []Filter{{Field: "equipment_id", Op: opIn, Values: []interface{}{req.EquipmentID}}},
+			Sort:    []SortKey{{Field: "checked_out_at", Desc: true}, {Field: "id", Desc: true}},
+			Limit:   1,
+		})
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if len(last) > 0 {
+			if last[0].Status == assignmentOut {
+				writeError(w, r, newAPIError(http.StatusConflict, "already_assigned", items[0].Name, last[0].ID))
+				return
+			}
+			if req.CheckedOutAt < last[0].ReturnedAt {
+				writeError(w, r, validationError(fieldError("checked_out_at", "before_return", last[0].ReturnedAt)))
+				return
+			}
+		}
+		id, err := store.Assignments.CheckOut(ctx, req)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		details := fmt.Sprintf("%s → склад %d, %s", items[0].Name, req.WarehouseID, req.Responsible)
+		if req.Crew != "" {
+			details += ", бригада " + req.Crew
+		}
+		logAction(store.Logs, "system", "Выдача оборудования", "equipment_assignments", details)
+		writeJSON(w, r, MessageResponse{Message: "Оборудование выдано", ID: id})
+	}
+}
+
+func returnEquipment(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, ErrNotFound)
+			return
+		}
+		var req AssignmentReturn
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		found, _, err := store.Assignments.List(ctx, byID(id))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if len(found) == 0 {
+			writeError(w, r, ErrNotFound)
+			return
+		}
+		a := found[0]
+		if a.Status != assignmentOut {
+			writeError(w, r, newAPIError(http.StatusConflict, "assignment_closed", a.ID))
+			return
+		}
+		req.ReturnedAt = normalizeTimestamp(req.ReturnedAt)
+		if req.ReturnedAt < a.CheckedOutAt {
+			writeError(w, r, validationError(fieldError("returned_at", "before_checkout", a.CheckedOutAt)))
+			return
+		}
+		if req.WarehouseID == 0 {
+			req.WarehouseID = a.FromWarehouseID
+		}
+		if req.Note == "" {
+			req.Note = a.Note
+		} else if a.Note != "" {
+			req.Note = a.Note + "\n" + req.Note
+		}
+		if err := store.Assignments.Return(ctx, id, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Возврат оборудования", "equipment_assignments",
+			fmt.Sprintf("%s → склад %d", a.EquipmentName, req.WarehouseID))
+		writeJSON(w, r, MessageResponse{Message: "Оборудование возвращено"})
+	}
+}
+
+var locationParams = []apiParam{
+	{Name: "date", Type: "string", Required: true, Description: "YYYY-MM-DD"},
+	{Name: "warehouse_id", Type: "integer", Description: "только оборудование, находившееся на складе в конце дня"},
+}
+
+// getEquipmentLocations reconstructs where every item was on a day from the
+// assignment history.
+func getEquipmentLocations(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		query := r.URL.Query()
+		day, err := time.ParseInLocation("2006-01-02", query.Get("date"), time.Local)
+		if err != nil {
+			writeError(w, r, queryError("date", "invalid_date", query.Get("date")))
+			return
+		}
+		onlyWarehouse := 0
+		if v := query.Get("warehouse_id"); v != "" {
+			if onlyWarehouse, err = strconv.Atoi(v); err != nil {
+				writeError(w, r, queryError("warehouse_id", "invalid_value", v))
+				return
+			}
+		}
+		start, end := day.Format(time.RFC3339), day.AddDate(0, 0, 1).Format(time.RFC3339)
+
+		equipment, err := listAll(ctx, store.Equipment.List, nil)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		assignments, err := listAll(ctx, store.Assignments.List, nil)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		sort.SliceStable(assignments, func(i, j int) bool { return assignments[i].CheckedOutAt < assignments[j].CheckedOutAt })
+		byItem := map[int][]Assignment{}
+		for _, a := range assignments {
+			byItem[a.EquipmentID] = append(byItem[a.EquipmentID], a)
+		}
+		warehouseNames := map[int]string{}
+		for _, a := range assignments {
+			warehouseNames[a.FromWarehouseID] = a.FromWarehouseName
+			warehouseNames[a.WarehouseID] = a.WarehouseName
+		}
+		for _, e := range equipment {
+			warehouseNames[e.WarehouseID] = e.WarehouseName
+		}
+
+		locations := []EquipmentLocation{}
+		for _, e := range equipment {
+			history := byItem[e.ID]
+			// Items entered after the day are left out unless their history,
+			// possibly entered later too, goes back to it.
+			created, ok := parseDate(e.CreatedAt)
+			if ok && !created.Before(day.AddDate(0, 0, 1)) && (len(history) == 0 || history[0].CheckedOutAt >= end) {
+				continue
+			}
+			loc := EquipmentLocation{EquipmentID: e.ID, EquipmentName: e.Name, InventoryNumber: e.InventoryNumber,
+				WarehouseID: e.WarehouseID, Assignments: []Assignment{}}
+			if len(history) > 0 {
+				// Before its first check-out the item was where that
+				// check-out took it from.
+				loc.WarehouseID = history[0].FromWarehouseID
+			}
+			for _, a := range history {
+				if a.CheckedOutAt >= end {
+					break
+				}
+				loc.WarehouseID = a.WarehouseID
+				if a.ReturnedAt != "" && a.ReturnedAt < end {
+					loc.WarehouseID = a.ReturnWarehouseID
+				}
+				if a.ReturnedAt == "" || a.ReturnedAt > start {
+					loc.Assignments = append(loc.Assignments, a)
+				}
+			}
+			if onlyWarehouse != 0 && loc.WarehouseID != onlyWarehouse {
+				continue
+			}
+			loc.WarehouseName = warehouseNames[loc.WarehouseID]
+			locations = append(locations, loc)
+		}
+		writeJSON(w, r, locations)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestAssignmentHistory checks an item out and back twice, with the
+// requests that must be refused in between, and then asks where it was on
+// each day.
+func TestAssignmentHistory(t *testing.T) {
+	ctx := context.Background()
+	store := openTestSQLite(t)
+	refs := loadTestRefs(t, store)
+	cfg := defaultConfig()
+	events := newEventBus()
+	defer events.close()
+	router := mux.NewRouter()
+	registerRoutes(router, apiRoutes(store, cfg, events), cfg.API)
+	call := func(method, path string, body interface{}) (int, []byte) {
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewReader(data)))
+		return w.Code, w.Body.Bytes()
+	}
+
+	categories, err := store.Reference.EquipmentCategories(ctx)
+	if err != nil || len(categories) == 0 {
+		t.Fatalf("no equipment categories: %v", err)
+	}
+	home, site := refs.warehouses[0], refs.warehouses[1]
+	equipment, err := store.Equipment.Create(ctx, NewEquipment{Name: "Генератор", CategoryID: categories[0].ID, WarehouseID: home,
+		UnitID: refs.unit, Quantity: 1, SerialNumber: "GEN-1", InventoryNumber: "ИНВ-77", Status: equipmentInService})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name      string
+		checkOut  bool
+		at        string
+		warehouse int
+		status    int
+		code      string
+		wantAt    int
+	}{
+		{"check out", true, "2026-03-01T10:00:00Z", site, http.StatusOK, "", site},
+		{"check out again", true, "2026-03-02T10:00:00Z", site, http.StatusConflict, "already_assigned", site},
+		{"return before check-out", false, "2026-02-28T10:00:00Z", 0, http.StatusBadRequest, "before_checkout", site},
+		{"return home", false, "2026-03-05T10:00:00Z", 0, http.StatusOK, "", home},
+		{"return again", false, "2026-03-06T10:00:00Z", 0, http.StatusConflict, "assignment_closed", home},
+		{"check out before the return", true, "2026-03-04T10:00:00Z", site, http.StatusBadRequest, "before_return", home},
+		{"check out for the second time", true, "2026-03-08T10:00:00Z", site, http.StatusOK, "", site},
+		{"return to the site", false, "2026-03-10T10:00:00Z", site, http.StatusOK, "", site},
+	}
+	assignment := 0
+	for _, step := range steps {
+		var status int
+		var body []byte
+		if step.checkOut {
+			status, body = call(http.MethodPost, "/api/v1/equipment-assignments", NewAssignment{EquipmentID: equipment,
+				WarehouseID: step.warehouse, Crew: "Бригада 2", Responsible: "Петров", CheckedOutAt: step.at})
+		} else {
+			status, body = call(http.MethodPost, fmt.Sprintf("/api/v1/equipment-assignments/%d/return", assignment),
+				AssignmentReturn{WarehouseID: step.warehouse, ReturnedAt: step.at})
+		}
+		if status != step.status {
+			t.Fatalf("%s: status %d, want %d: %s", step.name, status, step.status, body)
+		}
+		var reply struct {
+			ID    int
+			Error struct {
+				Code   string
+				Fields []struct{ Code string }
+			}
+		}
+		if err := json.Unmarshal(body, &reply); err != nil {
+			t.Fatal(err)
+		}
+		code := reply.Error.Code
+		if len(reply.Error.Fields) > 0 {
+			code = reply.Error.Fields[0].Code
+		}
+		if code != step.code {
+			t.Errorf("%s: error %q, want %q", step.name, code, step.code)
+		}
+		if step.checkOut && status == http.StatusOK {
+			assignment = reply.ID
+		}
+		items, _, err := store.Equipment.List(ctx, byID(equipment))
+		if err != nil || len(items) != 1 {
+			t.Fatalf("%s: %v (%v)", step.name, items, err)
+		}
+		if items[0].WarehouseID != step.wantAt {
+			t.Errorf("%s: item at warehouse %d, want %d", step.name, items[0].WarehouseID, step.wantAt)
+		}
+	}
+
+	history, _, err := store.Assignments.List(ctx, listQuery(t, assignmentListSpec, fmt.Sprintf("equipment_id=%d", equipment)))
+	if err != nil || len(history) != 2 {
+		t.Fatalf("history %+v (%v), want two assignments", history, err)
+	}
+	for _, a := range history {
+		if a.Status != assignmentReturned || a.FromWarehouseID != home || a.WarehouseID != site || a.Crew != "Бригада 2" || a.InventoryNumber != "ИНВ-77" {
+			t.Errorf("assignment read back as %+v", a)
+		}
+	}
+	if history[0].ID != assignment || history[0].ReturnWarehouseID != site || history[1].ReturnWarehouseID != home {
+		t.Errorf("history is not newest first or lost return warehouses: %+v", history)
+	}
+
+	days := []struct {
+		date        string
+		listed      bool
+		warehouse   int
+		assignments int
+	}{
+		{"2026-02-28", false, 0, 0},
+		{"2026-03-01", true, site, 1},
+		{"2026-03-05", true, home, 1},
+		{"2026-03-06", true, home, 0},
+		{"2026-03-09", true, site, 1},
+		{"2026-03-11", true, site, 0},
+	}
+	for _, day := range days {
+		status, body := call(http.MethodGet, "/api/v1/equipment/locations?date="+day.date, nil)
+		if status != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", day.date, status, body)
+		}
+		var locations []EquipmentLocation
+		if err := json.Unmarshal(body, &locations); err != nil {
+			t.Fatal(err)
+		}
+		var found *EquipmentLocation
+		for i := range locations {
+			if locations[i].EquipmentID == equipment {
+				found = &locations[i]
+			}
+		}
+		switch {
+		case found == nil:
+			if day.listed {
+				t.Errorf("%s: item not listed", day.date)
+			}
+		case !day.listed:
+			t.Errorf("%s: item listed before it was first checked out", day.date)
+		case found.WarehouseID != day.warehouse || len(found.Assignments) != day.assignments:
+			t.Errorf("%s: at %d with %d assignments, want %d with %d", day.date, found.WarehouseID, len(found.Assignments), day.warehouse, day.assignments)
+		}
+	}
+}
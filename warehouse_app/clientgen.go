@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+//go:generate go run . gen-client -out client/client.go
+
+// runGenClientCommand writes a Go client package for the current API
+// version of the routes in apiRoutes.
+func runGenClientCommand(args []string) error {
+	fs := flag.NewFlagSet("gen-client", flag.ExitOnError)
+	out := fs.String("out", "client/client.go", "output file")
+	pkg := fs.String("package", "client", "package name")
+	fs.Parse(args)
+
+	src, err := generateClient(*pkg, apiRoutes(nil, defaultConfig(), nil))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
+
+func generateClient(pkg string, routes []apiRoute) ([]byte, error) {
+	g := &clientGenerator{seen: map[string]bool{}}
+	fmt.Fprintf(&g.buf, clientPrelude, pkg)
+	for _, rt := range routes {
+		g.method(rt)
+	}
+	g.collect(reflect.TypeOf(ErrorResponse{}))
+	g.buf.WriteString(g.types.String())
+	return format.Source(g.buf.Bytes())
+}
+
+type clientGenerator struct {
+	buf   bytes.Buffer
+	types bytes.Buffer
+	seen  map[string]bool
+}
+
+func (g *clientGenerator) method(rt apiRoute) {
+	name := exportedName(rt.Operation)
+	args := []string{"ctx context.Context"}
+	fullPath := versionedPath(currentAPIVersion(), rt.Path)
+	path := fmt.Sprintf("%q", openAPIPath(fullPath))
+	var pathArgs []string
+	for _, m := range pathParam.FindAllStringSubmatch(rt.Path, -1) {
+		args = append(args, m[1]+" int")
+		pathArgs = append(pathArgs, m[1])
+	}
+	if len(pathArgs) > 0 {
+		path = fmt.Sprintf("fmt.Sprintf(%q, %s)", pathParam.ReplaceAllString(fullPath, "%d"), strings.Join(pathArgs, ", "))
+	}
+	query := "nil"
+	if rt.List != nil || len(rt.Params) > 0 {
+		args = append(args, "query url.Values")
+		query = "query"
+	}
+	body := "nil"
+	if rt.Request != nil {
+		args = append(args, "req "+g.typeName(reflect.TypeOf(rt.Request)))
+		body = "req"
+	}
+	if len(rt.Consumes) > 0 {
+		args = append(args, "contentType string", "body io.Reader")
+		body = "rawBody{contentType, body}"
+	}
+
+	fmt.Fprintf(&g.buf, "\n// %s calls %s %s (%s).\n", name, rt.Method, openAPIPath(fullPath), rt.Summary)
+	signature := fmt.Sprintf("func (c *Client) %s(%s)", name, strings.Join(args, ", "))
+	switch {
+	case rt.Response == nil:
+		fmt.Fprintf(&g.buf, "%s (io.ReadCloser, error) {\n", signature)
+		fmt.Fprintf(&g.buf, "\tresp, err := c.send(ctx, %q, %s, %s, %s)\n", rt.Method, path, query, body)
+		g.buf.WriteString("\tif err != nil {\n\t\treturn nil, err\n\t}\n\treturn resp.Body, nil\n}\n")
+		return
+	case rt.Paged:
+		item := reflect.TypeOf(rt.Response)
+		g.collectPage(item)
+		fmt.Fprintf(&g.buf, "%s (*%sPage, error) {\n\tvar out %sPage\n", signature, item.Name(), item.Name())
+	default:
+		t := reflect.TypeOf(rt.Response)
+		if t.Kind() == reflect.Slice {
+			fmt.Fprintf(&g.buf, "%s (%s, error) {\n\tvar out %s\n", signature, g.typeName(t), g.typeName(t))
+			fmt.Fprintf(&g.buf, "\tif err := c.do(ctx, %q, %s, %s, %s, &out); err != nil {\n\t\treturn nil, err\n\t}\n\treturn out, nil\n}\n", rt.Method, path, query, body)
+			return
+		}
+		fmt.Fprintf(&g.buf, "%s (*%s, error) {\n\tvar out %s\n", signature, g.typeName(t), g.typeName(t))
+	}
+	fmt.Fprintf(&g.buf, "\tif err := c.do(ctx, %q, %s, %s, %s, &out); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &out, nil\n}\n", rt.Method, path, query, body)
+}
+
+// typeName returns the Go spelling of t in the client package and emits the
+// struct types it refers to.
+func (g *clientGenerator) typeName(t reflect.Type) string {
+	if t == decimalType {
+		// json.Number keeps every digit of a decimal.
+		return "json.Number"
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + g.typeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + g.typeName(t.Elem())
+	case reflect.Map:
+		return "map[" + g.typeName(t.Key()) + "]" + g.typeName(t.Elem())
+	case reflect.Struct:
+		g.collect(t)
+		return t.Name()
+	case reflect.Interface:
+		return "interface{}"
+	}
+	return t.Kind().String()
+}
+
+func (g *clientGenerator) collect(t reflect.Type) {
+	if g.seen[t.Name()] {
+		return
+	}
+	g.seen[t.Name()] = true
+	var fields strings.Builder
+	for _, f := range jsonFields(t) {
+		tag := reflect.StructTag(f.Tag).Get("json")
+		fmt.Fprintf(&fields, "\t%s %s `json:%q`\n", f.GoName, g.typeName(f.Type), tag)
+	}
+	fmt.Fprintf(&g.types, "\ntype %s struct {\n%s}\n", t.Name(), fields.String())
+}
+
+func (g *clientGenerator) collectPage(item reflect.Type) {
+	name := item.Name() + "Page"
+	if g.seen[name] {
+		return
+	}
+	g.seen[name] = true
+	fmt.Fprintf(&g.types, "\ntype %s struct {\n\tItems []%s `json:\"items\"`\n\tTotal int `json:\"total\"`\n\tNextCursor string `json:\"next_cursor,omitempty\"`\n}\n", name, g.typeName(item))
+}
+
+func exportedName(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+const clientPrelude = `// Code generated by "warehouse_app gen-client"; DO NOT EDIT.
+
+// Package %[1]s is a Go client for the warehouse API. Regenerate it with
+// go generate after changing routes or request and response types.
+package %[1]s
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Language is sent as Accept-Language and selects the language of
+	// error messages.
+	Language string
+}
+
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// Error is returned for responses with a non-2xx status.
+type Error struct {
+	Status int
+	ErrorDetail
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%%d %%s: %%s", e.Status, e.Code, e.Message)
+}
+
+// rawBody is a request body sent as is rather than encoded as JSON.
+type rawBody struct {
+	contentType string
+	r           io.Reader
+}
+
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var reader io.Reader
+	contentType := "application/json"
+	if raw, ok := body.(rawBody); ok {
+		reader, contentType = raw.r, raw.contentType
+	} else if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Language != "" {
+		req.Header.Set("Accept-Language", c.Language)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		apiErr := &Error{Status: resp.StatusCode}
+		var envelope ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err == nil {
+			apiErr.ErrorDetail = envelope.Error
+		} else {
+			apiErr.Message = resp.Status
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	resp, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+`
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	capacityOff     = "off"
+	capacityWarn    = "warn"
+	capacityEnforce = "enforce"
+)
+
+type Config struct {
+	Listen    string         `yaml:"listen"`
+	TLS       TLSConfig      `yaml:"tls"`
+	Database  DatabaseConfig `yaml:"database"`
+	StaticDir string         `yaml:"static_dir"`
+	LogLevel  string         `yaml:"log_level"`
+	Seed      bool           `yaml:"seed"`
+	Logs      LogRetention   `yaml:"logs"`
+	Business  BusinessConfig `yaml:"business"`
+	Server    ServerConfig   `yaml:"server"`
+	API       APIConfig      `yaml:"api"`
+	Webhooks  WebhookConfig  `yaml:"webhooks"`
+}
+
+type TLSConfig struct {
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+}
+
+func (t TLSConfig) Enabled() bool {
+	return t.Cert != ""
+}
+
+type DatabaseConfig struct {
+	Driver string `yaml:"driver"`
+	DSN    string `yaml:"dsn"`
+}
+
+type BusinessConfig struct {
+	// DefaultUnit is the symbol of the unit used when a request omits
+	// unit_id. Empty means unit_id is required.
+	DefaultUnit string `yaml:"default_unit"`
+	// CapacityMode decides what happens when a new batch would exceed the
+	// warehouse capacity: off, warn or enforce.
+	CapacityMode string `yaml:"capacity_mode"`
+	// VATRate is the VAT percentage charged on sales orders.
+	VATRate float64 `yaml:"vat_rate"`
+	// Currency is the reporting currency: the default of orders and price
+	// lists, the one exchange rates are quoted in and analytics converted to.
+	Currency string `yaml:"currency"`
+
+	DefaultUnitID int `yaml:"-"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Listen:   ":8080",
+		Database: DatabaseConfig{Driver: "sqlite", DSN: "./warehouse.db"},
+		LogLevel: "info",
+		Seed:     true,
+		Logs:     LogRetention{Dir: "./archive/logs"},
+		Business: BusinessConfig{CapacityMode: capacityOff, VATRate: 20, Currency: "RUB"},
+		Server:   defaultServerConfig(),
+		API:      APIConfig{LegacyDeprecated: "2026-10-19"},
+		Webhooks: defaultWebhookConfig(),
+	}
+}
+
+// setting binds one option to its environment variable and flag.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	bool  bool
+	set   func(c *Config, value string) error
+}
+
+func stringSetting(field func(c *Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func intSetting(field func(c *Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", value)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func int64Setting(field func(c *Config) *int64) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", value)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func floatSetting(field func(c *Config) *float64) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("expected a number, got %q", value)
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+func durationSetting(field func(c *Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("expected a duration such as 30s, got %q", value)
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+func boolSetting(field func(c *Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", value)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+var settings = []setting{
+	{flag: "listen", env: "WAREHOUSE_LISTEN", usage: "address to listen on", set: stringSetting(func(c *Config) *string { return &c.Listen })},
+	{flag: "tls-cert", env: "WAREHOUSE_TLS_CERT", usage: "TLS certificate file (enables HTTPS)", set: stringSetting(func(c *Config) *string { return &c.TLS.Cert })},
+	{flag: "tls-key", env: "WAREHOUSE_TLS_KEY", usage: "TLS private key file", set: stringSetting(func(c *Config) *string { return &c.TLS.Key })},
+	{flag: "db-driver", env: "WAREHOUSE_DB_DRIVER", usage: "database backend: sqlite or postgres", set: stringSetting(func(c *Config) *string { return &c.Database.Driver })},
+	{flag: "db-dsn", env: "WAREHOUSE_DB_DSN", usage: "database connection string", set: stringSetting(func(c *Config) *string { return &c.Database.DSN })},
+	{flag: "static-dir", env: "WAREHOUSE_STATIC_DIR", usage: "serve the web UI from this directory instead of the embedded copy (development)", set: stringSetting(func(c *Config) *string { return &c.StaticDir })},
+	{flag: "log-level", env: "WAREHOUSE_LOG_LEVEL", usage: "debug, info, warn or error", set: stringSetting(func(c *Config) *string { return &c.LogLevel })},
+	{flag: "seed", env: "WAREHOUSE_SEED", usage: "fill empty reference tables with demo data", bool: true, set: boolSetting(func(c *Config) *bool { return &c.Seed })},
+	{flag: "log-retention-days", env: "WAREHOUSE_LOG_RETENTION_DAYS", usage: "archive log entries older than this many days (0 disables archiving)", set: intSetting(func(c *Config) *int { return &c.Logs.Days })},
+	{flag: "log-archive-dir", env: "WAREHOUSE_LOG_ARCHIVE_DIR", usage: "directory for compressed log archives", set: stringSetting(func(c *Config) *string { return &c.Logs.Dir })},
+	{flag: "default-unit", env: "WAREHOUSE_DEFAULT_UNIT", usage: "unit symbol used when unit_id is omitted", set: stringSetting(func(c *Config) *string { return &c.Business.DefaultUnit })},
+	{flag: "read-timeout", env: "WAREHOUSE_READ_TIMEOUT", usage: "maximum time to read a request", set: durationSetting(func(c *Config) *time.Duration { return &c.Server.ReadTimeout })},
+	{flag: "write-timeout", env: "WAREHOUSE_WRITE_TIMEOUT", usage: "maximum time to write a response", set: durationSetting(func(c *Config) *time.Duration { return &c.Server.WriteTimeout })},
+	{flag: "idle-timeout", env: "WAREHOUSE_IDLE_TIMEOUT", usage: "keep-alive idle timeout", set: durationSetting(func(c *Config) *time.Duration { return &c.Server.IdleTimeout })},
+	{flag: "shutdown-timeout", env: "WAREHOUSE_SHUTDOWN_TIMEOUT", usage: "time to drain active requests on SIGTERM", set: durationSetting(func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout })},
+	{flag: "max-body-bytes", env: "WAREHOUSE_MAX_BODY_BYTES", usage: "maximum request body size", set: int64Setting(func(c *Config) *int64 { return &c.Server.MaxBodyBytes })},
+	{flag: "http2", env: "WAREHOUSE_HTTP2", usage: "allow HTTP/2 over TLS", bool: true, set: boolSetting(func(c *Config) *bool { return &c.Server.HTTP2 })},
+	{flag: "legacy-deprecated", env: "WAREHOUSE_LEGACY_DEPRECATED", usage: "date (YYYY-MM-DD) sent in the Deprecation header of unversioned /api paths; empty sends none", set: stringSetting(func(c *Config) *string { return &c.API.LegacyDeprecated })},
+	{flag: "legacy-sunset", env: "WAREHOUSE_LEGACY_SUNSET", usage: "date (YYYY-MM-DD) after which unversioned /api paths answer 410; empty keeps them", set: stringSetting(func(c *Config) *string { return &c.API.LegacySunset })},
+	{flag: "webhook-timeout", env: "WAREHOUSE_WEBHOOK_TIMEOUT", usage: "timeout of one webhook delivery attempt", set: durationSetting(func(c *Config) *time.Duration { return &c.Webhooks.Timeout })},
+	{flag: "webhook-max-attempts", env: "WAREHOUSE_WEBHOOK_MAX_ATTEMPTS", usage: "attempts before a webhook delivery is marked failed", set: intSetting(func(c *Config) *int { return &c.Webhooks.MaxAttempts })},
+	{flag: "capacity-mode", env: "WAREHOUSE_CAPACITY_MODE", usage: "warehouse capacity check: off, warn or enforce", set: stringSetting(func(c *Config) *string { return &c.Business.CapacityMode })},
+	{flag: "vat-rate", env: "WAREHOUSE_VAT_RATE", usage: "VAT percentage charged on sales orders", set: floatSetting(func(c *Config) *float64 { return &c.Business.VATRate })},
+	{flag: "currency", env: "WAREHOUSE_CURRENCY", usage: "reporting currency code, e.g. RUB", set: stringSetting(func(c *Config) *string { return &c.Business.Currency })},
+}
+
+// configLoader collects the config flags of a flag set; load applies, in
+// increasing precedence, defaults, the config file, environment variables
+// and flags.
+type configLoader struct {
+	path  string
+	flags []func(c *Config) error
+}
+
+func bindConfigFlags(fs *flag.FlagSet) *configLoader {
+	l := &configLoader{}
+	fs.StringVar(&l.path, "config", "", "YAML config file (default $WAREHOUSE_CONFIG or ./warehouse.yaml if present)")
+	for _, s := range settings {
+		s := s
+		record := func(value string) error {
+			l.flags = append(l.flags, func(c *Config) error {
+				if err := s.set(c, value); err != nil {
+					return fmt.Errorf("flag -%s: %w", s.flag, err)
+				}
+				return nil
+			})
+			return nil
+		}
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		if s.bool {
+			fs.BoolFunc(s.flag, usage, record)
+		} else {
+			fs.Func(s.flag, usage, record)
+		}
+	}
+	return l
+}
+
+func (l *configLoader) load() (Config, error) {
+	cfg := defaultConfig()
+
+	path := l.path
+	if path == "" {
+		path = os.Getenv("WAREHOUSE_CONFIG")
+	}
+	explicit := path != ""
+	if !explicit {
+		path = "warehouse.yaml"
+	}
+	if err := readConfigFile(path, &cfg); err != nil {
+		if explicit || !errors.Is(err, os.ErrNotExist) {
+			return cfg, err
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok && value != "" {
+			if err := s.set(&cfg, value); err != nil {
+				return cfg, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	for _, apply := range l.flags {
+		if err := apply(&cfg); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, cfg.validate()
+}
+
+func readConfigFile(path string, cfg *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c Config) validate() error {
+	var problems []string
+	if c.Listen == "" {
+		problems = append(problems, "listen must not be empty")
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		problems = append(problems, "tls.cert and tls.key must be set together")
+	}
+	for _, file := range []string{c.TLS.Cert, c.TLS.Key} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			problems = append(problems, fmt.Sprintf("tls file %s: %v", file, err))
+		}
+	}
+	switch c.Database.Driver {
+	case "sqlite", "postgres":
+	default:
+		problems = append(problems, fmt.Sprintf("database.driver must be sqlite or postgres, got %q", c.Database.Driver))
+	}
+	if c.Database.DSN == "" {
+		problems = append(problems, "database.dsn must not be empty")
+	}
+	if c.StaticDir != "" {
+		if info, err := os.Stat(c.StaticDir); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("static_dir %s is not a directory", c.StaticDir))
+		}
+	}
+	if _, ok := logLevels[strings.ToLower(c.LogLevel)]; !ok {
+		problems = append(problems, fmt.Sprintf("log_level must be debug, info, warn or error, got %q", c.LogLevel))
+	}
+	if c.Logs.Days < 0 {
+		problems = append(problems, "logs.retention_days must not be negative")
+	}
+	if c.Logs.Enabled() && c.Logs.Dir == "" {
+		problems = append(problems, "logs.archive_dir is required when retention is enabled")
+	}
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+	}
+	for _, t := range timeouts {
+		if t.value < 0 {
+			problems = append(problems, t.name+" must not be negative")
+		}
+	}
+	if c.Server.MaxBodyBytes <= 0 {
+		problems = append(problems, "server.max_body_bytes must be positive")
+	}
+	switch c.Business.CapacityMode {
+	case capacityOff, capacityWarn, capacityEnforce:
+	default:
+		problems = append(problems, fmt.Sprintf("business.capacity_mode must be off, warn or enforce, got %q", c.Business.CapacityMode))
+	}
+	if c.Business.VATRate < 0 || c.Business.VATRate > 100 {
+		problems = append(problems, fmt.Sprintf("business.vat_rate must be between 0 and 100, got %v", c.Business.VATRate))
+	}
+	if !isCurrencyCode(c.Business.Currency) {
+		problems = append(problems, fmt.Sprintf("business.currency must be a three-letter code such as RUB, got %q", c.Business.Currency))
+	}
+	if _, ok := c.API.deprecated(); c.API.LegacyDeprecated != "" && !ok {
+		problems = append(problems, fmt.Sprintf("api.legacy_deprecated must be a YYYY-MM-DD date, got %q", c.API.LegacyDeprecated))
+	}
+	if _, ok := c.API.sunset(); c.API.LegacySunset != "" && !ok {
+		problems = append(problems, fmt.Sprintf("api.legacy_sunset must be a YYYY-MM-DD date, got %q", c.API.LegacySunset))
+	}
+	webhookDurations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"webhooks.poll_interval", c.Webhooks.PollInterval},
+		{"webhooks.timeout", c.Webhooks.Timeout},
+		{"webhooks.backoff_base", c.Webhooks.BackoffBase},
+		{"webhooks.backoff_max", c.Webhooks.BackoffMax},
+	}
+	for _, d := range webhookDurations {
+		if d.value <= 0 {
+			problems = append(problems, d.name+" must be positive")
+		}
+	}
+	if c.Webhooks.MaxAttempts <= 0 {
+		problems = append(problems, "webhooks.max_attempts must be positive")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// setupLogging routes both log and slog output through one handler, so
+// log.Printf messages are filtered as info. Errors that must always be
+// reported go through slog.Error or fatalf.
+func setupLogging(level string) {
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevels[strings.ToLower(level)]})
+	slog.SetDefault(slog.New(handler))
+}
+
+func fatalf(format string, args ...interface{}) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
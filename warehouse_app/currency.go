@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	rateManual = "Вручную"
+	rateFile   = "Файл"
+)
+
+// ExchangeRate is the price of one unit of Currency in the reporting
+// currency from RateDate until the next rate.
+type ExchangeRate struct {
+	ID        int     `json:"id"`
+	Currency  string  `json:"currency"`
+	RateDate  string  `json:"rate_date"`
+	Rate      Decimal `json:"rate"`
+	Source    string  `json:"source"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+// frozenRate is the rate an order is confirmed at.
+type frozenRate struct {
+	Rate     Decimal
+	Date     string
+	Currency string
+}
+
+var exchangeRateListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":        intField,
+		"currency":  textField,
+		"rate_date": dateField,
+	},
+	DefaultSort: "-rate_date,currency",
+}
+
+var exchangeRateImportColumns = []importColumn{
+	{Field: "currency", Headers: []string{"Валюта", "Код валюты", "Букв. код"}},
+	{Field: "rate_date", Headers: []string{"Дата", "Дата курса"}},
+	{Field: "rate", Headers: []string{"Курс"}},
+	{Field: "nominal", Headers: []string{"Номинал", "Единиц"}},
+}
+
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// rateOn returns the latest rate of a currency entered for the day or
+// before it.
+func rateOn(ctx context.Context, store *Store, currency, day string) (ExchangeRate, bool, error) {
+	rates, _, err := store.Rates.List(ctx, ListQuery{
+		Filters: []Filter{
+			{Field: "currency", Op: opIn, Values: []interface{}{currency}},
+			{Field: "rate_date", Op: opAtMost, Values: []interface{}{day}},
+		},
+		Sort:  []SortKey{{Field: "rate_date", Desc: true}},
+		Limit: 1,
+	})
+	if err != nil || len(rates) == 0 {
+		return ExchangeRate{}, false, err
+	}
+	return rates[0], true, nil
+}
+
+// confirmationRate is the rate of the current day an order in currency is
+// confirmed at, or a no_exchange_rate error.
+func confirmationRate(ctx context.Context, store *Store, business BusinessConfig, currency string) (*frozenRate, error) {
+	day := time.Now().Format("2006-01-02")
+	if currency == business.Currency {
+		return &frozenRate{Rate: decimalScale, Date: day, Currency: business.Currency}, nil
+	}
+	rate, ok, err := rateOn(ctx, store, currency, day)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, newAPIError(http.StatusConflict, "no_exchange_rate", currency, day)
+	}
+	return &frozenRate{Rate: rate.Rate, Date: rate.RateDate, Currency: business.Currency}, nil
+}
+
+// prepareRates brings rates to one unit of the currency and rejects rates
+// of the reporting currency itself.
+func prepareRates(rates []NewExchangeRate, business BusinessConfig, field func(i int, name string) string) error {
+	var problems []FieldError
+	for i := range rates {
+		r := &rates[i]
+		if r.Currency == business.Currency {
+			problems = append(problems, fieldError(field(i, "currency"), "reporting_currency", r.Currency))
+			continue
+		}
+		if t, ok := parseDate(r.RateDate); ok {
+			r.RateDate = t.In(time.Local).Format("2006-01-02")
+		}
+		if r.Nominal > 1 {
+			nominal, err := decimalOf(float64(r.Nominal))
+			if err != nil {
+				return err
+			}
+			rate, err := r.Rate.Div(nominal)
+			if err != nil {
+				return err
+			}
+			r.Rate = &rate
+		}
+	}
+	if len(problems) > 0 {
+		return validationError(problems...)
+	}
+	return nil
+}
+
+func getExchangeRates(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r, exchangeRateListSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		rates, total, err := store.Rates.List(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, ListPage{Items: rates, Total: total, NextCursor: q.nextCursor(rates, total)})
+	}
+}
+
+func addExchangeRate(store *Store, business BusinessConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req NewExchangeRate
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		rates := []NewExchangeRate{req}
+		if err := prepareRates(rates, business, func(_ int, name string) string { return name }); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if err := store.Rates.Set(ctx, rates, rateManual); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Курс валюты", "exchange_rates",
+			fmt.Sprintf("%s на %s: %s %s", rates[0].Currency, rates[0].RateDate, rates[0].Rate, business.Currency))
+		writeJSON(w, r, MessageResponse{Message: "Курс сохранён"})
+	}
+}
+
+// importExchangeRates loads rates from a CSV or XLSX file with the columns
+// of exchangeRateImportColumns, e.g. a table exported from the central
+// bank's site.
+func importExchangeRates(store *Store, business BusinessConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req, ok := readImportRequest(w, r)
+		if !ok {
+			return
+		}
+		result := ImportResult{DryRun: req.dryRun, Created: []int{}}
+		rates, lines, err := parseImport(req, exchangeRateImportColumns, importRefs{}, NewExchangeRate{}, &result)
+		if err == nil {
+			err = prepareRates(rates, business, func(i int, name string) string { return fmt.Sprintf("rows[%d].%s", lines[i], name) })
+		}
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if req.dryRun {
+			result.Preview = importPreview(rates, lines)
+			writeJSON(w, r, result)
+			return
+		}
+		if err := store.Rates.Set(ctx, rates, rateFile); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Импорт курсов валют", "exchange_rates", fmt.Sprintf("Загружено курсов: %d", len(rates)))
+		writeJSON(w, r, result)
+	}
+}
+
+// RevenueLine sums confirmed orders of one currency or one buyer.
+type RevenueLine struct {
+	Currency       string `json:"currency,omitempty"`
+	ContractorID   int    `json:"contractor_id,omitempty"`
+	ContractorName string `json:"contractor_name,omitempty"`
+	Orders         int    `json:"orders"`
+	// Amount is in Currency and only set for lines by currency.
+	Amount          *Decimal `json:"amount,omitempty"`
+	ReportingAmount Decimal  `json:"reporting_amount"`
+}
+
+// SalesRevenue is the value of confirmed orders with VAT in the reporting
+// currency, at the rates frozen when they were confirmed.
+type SalesRevenue struct {
+	Currency     string        `json:"currency"`
+	From         string        `json:"from"`
+	To           string        `json:"to"`
+	Orders       int           `json:"orders"`
+	Total        Decimal       `json:"total"`
+	ByCurrency   []RevenueLine `json:"by_currency"`
+	ByContractor []RevenueLine `json:"by_contractor"`
+	// Unconverted lists orders confirmed in another reporting currency.
+	Unconverted []string `json:"unconverted"`
+}
+
+var revenueParams = []apiParam{
+	{Name: "from", Type: "string", Description: "YYYY-MM-DD, дата заказа"},
+	{Name: "to", Type: "string", Description: "YYYY-MM-DD включительно"},
+}
+
+func getSalesRevenue(store *Store, business BusinessConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		report := SalesRevenue{Currency: business.Currency, From: query.Get("from"), To: query.Get("to"),
+			ByCurrency: []RevenueLine{}, ByContractor: []RevenueLine{}, Unconverted: []string{}}
+		for name, v := range map[string]string{"from": report.From, "to": report.To} {
+			if _, ok := parseDate(v); v != "" && !ok {
+				writeError(w, r, queryError(name, "invalid_date", v))
+				return
+			}
+		}
+		orders, err := listAll(r.Context(), store.Orders.List, nil)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		byCurrency := map[string]*RevenueLine{}
+		byContractor := map[int]*RevenueLine{}
+		var sumErr error
+		add := func(sum *Decimal, v Decimal) {
+			if sumErr == nil {
+				*sum, sumErr = sum.Add(v)
+			}
+		}
+		for _, o := range orders {
+			if o.Rate == nil {
+				continue
+			}
+			date := o.OrderDate
+			if date == "" {
+				date = o.RateDate
+			}
+			day := pricingDay(date)
+			if report.From != "" && day < pricingDay(report.From) || report.To != "" && day > pricingDay(report.To) {
+				continue
+			}
+			if o.ReportingCurrency != business.Currency {
+				report.Unconverted = append(report.Unconverted, o.OrderNumber)
+				continue
+			}
+			amount := *o.ReportingAmount
+			report.Orders++
+			add(&report.Total, amount)
+			c := byCurrency[o.Currency]
+			if c == nil {
+				zero := Decimal(0)
+				c = &RevenueLine{Currency: o.Currency, Amount: &zero}
+				byCurrency[o.Currency] = c
+			}
+			c.Orders++
+			add(c.Amount, o.TotalAmount)
+			add(&c.ReportingAmount, amount)
+			b := byContractor[o.ContractorID]
+			if b == nil {
+				b = &RevenueLine{ContractorID: o.ContractorID, ContractorName: o.ContractorName}
+				byContractor[o.ContractorID] = b
+			}
+			b.Orders++
+			add(&b.ReportingAmount, amount)
+		}
+		if sumErr != nil {
+			writeError(w, r, sumErr)
+			return
+		}
+		for _, c := range byCurrency {
+			report.ByCurrency = append(report.ByCurrency, *c)
+		}
+		for _, b := range byContractor {
+			report.ByContractor = append(report.ByContractor, *b)
+		}
+		sort.Slice(report.ByCurrency, func(i, j int) bool {
+			return report.ByCurrency[i].ReportingAmount > report.ByCurrency[j].ReportingAmount
+		})
+		sort.Slice(report.ByContractor, func(i, j int) bool {
+			return report.ByContractor[i].ReportingAmount > report.ByContractor[j].ReportingAmount
+		})
+		writeJSON(w, r, report)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// TestCurrencyFromConfig replays migration 0020 on price lists created
+// before and after 0014: only the older ones lose the roubles 0014 gave
+// them and get business.currency at startup.
+func TestCurrencyFromConfig(t *testing.T) {
+	ctx := context.Background()
+	store := openTestSQLite(t)
+	refs := loadTestRefs(t, store)
+	db := store.Rates.(*sqlRateStore).db
+
+	tests := []struct {
+		name     string
+		currency string
+		legacy   bool
+		want     string
+	}{
+		{"before 0014", "RUB", true, "KZT"},
+		{"roubles after 0014", "RUB", false, "RUB"},
+		{"dollars after 0014", "USD", false, "USD"},
+	}
+	ids := make([]int, len(tests))
+	for i, tt := range tests {
+		base := Decimal(100 * decimalScale)
+		id, err := store.Prices.Create(ctx, NewPriceList{OreTypeID: refs.oreType, UnitID: refs.unit, Currency: tt.currency, BasePrice: &base})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.legacy {
+			if _, err := db.exec(ctx, "UPDATE price_lists SET created_at = '2000-01-01T00:00:00Z' WHERE id = ?", id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ids[i] = id
+	}
+
+	migrations, err := loadMigrations(migrationFiles, "migrations/sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mig := range migrations {
+		if mig.Version == 20 {
+			if _, err := db.db.ExecContext(ctx, mig.Up); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if n, err := store.Rates.FillCurrency(ctx, "KZT"); err != nil || n != 1 {
+		t.Fatalf("FillCurrency = %d, %v; want 1 row", n, err)
+	}
+
+	lists, _, err := store.Prices.List(ctx, listQuery(t, priceListSpec, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	currencies := map[int]string{}
+	for _, p := range lists {
+		currencies[p.ID] = p.Currency
+	}
+	for i, tt := range tests {
+		if got := currencies[ids[i]]; got != tt.want {
+			t.Errorf("%s: currency %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
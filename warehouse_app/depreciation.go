@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	depreciationLinear    = "Линейный"
+	depreciationDeclining = "Уменьшаемого остатка"
+	// decliningFactor is the coefficient of the declining-balance method:
+	// each month writes off decliningFactor/life of the remaining value.
+	decliningFactor = 2.0
+)
+
+// DepreciationMonth is the depreciation charged in Month (YYYY-MM) and the
+// totals at its end.
+type DepreciationMonth struct {
+	Month        string  `json:"month"`
+	Depreciation Decimal `json:"depreciation"`
+	Accumulated  Decimal `json:"accumulated"`
+	BookValue    Decimal `json:"book_value"`
+}
+
+type DepreciationSchedule struct {
+	EquipmentID  int                 `json:"equipment_id"`
+	Name         string              `json:"name"`
+	Method       string              `json:"method"`
+	PurchaseCost Decimal             `json:"purchase_cost"`
+	SalvageValue Decimal             `json:"salvage_value"`
+	Months       []DepreciationMonth `json:"months"`
+}
+
+// DepreciationRow sums the items of a warehouse, category or single item at
+// the end of a month. Items without a cost, service life or purchase date
+// are counted in NotDepreciated and carried at their purchase cost.
+type DepreciationRow struct {
+	ID             int     `json:"id"`
+	Name           string  `json:"name"`
+	Equipment      int     `json:"equipment"`
+	PurchaseCost   Decimal `json:"purchase_cost"`
+	Depreciation   Decimal `json:"depreciation"`
+	Accumulated    Decimal `json:"accumulated"`
+	BookValue      Decimal `json:"book_value"`
+	EndOfLife      int     `json:"end_of_life"`
+	NotDepreciated int     `json:"not_depreciated"`
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+}
+
+// setLifecycle fills in the service life and book value of the item as of
+// now.
+func (e *Equipment) setLifecycle(now time.Time) {
+	purchased, ok := parseDate(e.PurchaseDate)
+	if !ok || e.ServiceLife <= 0 {
+		return
+	}
+	end := purchased.AddDate(0, e.ServiceLife, 0)
+	e.ServiceEndDate = end.Format("2006-01-02")
+	remaining := (end.Year()-now.Year())*12 + int(end.Month()-now.Month())
+	if end.Day() < now.Day() {
+		remaining--
+	}
+	remaining = max(remaining, 0)
+	e.RemainingLife = &remaining
+	e.EndOfLife = !now.Before(end) && e.Status != equipmentWrittenOff
+	if months := e.depreciation(); months != nil {
+		book := e.bookValue(months, now)
+		e.BookValue = &book
+	}
+}
+
+// depreciation returns the monthly schedule of the item, or nil if it has
+// no cost, service life or purchase date. Depreciation starts the month
+// after purchase and stops after the month the item is written off.
+func (e *Equipment) depreciation() []DepreciationMonth {
+	purchased, ok := parseDate(e.PurchaseDate)
+	if !ok || e.ServiceLife <= 0 || e.PurchaseCost == nil {
+		return nil
+	}
+	cost := *e.PurchaseCost
+	salvage := min(e.SalvageValue, cost)
+	start := monthStart(purchased).AddDate(0, 1, 0)
+	life := e.ServiceLife
+	if writtenOff, ok := parseDate(e.WrittenOffDate); ok {
+		life = min(life, (writtenOff.Year()-start.Year())*12+int(writtenOff.Month()-start.Month())+1)
+	}
+
+	months := make([]DepreciationMonth, 0, max(life, 0))
+	book := cost
+	for i := 0; i < life; i++ {
+		left := e.ServiceLife - i
+		remaining := book - salvage
+		// Cutting the quotient to six places does not change it in cents.
+		charge := remaining / Decimal(left)
+		if e.DepreciationMethod == depreciationDeclining {
+			// Switch to straight-line over the remaining months once it
+			// charges more, so the item still reaches salvage value. A
+			// charge too large for a Decimal is more than is left anyway.
+			declining, err := book.Mul(decliningFactor * decimalScale)
+			if err == nil {
+				declining, err = declining.Div(Decimal(e.ServiceLife * decimalScale))
+			}
+			if err != nil {
+				declining = remaining
+			}
+			charge = max(declining, charge)
+		}
+		charge = min(charge.Round(2), remaining.Round(2))
+		if left == 1 {
+			charge = remaining.Round(2)
+		}
+		book = (book - charge).Round(2)
+		months = append(months, DepreciationMonth{
+			Month:        start.AddDate(0, i, 0).Format("2006-01"),
+			Depreciation: charge,
+			Accumulated:  (cost - book).Round(2),
+			BookValue:    book,
+		})
+	}
+	return months
+}
+
+// bookValue is the value at the end of the month containing at.
+func (e *Equipment) bookValue(months []DepreciationMonth, at time.Time) Decimal {
+	month := at.Format("2006-01")
+	book := *e.PurchaseCost
+	for _, m := range months {
+		if m.Month > month {
+			break
+		}
+		book = m.BookValue
+	}
+	return book
+}
+
+func equipmentByID(r *http.Request, store *Store) (Equipment, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return Equipment{}, ErrNotFound
+	}
+	items, _, err := store.Equipment.List(r.Context(), byID(id))
+	if err != nil {
+		return Equipment{}, err
+	}
+	if len(items) == 0 {
+		return Equipment{}, ErrNotFound
+	}
+	return items[0], nil
+}
+
+func getEquipmentDepreciation(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e, err := equipmentByID(r, store)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		months := e.depreciation()
+		if months == nil {
+			months = []DepreciationMonth{}
+		}
+		method := e.DepreciationMethod
+		if method == "" {
+			method = depreciationLinear
+		}
+		schedule := DepreciationSchedule{EquipmentID: e.ID, Name: e.Name, Method: method, SalvageValue: e.SalvageValue, Months: months}
+		if e.PurchaseCost != nil {
+			schedule.PurchaseCost = *e.PurchaseCost
+		}
+		writeJSON(w, r, schedule)
+	}
+}
+
+var depreciationReportParams = []apiParam{
+	{Name: "month", Type: "string", Description: "месяц отчета, YYYY-MM; по умолчанию текущий"},
+	{Name: "group_by", Type: "string", Enum: []string{"warehouse", "category", "equipment"}},
+	{Name: "warehouse_id", Type: "integer"},
+	{Name: "category_id", Type: "integer"},
+}
+
+// getDepreciationReport sums book values at the end of a month. Items
+// written off by then are no longer on the books and are left out.
+func getDepreciationReport(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		month := monthStart(time.Now())
+		if v := query.Get("month"); v != "" {
+			t, err := time.ParseInLocation("2006-01", v, time.Local)
+			if err != nil {
+				writeError(w, r, queryError("month", "invalid_date", v))
+				return
+			}
+			month = t
+		}
+		groupBy := query.Get("group_by")
+		if groupBy == "" {
+			groupBy = "warehouse"
+		}
+		if groupBy != "warehouse" && groupBy != "category" && groupBy != "equipment" {
+			writeError(w, r, queryError("group_by", "one_of", "warehouse, category, equipment"))
+			return
+		}
+		var filters []Filter
+		for _, name := range []string{"warehouse_id", "category_id"} {
+			if v := query.Get(name); v != "" {
+				id, err := strconv.Atoi(v)
+				if err != nil {
+					writeError(w, r, queryError(name, "invalid_value", v))
+					return
+				}
+				filters = append(filters, Filter{Field: name, Op: opIn, Values: []interface{}{id}})
+			}
+		}
+		equipment, err := listAll(r.Context(), store.Equipment.List, filters)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		key := month.Format("2006-01")
+		monthEnd := month.AddDate(0, 1, 0).Add(-time.Nanosecond)
+		groups := map[int]*DepreciationRow{}
+		var rows []*DepreciationRow
+		var sumErr error
+		add := func(sum *Decimal, v Decimal) {
+			if sumErr == nil {
+				*sum, sumErr = sum.Add(v)
+			}
+		}
+		for _, e := range equipment {
+			if purchased, ok := parseDate(e.PurchaseDate); ok && purchased.After(monthEnd) {
+				continue
+			}
+			if writtenOff, ok := parseDate(e.WrittenOffDate); ok && !writtenOff.After(monthEnd) {
+				continue
+			}
+			id, name := e.WarehouseID, e.WarehouseName
+			switch groupBy {
+			case "category":
+				id, name = e.CategoryID, e.CategoryName
+			case "equipment":
+				id, name = e.ID, e.Name
+			}
+			row := groups[id]
+			if row == nil {
+				row = &DepreciationRow{ID: id, Name: name}
+				groups[id] = row
+				rows = append(rows, row)
+			}
+			row.Equipment++
+			if e.ServiceEndDate != "" {
+				if end, ok := parseDate(e.ServiceEndDate); ok && !end.After(monthEnd) {
+					row.EndOfLife++
+				}
+			}
+			if e.PurchaseCost == nil {
+				row.NotDepreciated++
+				continue
+			}
+			add(&row.PurchaseCost, *e.PurchaseCost)
+			months := e.depreciation()
+			if months == nil {
+				row.NotDepreciated++
+				add(&row.BookValue, *e.PurchaseCost)
+				continue
+			}
+			book := e.bookValue(months, monthEnd)
+			add(&row.BookValue, book)
+			add(&row.Accumulated, *e.PurchaseCost-book)
+			for _, m := range months {
+				if m.Month == key {
+					add(&row.Depreciation, m.Depreciation)
+				}
+			}
+		}
+		if sumErr != nil {
+			writeError(w, r, sumErr)
+			return
+		}
+		result := make([]DepreciationRow, 0, len(rows))
+		for _, row := range rows {
+			row.PurchaseCost = row.PurchaseCost.Round(2)
+			row.Depreciation = row.Depreciation.Round(2)
+			row.Accumulated = row.Accumulated.Round(2)
+			row.BookValue = row.BookValue.Round(2)
+			result = append(result, *row)
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+		writeJSON(w, r, result)
+	}
+}
+
+func writeOffEquipment(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req EquipmentWriteOff
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		e, err := equipmentByID(r, store)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if e.Status == equipmentWrittenOff {
+			writeError(w, r, newAPIError(http.StatusConflict, "equipment_written_off", e.Name))
+			return
+		}
+		if req.Date == "" {
+			req.Date = time.Now().Format("2006-01-02")
+		}
+		if req.ResidualValue == nil && e.PurchaseCost != nil {
+			at, _ := parseDate(req.Date)
+			book := *e.PurchaseCost
+			if months := e.depreciation(); months != nil {
+				book = e.bookValue(months, at)
+			}
+			req.ResidualValue = &book
+		}
+		if err := store.Equipment.WriteOff(ctx, e.ID, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		details := fmt.Sprintf("%s, дата %s", e.Name, req.Date)
+		if req.ResidualValue != nil {
+			details += fmt.Sprintf(", остаточная стоимость %s", req.ResidualValue.Round(2))
+		}
+		logAction(store.Logs, "system", "Списание оборудования", "equipment", details)
+		writeJSON(w, r, MessageResponse{Message: "Оборудование списано"})
+	}
+}
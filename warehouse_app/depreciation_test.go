@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDepreciationBookValues(t *testing.T) {
+	money := func(s string) *Decimal {
+		d, err := parseDecimal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &d
+	}
+	tests := []struct {
+		name      string
+		equipment Equipment
+		wantFirst string
+		wantBook  []string
+		// bookAt is the book value at the end of the month of each date.
+		bookAt map[string]string
+	}{
+		{"linear", Equipment{PurchaseDate: "2026-01-15", ServiceLife: 4, PurchaseCost: money("12000"), DepreciationMethod: depreciationLinear},
+			"2026-02", []string{"9000", "6000", "3000", "0"},
+			map[string]string{"2026-01-31": "12000", "2026-02-01": "9000", "2026-04-30": "3000", "2027-06-01": "0"}},
+		{"linear to salvage", Equipment{PurchaseDate: "2026-01-31", ServiceLife: 3, PurchaseCost: money("1000"), SalvageValue: *money("100")},
+			"2026-02", []string{"700", "400", "100"}, map[string]string{"2030-01-01": "100"}},
+		{"cents carried to the last month", Equipment{PurchaseDate: "2026-03-01", ServiceLife: 3, PurchaseCost: money("100")},
+			"2026-04", []string{"66.67", "33.33", "0"}, nil},
+		{"declining then straight-line", Equipment{PurchaseDate: "2026-01-15", ServiceLife: 4, PurchaseCost: money("12000"), DepreciationMethod: depreciationDeclining},
+			"2026-02", []string{"6000", "3000", "1500", "0"}, nil},
+		{"written off", Equipment{PurchaseDate: "2026-01-15", ServiceLife: 12, PurchaseCost: money("12000"), WrittenOffDate: "2026-04-10"},
+			"2026-02", []string{"11000", "10000", "9000"}, map[string]string{"2026-12-31": "9000"}},
+		{"salvage above cost", Equipment{PurchaseDate: "2026-01-15", ServiceLife: 2, PurchaseCost: money("500"), SalvageValue: *money("800")},
+			"2026-02", []string{"500", "500"}, nil},
+		{"no cost", Equipment{PurchaseDate: "2026-01-15", ServiceLife: 12}, "", nil, nil},
+		{"no service life", Equipment{PurchaseDate: "2026-01-15", PurchaseCost: money("100")}, "", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			months := tt.equipment.depreciation()
+			var book []string
+			for i, m := range months {
+				book = append(book, m.BookValue.String())
+				if sum, _ := m.Accumulated.Add(m.BookValue); sum != *tt.equipment.PurchaseCost {
+					t.Errorf("%s: accumulated %s + book %s is not the cost", m.Month, m.Accumulated, m.BookValue)
+				}
+				if i > 0 && m.Accumulated-months[i-1].Accumulated != m.Depreciation {
+					t.Errorf("%s: depreciation %s does not add up to accumulated %s", m.Month, m.Depreciation, m.Accumulated)
+				}
+			}
+			if !reflect.DeepEqual(book, tt.wantBook) {
+				t.Errorf("book values %v, want %v", book, tt.wantBook)
+			}
+			if len(months) > 0 && months[0].Month != tt.wantFirst {
+				t.Errorf("first month %s, want %s", months[0].Month, tt.wantFirst)
+			}
+			for date, want := range tt.bookAt {
+				at, _ := time.Parse("2006-01-02", date)
+				if got := tt.equipment.bookValue(months, at).String(); got != want {
+					t.Errorf("book value on %s is %s, want %s", date, got, want)
+				}
+			}
+		})
+	}
+}
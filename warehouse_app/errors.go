@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// APIError is the body of every failed API response:
+//
+//	{"error": {"code": "...", "message": "...", "fields": [...], "request_id": "..."}}
+//
+// Codes are stable; messages are localized from Accept-Language when the
+// response is written.
+type APIError struct {
+	Status int
+	Code   string
+	Fields []FieldError
+	params []interface{}
+}
+
+// ErrorResponse and ErrorDetail are the JSON shape written by writeError.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	params  []interface{}
+}
+
+func (e *APIError) Error() string {
+	return e.Code
+}
+
+func newAPIError(status int, code string, params ...interface{}) *APIError {
+	return &APIError{Status: status, Code: code, params: params}
+}
+
+func fieldError(field, code string, params ...interface{}) FieldError {
+	return FieldError{Field: field, Code: code, params: params}
+}
+
+// validationError reports invalid request body fields.
+func validationError(fields ...FieldError) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: "validation_failed", Fields: fields}
+}
+
+// queryError reports an invalid query string parameter.
+func queryError(param, code string, params ...interface{}) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: "invalid_query", Fields: []FieldError{fieldError(param, code, params...)}}
+}
+
+// messages maps error codes to their Russian and English texts.
+var messages = map[string][2]string{
+	"bad_request":           {"Некорректный запрос: %s", "Malformed request: %s"},
+	"validation_failed":     {"Проверьте заполнение полей", "Some fields are invalid"},
+	"invalid_query":         {"Некорректные параметры запроса", "Invalid query parameters"},
+	"not_found":             {"Запись не найдена", "Record not found"},
+	"reference_not_found":   {"Связанная запись не существует", "A referenced record does not exist"},
+	"duplicate":             {"Запись с такими данными уже существует", "A record with these values already exists"},
+	"capacity_exceeded":     {"Превышена вместимость склада %s: %.2f из %.2f", "Warehouse %s capacity exceeded: %.2f of %.2f"},
+	"gone":                  {"Путь больше не поддерживается, используйте %s", "This path is no longer served, use %s"},
+	"payload_too_large":     {"Тело запроса больше %d байт", "Request body exceeds %d bytes"},
+	"equipment_written_off": {"Оборудование «%s» списано", "Equipment %q is written off"},
+	"work_order_closed":     {"Наряд %d уже выполнен", "Work order %d is already done"},
+	"already_serialized":    {"Оборудование «%s» уже учитывается поштучно", "Equipment %q is already tracked by unit"},
+	"already_assigned":      {"Оборудование «%s» уже выдано (выдача %d)", "Equipment %q is already checked out (assignment %d)"},
+	"assignment_closed":     {"Выдача %d уже закрыта", "Assignment %d is already closed"},
+	"no_exchange_rate":      {"Нет курса %s на %s, заказ нельзя подтвердить", "No %s rate on %s, the order cannot be confirmed"},
+	"insufficient_stock":    {"Недостаточно на складе, остаток %g", "Not enough in stock, %g left"},
+	"not_receivable":        {"По заказу %s в статусе «%s» поступление не оформляется", "Order %s with status %q cannot be received"},
+	"invalid_transition":    {"Нельзя перевести из статуса «%s» в «%s»", "Cannot change status from %q to %q"},
+	"unavailable":           {"Сервер останавливается, повторите запрос позже", "The server is shutting down, retry later"},
+	"internal":              {"Внутренняя ошибка сервера", "Internal server error"},
+
+	// field codes
+	"required":              {"Обязательное поле", "This field is required"},
+	"positive":              {"Значение должно быть больше нуля", "Must be greater than zero"},
+	"min":                   {"Значение должно быть не меньше %s", "Must be at least %s"},
+	"max":                   {"Значение должно быть не больше %s", "Must be at most %s"},
+	"invalid_value":         {"Некорректное значение: %s", "Invalid value: %s"},
+	"invalid_date":          {"Некорректная дата: %s", "Invalid date: %s"},
+	"future_date":           {"Дата не может быть в будущем", "Date cannot be in the future"},
+	"invalid_cursor":        {"Некорректный курсор", "Invalid cursor"},
+	"unsupported_sort":      {"Сортировка по полю %s не поддерживается", "Sorting by %s is not supported"},
+	"one_of":                {"Допустимые значения: %s", "Allowed values: %s"},
+	"not_exists":            {"Запись не существует", "Record does not exist"},
+	"taken":                 {"Значение уже используется", "Value is already in use"},
+	"empty_file":            {"В файле нет строк с данными", "The file has no data rows"},
+	"missing_column":        {"В файле нет колонки %s", "The file has no %s column"},
+	"other_equipment":       {"План относится к другому оборудованию", "The plan belongs to other equipment"},
+	"unknown_name":          {"Нет в справочнике: %s", "Not found in the reference data: %s"},
+	"serial_quantity":       {"При поштучном учете в записи одна единица", "A serialized record holds exactly one unit"},
+	"bulk_numbered":         {"Номер указывается только при поштучном учете", "Only serialized equipment has this number"},
+	"units_count":           {"Число единиц должно совпадать с количеством в записи: %g", "The number of units must equal the quantity of the row: %g"},
+	"meter_below_previous":  {"Показание меньше предыдущего: %g на %s", "The reading is below the previous one: %g at %s"},
+	"meter_above_next":      {"Показание больше следующего: %g на %s", "The reading is above the next one: %g at %s"},
+	"not_supplier":          {"Контрагент не является поставщиком", "The contractor is not a supplier"},
+	"below_min":             {"Меньше минимального остатка", "Less than the minimum level"},
+	"part_or_category":      {"Укажите либо запчасть, либо категорию оборудования", "Set either a spare part or an equipment category"},
+	"part_unit":             {"Запчасть учитывается в %s", "The part is counted in %s"},
+	"exceeds_ordered":       {"Больше, чем осталось получить: %g", "More than is left to receive: %g"},
+	"before_return":         {"Раньше предыдущего возврата: %s", "Earlier than the previous return: %s"},
+	"before_checkout":       {"Раньше выдачи: %s", "Earlier than the check-out: %s"},
+	"before_valid_from":     {"Раньше начала действия: %s", "Earlier than the start of validity: %s"},
+	"amount_out_of_range":   {"Сумма, цена или курс вне допустимого диапазона", "An amount, price or rate is out of range"},
+	"no_price_list":         {"Нет прайс-листа на «%s» в %s на %s, укажите цену", "No price list for %q in %s on %s, give a price"},
+	"price_reason_required": {"Цена отличается от прайс-листа (%s), укажите причину", "The price differs from the price list (%s), give a reason"},
+	"invalid_currency":      {"Код валюты из трёх латинских букв, например USD: %s", "A three-letter currency code such as USD: %s"},
+	"reporting_currency":    {"%s — валюта учёта, её курс всегда 1", "%s is the reporting currency, its rate is always 1"},
+}
+
+func localize(lang, code string, params []interface{}) string {
+	texts, ok := messages[code]
+	if !ok {
+		return code
+	}
+	text := texts[0]
+	if lang == "en" {
+		text = texts[1]
+	}
+	if len(params) > 0 {
+		return fmt.Sprintf(text, params...)
+	}
+	return text
+}
+
+func requestLanguage(r *http.Request) string {
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch {
+		case strings.HasPrefix(tag, "ru"):
+			return "ru"
+		case strings.HasPrefix(tag, "en"):
+			return "en"
+		}
+	}
+	return "ru"
+}
+
+// writeError maps err to an APIError and writes it. Unexpected errors are
+// logged with the request ID and reported without details.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		apiErr     *APIError
+		constraint *ConstraintError
+		shortage   *StockShortage
+		conflict   *StatusConflict
+		tooLarge   *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.Is(err, ErrNotFound):
+		apiErr = newAPIError(http.StatusNotFound, "not_found")
+	case errors.As(err, &shortage):
+		apiErr = newAPIError(http.StatusConflict, "insufficient_stock", shortage.Available)
+	case errors.Is(err, errDecimalRange):
+		apiErr = newAPIError(http.StatusUnprocessableEntity, "amount_out_of_range")
+	case errors.As(err, &conflict):
+		apiErr = newAPIError(http.StatusConflict, "invalid_transition", conflict.From, conflict.To)
+	case errors.As(err, &constraint):
+		apiErr = constraintAPIError(constraint)
+	case errors.As(err, &tooLarge):
+		apiErr = newAPIError(http.StatusRequestEntityTooLarge, "payload_too_large", tooLarge.Limit)
+	default:
+		slog.Error("request failed", "request_id", requestID(r.Context()), "method", r.Method, "path", r.URL.Path, "err", err)
+		apiErr = newAPIError(http.StatusInternalServerError, "internal")
+	}
+
+	lang := requestLanguage(r)
+	b := ErrorDetail{
+		Code:      apiErr.Code,
+		Message:   localize(lang, apiErr.Code, apiErr.params),
+		RequestID: requestID(r.Context()),
+	}
+	for _, f := range apiErr.Fields {
+		f.Message = localize(lang, f.Code, f.params)
+		b.Fields = append(b.Fields, f)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: b})
+}
+
+func constraintAPIError(c *ConstraintError) *APIError {
+	var apiErr *APIError
+	var fieldCode string
+	if c.Kind == constraintUnique {
+		apiErr, fieldCode = newAPIError(http.StatusConflict, "duplicate"), "taken"
+	} else {
+		apiErr, fieldCode = newAPIError(http.StatusUnprocessableEntity, "reference_not_found"), "not_exists"
+	}
+	if c.Field != "" {
+		apiErr.Fields = []FieldError{fieldError(c.Field, fieldCode)}
+	}
+	return apiErr
+}
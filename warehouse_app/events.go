@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// eventHistorySize is how many recent events a reconnecting client can
+	// catch up on with Last-Event-ID.
+	eventHistorySize = 1000
+	// eventBuffer is how far a subscriber may fall behind before it is
+	// disconnected; it then reconnects and replays what it missed.
+	eventBuffer    = 64
+	eventKeepAlive = 25 * time.Second
+	eventRetry     = 3 * time.Second
+)
+
+// Event is a change notification. WarehouseID 0 means the event is not tied
+// to a warehouse and goes to every subscriber.
+type Event struct {
+	Seq         int64
+	Type        string
+	WarehouseID int
+	Data        interface{}
+}
+
+// eventBus is the in-process pub/sub behind /api/events. Event ids are
+// "<boot>-<seq>", so ids from before a restart are recognised as stale.
+type eventBus struct {
+	mu      sync.Mutex
+	boot    string
+	seq     int64
+	history []Event
+	subs    map[*subscription]struct{}
+	closed  bool
+}
+
+type subscription struct {
+	warehouses map[int]bool
+	ch         chan Event
+}
+
+func (s *subscription) wants(e Event) bool {
+	return len(s.warehouses) == 0 || e.WarehouseID == 0 || s.warehouses[e.WarehouseID]
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		boot: strconv.FormatInt(time.Now().UnixNano(), 36),
+		subs: make(map[*subscription]struct{}),
+	}
+}
+
+func (b *eventBus) eventID(seq int64) string {
+	return b.boot + "-" + strconv.FormatInt(seq, 10)
+}
+
+func (b *eventBus) publish(typ string, warehouseID int, data interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.seq++
+	e := Event{Seq: b.seq, Type: typ, WarehouseID: warehouseID, Data: data}
+	if len(b.history) == eventHistorySize {
+		copy(b.history, b.history[1:])
+		b.history = b.history[:eventHistorySize-1]
+	}
+	b.history = append(b.history, e)
+	for s := range b.subs {
+		if !s.wants(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			close(s.ch)
+			delete(b.subs, s)
+		}
+	}
+}
+
+// subscribe registers a subscriber and returns the events after lastID it
+// has missed. reset is set when those events are no longer available (the
+// id is from an earlier run or older than the history) and the client has to
+// reload its data. sub is nil once the bus is closed.
+func (b *eventBus) subscribe(lastID string, warehouses map[int]bool) (sub *subscription, replay []Event, reset bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil, false
+	}
+	sub = &subscription{warehouses: warehouses, ch: make(chan Event, eventBuffer)}
+	b.subs[sub] = struct{}{}
+	if lastID == "" {
+		return sub, nil, false
+	}
+
+	boot, seqText, _ := strings.Cut(lastID, "-")
+	seq, err := strconv.ParseInt(seqText, 10, 64)
+	if err != nil || boot != b.boot || seq > b.seq {
+		return sub, nil, true
+	}
+	if len(b.history) > 0 && seq < b.history[0].Seq-1 {
+		return sub, nil, true
+	}
+	for _, e := range b.history {
+		if e.Seq > seq && sub.wants(e) {
+			replay = append(replay, e)
+		}
+	}
+	return sub, replay, false
+}
+
+func (b *eventBus) unsubscribe(sub *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// close ends every stream so a graceful shutdown doesn't wait for them.
+func (b *eventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		close(s.ch)
+	}
+	b.subs = map[*subscription]struct{}{}
+}
+
+func streamEvents(events *eventBus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		warehouses := map[int]bool{}
+		for _, v := range splitList(r.URL.Query().Get("warehouse_id")) {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				writeError(w, r, queryError("warehouse_id", "invalid_value", v))
+				return
+			}
+			warehouses[id] = true
+		}
+		// Browsers send Last-Event-ID when they reconnect; the query
+		// parameter lets a fresh page continue from a stored id.
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = r.URL.Query().Get("last_event_id")
+		}
+
+		sub, replay, reset := events.subscribe(lastID, warehouses)
+		if sub == nil {
+			writeError(w, r, newAPIError(http.StatusServiceUnavailable, "unavailable"))
+			return
+		}
+		defer events.unsubscribe(sub)
+
+		rc := http.NewResponseController(w)
+		rc.SetWriteDeadline(time.Time{})
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", eventRetry.Milliseconds())
+
+		if reset {
+			events.mu.Lock()
+			latest := events.eventID(events.seq)
+			events.mu.Unlock()
+			fmt.Fprintf(w, "id: %s\nevent: reset\ndata: {}\n\n", latest)
+		}
+		for _, e := range replay {
+			writeEvent(w, events, e)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(eventKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-sub.ch:
+				if !ok {
+					return
+				}
+				writeEvent(w, events, e)
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, events *eventBus, e Event) {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		slog.Error("failed to encode event", "type", e.Type, "err", err)
+		return
+	}
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", events.eventID(e.Seq), e.Type, data)
+}
+
+func byID(id int) ListQuery {
+	return ListQuery{Filters: []Filter{{Field: "id", Op: opIn, Values: []interface{}{id}}}, Limit: 1}
+}
+
+// The write handlers publish the stored rows in the same form the list
+// endpoints return them. A failed lookup only costs the notification.
+
+func publishOreBatch(ctx context.Context, store *Store, events *eventBus, typ string, id int) {
+	batches, _, err := store.Batches.List(ctx, byID(id))
+	if err != nil || len(batches) == 0 {
+		slog.Warn("event not published", "type", typ, "id", id, "err", err)
+		return
+	}
+	events.publish(typ, batches[0].WarehouseID, batches[0])
+}
+
+func publishOrder(ctx context.Context, store *Store, events *eventBus, typ string, id int) {
+	orders, _, err := store.Orders.List(ctx, byID(id))
+	if err != nil || len(orders) == 0 {
+		slog.Warn("event not published", "type", typ, "id", id, "err", err)
+		return
+	}
+	events.publish(typ, orders[0].WarehouseID, orders[0])
+}
+
+func publishShipment(ctx context.Context, store *Store, events *eventBus, typ string, id int) {
+	shipments, _, err := store.Shipments.List(ctx, byID(id))
+	if err != nil || len(shipments) == 0 {
+		slog.Warn("event not published", "type", typ, "id", id, "err", err)
+		return
+	}
+	events.publish(typ, shipments[0].WarehouseID, shipments[0])
+}
+
+// publishingLogStore publishes every added entry as a log.created event.
+type publishingLogStore struct {
+	LogStore
+	events *eventBus
+}
+
+func (s publishingLogStore) Add(ctx context.Context, entry LogEntry) error {
+	if entry.EventTime == "" {
+		entry.EventTime = logTime(time.Now())
+	}
+	if err := s.LogStore.Add(ctx, entry); err != nil {
+		return err
+	}
+	s.events.publish("log.created", 0, entry)
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestEventReplay reconnects to /api/events with Last-Event-ID after events
+// for warehouses 1, 2 and all (0) were published, and checks what is sent
+// before live events.
+func TestEventReplay(t *testing.T) {
+	warehouses := []int{1, 2, 0, 1, 2}
+	tests := []struct {
+		name      string
+		published int
+		lastID    func(b *eventBus) string
+		query     string
+		want      []string
+	}{
+		{"first connect", 5, func(*eventBus) string { return "" }, "", nil},
+		{"after event 2", 5, func(b *eventBus) string { return b.eventID(2) }, "", []string{"3 e", "4 e", "5 e"}},
+		{"up to date", 5, func(b *eventBus) string { return b.eventID(5) }, "", nil},
+		{"from the start for warehouse 1", 5, func(b *eventBus) string { return b.eventID(0) }, "?warehouse_id=1", []string{"1 e", "3 e", "4 e"}},
+		{"query parameter", 5, func(*eventBus) string { return "" }, "?last_event_id=%s", []string{"5 e"}},
+		{"earlier run", 5, func(*eventBus) string { return "0-3" }, "", []string{"5 reset"}},
+		{"ahead of the bus", 5, func(b *eventBus) string { return b.eventID(9) }, "", []string{"5 reset"}},
+		{"malformed", 5, func(*eventBus) string { return "garbage" }, "", []string{"5 reset"}},
+		{"dropped from history", eventHistorySize + 5, func(b *eventBus) string { return b.eventID(3) }, "", []string{fmt.Sprint(eventHistorySize+5) + " reset"}},
+		{"just before the history", eventHistorySize + 2, func(b *eventBus) string { return b.eventID(2) }, "", replayed(3, eventHistorySize+2)},
+		{"dropped just now", eventHistorySize + 2, func(b *eventBus) string { return b.eventID(1) }, "", []string{fmt.Sprint(eventHistorySize+2) + " reset"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := newEventBus()
+			defer events.close()
+			for i := 0; i < tt.published; i++ {
+				events.publish("e", warehouses[i%len(warehouses)], i)
+			}
+			path := "/api/v1/events" + tt.query
+			if strings.Contains(path, "%s") {
+				path = fmt.Sprintf(path, events.eventID(4))
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel() // the handler writes the replay and returns
+			r := httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx)
+			if id := tt.lastID(events); id != "" {
+				r.Header.Set("Last-Event-ID", id)
+			}
+			w := httptest.NewRecorder()
+			streamEvents(events)(w, r)
+
+			var got []string
+			for _, block := range strings.Split(w.Body.String(), "\n\n") {
+				var id, typ string
+				for _, line := range strings.Split(block, "\n") {
+					if v, ok := strings.CutPrefix(line, "id: "); ok {
+						_, id, _ = strings.Cut(v, "-")
+					}
+					if v, ok := strings.CutPrefix(line, "event: "); ok {
+						typ = v
+					}
+				}
+				if id != "" {
+					got = append(got, id+" "+typ)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func replayed(from, to int) []string {
+	var seqs []string
+	for seq := from; seq <= to; seq++ {
+		seqs = append(seqs, fmt.Sprint(seq)+" e")
+	}
+	return seqs
+}
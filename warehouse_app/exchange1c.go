@@ -0,0 +1,595 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Exchange with 1C:Enterprise uses CommerceML 2 files: orders, shipments
+// and contractors go out, contractor and price updates come in. Every record
+// that crosses the boundary gets a GUID kept in exchange_ids, and every file
+// is stored in exchange_log.
+
+const commerceMLVersion = "2.10"
+
+// Entities in exchange_ids.
+const (
+	exchangeContractorEntity = "contractor"
+	exchangeOrderEntity      = "order"
+	exchangeShipmentEntity   = "shipment"
+	exchangeOreTypeEntity    = "ore_type"
+)
+
+const (
+	exchangeExport = "export"
+	exchangeImport = "import"
+
+	exchangeOK      = "ok"
+	exchangePartial = "partial"
+	exchangeError   = "error"
+)
+
+type ExchangeLogEntry struct {
+	ID        int      `json:"id"`
+	Direction string   `json:"direction"`
+	FileName  string   `json:"file_name"`
+	Status    string   `json:"status"`
+	Summary   string   `json:"summary"`
+	Errors    []string `json:"errors,omitempty"`
+	// Size is the length of the stored file in bytes.
+	Size      int    `json:"size"`
+	CreatedAt string `json:"created_at"`
+	Document  string `json:"-"`
+}
+
+type ExchangeResult struct {
+	LogID              int      `json:"log_id"`
+	ContractorsCreated int      `json:"contractors_created"`
+	ContractorsUpdated int      `json:"contractors_updated"`
+	PricesUpdated      int      `json:"prices_updated"`
+	Errors             []string `json:"errors,omitempty"`
+}
+
+type exchangeContractor struct {
+	GUID, Name, Type, ContactPerson, Phone, Email string
+}
+
+type exchangePrice struct {
+	GUID, Name string
+	Price      Decimal
+}
+
+type cmlDocument struct {
+	XMLName       xml.Name        `xml:"КоммерческаяИнформация"`
+	SchemaVersion string          `xml:"ВерсияСхемы,attr"`
+	CreatedAt     string          `xml:"ДатаФормирования,attr"`
+	Contractors   []cmlContractor `xml:"Контрагенты>Контрагент"`
+	Documents     []cmlDoc        `xml:"Документ"`
+	Offers        *cmlOffers      `xml:"ПакетПредложений"`
+}
+
+type cmlContractor struct {
+	ID              string              `xml:"Ид"`
+	Name            string              `xml:"Наименование"`
+	Role            string              `xml:"Роль,omitempty"`
+	Contacts        *cmlContacts        `xml:"Контакты"`
+	Representatives *cmlRepresentatives `xml:"Представители"`
+}
+
+type cmlContacts struct {
+	Items []cmlContact `xml:"Контакт"`
+}
+
+type cmlRepresentatives struct {
+	Items []cmlRepresentative `xml:"Представитель"`
+}
+
+type cmlContact struct {
+	Type  string `xml:"Тип"`
+	Value string `xml:"Значение"`
+}
+
+type cmlRepresentative struct {
+	Relation string `xml:"Отношение"`
+	Name     string `xml:"Контрагент>Наименование"`
+}
+
+type cmlDoc struct {
+	ID          string          `xml:"Ид"`
+	Number      string          `xml:"Номер"`
+	Date        string          `xml:"Дата"`
+	Operation   string          `xml:"ХозОперация"`
+	Role        string          `xml:"Роль"`
+	Currency    string          `xml:"Валюта"`
+	Rate        string          `xml:"Курс"`
+	Sum         string          `xml:"Сумма"`
+	Contractors []cmlContractor `xml:"Контрагенты>Контрагент"`
+	Items       []cmlItem       `xml:"Товары>Товар"`
+	Properties  []cmlProperty   `xml:"ЗначенияРеквизитов>ЗначениеРеквизита"`
+}
+
+type cmlItem struct {
+	ID         string        `xml:"Ид"`
+	Name       string        `xml:"Наименование"`
+	Unit       cmlUnit       `xml:"БазоваяЕдиница"`
+	Price      string        `xml:"ЦенаЗаЕдиницу"`
+	Quantity   string        `xml:"Количество"`
+	Sum        string        `xml:"Сумма"`
+	Properties []cmlProperty `xml:"ЗначенияРеквизитов>ЗначениеРеквизита"`
+}
+
+type cmlUnit struct {
+	FullName string `xml:"НаименованиеПолное,attr"`
+	Symbol   string `xml:",chardata"`
+}
+
+type cmlProperty struct {
+	Name  string `xml:"Наименование"`
+	Value string `xml:"Значение"`
+}
+
+type cmlOffers struct {
+	Offers []cmlOffer `xml:"Предложения>Предложение"`
+}
+
+type cmlOffer struct {
+	ID     string     `xml:"Ид"`
+	Name   string     `xml:"Наименование"`
+	Prices []cmlPrice `xml:"Цены>Цена"`
+}
+
+type cmlPrice struct {
+	Price    string `xml:"ЦенаЗаЕдиницу"`
+	Currency string `xml:"Валюта"`
+}
+
+func newGUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func cmlAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// cmlRate is the rate an order was confirmed at, 1 for drafts.
+func cmlRate(o SalesOrder) string {
+	if o.Rate == nil {
+		return "1"
+	}
+	return o.Rate.String()
+}
+
+func cmlDate(value string, fallback time.Time) string {
+	if t, ok := parseDate(value); ok {
+		return t.Format("2006-01-02")
+	}
+	return fallback.Format("2006-01-02")
+}
+
+// listAll reads every page of a list.
+func listAll[T any](ctx context.Context, list func(context.Context, ListQuery) ([]T, int, error), filters []Filter) ([]T, error) {
+	var all []T
+	for {
+		page, total, err := list(ctx, ListQuery{Filters: filters, Sort: []SortKey{{Field: "id"}}, Limit: maxListLimit, Offset: len(all)})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) == 0 || len(all) >= total {
+			return all, nil
+		}
+	}
+}
+
+func idFilter(ids []int) []Filter {
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	return []Filter{{Field: "id", Op: opIn, Values: values}}
+}
+
+// exportSince resolves the since parameter: a date, "last" for the time of
+// the previous successful export, or empty for everything.
+func exportSince(ctx context.Context, store *Store, value string) (string, error) {
+	switch value {
+	case "":
+		return "", nil
+	case "last":
+		entries, _, err := store.Exchange.Log(ctx, ListQuery{
+			Filters: []Filter{
+				{Field: "direction", Op: opIn, Values: []interface{}{exchangeExport}},
+				{Field: "status", Op: opIn, Values: []interface{}{exchangeOK}},
+			},
+			Sort:  []SortKey{{Field: "created_at", Desc: true}, {Field: "id", Desc: true}},
+			Limit: 1,
+		})
+		if err != nil || len(entries) == 0 {
+			return "", err
+		}
+		return entries[0].CreatedAt, nil
+	}
+	t, ok := parseDate(value)
+	if !ok {
+		return "", queryError("since", "invalid_date", value)
+	}
+	return t.Format(time.RFC3339), nil
+}
+
+func export1C(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		since, err := exportSince(ctx, store, r.URL.Query().Get("since"))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		var changed []Filter
+		if since != "" {
+			changed = []Filter{{Field: "updated_at", Op: opAtLeast, Values: []interface{}{since}}}
+		}
+		doc, summary, err := buildExport(ctx, store, changed)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		data, err := xml.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		data = append([]byte(xml.Header), data...)
+
+		name := fmt.Sprintf("orders-%s.xml", time.Now().Format("20060102-150405"))
+		logID, err := store.Exchange.AddLog(ctx, ExchangeLogEntry{Direction: exchangeExport, FileName: name, Status: exchangeOK, Summary: summary, Document: string(data)})
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Выгрузка в 1С", "exchange_log", summary)
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		w.Header().Set("X-Exchange-Log-ID", strconv.Itoa(logID))
+		w.Write(data)
+	}
+}
+
+func buildExport(ctx context.Context, store *Store, changed []Filter) (cmlDocument, string, error) {
+	now := time.Now()
+	doc := cmlDocument{SchemaVersion: commerceMLVersion, CreatedAt: now.Format("2006-01-02T15:04:05")}
+
+	orders, err := listAll(ctx, store.Orders.List, changed)
+	if err != nil {
+		return doc, "", err
+	}
+	shipments, err := listAll(ctx, store.Shipments.List, changed)
+	if err != nil {
+		return doc, "", err
+	}
+	contractors, err := store.Reference.Contractors(ctx)
+	if err != nil {
+		return doc, "", err
+	}
+
+	// Shipments repeat the contractor and goods of their order, which may
+	// not have changed itself.
+	ordersByID := map[int]SalesOrder{}
+	for _, o := range orders {
+		ordersByID[o.ID] = o
+	}
+	var missing []int
+	for _, sh := range shipments {
+		if _, ok := ordersByID[sh.OrderID]; !ok {
+			missing = append(missing, sh.OrderID)
+		}
+	}
+	if len(missing) > 0 {
+		extra, err := listAll(ctx, store.Orders.List, idFilter(missing))
+		if err != nil {
+			return doc, "", err
+		}
+		for _, o := range extra {
+			ordersByID[o.ID] = o
+		}
+	}
+
+	var batchIDs []int
+	for _, o := range ordersByID {
+		for _, item := range o.Items {
+			batchIDs = append(batchIDs, item.OreBatchID)
+		}
+	}
+	batches := map[int]OreBatch{}
+	if len(batchIDs) > 0 {
+		list, err := listAll(ctx, store.Batches.List, idFilter(batchIDs))
+		if err != nil {
+			return doc, "", err
+		}
+		for _, b := range list {
+			batches[b.ID] = b
+		}
+	}
+
+	guids := map[string]map[int]string{}
+	collect := func(entity string, ids []int) error {
+		m, err := store.Exchange.GUIDs(ctx, entity, ids)
+		guids[entity] = m
+		return err
+	}
+	var contractorIDs, orderIDs, shipmentIDs, oreTypeIDs []int
+	for _, c := range contractors {
+		contractorIDs = append(contractorIDs, c.ID)
+	}
+	for id := range ordersByID {
+		orderIDs = append(orderIDs, id)
+	}
+	for _, sh := range shipments {
+		shipmentIDs = append(shipmentIDs, sh.ID)
+	}
+	for _, b := range batches {
+		oreTypeIDs = append(oreTypeIDs, b.OreTypeID)
+	}
+	for entity, ids := range map[string][]int{
+		exchangeContractorEntity: contractorIDs,
+		exchangeOrderEntity:      orderIDs,
+		exchangeShipmentEntity:   shipmentIDs,
+		exchangeOreTypeEntity:    oreTypeIDs,
+	} {
+		if err := collect(entity, ids); err != nil {
+			return doc, "", err
+		}
+	}
+
+	contractorsByID := map[int]Contractor{}
+	for _, c := range contractors {
+		contractorsByID[c.ID] = c
+		doc.Contractors = append(doc.Contractors, cmlContractorOf(c, guids[exchangeContractorEntity][c.ID]))
+	}
+	buyer := func(o SalesOrder) []cmlContractor {
+		return []cmlContractor{{ID: guids[exchangeContractorEntity][o.ContractorID], Name: o.ContractorName, Role: "Покупатель"}}
+	}
+	goods := func(o SalesOrder) ([]cmlItem, Decimal) {
+		var items []cmlItem
+		total := Decimal(0)
+		for _, item := range o.Items {
+			b := batches[item.OreBatchID]
+			sum := item.Amount
+			total += sum
+			items = append(items, cmlItem{
+				ID:       guids[exchangeOreTypeEntity][b.OreTypeID],
+				Name:     b.OreTypeName,
+				Unit:     cmlUnit{FullName: item.UnitName, Symbol: item.UnitSymbol},
+				Price:    item.PricePerUnit.String(),
+				Quantity: cmlAmount(item.Quantity),
+				Sum:      sum.String(),
+				Properties: []cmlProperty{
+					{Name: "Партия", Value: item.OreBatchName},
+				},
+			})
+		}
+		return items, total
+	}
+
+	for _, o := range orders {
+		items, total := goods(o)
+		doc.Documents = append(doc.Documents, cmlDoc{
+			ID:          guids[exchangeOrderEntity][o.ID],
+			Number:      o.OrderNumber,
+			Date:        cmlDate(o.OrderDate, now),
+			Operation:   "Заказ товара",
+			Role:        "Продавец",
+			Currency:    o.Currency,
+			Rate:        cmlRate(o),
+			Sum:         total.String(),
+			Contractors: buyer(o),
+			Items:       items,
+			Properties: []cmlProperty{
+				{Name: "Статус заказа", Value: o.Status},
+				{Name: "Склад", Value: o.WarehouseName},
+			},
+		})
+	}
+	for _, sh := range shipments {
+		o := ordersByID[sh.OrderID]
+		items, total := goods(o)
+		date := sh.ActualDate
+		if date == "" {
+			date = sh.PlannedDate
+		}
+		doc.Documents = append(doc.Documents, cmlDoc{
+			ID:          guids[exchangeShipmentEntity][sh.ID],
+			Number:      strconv.Itoa(sh.ID),
+			Date:        cmlDate(date, now),
+			Operation:   "Отпуск товара",
+			Role:        "Продавец",
+			Currency:    o.Currency,
+			Rate:        cmlRate(o),
+			Sum:         total.String(),
+			Contractors: buyer(o),
+			Items:       items,
+			Properties: []cmlProperty{
+				{Name: "Основание", Value: guids[exchangeOrderEntity][o.ID]},
+				{Name: "Номер заказа", Value: o.OrderNumber},
+				{Name: "Статус отгрузки", Value: sh.Status},
+				{Name: "Транспорт", Value: sh.TransportName},
+				{Name: "Плановая дата", Value: sh.PlannedDate},
+				{Name: "Фактическая дата", Value: sh.ActualDate},
+			},
+		})
+	}
+
+	summary := fmt.Sprintf("Заказов: %d, отгрузок: %d, контрагентов: %d", len(orders), len(shipments), len(contractors))
+	return doc, summary, nil
+}
+
+func cmlContractorOf(c Contractor, guid string) cmlContractor {
+	out := cmlContractor{ID: guid, Name: c.Name, Role: c.Type}
+	var contacts []cmlContact
+	if c.Phone != "" {
+		contacts = append(contacts, cmlContact{Type: "Телефон рабочий", Value: c.Phone})
+	}
+	if c.Email != "" {
+		contacts = append(contacts, cmlContact{Type: "Почта", Value: c.Email})
+	}
+	if len(contacts) > 0 {
+		out.Contacts = &cmlContacts{Items: contacts}
+	}
+	if c.ContactPerson != "" {
+		out.Representatives = &cmlRepresentatives{Items: []cmlRepresentative{{Relation: "Контактное лицо", Name: c.ContactPerson}}}
+	}
+	return out
+}
+
+// parseCommerceML picks contractor and price updates out of a file. Records
+// it can't use are reported in errors.
+func parseCommerceML(doc cmlDocument) (contractors []exchangeContractor, prices []exchangePrice, errors []string) {
+	for i, c := range doc.Contractors {
+		if c.ID == "" || c.Name == "" {
+			errors = append(errors, fmt.Sprintf("Контрагент №%d: не заполнены Ид или Наименование", i+1))
+			continue
+		}
+		ec := exchangeContractor{GUID: c.ID, Name: c.Name, Type: c.Role}
+		if c.Contacts != nil {
+			for _, contact := range c.Contacts.Items {
+				switch {
+				case strings.Contains(contact.Type, "Телефон"):
+					ec.Phone = contact.Value
+				case strings.Contains(contact.Type, "Почта"):
+					ec.Email = contact.Value
+				}
+			}
+		}
+		if c.Representatives != nil {
+			for _, rep := range c.Representatives.Items {
+				if rep.Name != "" {
+					ec.ContactPerson = rep.Name
+					break
+				}
+			}
+		}
+		contractors = append(contractors, ec)
+	}
+	if doc.Offers == nil {
+		return contractors, prices, errors
+	}
+	for i, offer := range doc.Offers.Offers {
+		if offer.ID == "" || len(offer.Prices) == 0 {
+			errors = append(errors, fmt.Sprintf("Предложение №%d: не заполнены Ид или Цены", i+1))
+			continue
+		}
+		price, err := parseDecimal(strings.ReplaceAll(offer.Prices[0].Price, ",", "."))
+		if err != nil || price < 0 {
+			errors = append(errors, fmt.Sprintf("Предложение «%s»: некорректная цена %q", offer.Name, offer.Prices[0].Price))
+			continue
+		}
+		prices = append(prices, exchangePrice{GUID: offer.ID, Name: offer.Name, Price: price})
+	}
+	return contractors, prices, errors
+}
+
+func import1C(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		data, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+		name := r.URL.Query().Get("file_name")
+		entry := ExchangeLogEntry{Direction: exchangeImport, FileName: name, Document: string(data)}
+
+		var doc cmlDocument
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			entry.Status, entry.Summary, entry.Errors = exchangeError, "Файл не разобран", []string{err.Error()}
+			if _, logErr := store.Exchange.AddLog(ctx, entry); logErr != nil {
+				writeError(w, r, logErr)
+				return
+			}
+			writeError(w, r, newAPIError(http.StatusBadRequest, "bad_request", err.Error()))
+			return
+		}
+
+		contractors, prices, problems := parseCommerceML(doc)
+		result, err := store.Exchange.Import(ctx, contractors, prices)
+		if err != nil {
+			entry.Status, entry.Summary, entry.Errors = exchangeError, "Изменения не сохранены", []string{err.Error()}
+			store.Exchange.AddLog(ctx, entry)
+			writeError(w, r, err)
+			return
+		}
+		result.Errors = append(problems, result.Errors...)
+
+		entry.Status = exchangeOK
+		if len(result.Errors) > 0 {
+			entry.Status = exchangePartial
+		}
+		entry.Summary = fmt.Sprintf("Контрагентов создано: %d, обновлено: %d; цен обновлено: %d",
+			result.ContractorsCreated, result.ContractorsUpdated, result.PricesUpdated)
+		entry.Errors = result.Errors
+		if result.LogID, err = store.Exchange.AddLog(ctx, entry); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Загрузка из 1С", "exchange_log", entry.Summary)
+		writeJSON(w, r, result)
+	}
+}
+
+var exchangeLogListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":         intField,
+		"direction":  textField,
+		"status":     textField,
+		"created_at": dateField,
+	},
+	DefaultSort: "-created_at,-id",
+}
+
+func getExchangeLog(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r, exchangeLogListSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		entries, total, err := store.Exchange.Log(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, ListPage{Items: entries, Total: total, NextCursor: q.nextCursor(entries, total)})
+	}
+}
+
+func getExchangeDocument(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, ErrNotFound)
+			return
+		}
+		entry, err := store.Exchange.Document(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		name := entry.FileName
+		if name == "" {
+			name = fmt.Sprintf("%s-%d.xml", entry.Direction, entry.ID)
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		w.Write([]byte(entry.Document))
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+// TestExchange1CRoundTrip exports to CommerceML, edits the file as 1C would
+// and imports it back: a second export must carry the edited contractors
+// under the same Ид, and prices must reach the exported nomenclature.
+func TestExchange1CRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		edit         func(doc *cmlDocument, oreTypeGUID string)
+		created      int
+		prices       int
+		errors       int
+		wantOrePrice string
+		rejected     bool
+	}{
+		{"unchanged", func(*cmlDocument, string) {}, 0, 0, 0, "", false},
+		{"renamed with contacts", func(doc *cmlDocument, _ string) {
+			c := &doc.Contractors[0]
+			c.Name = "ООО «Северная руда»"
+			c.Contacts = &cmlContacts{Items: []cmlContact{{Type: "Телефон рабочий", Value: "+7 900 000-00-00"}, {Type: "Почта", Value: "buy@ore.example"}}}
+			c.Representatives = &cmlRepresentatives{Items: []cmlRepresentative{{Relation: "Контактное лицо", Name: "Иванов И. И."}}}
+		}, 0, 0, 0, "", false},
+		{"new contractor", func(doc *cmlDocument, _ string) {
+			doc.Contractors = append(doc.Contractors, cmlContractor{ID: newGUID(), Name: "АО «Новый покупатель»", Role: "Покупатель"})
+		}, 1, 0, 0, "", false},
+		{"price with a comma", func(doc *cmlDocument, guid string) {
+			doc.Offers = &cmlOffers{Offers: []cmlOffer{{ID: guid, Name: "Руда", Prices: []cmlPrice{{Price: "1500,25", Currency: "RUB"}}}}}
+		}, 0, 1, 0, "1500.25", false},
+		{"unknown nomenclature", func(doc *cmlDocument, _ string) {
+			doc.Offers = &cmlOffers{Offers: []cmlOffer{{ID: newGUID(), Name: "Чужая руда", Prices: []cmlPrice{{Price: "10"}}}}}
+		}, 0, 0, 1, "", false},
+		{"fraction price", func(doc *cmlDocument, guid string) {
+			doc.Offers = &cmlOffers{Offers: []cmlOffer{{ID: guid, Name: "Руда", Prices: []cmlPrice{{Price: "1/3"}}}}}
+		}, 0, 0, 1, "", false},
+		{"contractor without a name", func(doc *cmlDocument, _ string) {
+			doc.Contractors[0].Name = ""
+		}, 0, 0, 1, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := openTestSQLite(t)
+			refs := loadTestRefs(t, store)
+			batch := createTestBatches(t, store, refs, quality(62))[0]
+			price, _ := parseDecimal("1200")
+			if _, err := store.Orders.Create(ctx, NewOrder{OrderNumber: "1С-1", ContractorID: refs.contractor, WarehouseID: refs.warehouses[0],
+				Status: "Черновик", Currency: "RUB", VATRate: 20,
+				Items: []NewOrderItem{{OreBatchID: batch, UnitID: refs.unit, Quantity: 2, PricePerUnit: &price, PriceSource: priceManual}}}); err != nil {
+				t.Fatal(err)
+			}
+
+			exported, _, err := buildExport(ctx, store, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(exported.Contractors) == 0 || len(exported.Documents) != 1 || len(exported.Documents[0].Items) != 1 {
+				t.Fatalf("export has %d contractors and documents %+v", len(exported.Contractors), exported.Documents)
+			}
+			oreTypeGUID := exported.Documents[0].Items[0].ID
+			file, err := xml.Marshal(exported)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc cmlDocument
+			if err := xml.Unmarshal(file, &doc); err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(&doc, oreTypeGUID)
+
+			contractors, prices, problems := parseCommerceML(doc)
+			result, err := store.Exchange.Import(ctx, contractors, prices)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(problems) + len(result.Errors); got != tt.errors {
+				t.Errorf("errors %v %v, want %d", problems, result.Errors, tt.errors)
+			}
+			if result.ContractorsCreated != tt.created || result.ContractorsUpdated != len(contractors)-tt.created || result.PricesUpdated != tt.prices {
+				t.Errorf("result %+v, want %d created and %d prices", result, tt.created, tt.prices)
+			}
+
+			again, _, err := buildExport(ctx, store, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			byGUID := func(list []cmlContractor) map[string]cmlContractor {
+				m := map[string]cmlContractor{}
+				for _, c := range list {
+					m[c.ID] = c
+				}
+				return m
+			}
+			// A rejected record leaves the database as it was exported.
+			want := byGUID(doc.Contractors)
+			if tt.rejected {
+				want = byGUID(exported.Contractors)
+			}
+			if got := byGUID(again.Contractors); !reflect.DeepEqual(got, want) {
+				t.Errorf("re-exported contractors\n%+v\nwant\n%+v", got, want)
+			}
+			if again.Documents[0].Items[0].ID != oreTypeGUID {
+				t.Errorf("nomenclature Ид changed from %s to %s", oreTypeGUID, again.Documents[0].Items[0].ID)
+			}
+
+			oreTypes, err := store.Reference.OreTypes(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, o := range oreTypes {
+				if o.ID != refs.oreType {
+					continue
+				}
+				got := ""
+				if o.Price != nil {
+					got = o.Price.String()
+				}
+				if got != tt.wantOrePrice {
+					t.Errorf("ore type price %q, want %q", got, tt.wantOrePrice)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+#!/bin/sh
+# Downloads the Font Awesome Free web kit into static/vendor/fontawesome so it
+# is embedded into the binary. Run via `go generate` on a machine with
+# internet access and commit the result.
+set -eu
+
+VERSION=6.4.0
+DEST=static/vendor/fontawesome
+URL="https://use.fontawesome.com/releases/v${VERSION}/fontawesome-free-${VERSION}-web.zip"
+
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+curl -fsSL -o "$tmp/fa.zip" "$URL"
+unzip -q "$tmp/fa.zip" -d "$tmp"
+src="$tmp/fontawesome-free-${VERSION}-web"
+
+rm -rf "$DEST"
+mkdir -p "$DEST/css"
+cp "$src/css/all.min.css" "$DEST/css/"
+cp -R "$src/webfonts" "$DEST/"
+cp "$src/LICENSE.txt" "$DEST/"
+echo "Font Awesome ${VERSION} saved to ${DEST}"
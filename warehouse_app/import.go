@@ -0,0 +1,487 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bulk import creates ore batches or equipment from a CSV or XLSX file. The
+// first row holds the headers; every following non-empty row becomes one
+// request of the same type the single-item endpoint takes, checked by the
+// same validate tags. Nothing is written unless every row is valid.
+
+// importColumn maps a spreadsheet column to a field of the request type.
+type importColumn struct {
+	// Field is the json name of the field; it is also accepted as a header.
+	Field string
+	// Headers are the other accepted header names, compared
+	// case-insensitively.
+	Headers []string
+	// Ref is the reference table a value is looked up in by name. Numeric
+	// ids are accepted as well.
+	Ref string
+}
+
+var oreBatchImportColumns = []importColumn{
+	{Field: "ore_type_id", Headers: []string{"ore_type", "Тип руды", "Вид руды"}, Ref: "ore_types"},
+	{Field: "warehouse_id", Headers: []string{"warehouse", "Склад", "Склад хранения"}, Ref: "warehouses"},
+	{Field: "unit_id", Headers: []string{"unit", "Единица измерения", "Ед. изм."}, Ref: "units"},
+	{Field: "batch_code", Headers: []string{"Код партии", "Партия"}},
+	{Field: "quantity", Headers: []string{"Количество"}},
+	{Field: "quality", Headers: []string{"Качество", "Качество (%)"}},
+	{Field: "priority", Headers: []string{"Приоритет"}},
+	{Field: "extraction_date", Headers: []string{"Дата добычи"}},
+	{Field: "status", Headers: []string{"Статус", "Статус партии"}},
+}
+
+var equipmentImportColumns = []importColumn{
+	{Field: "name", Headers: []string{"Наименование", "Название"}},
+	{Field: "category_id", Headers: []string{"category", "Категория"}, Ref: "equipment_categories"},
+	{Field: "warehouse_id", Headers: []string{"warehouse", "Склад", "Склад эксплуатации"}, Ref: "warehouses"},
+	{Field: "unit_id", Headers: []string{"unit", "Единица учета", "Единица измерения", "Ед. изм."}, Ref: "units"},
+	{Field: "quantity", Headers: []string{"Количество"}},
+	{Field: "serial_number", Headers: []string{"Серийный номер"}},
+	{Field: "inventory_number", Headers: []string{"Инвентарный номер"}},
+	{Field: "tracking", Headers: []string{"Учет"}},
+	{Field: "service_life_months", Headers: []string{"Срок службы", "Срок службы (мес)"}},
+	{Field: "status", Headers: []string{"Статус"}},
+	{Field: "purchase_date", Headers: []string{"Дата ввода", "Дата покупки"}},
+	{Field: "purchase_cost", Headers: []string{"Стоимость", "Первоначальная стоимость"}},
+	{Field: "salvage_value", Headers: []string{"Ликвидационная стоимость"}},
+	{Field: "depreciation_method", Headers: []string{"Способ амортизации"}},
+}
+
+type ImportResult struct {
+	DryRun bool `json:"dry_run"`
+	Rows   int  `json:"rows"`
+	// Created lists the ids of the new records; empty on a dry run.
+	Created []int `json:"created"`
+	// Columns maps the headers of the file to request fields.
+	Columns        map[string]string `json:"columns"`
+	IgnoredColumns []string          `json:"ignored_columns,omitempty"`
+	Warnings       []string          `json:"warnings,omitempty"`
+	// Preview holds the rows as they would be created, on a dry run.
+	Preview []ImportRow `json:"preview,omitempty"`
+}
+
+type ImportRow struct {
+	Line int         `json:"line"`
+	Data interface{} `json:"data"`
+}
+
+var importMediaTypes = []string{"text/csv", xlsxContentType}
+
+var importParams = []apiParam{
+	{Name: "dry_run", Type: "boolean", Description: "только проверить файл и вернуть строки без сохранения"},
+	{Name: "sheet", Type: "string", Description: "лист XLSX; по умолчанию первый"},
+	{Name: "columns", Type: "string", Description: "сопоставление колонок через запятую: Заголовок=поле"},
+}
+
+type importRequest struct {
+	rows    []sheetRow
+	dryRun  bool
+	mapping map[string]string
+}
+
+func readImportRequest(w http.ResponseWriter, r *http.Request) (importRequest, bool) {
+	var req importRequest
+	query := r.URL.Query()
+	if v := query.Get("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, r, queryError("dry_run", "invalid_value", v))
+			return req, false
+		}
+		req.dryRun = dryRun
+	}
+	req.mapping = map[string]string{}
+	for _, pair := range splitList(query.Get("columns")) {
+		header, field, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(header) == "" || strings.TrimSpace(field) == "" {
+			writeError(w, r, queryError("columns", "invalid_value", pair))
+			return req, false
+		}
+		req.mapping[normalizeHeader(header)] = strings.TrimSpace(field)
+	}
+
+	data, ok := readBody(w, r)
+	if !ok {
+		return req, false
+	}
+	rows, err := readSpreadsheet(data, query.Get("sheet"))
+	if err != nil {
+		writeError(w, r, newAPIError(http.StatusBadRequest, "bad_request", err.Error()))
+		return req, false
+	}
+	req.rows = rows
+	return req, true
+}
+
+func normalizeHeader(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// importRefs maps a reference table to its rows by lower-cased name (and
+// symbol, for units) and by id.
+type importRefs map[string]map[string]int
+
+func loadImportRefs(ctx context.Context, store ReferenceStore) (importRefs, error) {
+	refs := importRefs{}
+	add := func(table string, id int, names ...string) {
+		if refs[table] == nil {
+			refs[table] = map[string]int{}
+		}
+		refs[table][strconv.Itoa(id)] = id
+		for _, name := range names {
+			if name != "" {
+				refs[table][normalizeHeader(name)] = id
+			}
+		}
+	}
+	units, err := store.Units(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range units {
+		add("units", u.ID, u.Name, u.Symbol)
+	}
+	warehouses, err := store.Warehouses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, wh := range warehouses {
+		add("warehouses", wh.ID, wh.Name)
+	}
+	oreTypes, err := store.OreTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, ot := range oreTypes {
+		add("ore_types", ot.ID, ot.Name)
+	}
+	categories, err := store.EquipmentCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		add("equipment_categories", c.ID, c.Name)
+	}
+	return refs, nil
+}
+
+// parseImport turns the rows of a file into requests of type T, starting each
+// from base. Row errors are reported as rows[<line>].<field>, where line is
+// the row number in the file.
+func parseImport[T any](req importRequest, columns []importColumn, refs importRefs, base T, result *ImportResult) ([]T, []int, error) {
+	if len(req.rows) < 2 {
+		return nil, nil, validationError(fieldError("file", "empty_file"))
+	}
+
+	byName := map[string]importColumn{}
+	for _, c := range columns {
+		byName[c.Field] = c
+		byName[normalizeHeader(c.Field)] = c
+		for _, h := range c.Headers {
+			byName[normalizeHeader(h)] = c
+		}
+	}
+	var fields []FieldError
+	for _, header := range slices.Sorted(maps.Keys(req.mapping)) {
+		if target := req.mapping[header]; byName[normalizeHeader(target)].Field == "" {
+			fields = append(fields, fieldError("columns", "invalid_value", target))
+		}
+	}
+	header := req.rows[0].Cells
+	mapped := make([]*importColumn, len(header))
+	result.Columns = map[string]string{}
+	for i, h := range header {
+		key := normalizeHeader(h)
+		target, explicit := req.mapping[key]
+		if !explicit {
+			target = key
+		}
+		if c, ok := byName[normalizeHeader(target)]; ok {
+			mapped[i] = &c
+			result.Columns[h] = c.Field
+		} else if key != "" {
+			result.IgnoredColumns = append(result.IgnoredColumns, h)
+		}
+	}
+
+	t := reflect.TypeOf(base)
+	baseValue := reflect.ValueOf(base)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		required := slices.Contains(strings.Split(t.Field(i).Tag.Get("validate"), ","), "required")
+		if !required || !baseValue.Field(i).IsZero() {
+			continue
+		}
+		if !slices.ContainsFunc(mapped, func(c *importColumn) bool { return c != nil && c.Field == name }) {
+			fields = append(fields, fieldError("columns", "missing_column", name))
+		}
+	}
+	if len(fields) > 0 {
+		return nil, nil, validationError(fields...)
+	}
+
+	var items []T
+	var lines []int
+	for _, row := range req.rows[1:] {
+		item := base
+		rv := reflect.ValueOf(&item).Elem()
+		prefix := fmt.Sprintf("rows[%d].", row.Line)
+		var rowErrors []FieldError
+		for i, c := range mapped {
+			if c == nil || i >= len(row.Cells) {
+				continue
+			}
+			cell := strings.TrimSpace(row.Cells[i])
+			if cell == "" {
+				continue
+			}
+			if err := setImportField(rv, c, cell, refs); err != nil {
+				err.Field = prefix + c.Field
+				rowErrors = append(rowErrors, *err)
+			}
+		}
+		// Cells that didn't convert are left out of validation, which would
+		// only add a second error for the same field.
+		var v validator
+		v.walk(rv, prefix)
+		for _, f := range v.fields {
+			if !slices.ContainsFunc(rowErrors, func(e FieldError) bool { return e.Field == f.Field }) {
+				rowErrors = append(rowErrors, f)
+			}
+		}
+		fields = append(fields, rowErrors...)
+		items = append(items, item)
+		lines = append(lines, row.Line)
+	}
+	result.Rows = len(items)
+	if len(fields) > 0 {
+		return nil, nil, validationError(fields...)
+	}
+	return items, lines, nil
+}
+
+// setImportField stores a cell in the field c maps to, converting it to the
+// field's type.
+func setImportField(rv reflect.Value, c *importColumn, cell string, refs importRefs) *FieldError {
+	var fv reflect.Value
+	for i := 0; i < rv.NumField(); i++ {
+		if name, _, _ := strings.Cut(rv.Type().Field(i).Tag.Get("json"), ","); name == c.Field {
+			fv = rv.Field(i)
+		}
+	}
+	if !fv.IsValid() {
+		panic("import: no field " + c.Field)
+	}
+	if c.Ref != "" {
+		id, ok := refs[c.Ref][normalizeHeader(cell)]
+		if !ok {
+			err := fieldError("", "unknown_name", cell)
+			return &err
+		}
+		fv.SetInt(int64(id))
+		return nil
+	}
+
+	target := fv
+	if fv.Kind() == reflect.Ptr {
+		target = reflect.New(fv.Type().Elem()).Elem()
+	}
+	switch target.Kind() {
+	case reflect.Int64:
+		if err := target.Addr().Interface().(*Decimal).UnmarshalText([]byte(importNumber(cell))); err != nil {
+			err := fieldError("", "invalid_value", cell)
+			return &err
+		}
+	case reflect.String:
+		if strings.HasSuffix(c.Field, "_date") || strings.HasSuffix(c.Field, "_at") {
+			date, ok := parseImportDate(cell)
+			if !ok {
+				err := fieldError("", "invalid_date", cell)
+				return &err
+			}
+			cell = date
+		}
+		target.SetString(cell)
+	case reflect.Float64:
+		n, ok := parseImportNumber(cell)
+		if !ok {
+			err := fieldError("", "invalid_value", cell)
+			return &err
+		}
+		target.SetFloat(n)
+	case reflect.Int:
+		n, ok := parseImportNumber(cell)
+		if !ok || n != math.Trunc(n) {
+			err := fieldError("", "invalid_value", cell)
+			return &err
+		}
+		target.SetInt(int64(n))
+	default:
+		panic("import: unsupported field type " + target.Type().String())
+	}
+	if fv.Kind() == reflect.Ptr {
+		fv.Set(target.Addr())
+	}
+	return nil
+}
+
+// importNumber accepts a decimal comma and spaces between digit groups, as
+// Russian spreadsheets write numbers.
+func importNumber(s string) string {
+	return strings.NewReplacer(" ", "", "\u00a0", "", ",", ".").Replace(s)
+}
+
+func parseImportNumber(s string) (float64, bool) {
+	n, err := strconv.ParseFloat(importNumber(s), 64)
+	return n, err == nil && !math.IsInf(n, 0) && !math.IsNaN(n)
+}
+
+// parseImportDate accepts the API date formats, DD.MM.YYYY and the serial
+// day numbers XLSX stores dates as, and returns the date as YYYY-MM-DD
+// unless it was already in an API format.
+func parseImportDate(s string) (string, bool) {
+	if _, ok := parseDate(s); ok {
+		return s, true
+	}
+	if t, err := time.ParseInLocation("02.01.2006", s, time.Local); err == nil {
+		return t.Format("2006-01-02"), true
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil && n >= 1 && n < 2958466 {
+		epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.Local)
+		return epoch.AddDate(0, 0, int(n)).Format("2006-01-02"), true
+	}
+	return "", false
+}
+
+func importPreview[T any](items []T, lines []int) []ImportRow {
+	preview := make([]ImportRow, len(items))
+	for i, item := range items {
+		preview[i] = ImportRow{Line: lines[i], Data: item}
+	}
+	return preview
+}
+
+// importCapacity checks the batches against warehouse capacity as if they
+// were added one by one. In enforce mode the first batch that overflows a
+// warehouse is reported as a row error; in warn mode it becomes a warning.
+func importCapacity(ctx context.Context, store *Store, business BusinessConfig, lang string, batches []NewOreBatch, lines []int, result *ImportResult) error {
+	if business.CapacityMode == capacityOff {
+		return nil
+	}
+	warehouses, err := store.Reference.Warehouses(ctx)
+	if err != nil {
+		return err
+	}
+	stock := map[int]float64{}
+	reported := map[int]bool{}
+	var fields []FieldError
+	for i, b := range batches {
+		if _, ok := stock[b.WarehouseID]; !ok {
+			if stock[b.WarehouseID], err = store.Batches.WarehouseStock(ctx, b.WarehouseID); err != nil {
+				return err
+			}
+		}
+		stock[b.WarehouseID] += b.Quantity
+		for _, wh := range warehouses {
+			if wh.ID != b.WarehouseID || wh.Capacity <= 0 || stock[b.WarehouseID] <= wh.Capacity || reported[wh.ID] {
+				continue
+			}
+			reported[wh.ID] = true
+			params := []interface{}{wh.Name, stock[b.WarehouseID], wh.Capacity}
+			if business.CapacityMode == capacityEnforce {
+				fields = append(fields, fieldError(fmt.Sprintf("rows[%d].quantity", lines[i]), "capacity_exceeded", params...))
+			} else {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("rows[%d]: %s", lines[i], localize(lang, "capacity_exceeded", params)))
+			}
+		}
+	}
+	if len(fields) > 0 {
+		return validationError(fields...)
+	}
+	return nil
+}
+
+func importOreBatches(store *Store, business BusinessConfig, events *eventBus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req, ok := readImportRequest(w, r)
+		if !ok {
+			return
+		}
+		refs, err := loadImportRefs(ctx, store.Reference)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		result := ImportResult{DryRun: req.dryRun, Created: []int{}}
+		batches, lines, err := parseImport(req, oreBatchImportColumns, refs, NewOreBatch{UnitID: business.DefaultUnitID}, &result)
+		if err == nil {
+			err = importCapacity(ctx, store, business, requestLanguage(r), batches, lines, &result)
+		}
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if req.dryRun {
+			result.Preview = importPreview(batches, lines)
+			writeJSON(w, r, result)
+			return
+		}
+		if result.Created, err = store.Batches.CreateMany(ctx, batches); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		for _, id := range result.Created {
+			publishOreBatch(ctx, store, events, "ore_batch.created", id)
+		}
+		logAction(store.Logs, "system", "Импорт партий руды", "ore_batches", fmt.Sprintf("Загружено партий: %d", len(result.Created)))
+		writeJSON(w, r, result)
+	}
+}
+
+func importEquipment(store *Store, business BusinessConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req, ok := readImportRequest(w, r)
+		if !ok {
+			return
+		}
+		refs, err := loadImportRefs(ctx, store.Reference)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		result := ImportResult{DryRun: req.dryRun, Created: []int{}}
+		items, lines, err := parseImport(req, equipmentImportColumns, refs, NewEquipment{UnitID: business.DefaultUnitID}, &result)
+		if err == nil {
+			err = prepareEquipment(ctx, store, items, func(i int, name string) string { return fmt.Sprintf("rows[%d].%s", lines[i], name) })
+		}
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if req.dryRun {
+			result.Preview = importPreview(items, lines)
+			writeJSON(w, r, result)
+			return
+		}
+		if result.Created, err = store.Equipment.CreateMany(ctx, items); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Импорт оборудования", "equipment", fmt.Sprintf("Загружено единиц: %d", len(result.Created)))
+		writeJSON(w, r, result)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type logFilter struct {
+	ID       int
+	Users    []string
+	Actions  []string
+	Entities []string
+	From     string
+	To       string
+	Query    string
+	Desc     bool
+	Limit    int
+	Cursor   *logCursor
+}
+
+type logCursor struct {
+	EventTime string `json:"t"`
+	ID        int    `json:"id"`
+}
+
+func encodeLogCursor(entry LogEntry) string {
+	data, _ := json.Marshal(logCursor{EventTime: entry.EventTime, ID: entry.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeLogCursor(value string) (*logCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, queryError("cursor", "invalid_cursor")
+	}
+	var c logCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, queryError("cursor", "invalid_cursor")
+	}
+	return &c, nil
+}
+
+func parseLogFilter(r *http.Request) (logFilter, error) {
+	q := r.URL.Query()
+	f := logFilter{
+		Users:    splitList(q.Get("user")),
+		Actions:  splitList(q.Get("action")),
+		Entities: splitList(q.Get("entity")),
+		Query:    strings.TrimSpace(q.Get("q")),
+		Desc:     true,
+		Limit:    defaultListLimit,
+	}
+
+	if v := q.Get("id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return f, queryError("id", "invalid_value", v)
+		}
+		f.ID = id
+	}
+
+	switch strings.ToLower(q.Get("sort")) {
+	case "", "desc":
+	case "asc":
+		f.Desc = false
+	default:
+		return f, queryError("sort", "one_of", "asc, desc")
+	}
+
+	if v := q.Get("from"); v != "" {
+		from, err := parseLogTime(v, false)
+		if err != nil {
+			return f, queryError("from", "invalid_date", v)
+		}
+		f.From = from
+	}
+	if v := q.Get("to"); v != "" {
+		to, err := parseLogTime(v, true)
+		if err != nil {
+			return f, queryError("to", "invalid_date", v)
+		}
+		f.To = to
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return f, queryError("limit", "positive")
+		}
+		if limit > maxListLimit {
+			limit = maxListLimit
+		}
+		f.Limit = limit
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		c, err := decodeLogCursor(v)
+		if err != nil {
+			return f, err
+		}
+		f.Cursor = c
+	}
+	return f, nil
+}
+
+// logTime is the form event times are stored and compared in. They are kept
+// in UTC so that entries and bounds written with different offsets compare
+// correctly as strings.
+func logTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// A date-only upper bound is moved to the start of the next day so the whole
+// day is included.
+func parseLogTime(value string, upper bool) (string, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return logTime(t), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return "", err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return logTime(t), nil
+}
+
+func splitList(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+func getLogs(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseLogFilter(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		// One extra row tells us whether another page exists.
+		probe := filter
+		probe.Limit++
+		entries, total, err := store.Logs.List(r.Context(), probe)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		page := ListPage{Total: total}
+		if len(entries) > filter.Limit {
+			entries = entries[:filter.Limit]
+			page.NextCursor = encodeLogCursor(entries[len(entries)-1])
+		}
+		page.Items = entries
+		writeJSON(w, r, page)
+	}
+}
+
+func exportLogs(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseLogFilter(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "csv"
+		}
+		if format != "csv" && format != "jsonl" {
+			writeError(w, r, queryError("format", "one_of", "csv, jsonl"))
+			return
+		}
+
+		// An export can outlast the server's write timeout.
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		filename := "logs-" + time.Now().Format("20060102-150405") + "." + format
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		var write func(LogEntry) error
+		if format == "csv" {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			writer := csv.NewWriter(w)
+			defer writer.Flush()
+			writer.Write([]string{"id", "event_time", "user", "action", "entity", "details"})
+			write = func(entry LogEntry) error {
+				return writer.Write([]string{strconv.Itoa(entry.ID), entry.EventTime, entry.User, entry.Action, entry.Entity, entry.Details})
+			}
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			encoder := json.NewEncoder(w)
+			write = func(entry LogEntry) error {
+				return encoder.Encode(entry)
+			}
+		}
+		if err := store.Logs.Each(r.Context(), filter, write); err != nil {
+			slog.Error("log export failed", "err", err)
+		}
+	}
+}
+
+// LogRetention moves entries older than Days into gzip-compressed JSON lines
+// files under Dir.
+type LogRetention struct {
+	Days     int           `yaml:"retention_days"`
+	Dir      string        `yaml:"archive_dir"`
+	Interval time.Duration `yaml:"-"`
+}
+
+func (p LogRetention) Enabled() bool {
+	return p.Days > 0
+}
+
+func (p LogRetention) Run(logs LogStore, stop <-chan struct{}) {
+	if !p.Enabled() {
+		return
+	}
+	interval := p.Interval
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, path, err := archiveLogs(logs, p, time.Now()); err != nil {
+			slog.Error("log retention failed", "err", err)
+		} else if n > 0 {
+			log.Printf("archived %d log entries to %s", n, path)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// archiveLogs writes the archive to a temporary file and gives it its final
+// name before the entries are deleted. If the deletion is rolled back the
+// archive is removed again, so a failed run leaves neither a partial or
+// duplicate archive nor lost entries.
+func archiveLogs(logs LogStore, p LogRetention, now time.Time) (int, string, error) {
+	cutoff := logTime(now.AddDate(0, 0, -p.Days))
+	path := filepath.Join(p.Dir, fmt.Sprintf("logs-%s.jsonl.gz", now.Format("20060102-150405")))
+	if err := os.MkdirAll(p.Dir, 0o755); err != nil {
+		return 0, "", err
+	}
+	archive, err := createLogArchive(path + ".tmp")
+	if err != nil {
+		return 0, "", err
+	}
+	defer os.Remove(archive.path)
+	renamed := false
+	finish := func() error {
+		if err := archive.close(); err != nil {
+			return err
+		}
+		if err := os.Rename(archive.path, path); err != nil {
+			return err
+		}
+		renamed = true
+		return nil
+	}
+	n, err := logs.Archive(context.Background(), cutoff, archive.write, finish)
+	archive.close()
+	if err != nil {
+		if renamed {
+			os.Remove(path)
+		}
+		return 0, "", err
+	}
+	if n == 0 {
+		return 0, "", nil
+	}
+	return n, path, nil
+}
+
+type logArchive struct {
+	path    string
+	file    *os.File
+	gz      *gzip.Writer
+	encoder *json.Encoder
+	closed  bool
+}
+
+func createLogArchive(path string) (*logArchive, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(file)
+	return &logArchive{path: path, file: file, gz: gz, encoder: json.NewEncoder(gz)}, nil
+}
+
+func (a *logArchive) write(entry LogEntry) error {
+	return a.encoder.Encode(entry)
+}
+
+// close flushes the archive to disk; it is safe to call twice.
+func (a *logArchive) close() error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	err := a.gz.Close()
+	if err == nil {
+		err = a.file.Sync()
+	}
+	if cerr := a.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiveLogs(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		// blockRename puts a directory where the archive should go.
+		blockRename bool
+		wantLeft    int
+	}{
+		{"archived", false, 1},
+		{"rename fails", true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := openTestSQLite(t)
+			for _, at := range []time.Time{now.AddDate(0, 0, -40), now.AddDate(0, 0, -35), now.AddDate(0, 0, -1)} {
+				if err := store.Logs.Add(ctx, LogEntry{EventTime: logTime(at), User: "test", Action: "a", Entity: "logs"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			p := LogRetention{Days: 30, Dir: t.TempDir()}
+			final := filepath.Join(p.Dir, "logs-"+now.Format("20060102-150405")+".jsonl.gz")
+			if tt.blockRename {
+				if err := os.MkdirAll(filepath.Join(final, "keep"), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			n, path, err := archiveLogs(store.Logs, p, now)
+			if tt.blockRename != (err != nil) {
+				t.Fatalf("got %d, %q, %v", n, path, err)
+			}
+			_, left, err := store.Logs.List(ctx, logFilter{Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if left != tt.wantLeft {
+				t.Errorf("%d entries left, want %d", left, tt.wantLeft)
+			}
+			if info, err := os.Stat(final); err != nil || info.IsDir() != tt.blockRename {
+				t.Errorf("archive %s: %v, %v", final, info, err)
+			}
+			if _, err := os.Stat(final + ".tmp"); !os.IsNotExist(err) {
+				t.Errorf("temporary archive left behind: %v", err)
+			}
+		})
+	}
+}
@@ -1,15 +1,17 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
-	"time"
+	"os"
+	"strconv"
 
 	"github.com/gorilla/mux"
-	_ "github.com/mattn/go-sqlite3"
 )
 
 type Unit struct {
@@ -31,6 +33,8 @@ type OreType struct {
 	Name        string `json:"name"`
 	Category    string `json:"category"`
 	Description string `json:"description"`
+	// Price per unit, as last received from 1C.
+	Price *Decimal `json:"price"`
 }
 
 type OreBatch struct {
@@ -68,10 +72,27 @@ type Equipment struct {
 	UnitSymbol    string  `json:"unit_symbol"`
 	Quantity      float64 `json:"quantity"`
 	SerialNumber  string  `json:"serial_number"`
-	ServiceLife   int     `json:"service_life_months"`
-	Status        string  `json:"status"`
-	PurchaseDate  string  `json:"purchase_date"`
-	CreatedAt     string  `json:"created_at"`
+	// Tracking is serial for a single physical unit with its own serial and
+	// inventory numbers, bulk for a quantity of interchangeable items.
+	InventoryNumber string `json:"inventory_number"`
+	Tracking        string `json:"tracking"`
+	ServiceLife     int    `json:"service_life_months"`
+	Status          string `json:"status"`
+	PurchaseDate    string `json:"purchase_date"`
+	// PurchaseCost is the cost of the whole row, depreciated down to
+	// SalvageValue over ServiceLife.
+	PurchaseCost       *Decimal `json:"purchase_cost"`
+	SalvageValue       Decimal  `json:"salvage_value"`
+	DepreciationMethod string   `json:"depreciation_method"`
+	WrittenOffDate     string   `json:"written_off_date"`
+	ResidualValue      *Decimal `json:"residual_value"`
+	WriteOffReason     string   `json:"write_off_reason"`
+	// Set by setLifecycle from the fields above.
+	ServiceEndDate string   `json:"service_end_date,omitempty"`
+	RemainingLife  *int     `json:"remaining_life_months,omitempty"`
+	EndOfLife      bool     `json:"end_of_life"`
+	BookValue      *Decimal `json:"book_value,omitempty"`
+	CreatedAt      string   `json:"created_at"`
 }
 
 type Contractor struct {
@@ -92,20 +113,43 @@ type SalesOrderItem struct {
 	UnitName     string  `json:"unit_name"`
 	UnitSymbol   string  `json:"unit_symbol"`
 	Quantity     float64 `json:"quantity"`
-	PricePerUnit float64 `json:"price_per_unit"`
+	PricePerUnit Decimal `json:"price_per_unit"`
+	// ListPrice is the price list's price for the batch grade, kept when
+	// PricePerUnit was set by hand.
+	ListPrice   *Decimal `json:"list_price"`
+	PriceListID int      `json:"price_list_id,omitempty"`
+	Grade       float64  `json:"grade"`
+	PriceSource string   `json:"price_source"`
+	PriceReason string   `json:"price_reason"`
+	Amount      Decimal  `json:"amount"`
 }
 
 type SalesOrder struct {
-	ID             int              `json:"id"`
-	OrderNumber    string           `json:"order_number"`
-	ContractorID   int              `json:"contractor_id"`
-	ContractorName string           `json:"contractor_name"`
-	WarehouseID    int              `json:"warehouse_id"`
-	WarehouseName  string           `json:"warehouse_name"`
-	Status         string           `json:"status"`
-	OrderDate      string           `json:"order_date"`
-	TotalQuantity  float64          `json:"total_quantity"`
-	Items          []SalesOrderItem `json:"items"`
+	ID             int     `json:"id"`
+	OrderNumber    string  `json:"order_number"`
+	ContractorID   int     `json:"contractor_id"`
+	ContractorName string  `json:"contractor_name"`
+	WarehouseID    int     `json:"warehouse_id"`
+	WarehouseName  string  `json:"warehouse_name"`
+	Status         string  `json:"status"`
+	OrderDate      string  `json:"order_date"`
+	TotalQuantity  float64 `json:"total_quantity"`
+	// Amount is the sum of the items without VAT, TotalAmount with it,
+	// both in Currency. Orders created before pricing have no VAT rate.
+	Currency    string   `json:"currency"`
+	Amount      Decimal  `json:"amount"`
+	VATRate     *float64 `json:"vat_rate"`
+	VATAmount   Decimal  `json:"vat_amount"`
+	TotalAmount Decimal  `json:"total_amount"`
+	// Rate is the price of a unit of Currency in ReportingCurrency, frozen
+	// when the order was confirmed, and ReportingAmount is TotalAmount at
+	// that rate.
+	Rate              *Decimal         `json:"rate"`
+	RateDate          string           `json:"rate_date"`
+	ReportingCurrency string           `json:"reporting_currency"`
+	ReportingAmount   *Decimal         `json:"reporting_amount"`
+	CreatedAt         string           `json:"created_at"`
+	Items             []SalesOrderItem `json:"items"`
 }
 
 type Transport struct {
@@ -123,6 +167,7 @@ type Shipment struct {
 	ID            int    `json:"id"`
 	OrderID       int    `json:"order_id"`
 	OrderNumber   string `json:"order_number"`
+	WarehouseID   int    `json:"warehouse_id"`
 	TransportID   int    `json:"transport_id"`
 	TransportName string `json:"transport_name"`
 	PlannedDate   string `json:"planned_date"`
@@ -150,927 +195,532 @@ type ReferenceData struct {
 }
 
 func main() {
-	db, err := sql.Open("sqlite3", "./warehouse.db")
-	if err != nil {
-		log.Fatalf("can't open db %+v", err)
-	}
-	defer db.Close()
-
-	if err := initializeSchema(db); err != nil {
-		log.Fatalf("failed to initialize schema: %v", err)
-	}
-	if err := seedReferenceData(db); err != nil {
-		log.Fatalf("failed to seed reference data: %v", err)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrateCommand(os.Args[2:]); err != nil {
+				log.Fatalf("migrate: %v", err)
+			}
+			return
+		case "openapi":
+			doc, _ := json.MarshalIndent(openAPIDocument(apiRoutes(nil, defaultConfig(), nil), currentAPIVersion()), "", "  ")
+			fmt.Println(string(doc))
+			return
+		case "webhook-receiver":
+			if err := runWebhookReceiverCommand(os.Args[2:]); err != nil {
+				log.Fatalf("webhook-receiver: %v", err)
+			}
+			return
+		case "gen-client":
+			if err := runGenClientCommand(os.Args[2:]); err != nil {
+				log.Fatalf("gen-client: %v", err)
+			}
+			return
+		}
 	}
 
-	router := mux.NewRouter()
-	router.HandleFunc("/api/reference-data", getReferenceData(db)).Methods("GET")
-	router.HandleFunc("/api/ore-batches", getOreBatches(db)).Methods("GET")
-	router.HandleFunc("/api/ore-batches", addOreBatch(db)).Methods("POST")
-	router.HandleFunc("/api/equipment", getEquipment(db)).Methods("GET")
-	router.HandleFunc("/api/equipment", addEquipment(db)).Methods("POST")
-	router.HandleFunc("/api/orders", getOrders(db)).Methods("GET")
-	router.HandleFunc("/api/orders", addOrder(db)).Methods("POST")
-	router.HandleFunc("/api/orders/{id}/status", updateOrderStatus(db)).Methods("PUT")
-	router.HandleFunc("/api/shipments", getShipments(db)).Methods("GET")
-	router.HandleFunc("/api/shipments", addShipment(db)).Methods("POST")
-	router.HandleFunc("/api/logs", getLogs(db)).Methods("GET")
-
-	router.PathPrefix("/").Handler(http.FileServer(http.Dir("./static")))
-
-	fmt.Println("Сервер запущен на http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", router))
-}
-
-func initializeSchema(db *sql.DB) error {
-	schema := `
-    PRAGMA foreign_keys = ON;
-    CREATE TABLE IF NOT EXISTS units (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        name TEXT NOT NULL,
-        symbol TEXT NOT NULL,
-        created_at TEXT,
-        updated_at TEXT
-    );
-    CREATE TABLE IF NOT EXISTS warehouses (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        name TEXT NOT NULL,
-        location TEXT,
-        supervisor TEXT,
-        capacity REAL,
-        created_at TEXT,
-        updated_at TEXT
-    );
-    CREATE TABLE IF NOT EXISTS ore_types (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        name TEXT NOT NULL,
-        category TEXT,
-        description TEXT,
-        created_at TEXT,
-        updated_at TEXT
-    );
-    CREATE TABLE IF NOT EXISTS ore_batches (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        ore_type_id INTEGER NOT NULL,
-        warehouse_id INTEGER NOT NULL,
-        unit_id INTEGER NOT NULL,
-        batch_code TEXT,
-        quantity REAL NOT NULL,
-        quality REAL,
-        priority TEXT,
-        extraction_date TEXT,
-        status TEXT,
-        created_at TEXT,
-        updated_at TEXT,
-        FOREIGN KEY (ore_type_id) REFERENCES ore_types(id),
-        FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
-        FOREIGN KEY (unit_id) REFERENCES units(id)
-    );
-    CREATE TABLE IF NOT EXISTS equipment_categories (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        name TEXT NOT NULL,
-        created_at TEXT,
-        updated_at TEXT
-    );
-    CREATE TABLE IF NOT EXISTS equipment (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        category_id INTEGER NOT NULL,
-        warehouse_id INTEGER NOT NULL,
-        unit_id INTEGER NOT NULL,
-        name TEXT NOT NULL,
-        quantity REAL NOT NULL,
-        serial_number TEXT,
-        service_life_months INTEGER,
-        status TEXT,
-        purchase_date TEXT,
-        created_at TEXT,
-        updated_at TEXT,
-        FOREIGN KEY (category_id) REFERENCES equipment_categories(id),
-        FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
-        FOREIGN KEY (unit_id) REFERENCES units(id)
-    );
-    CREATE TABLE IF NOT EXISTS contractors (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        name TEXT NOT NULL,
-        type TEXT,
-        contact_person TEXT,
-        phone TEXT,
-        email TEXT,
-        created_at TEXT,
-        updated_at TEXT
-    );
-    CREATE TABLE IF NOT EXISTS sales_orders (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        order_number TEXT NOT NULL,
-        contractor_id INTEGER NOT NULL,
-        warehouse_id INTEGER NOT NULL,
-        status TEXT,
-        order_date TEXT,
-        total_quantity REAL,
-        created_at TEXT,
-        updated_at TEXT,
-        FOREIGN KEY (contractor_id) REFERENCES contractors(id),
-        FOREIGN KEY (warehouse_id) REFERENCES warehouses(id)
-    );
-    CREATE TABLE IF NOT EXISTS sales_order_items (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        order_id INTEGER NOT NULL,
-        ore_batch_id INTEGER NOT NULL,
-        unit_id INTEGER NOT NULL,
-        quantity REAL NOT NULL,
-        price_per_unit REAL,
-        created_at TEXT,
-        updated_at TEXT,
-        FOREIGN KEY (order_id) REFERENCES sales_orders(id) ON DELETE CASCADE,
-        FOREIGN KEY (ore_batch_id) REFERENCES ore_batches(id),
-        FOREIGN KEY (unit_id) REFERENCES units(id)
-    );
-    CREATE TABLE IF NOT EXISTS transport (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        name TEXT NOT NULL,
-        type TEXT,
-        vehicle_number TEXT,
-        capacity REAL,
-        unit_id INTEGER,
-        created_at TEXT,
-        updated_at TEXT,
-        FOREIGN KEY (unit_id) REFERENCES units(id)
-    );
-    CREATE TABLE IF NOT EXISTS shipments (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        order_id INTEGER NOT NULL,
-        transport_id INTEGER,
-        planned_date TEXT,
-        actual_date TEXT,
-        status TEXT,
-        created_at TEXT,
-        updated_at TEXT,
-        FOREIGN KEY (order_id) REFERENCES sales_orders(id),
-        FOREIGN KEY (transport_id) REFERENCES transport(id)
-    );
-    CREATE TABLE IF NOT EXISTS logs (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        event_time TEXT,
-        user TEXT,
-        action TEXT,
-        entity TEXT,
-        details TEXT
-    );
-    `
-	_, err := db.Exec(schema)
-	return err
-}
+	config := bindConfigFlags(flag.CommandLine)
+	migrateDryRun := flag.Bool("migrate-dry-run", false, "print pending migrations and exit without applying them")
+	flag.Parse()
 
-func seedReferenceData(db *sql.DB) error {
-	now := time.Now().Format(time.RFC3339)
-	if err := seedUnits(db, now); err != nil {
-		return err
-	}
-	if err := seedWarehouses(db, now); err != nil {
-		return err
-	}
-	if err := seedOreTypes(db, now); err != nil {
-		return err
-	}
-	if err := seedEquipmentCategories(db, now); err != nil {
-		return err
-	}
-	if err := seedContractors(db, now); err != nil {
-		return err
-	}
-	if err := seedTransport(db, now); err != nil {
-		return err
+	cfg, err := config.load()
+	if err != nil {
+		log.Fatal(err)
 	}
-	return nil
-}
+	setupLogging(cfg.LogLevel)
 
-func seedUnits(db *sql.DB, now string) error {
-	count, err := countRecords(db, "units")
+	store, err := OpenStore(cfg.Database.Driver, cfg.Database.DSN)
 	if err != nil {
-		return err
-	}
-	if count > 0 {
-		return nil
-	}
-	units := []struct{ name, symbol string }{
-		{"Тонны", "т"},
-		{"Килограммы", "кг"},
-		{"Штуки", "шт"},
-		{"Метры", "м"},
-	}
-	for _, u := range units {
-		if _, err := db.Exec("INSERT INTO units (name, symbol, created_at, updated_at) VALUES (?, ?, ?, ?)", u.name, u.symbol, now, now); err != nil {
-			return err
-		}
+		fatalf("can't open db %+v", err)
 	}
-	return nil
-}
 
-func seedWarehouses(db *sql.DB, now string) error {
-	count, err := countRecords(db, "warehouses")
+	applied, err := store.Migrate(*migrateDryRun)
 	if err != nil {
-		return err
-	}
-	if count > 0 {
-		return nil
+		fatalf("failed to apply migrations: %v", err)
 	}
-	warehouses := []struct {
-		name, location, supervisor string
-		capacity                   float64
-	}{
-		{"Склад №1", "Карьер Северный", "Иван Петров", 1200},
-		{"Склад №2", "Карьер Южный", "Анна Смирнова", 800},
-	}
-	for _, w := range warehouses {
-		if _, err := db.Exec("INSERT INTO warehouses (name, location, supervisor, capacity, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)", w.name, w.location, w.supervisor, w.capacity, now, now); err != nil {
-			return err
+	if *migrateDryRun {
+		for _, m := range applied {
+			fmt.Printf("pending %04d %s\n", m.Version, m.Name)
 		}
+		store.Close()
+		return
 	}
-	return nil
-}
-
-func seedOreTypes(db *sql.DB, now string) error {
-	count, err := countRecords(db, "ore_types")
-	if err != nil {
-		return err
+	for _, m := range applied {
+		log.Printf("applied migration %04d %s", m.Version, m.Name)
 	}
-	if count > 0 {
-		return nil
+	if cfg.Seed {
+		if err := store.Reference.Seed(context.Background()); err != nil {
+			fatalf("failed to seed reference data: %v", err)
+		}
 	}
-	ores := []struct {
-		name, category, description string
-	}{
-		{"Железная руда 65%", "Железные руды", "Высокое содержание железа"},
-		{"Медная руда", "Цветные руды", "Содержание меди до 25%"},
-		{"Золотосодержащая руда", "Драгоценные", "Руда с повышенным содержанием золота"},
+	if err := resolveBusinessConfig(store, &cfg.Business); err != nil {
+		fatalf("invalid configuration: %v", err)
 	}
-	for _, o := range ores {
-		if _, err := db.Exec("INSERT INTO ore_types (name, category, description, created_at, updated_at) VALUES (?, ?, ?, ?, ?)", o.name, o.category, o.description, now, now); err != nil {
-			return err
-		}
+	if n, err := store.Rates.FillCurrency(context.Background(), cfg.Business.Currency); err != nil {
+		fatalf("failed to fill order currencies: %v", err)
+	} else if n > 0 {
+		log.Printf("set currency %s on %d price lists and orders", cfg.Business.Currency, n)
 	}
-	return nil
-}
+	stopRetention := make(chan struct{})
+	retentionDone := make(chan struct{})
+	go func() {
+		defer close(retentionDone)
+		cfg.Logs.Run(store.Logs, stopRetention)
+	}()
+	stopWebhooks := make(chan struct{})
+	webhooksDone := make(chan struct{})
+	go func() {
+		defer close(webhooksDone)
+		newWebhookDispatcher(store.Webhooks, cfg.Webhooks).Run(stopWebhooks)
+	}()
 
-func seedEquipmentCategories(db *sql.DB, now string) error {
-	count, err := countRecords(db, "equipment_categories")
-	if err != nil {
-		return err
+	events := newEventBus()
+	store.Logs = publishingLogStore{LogStore: store.Logs, events: events}
+
+	router := mux.NewRouter()
+	routes := apiRoutes(store, cfg, events)
+	registerRoutes(router, routes, cfg.API)
+	if err := checkRoutes(router, routes); err != nil {
+		fatalf("%v", err)
 	}
-	if count > 0 {
-		return nil
+
+	router.PathPrefix("/").Handler(newStaticHandler(cfg.StaticDir))
+
+	srv := newHTTPServer(cfg, router)
+	srv.RegisterOnShutdown(events.close)
+	err = serve(srv, cfg)
+	close(stopRetention)
+	close(stopWebhooks)
+	<-retentionDone
+	<-webhooksDone
+	if err := store.Close(); err != nil {
+		slog.Error("failed to close database", "err", err)
 	}
-	categories := []string{"Погрузочная техника", "Буровое оборудование", "Транспортировка", "Контроль качества"}
-	for _, c := range categories {
-		if _, err := db.Exec("INSERT INTO equipment_categories (name, created_at, updated_at) VALUES (?, ?, ?)", c, now, now); err != nil {
-			return err
-		}
+	if err != nil {
+		fatalf("server stopped: %v", err)
 	}
-	return nil
+	log.Printf("server stopped")
 }
 
-func seedContractors(db *sql.DB, now string) error {
-	count, err := countRecords(db, "contractors")
-	if err != nil {
-		return err
-	}
-	if count > 0 {
+// resolveBusinessConfig checks settings that refer to reference data.
+func resolveBusinessConfig(store *Store, b *BusinessConfig) error {
+	if b.DefaultUnit == "" {
 		return nil
 	}
-	contractors := []struct {
-		name, ctype, contact, phone, email string
-	}{
-		{"ООО МеталлИнвест", "Покупатель", "Дмитрий Кузнецов", "+7 (921) 123-45-67", "sales@metallinvest.ru"},
-		{"ЗАО РудаТрейд", "Покупатель", "Алексей Морозов", "+7 (812) 555-12-34", "info@rudatrade.ru"},
-		{"АО Горные Машины", "Поставщик", "Екатерина Соколова", "+7 (495) 777-77-77", "supply@gormash.ru"},
-	}
-	for _, c := range contractors {
-		if _, err := db.Exec("INSERT INTO contractors (name, type, contact_person, phone, email, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)", c.name, c.ctype, c.contact, c.phone, c.email, now, now); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func seedTransport(db *sql.DB, now string) error {
-	count, err := countRecords(db, "transport")
+	units, err := store.Reference.Units(context.Background())
 	if err != nil {
 		return err
 	}
-	if count > 0 {
-		return nil
-	}
-	transports := []struct {
-		name, ttype, number string
-		capacity            float64
-		unitID              int
-	}{
-		{"БелАЗ 7513", "Самосвал", "A123BC", 90, 1},
-		{"MAN TGX", "Тягач", "B456DE", 40, 1},
-		{"ЖД состав №12", "Железная дорога", "TR-12", 200, 1},
-	}
-	for _, t := range transports {
-		if _, err := db.Exec("INSERT INTO transport (name, type, vehicle_number, capacity, unit_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)", t.name, t.ttype, t.number, t.capacity, t.unitID, now, now); err != nil {
-			return err
+	for _, u := range units {
+		if u.Symbol == b.DefaultUnit {
+			b.DefaultUnitID = u.ID
+			return nil
 		}
 	}
-	return nil
+	return fmt.Errorf("business.default_unit: unit with symbol %q does not exist", b.DefaultUnit)
 }
 
-func countRecords(db *sql.DB, table string) (int, error) {
-	row := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table))
-	var count int
-	if err := row.Scan(&count); err != nil {
-		return 0, err
-	}
-	return count, nil
-}
-
-func getReferenceData(db *sql.DB) http.HandlerFunc {
+func getReferenceData(store *Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		ref := store.Reference
 		data := ReferenceData{}
-		if units, err := fetchUnits(db); err == nil {
+		if units, err := ref.Units(ctx); err == nil {
 			data.Units = units
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
-		if warehouses, err := fetchWarehouses(db); err == nil {
+		if warehouses, err := ref.Warehouses(ctx); err == nil {
 			data.Warehouses = warehouses
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
-		if oreTypes, err := fetchOreTypes(db); err == nil {
+		if oreTypes, err := ref.OreTypes(ctx); err == nil {
 			data.OreTypes = oreTypes
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
-		if categories, err := fetchEquipmentCategories(db); err == nil {
+		if categories, err := ref.EquipmentCategories(ctx); err == nil {
 			data.EquipmentCategories = categories
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
-		if contractors, err := fetchContractors(db); err == nil {
+		if contractors, err := ref.Contractors(ctx); err == nil {
 			data.Contractors = contractors
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
-		if transport, err := fetchTransport(db); err == nil {
+		if transport, err := ref.Transport(ctx); err == nil {
 			data.Transport = transport
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
+		writeJSON(w, r, data)
 	}
 }
 
-func fetchUnits(db *sql.DB) ([]Unit, error) {
-	rows, err := db.Query("SELECT id, name, symbol FROM units ORDER BY name")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var units []Unit
-	for rows.Next() {
-		var u Unit
-		if err := rows.Scan(&u.ID, &u.Name, &u.Symbol); err != nil {
-			return nil, err
-		}
-		units = append(units, u)
-	}
-	return units, nil
+var oreBatchListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":              intField,
+		"batch_code":      textField,
+		"ore_type_id":     intField,
+		"warehouse_id":    intField,
+		"unit_id":         intField,
+		"quantity":        floatField,
+		"quality":         floatField,
+		"priority":        textField,
+		"status":          textField,
+		"extraction_date": dateField,
+		"created_at":      dateField,
+	},
+	DefaultSort: "-created_at,-id",
 }
 
-func fetchWarehouses(db *sql.DB) ([]Warehouse, error) {
-	rows, err := db.Query("SELECT id, name, location, capacity, supervisor FROM warehouses ORDER BY name")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var warehouses []Warehouse
-	for rows.Next() {
-		var w Warehouse
-		if err := rows.Scan(&w.ID, &w.Name, &w.Location, &w.Capacity, &w.Supervisor); err != nil {
-			return nil, err
+func getOreBatches(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r, oreBatchListSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
 		}
-		warehouses = append(warehouses, w)
-	}
-	return warehouses, nil
-}
-
-func fetchOreTypes(db *sql.DB) ([]OreType, error) {
-	rows, err := db.Query("SELECT id, name, category, description FROM ore_types ORDER BY name")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var ores []OreType
-	for rows.Next() {
-		var o OreType
-		if err := rows.Scan(&o.ID, &o.Name, &o.Category, &o.Description); err != nil {
-			return nil, err
+		batches, total, err := store.Batches.List(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
 		}
-		ores = append(ores, o)
+		writeJSON(w, r, ListPage{Items: batches, Total: total, NextCursor: q.nextCursor(batches, total)})
 	}
-	return ores, nil
 }
 
-func fetchEquipmentCategories(db *sql.DB) ([]EquipmentCategory, error) {
-	rows, err := db.Query("SELECT id, name FROM equipment_categories ORDER BY name")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var categories []EquipmentCategory
-	for rows.Next() {
-		var c EquipmentCategory
-		if err := rows.Scan(&c.ID, &c.Name); err != nil {
-			return nil, err
+func addOreBatch(store *Store, business BusinessConfig, events *eventBus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req NewOreBatch
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if req.UnitID == 0 {
+			req.UnitID = business.DefaultUnitID
+		}
+		if err := validateRequest(r.Context(), store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
 		}
-		categories = append(categories, c)
+		response := map[string]string{"message": "Партия руды добавлена"}
+		if business.CapacityMode != capacityOff {
+			exceeded, err := capacityExceeded(r.Context(), store, req.WarehouseID, req.Quantity)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			if exceeded != nil {
+				if business.CapacityMode == capacityEnforce {
+					writeError(w, r, exceeded)
+					return
+				}
+				slog.Warn("warehouse capacity exceeded", "warehouse_id", req.WarehouseID, "batch", req.BatchCode)
+				response["warning"] = localize(requestLanguage(r), exceeded.Code, exceeded.params)
+			}
+		}
+		id, err := store.Batches.Create(r.Context(), req)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		publishOreBatch(r.Context(), store, events, "ore_batch.created", id)
+		logAction(store.Logs, "system", "Добавление партии руды", "ore_batches", fmt.Sprintf("Партия %s", req.BatchCode))
+		writeJSON(w, r, response)
 	}
-	return categories, nil
 }
 
-func fetchContractors(db *sql.DB) ([]Contractor, error) {
-	rows, err := db.Query("SELECT id, name, type, contact_person, phone, email FROM contractors ORDER BY name")
+// capacityExceeded returns a capacity_exceeded error when adding quantity to
+// the warehouse would exceed its capacity. Warehouses without a capacity are
+// unlimited.
+func capacityExceeded(ctx context.Context, store *Store, warehouseID int, quantity float64) (*APIError, error) {
+	warehouses, err := store.Reference.Warehouses(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	var contractors []Contractor
-	for rows.Next() {
-		var c Contractor
-		if err := rows.Scan(&c.ID, &c.Name, &c.Type, &c.ContactPerson, &c.Phone, &c.Email); err != nil {
+	for _, wh := range warehouses {
+		if wh.ID != warehouseID || wh.Capacity <= 0 {
+			continue
+		}
+		stock, err := store.Batches.WarehouseStock(ctx, warehouseID)
+		if err != nil {
 			return nil, err
 		}
-		contractors = append(contractors, c)
+		if stock+quantity > wh.Capacity {
+			return newAPIError(http.StatusConflict, "capacity_exceeded", wh.Name, stock+quantity, wh.Capacity), nil
+		}
 	}
-	return contractors, nil
+	return nil, nil
 }
 
-func fetchTransport(db *sql.DB) ([]Transport, error) {
-	rows, err := db.Query(`
-        SELECT t.id, t.name, t.type, t.vehicle_number, t.capacity, t.unit_id, IFNULL(u.name, ''), IFNULL(u.symbol, '')
-        FROM transport t
-        LEFT JOIN units u ON t.unit_id = u.id
-        ORDER BY t.name
-    `)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var transport []Transport
-	for rows.Next() {
-		var t Transport
-		if err := rows.Scan(&t.ID, &t.Name, &t.Type, &t.VehicleNumber, &t.Capacity, &t.UnitID, &t.UnitName, &t.UnitSymbol); err != nil {
-			return nil, err
-		}
-		transport = append(transport, t)
-	}
-	return transport, nil
+var equipmentListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":                  intField,
+		"name":                textField,
+		"category_id":         intField,
+		"warehouse_id":        intField,
+		"unit_id":             intField,
+		"quantity":            floatField,
+		"serial_number":       textField,
+		"inventory_number":    textField,
+		"tracking":            textField,
+		"service_life_months": intField,
+		"status":              textField,
+		"purchase_date":       dateField,
+		"purchase_cost":       floatField,
+		"written_off_date":    dateField,
+		"created_at":          dateField,
+	},
+	DefaultSort: "-created_at,-id",
 }
 
-func getOreBatches(db *sql.DB) http.HandlerFunc {
+func getEquipment(store *Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := db.Query(`
-            SELECT ob.id, ob.batch_code, ob.ore_type_id, ot.name, ob.warehouse_id, w.name,
-                   ob.unit_id, u.name, u.symbol, ob.quantity, IFNULL(ob.quality, 0), IFNULL(ob.priority, ''),
-                   IFNULL(ob.extraction_date, ''), IFNULL(ob.status, ''), IFNULL(ob.created_at, '')
-            FROM ore_batches ob
-            JOIN ore_types ot ON ob.ore_type_id = ot.id
-            JOIN warehouses w ON ob.warehouse_id = w.id
-            JOIN units u ON ob.unit_id = u.id
-            ORDER BY ob.created_at DESC, ob.id DESC
-        `)
+		q, err := parseListQuery(r, equipmentListSpec)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
-		defer rows.Close()
-
-		var batches []OreBatch
-		for rows.Next() {
-			var ob OreBatch
-			if err := rows.Scan(&ob.ID, &ob.BatchCode, &ob.OreTypeID, &ob.OreTypeName, &ob.WarehouseID, &ob.WarehouseName,
-				&ob.UnitID, &ob.UnitName, &ob.UnitSymbol, &ob.Quantity, &ob.Quality, &ob.Priority,
-				&ob.ExtractionDate, &ob.Status, &ob.CreatedAt); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			batches = append(batches, ob)
+		items, total, err := store.Equipment.List(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(batches)
+		writeJSON(w, r, ListPage{Items: items, Total: total, NextCursor: q.nextCursor(items, total)})
 	}
 }
 
-func addOreBatch(db *sql.DB) http.HandlerFunc {
-	type request struct {
-		OreTypeID      int      `json:"ore_type_id"`
-		WarehouseID    int      `json:"warehouse_id"`
-		UnitID         int      `json:"unit_id"`
-		BatchCode      string   `json:"batch_code"`
-		Quantity       float64  `json:"quantity"`
-		Quality        *float64 `json:"quality"`
-		Priority       string   `json:"priority"`
-		ExtractionDate string   `json:"extraction_date"`
-		Status         string   `json:"status"`
-	}
+func addEquipment(store *Store, business BusinessConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req request
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		var req NewEquipment
+		if !decodeJSON(w, r, &req) {
 			return
 		}
-		if req.OreTypeID == 0 || req.WarehouseID == 0 || req.UnitID == 0 || req.Quantity <= 0 {
-			http.Error(w, "Отсутствуют обязательные поля", http.StatusBadRequest)
+		if req.UnitID == 0 {
+			req.UnitID = business.DefaultUnitID
+		}
+		if req.Quantity == 0 && (req.Tracking == trackingSerial || req.Tracking == "" && req.SerialNumber != "") {
+			req.Quantity = 1
+		}
+		if err := validateRequest(r.Context(), store.Reference, req); err != nil {
+			writeError(w, r, err)
 			return
 		}
-		now := time.Now().Format(time.RFC3339)
-		_, err := db.Exec(`
-            INSERT INTO ore_batches (ore_type_id, warehouse_id, unit_id, batch_code, quantity, quality, priority, extraction_date, status, created_at, updated_at)
-            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-        `, req.OreTypeID, req.WarehouseID, req.UnitID, req.BatchCode, req.Quantity, req.Quality, req.Priority, req.ExtractionDate, req.Status, now, now)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		items := []NewEquipment{req}
+		if err := prepareEquipment(r.Context(), store, items, func(_ int, name string) string { return name }); err != nil {
+			writeError(w, r, err)
 			return
 		}
-		logAction(db, "system", "Добавление партии руды", "ore_batches", fmt.Sprintf("Партия %s", req.BatchCode))
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"message": "Партия руды добавлена"})
+		if _, err := store.Equipment.Create(r.Context(), items[0]); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Добавление оборудования", "equipment", req.Name)
+		writeJSON(w, r, map[string]string{"message": "Единица оборудования добавлена"})
 	}
 }
 
-func getEquipment(db *sql.DB) http.HandlerFunc {
+var orderListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":             intField,
+		"order_number":   textField,
+		"contractor_id":  intField,
+		"warehouse_id":   intField,
+		"status":         textField,
+		"order_date":     dateField,
+		"total_quantity": floatField,
+		"currency":       textField,
+		"created_at":     dateField,
+	},
+	DefaultSort: "-order_date,-id",
+}
+
+func getOrders(store *Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := db.Query(`
-            SELECT e.id, e.name, e.category_id, c.name, e.warehouse_id, w.name, e.unit_id, u.name, u.symbol,
-                   e.quantity, IFNULL(e.serial_number, ''), IFNULL(e.service_life_months, 0), IFNULL(e.status, ''),
-                   IFNULL(e.purchase_date, ''), IFNULL(e.created_at, '')
-            FROM equipment e
-            JOIN equipment_categories c ON e.category_id = c.id
-            JOIN warehouses w ON e.warehouse_id = w.id
-            JOIN units u ON e.unit_id = u.id
-            ORDER BY e.created_at DESC, e.id DESC
-        `)
+		q, err := parseListQuery(r, orderListSpec)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
-		defer rows.Close()
-
-		var items []Equipment
-		for rows.Next() {
-			var eq Equipment
-			if err := rows.Scan(&eq.ID, &eq.Name, &eq.CategoryID, &eq.CategoryName, &eq.WarehouseID, &eq.WarehouseName,
-				&eq.UnitID, &eq.UnitName, &eq.UnitSymbol, &eq.Quantity, &eq.SerialNumber, &eq.ServiceLife,
-				&eq.Status, &eq.PurchaseDate, &eq.CreatedAt); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			items = append(items, eq)
+		orders, total, err := store.Orders.List(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(items)
+		writeJSON(w, r, ListPage{Items: orders, Total: total, NextCursor: q.nextCursor(orders, total)})
 	}
 }
 
-func addEquipment(db *sql.DB) http.HandlerFunc {
-	type request struct {
-		Name         string  `json:"name"`
-		CategoryID   int     `json:"category_id"`
-		WarehouseID  int     `json:"warehouse_id"`
-		UnitID       int     `json:"unit_id"`
-		Quantity     float64 `json:"quantity"`
-		SerialNumber string  `json:"serial_number"`
-		ServiceLife  *int    `json:"service_life_months"`
-		Status       string  `json:"status"`
-		PurchaseDate string  `json:"purchase_date"`
-	}
+func addOrder(store *Store, business BusinessConfig, events *eventBus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req request
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		var req NewOrder
+		if !decodeJSON(w, r, &req) {
 			return
 		}
-		if req.Name == "" || req.CategoryID == 0 || req.WarehouseID == 0 || req.UnitID == 0 || req.Quantity <= 0 {
-			http.Error(w, "Отсутствуют обязательные поля", http.StatusBadRequest)
+		if err := validateRequest(r.Context(), store.Reference, req); err != nil {
+			writeError(w, r, err)
 			return
 		}
-		now := time.Now().Format(time.RFC3339)
-		_, err := db.Exec(`
-            INSERT INTO equipment (name, category_id, warehouse_id, unit_id, quantity, serial_number, service_life_months, status, purchase_date, created_at, updated_at)
-            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-        `, req.Name, req.CategoryID, req.WarehouseID, req.UnitID, req.Quantity, req.SerialNumber, req.ServiceLife, req.Status, req.PurchaseDate, now, now)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		if req.Currency == "" {
+			req.Currency = business.Currency
 		}
-		logAction(db, "system", "Добавление оборудования", "equipment", req.Name)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"message": "Единица оборудования добавлена"})
-	}
-}
-
-func getOrders(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ordersRows, err := db.Query(`
-            SELECT o.id, o.order_number, o.contractor_id, c.name, o.warehouse_id, w.name, IFNULL(o.status, ''), IFNULL(o.order_date, ''), IFNULL(o.total_quantity, 0)
-            FROM sales_orders o
-            JOIN contractors c ON o.contractor_id = c.id
-            JOIN warehouses w ON o.warehouse_id = w.id
-            ORDER BY o.order_date DESC, o.id DESC
-        `)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := priceOrder(r.Context(), store, &req); err != nil {
+			writeError(w, r, err)
 			return
 		}
-		defer ordersRows.Close()
-
-		ordersMap := make(map[int]*SalesOrder)
-		var orderIDs []int
-		for ordersRows.Next() {
-			var o SalesOrder
-			if err := ordersRows.Scan(&o.ID, &o.OrderNumber, &o.ContractorID, &o.ContractorName, &o.WarehouseID, &o.WarehouseName, &o.Status, &o.OrderDate, &o.TotalQuantity); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			o.Items = []SalesOrderItem{}
-			ordersMap[o.ID] = &o
-			orderIDs = append(orderIDs, o.ID)
-		}
-
-		if len(orderIDs) > 0 {
-			query := `
-                SELECT i.id, i.order_id, i.ore_batch_id, IFNULL(ob.batch_code, ''), i.unit_id, u.name, u.symbol, i.quantity, IFNULL(i.price_per_unit, 0)
-                FROM sales_order_items i
-                LEFT JOIN ore_batches ob ON i.ore_batch_id = ob.id
-                LEFT JOIN units u ON i.unit_id = u.id
-                WHERE i.order_id IN (` + placeholders(len(orderIDs)) + `)
-                ORDER BY i.id
-            `
-			args := make([]interface{}, len(orderIDs))
-			for i, id := range orderIDs {
-				args[i] = id
-			}
-			rows, err := db.Query(query, args...)
+		req.VATRate = business.VATRate
+		if req.Status == orderConfirmed {
+			rate, err := confirmationRate(r.Context(), store, business, req.Currency)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeError(w, r, err)
 				return
 			}
-			defer rows.Close()
-			for rows.Next() {
-				var item SalesOrderItem
-				if err := rows.Scan(&item.ID, &item.OrderID, &item.OreBatchID, &item.OreBatchName, &item.UnitID, &item.UnitName, &item.UnitSymbol, &item.Quantity, &item.PricePerUnit); err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
-				}
-				if order, ok := ordersMap[item.OrderID]; ok {
-					order.Items = append(order.Items, item)
-				}
-			}
+			req.Rate = rate
 		}
-
-		orders := make([]SalesOrder, 0, len(ordersMap))
-		for _, order := range ordersMap {
-			orders = append(orders, *order)
+		id, err := store.Orders.Create(r.Context(), req)
+		if err != nil {
+			writeError(w, r, err)
+			return
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(orders)
+		publishOrder(r.Context(), store, events, "order.created", id)
+		logAction(store.Logs, "system", "Создание заказа", "sales_orders", req.OrderNumber)
+		logPriceOverrides(store, req)
+		writeJSON(w, r, MessageResponse{Message: "Заказ создан", ID: id})
 	}
 }
 
-func addOrder(db *sql.DB) http.HandlerFunc {
-	type itemRequest struct {
-		OreBatchID   int     `json:"ore_batch_id"`
-		UnitID       int     `json:"unit_id"`
-		Quantity     float64 `json:"quantity"`
-		PricePerUnit float64 `json:"price_per_unit"`
-	}
-	type request struct {
-		OrderNumber  string        `json:"order_number"`
-		ContractorID int           `json:"contractor_id"`
-		WarehouseID  int           `json:"warehouse_id"`
-		Status       string        `json:"status"`
-		OrderDate    string        `json:"order_date"`
-		Items        []itemRequest `json:"items"`
-	}
+func updateOrderStatus(store *Store, business BusinessConfig, events *eventBus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req request
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		if req.OrderNumber == "" || req.ContractorID == 0 || req.WarehouseID == 0 || len(req.Items) == 0 {
-			http.Error(w, "Заполните обязательные поля", http.StatusBadRequest)
-			return
-		}
-
-		tx, err := db.Begin()
+		vars := mux.Vars(r)
+		orderID, err := strconv.Atoi(vars["id"])
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, ErrNotFound)
 			return
 		}
-		now := time.Now().Format(time.RFC3339)
-		res, err := tx.Exec(`
-            INSERT INTO sales_orders (order_number, contractor_id, warehouse_id, status, order_date, total_quantity, created_at, updated_at)
-            VALUES (?, ?, ?, ?, ?, 0, ?, ?)
-        `, req.OrderNumber, req.ContractorID, req.WarehouseID, req.Status, req.OrderDate, now, now)
-		if err != nil {
-			tx.Rollback()
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		var req OrderStatusUpdate
+		if !decodeJSON(w, r, &req) {
 			return
 		}
-		orderID, err := res.LastInsertId()
-		if err != nil {
-			tx.Rollback()
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := validateRequest(r.Context(), store.Reference, req); err != nil {
+			writeError(w, r, err)
 			return
 		}
-
-		total := 0.0
-		for _, item := range req.Items {
-			if item.OreBatchID == 0 || item.UnitID == 0 || item.Quantity <= 0 {
-				tx.Rollback()
-				http.Error(w, "Некорректные строки заказа", http.StatusBadRequest)
+		if req.Status == orderConfirmed {
+			orders, _, err := store.Orders.List(r.Context(), byID(orderID))
+			if err != nil {
+				writeError(w, r, err)
 				return
 			}
-			total += item.Quantity
-			if _, err := tx.Exec(`
-                INSERT INTO sales_order_items (order_id, ore_batch_id, unit_id, quantity, price_per_unit, created_at, updated_at)
-                VALUES (?, ?, ?, ?, ?, ?, ?)
-            `, orderID, item.OreBatchID, item.UnitID, item.Quantity, item.PricePerUnit, now, now); err != nil {
-				tx.Rollback()
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+			if len(orders) == 0 {
+				writeError(w, r, ErrNotFound)
+				return
+			}
+			if req.Rate, err = confirmationRate(r.Context(), store, business, orders[0].Currency); err != nil {
+				writeError(w, r, err)
 				return
 			}
 		}
-		if _, err := tx.Exec("UPDATE sales_orders SET total_quantity = ?, updated_at = ? WHERE id = ?", total, now, orderID); err != nil {
-			tx.Rollback()
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if err := tx.Commit(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := store.Orders.UpdateStatus(r.Context(), orderID, req); err != nil {
+			writeError(w, r, err)
 			return
 		}
-		logAction(db, "system", "Создание заказа", "sales_orders", req.OrderNumber)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"message": "Заказ создан"})
+		publishOrder(r.Context(), store, events, "order.updated", orderID)
+		logAction(store.Logs, "system", "Обновление статуса заказа", "sales_orders", fmt.Sprintf("ID %d -> %s", orderID, req.Status))
+		writeJSON(w, r, map[string]string{"message": "Статус обновлён"})
 	}
 }
 
-func updateOrderStatus(db *sql.DB) http.HandlerFunc {
-	type request struct {
-		Status string `json:"status"`
-	}
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		orderID := vars["id"]
-		var req request
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		if req.Status == "" {
-			http.Error(w, "Укажите новый статус", http.StatusBadRequest)
-			return
-		}
-		now := time.Now().Format(time.RFC3339)
-		res, err := db.Exec("UPDATE sales_orders SET status = ?, updated_at = ? WHERE id = ?", req.Status, now, orderID)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		affected, _ := res.RowsAffected()
-		if affected == 0 {
-			http.Error(w, "Заказ не найден", http.StatusNotFound)
-			return
-		}
-		logAction(db, "system", "Обновление статуса заказа", "sales_orders", fmt.Sprintf("ID %s -> %s", orderID, req.Status))
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"message": "Статус обновлён"})
-	}
+var shipmentListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":           intField,
+		"order_id":     intField,
+		"warehouse_id": intField,
+		"transport_id": intField,
+		"status":       textField,
+		"planned_date": dateField,
+		"actual_date":  dateField,
+		"created_at":   dateField,
+	},
+	DefaultSort: "-created_at,-id",
 }
 
-func getShipments(db *sql.DB) http.HandlerFunc {
+func getShipments(store *Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := db.Query(`
-            SELECT s.id, s.order_id, o.order_number, IFNULL(s.transport_id, 0), IFNULL(t.name, ''),
-                   IFNULL(s.planned_date, ''), IFNULL(s.actual_date, ''), IFNULL(s.status, ''), IFNULL(s.created_at, '')
-            FROM shipments s
-            JOIN sales_orders o ON s.order_id = o.id
-            LEFT JOIN transport t ON s.transport_id = t.id
-            ORDER BY s.created_at DESC, s.id DESC
-        `)
+		q, err := parseListQuery(r, shipmentListSpec)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
-		defer rows.Close()
-
-		var shipments []Shipment
-		for rows.Next() {
-			var s Shipment
-			if err := rows.Scan(&s.ID, &s.OrderID, &s.OrderNumber, &s.TransportID, &s.TransportName, &s.PlannedDate, &s.ActualDate, &s.Status, &s.CreatedAt); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			shipments = append(shipments, s)
+		shipments, total, err := store.Shipments.List(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(shipments)
+		writeJSON(w, r, ListPage{Items: shipments, Total: total, NextCursor: q.nextCursor(shipments, total)})
 	}
 }
 
-func addShipment(db *sql.DB) http.HandlerFunc {
-	type request struct {
-		OrderID     int    `json:"order_id"`
-		TransportID int    `json:"transport_id"`
-		PlannedDate string `json:"planned_date"`
-		ActualDate  string `json:"actual_date"`
-		Status      string `json:"status"`
-	}
+func addShipment(store *Store, events *eventBus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req request
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		var req NewShipment
+		if !decodeJSON(w, r, &req) {
 			return
 		}
-		if req.OrderID == 0 {
-			http.Error(w, "Не указан заказ", http.StatusBadRequest)
+		if err := validateRequest(r.Context(), store.Reference, req); err != nil {
+			writeError(w, r, err)
 			return
 		}
-		now := time.Now().Format(time.RFC3339)
-		_, err := db.Exec(`
-            INSERT INTO shipments (order_id, transport_id, planned_date, actual_date, status, created_at, updated_at)
-            VALUES (?, ?, ?, ?, ?, ?, ?)
-        `, req.OrderID, nullableInt(req.TransportID), req.PlannedDate, req.ActualDate, req.Status, now, now)
+		id, err := store.Shipments.Create(r.Context(), req)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
-		logAction(db, "system", "Создание отгрузки", "shipments", fmt.Sprintf("Заказ %d", req.OrderID))
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"message": "Отгрузка создана"})
+		publishShipment(r.Context(), store, events, "shipment.created", id)
+		logAction(store.Logs, "system", "Создание отгрузки", "shipments", fmt.Sprintf("Заказ %d", req.OrderID))
+		writeJSON(w, r, map[string]string{"message": "Отгрузка создана"})
 	}
 }
 
-func nullableInt(value int) interface{} {
-	if value == 0 {
-		return nil
-	}
-	return value
-}
-
-func getLogs(db *sql.DB) http.HandlerFunc {
+func updateShipmentStatus(store *Store, events *eventBus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := db.Query("SELECT id, IFNULL(event_time, ''), IFNULL(user, ''), IFNULL(action, ''), IFNULL(entity, ''), IFNULL(details, '') FROM logs ORDER BY event_time DESC, id DESC LIMIT 100")
+		shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, ErrNotFound)
 			return
 		}
-		defer rows.Close()
-		var logs []LogEntry
-		for rows.Next() {
-			var logEntry LogEntry
-			if err := rows.Scan(&logEntry.ID, &logEntry.EventTime, &logEntry.User, &logEntry.Action, &logEntry.Entity, &logEntry.Details); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			logs = append(logs, logEntry)
+		var req ShipmentStatusUpdate
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(r.Context(), store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(logs)
+		if err := store.Shipments.UpdateStatus(r.Context(), shipmentID, req.Status); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		publishShipment(r.Context(), store, events, "shipment.updated", shipmentID)
+		logAction(store.Logs, "system", "Обновление статуса отгрузки", "shipments", fmt.Sprintf("ID %d -> %s", shipmentID, req.Status))
+		writeJSON(w, r, map[string]string{"message": "Статус обновлён"})
 	}
 }
 
-func logAction(db *sql.DB, user, action, entity, details string) {
+func logAction(logs LogStore, user, action, entity, details string) {
 	if user == "" {
 		user = "system"
 	}
-	now := time.Now().Format(time.RFC3339)
-	if _, err := db.Exec("INSERT INTO logs (event_time, user, action, entity, details) VALUES (?, ?, ?, ?, ?)", now, user, action, entity, details); err != nil {
-		log.Printf("failed to insert log: %v", err)
-	}
-}
-
-func placeholders(n int) string {
-	if n <= 0 {
-		return ""
-	}
-	result := "?"
-	for i := 1; i < n; i++ {
-		result += ",?"
+	entry := LogEntry{User: user, Action: action, Entity: entity, Details: details}
+	if err := logs.Add(context.Background(), entry); err != nil {
+		slog.Error("failed to insert log", "err", err)
 	}
-	return result
 }
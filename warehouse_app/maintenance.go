@@ -0,0 +1,541 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	equipmentInService   = "В эксплуатации"
+	equipmentMaintenance = "На обслуживании"
+	equipmentWrittenOff  = "Списано"
+)
+
+const (
+	workOrderOpen       = "Открыт"
+	workOrderInProgress = "В работе"
+	workOrderDone       = "Выполнен"
+)
+
+// workOrderNext lists the statuses a work order may move to.
+var workOrderNext = map[string][]string{
+	workOrderOpen:       {workOrderInProgress, workOrderDone},
+	workOrderInProgress: {workOrderDone},
+}
+
+// MaintenancePlan is a recurring service of one item, due every
+// IntervalDays, every IntervalHours of engine time, or whichever comes first.
+type MaintenancePlan struct {
+	ID            int      `json:"id"`
+	EquipmentID   int      `json:"equipment_id"`
+	EquipmentName string   `json:"equipment_name"`
+	WarehouseID   int      `json:"warehouse_id"`
+	Name          string   `json:"name"`
+	IntervalDays  *int     `json:"interval_days"`
+	IntervalHours *float64 `json:"interval_hours"`
+	StartDate     string   `json:"start_date"`
+	StartHours    *float64 `json:"start_hours"`
+	LastDoneDate  string   `json:"last_done_date"`
+	LastDoneHours *float64 `json:"last_done_hours"`
+	NextDueDate   string   `json:"next_due_date,omitempty"`
+	NextDueHours  *float64 `json:"next_due_hours,omitempty"`
+	// CurrentHours is the latest engine hours reading of the item.
+	CurrentHours *float64 `json:"current_hours"`
+	CreatedAt    string   `json:"created_at"`
+}
+
+// setDue counts the next service from the last one, or from the start of
+// the plan if it has never been done.
+func (p *MaintenancePlan) setDue() {
+	if p.IntervalDays != nil {
+		from := p.LastDoneDate
+		if from == "" {
+			from = p.StartDate
+		}
+		if t, ok := parseDate(from); ok {
+			p.NextDueDate = t.AddDate(0, 0, *p.IntervalDays).Format("2006-01-02")
+		}
+	}
+	if p.IntervalHours != nil {
+		from := 0.0
+		if p.LastDoneHours != nil {
+			from = *p.LastDoneHours
+		} else if p.StartHours != nil {
+			from = *p.StartHours
+		}
+		due := from + *p.IntervalHours
+		p.NextDueHours = &due
+	}
+}
+
+type WorkOrder struct {
+	ID            int              `json:"id"`
+	EquipmentID   int              `json:"equipment_id"`
+	EquipmentName string           `json:"equipment_name"`
+	WarehouseID   int              `json:"warehouse_id"`
+	PlanID        int              `json:"plan_id,omitempty"`
+	PlanName      string           `json:"plan_name,omitempty"`
+	Title         string           `json:"title"`
+	Description   string           `json:"description"`
+	Status        string           `json:"status"`
+	PlannedDate   string           `json:"planned_date"`
+	StartedAt     string           `json:"started_at"`
+	CompletedAt   string           `json:"completed_at"`
+	MeterHours    *float64         `json:"meter_hours"`
+	PartsCost     Decimal          `json:"parts_cost"`
+	LaborHours    float64          `json:"labor_hours"`
+	CreatedAt     string           `json:"created_at"`
+	Parts         []WorkOrderPart  `json:"parts,omitempty"`
+	Labor         []WorkOrderLabor `json:"labor,omitempty"`
+}
+
+type WorkOrderPart struct {
+	ID int `json:"id"`
+	// PartID and WarehouseID are set for parts taken from stock.
+	PartID      int      `json:"part_id,omitempty"`
+	WarehouseID int      `json:"warehouse_id,omitempty"`
+	Name        string   `json:"name"`
+	UnitID      int      `json:"unit_id"`
+	UnitSymbol  string   `json:"unit_symbol"`
+	Quantity    float64  `json:"quantity"`
+	UnitCost    *Decimal `json:"unit_cost"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+type WorkOrderLabor struct {
+	ID        int     `json:"id"`
+	Worker    string  `json:"worker"`
+	Hours     float64 `json:"hours"`
+	WorkDate  string  `json:"work_date"`
+	Note      string  `json:"note"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// MaintenanceDue is a plan whose next service is due. DaysOverdue and
+// HoursOverdue are negative for services due soon; HoursOverdue is set for
+// plans with an hours interval once the item has a reading.
+type MaintenanceDue struct {
+	Plan            MaintenancePlan `json:"plan"`
+	DaysOverdue     int             `json:"days_overdue"`
+	HoursOverdue    *float64        `json:"hours_overdue,omitempty"`
+	OpenWorkOrderID int             `json:"open_work_order_id,omitempty"`
+}
+
+var maintenancePlanListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":           intField,
+		"equipment_id": intField,
+		"warehouse_id": intField,
+		"name":         textField,
+		"created_at":   dateField,
+	},
+	DefaultSort: "id",
+}
+
+var workOrderListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":           intField,
+		"equipment_id": intField,
+		"warehouse_id": intField,
+		"plan_id":      intField,
+		"status":       textField,
+		"planned_date": dateField,
+		"completed_at": dateField,
+		"created_at":   dateField,
+	},
+	DefaultSort: "-created_at,-id",
+}
+
+func getMaintenancePlans(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r, maintenancePlanListSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		plans, total, err := store.Maintenance.Plans(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, ListPage{Items: plans, Total: total, NextCursor: q.nextCursor(plans, total)})
+	}
+}
+
+func addMaintenancePlan(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req NewMaintenancePlan
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(r.Context(), store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if req.IntervalDays == nil && req.IntervalHours == nil {
+			writeError(w, r, validationError(fieldError("interval_days", "required"), fieldError("interval_hours", "required")))
+			return
+		}
+		if req.StartDate == "" {
+			req.StartDate = time.Now().Format("2006-01-02")
+		}
+		if req.IntervalHours != nil && req.StartHours == nil {
+			hours, err := currentHours(r.Context(), store, req.EquipmentID, timestamp())
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			req.StartHours = hours
+		}
+		id, err := store.Maintenance.CreatePlan(r.Context(), req)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Добавление плана обслуживания", "maintenance_plans", fmt.Sprintf("План %d: %s", id, req.Name))
+		writeJSON(w, r, MessageResponse{Message: "План обслуживания добавлен", ID: id})
+	}
+}
+
+func deleteMaintenancePlan(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, ErrNotFound)
+			return
+		}
+		if err := store.Maintenance.DeletePlan(r.Context(), id); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Удаление плана обслуживания", "maintenance_plans", fmt.Sprintf("План %d", id))
+		writeJSON(w, r, MessageResponse{Message: "План обслуживания удален"})
+	}
+}
+
+func getWorkOrders(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r, workOrderListSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		orders, total, err := store.Maintenance.WorkOrders(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, ListPage{Items: orders, Total: total, NextCursor: q.nextCursor(orders, total)})
+	}
+}
+
+func getWorkOrder(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, ErrNotFound)
+			return
+		}
+		wo, err := store.Maintenance.WorkOrder(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, wo)
+	}
+}
+
+func addWorkOrder(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req NewWorkOrder
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		items, _, err := store.Equipment.List(ctx, byID(req.EquipmentID))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if len(items) > 0 && items[0].Status == equipmentWrittenOff {
+			writeError(w, r, newAPIError(http.StatusConflict, "equipment_written_off", items[0].Name))
+			return
+		}
+		if req.PlanID != 0 {
+			plans, _, err := store.Maintenance.Plans(ctx, byID(req.PlanID))
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			if len(plans) > 0 && plans[0].EquipmentID != req.EquipmentID {
+				writeError(w, r, validationError(fieldError("plan_id", "other_equipment")))
+				return
+			}
+		}
+		id, err := store.Maintenance.CreateWorkOrder(ctx, req)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Открытие наряда на обслуживание", "work_orders", fmt.Sprintf("Наряд %d: %s", id, req.Title))
+		writeJSON(w, r, MessageResponse{Message: "Наряд открыт", ID: id})
+	}
+}
+
+// openWorkOrder loads a work order for a change, failing with 409 once it is
+// done.
+func openWorkOrder(ctx context.Context, store *Store, r *http.Request) (WorkOrder, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return WorkOrder{}, ErrNotFound
+	}
+	wo, err := store.Maintenance.WorkOrder(ctx, id)
+	if err != nil {
+		return wo, err
+	}
+	if wo.Status == workOrderDone {
+		return wo, newAPIError(http.StatusConflict, "work_order_closed", wo.ID)
+	}
+	return wo, nil
+}
+
+func updateWorkOrderStatus(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req WorkOrderStatusUpdate
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		wo, err := openWorkOrder(ctx, store, r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if req.Date == "" {
+			req.Date = timestamp()
+		}
+		if req.Status == workOrderDone && req.MeterHours == nil {
+			if req.MeterHours, err = currentHours(ctx, store, wo.EquipmentID, req.Date); err != nil {
+				writeError(w, r, err)
+				return
+			}
+		}
+		if err := store.Maintenance.UpdateWorkOrderStatus(ctx, wo.ID, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Обновление статуса наряда", "work_orders", fmt.Sprintf("Наряд %d: %s", wo.ID, req.Status))
+		writeJSON(w, r, MessageResponse{Message: "Статус наряда обновлен"})
+	}
+}
+
+func addWorkOrderPart(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req NewWorkOrderPart
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		wo, err := openWorkOrder(ctx, store, r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if req.PartID != 0 {
+			parts, _, err := store.Parts.List(ctx, byID(req.PartID))
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			part := parts[0]
+			if req.Name == "" {
+				req.Name = part.Name
+			}
+			if req.UnitID == 0 {
+				req.UnitID = part.UnitID
+			} else if req.UnitID != part.UnitID {
+				// The quantity is taken off stock as it is, so it must be in
+				// the unit the part is counted in.
+				writeError(w, r, validationError(fieldError("unit_id", "part_unit", part.UnitSymbol)))
+				return
+			}
+			if req.UnitCost == nil {
+				req.UnitCost = part.UnitCost
+			}
+			if req.WarehouseID == 0 {
+				req.WarehouseID = wo.WarehouseID
+			}
+		} else {
+			var fields []FieldError
+			if req.Name == "" {
+				fields = append(fields, fieldError("name", "required"))
+			}
+			if req.UnitID == 0 {
+				fields = append(fields, fieldError("unit_id", "required"))
+			}
+			if len(fields) > 0 {
+				writeError(w, r, validationError(fields...))
+				return
+			}
+		}
+		if _, err := store.Maintenance.AddPart(ctx, wo.ID, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Списание запчастей по наряду", "work_orders", fmt.Sprintf("Наряд %d: %s, %g", wo.ID, req.Name, req.Quantity))
+		writeJSON(w, r, MessageResponse{Message: "Запчасти добавлены в наряд"})
+	}
+}
+
+func addWorkOrderLabor(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req NewWorkOrderLabor
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		wo, err := openWorkOrder(ctx, store, r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if _, err := store.Maintenance.AddLabor(ctx, wo.ID, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Учет работ по наряду", "work_orders", fmt.Sprintf("Наряд %d: %s, %g ч", wo.ID, req.Worker, req.Hours))
+		writeJSON(w, r, MessageResponse{Message: "Работы добавлены в наряд"})
+	}
+}
+
+var maintenanceDueParams = []apiParam{
+	{Name: "warehouse_id", Type: "integer"},
+	{Name: "as_of", Type: "string", Description: "дата проверки, YYYY-MM-DD; по умолчанию сегодня"},
+	{Name: "within_days", Type: "integer", Description: "включить обслуживание, которое наступит в ближайшие дни"},
+	{Name: "within_hours", Type: "number", Description: "включить обслуживание, до которого осталось меньше моточасов"},
+}
+
+// getOverdueMaintenance lists the plans whose next service date has passed,
+// most overdue first. Written-off equipment is left out.
+func getOverdueMaintenance(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		query := r.URL.Query()
+		y, m, d := time.Now().Date()
+		asOf := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+		if v := query.Get("as_of"); v != "" {
+			t, ok := parseDate(v)
+			if !ok {
+				writeError(w, r, queryError("as_of", "invalid_date", v))
+				return
+			}
+			asOf = t
+		}
+		within := 0
+		if v := query.Get("within_days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeError(w, r, queryError("within_days", "invalid_value", v))
+				return
+			}
+			within = n
+		}
+		withinHours := 0.0
+		if v := query.Get("within_hours"); v != "" {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil || n < 0 {
+				writeError(w, r, queryError("within_hours", "invalid_value", v))
+				return
+			}
+			withinHours = n
+		}
+		var filters []Filter
+		if v := query.Get("warehouse_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				writeError(w, r, queryError("warehouse_id", "invalid_value", v))
+				return
+			}
+			filters = []Filter{{Field: "warehouse_id", Op: opIn, Values: []interface{}{id}}}
+		}
+
+		due, err := maintenanceDue(ctx, store, filters, asOf, within, withinHours)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, due)
+	}
+}
+
+func maintenanceDue(ctx context.Context, store *Store, filters []Filter, asOf time.Time, within int, withinHours float64) ([]MaintenanceDue, error) {
+	plans, err := listAll(ctx, store.Maintenance.Plans, filters)
+	if err != nil {
+		return nil, err
+	}
+	equipment, err := listAll(ctx, store.Equipment.List, filters)
+	if err != nil {
+		return nil, err
+	}
+	writtenOff := map[int]bool{}
+	for _, e := range equipment {
+		writtenOff[e.ID] = e.Status == equipmentWrittenOff
+	}
+	open, err := listAll(ctx, store.Maintenance.WorkOrders, append(filters,
+		Filter{Field: "status", Op: opIn, Values: []interface{}{workOrderOpen, workOrderInProgress}}))
+	if err != nil {
+		return nil, err
+	}
+	openByPlan := map[int]int{}
+	for _, wo := range open {
+		if wo.PlanID != 0 && openByPlan[wo.PlanID] == 0 {
+			openByPlan[wo.PlanID] = wo.ID
+		}
+	}
+
+	result := []MaintenanceDue{}
+	for _, p := range plans {
+		if writtenOff[p.EquipmentID] {
+			continue
+		}
+		d := MaintenanceDue{Plan: p, OpenWorkOrderID: openByPlan[p.ID]}
+		due := false
+		if next, ok := parseDate(p.NextDueDate); ok {
+			d.DaysOverdue = int(math.Round(asOf.Sub(next).Hours() / 24))
+			due = d.DaysOverdue >= 0 || within > 0 && d.DaysOverdue >= -within
+		}
+		if p.NextDueHours != nil && p.CurrentHours != nil {
+			hours := *p.CurrentHours - *p.NextDueHours
+			d.HoursOverdue = &hours
+			due = due || hours >= 0 || withinHours > 0 && hours >= -withinHours
+		}
+		if due {
+			result = append(result, d)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].DaysOverdue > result[j].DaysOverdue })
+	return result, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMaintenanceDue(t *testing.T) {
+	ctx := context.Background()
+	store := openTestSQLite(t)
+	refs := loadTestRefs(t, store)
+	categories, err := store.Reference.EquipmentCategories(ctx)
+	if err != nil || len(categories) == 0 {
+		t.Fatalf("no equipment categories: %v", err)
+	}
+	asOf := time.Date(2026, 6, 30, 0, 0, 0, 0, time.Local)
+	interval := 30
+
+	tests := []struct {
+		name    string
+		nextDue string
+		within  int
+		want    bool
+	}{
+		{"overdue", "2026-06-29", 0, true},
+		{"due today", "2026-06-30", 0, true},
+		{"due tomorrow", "2026-07-01", 0, false},
+		{"due tomorrow within a week", "2026-07-01", 7, true},
+		{"due in a fortnight within a week", "2026-07-14", 7, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			equipment, err := store.Equipment.Create(ctx, NewEquipment{Name: "Экскаватор " + tt.name, CategoryID: categories[0].ID,
+				WarehouseID: refs.warehouses[0], UnitID: refs.unit, Quantity: 1, Status: equipmentInService})
+			if err != nil {
+				t.Fatal(err)
+			}
+			next, _ := time.Parse("2006-01-02", tt.nextDue)
+			plan, err := store.Maintenance.CreatePlan(ctx, NewMaintenancePlan{EquipmentID: equipment, Name: "ТО-1",
+				IntervalDays: &interval, StartDate: next.AddDate(0, 0, -interval).Format("2006-01-02")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			filters := []Filter{{Field: "equipment_id", Op: opIn, Values: []interface{}{equipment}}}
+			due, err := maintenanceDue(ctx, store, filters, asOf, tt.within, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(due) == 1 && due[0].Plan.ID == plan; got != tt.want {
+				t.Errorf("due %+v, want the plan listed: %v", due, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkOrderPartUnit(t *testing.T) {
+	ctx := context.Background()
+	store := openTestSQLite(t)
+	refs := loadTestRefs(t, store)
+	cfg := defaultConfig()
+	events := newEventBus()
+	defer events.close()
+	router := mux.NewRouter()
+	registerRoutes(router, apiRoutes(store, cfg, events), cfg.API)
+
+	units, err := store.Reference.Units(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := 0
+	for _, u := range units {
+		if u.ID != refs.unit {
+			other = u.ID
+			break
+		}
+	}
+	categories, err := store.Reference.EquipmentCategories(ctx)
+	if err != nil || len(categories) == 0 || other == 0 {
+		t.Fatalf("no equipment categories or second unit: %v", err)
+	}
+	equipment, err := store.Equipment.Create(ctx, NewEquipment{Name: "Самосвал", CategoryID: categories[0].ID,
+		WarehouseID: refs.warehouses[0], UnitID: refs.unit, Quantity: 1, Status: equipmentInService})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workOrder, err := store.Maintenance.CreateWorkOrder(ctx, NewWorkOrder{EquipmentID: equipment, Title: "Замена ремня"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := store.Parts.Create(ctx, NewSparePart{Name: "Ремень", UnitID: refs.unit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := 10.0
+	if _, err := store.Parts.Move(ctx, NewPartMovement{PartID: part, WarehouseID: refs.warehouses[0], Kind: partReceipt, Quantity: &received}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		unit   int
+		status int
+		left   float64
+	}{
+		{"unit of the part", refs.unit, http.StatusOK, 8},
+		{"unit by default", 0, http.StatusOK, 6},
+		{"other unit", other, http.StatusBadRequest, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(NewWorkOrderPart{PartID: part, UnitID: tt.unit, Quantity: 2})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/work-orders/%d/parts", workOrder), bytes.NewReader(body)))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			stock, _, err := store.Parts.Stock(ctx, listQuery(t, partStockListSpec, fmt.Sprintf("part_id=%d", part)))
+			if err != nil || len(stock) != 1 || stock[0].Quantity != tt.left {
+				t.Errorf("stock %+v (%v), want %g left", stock, err, tt.left)
+			}
+		})
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	meterHours   = "Моточасы"
+	meterMileage = "Пробег"
+)
+
+// MeterReading is one reading of an engine hours or mileage (km) meter.
+// Readings of a meter never decrease over time.
+type MeterReading struct {
+	ID            int     `json:"id"`
+	EquipmentID   int     `json:"equipment_id"`
+	EquipmentName string  `json:"equipment_name"`
+	Meter         string  `json:"meter"`
+	Value         float64 `json:"value"`
+	ReadAt        string  `json:"read_at"`
+	Source        string  `json:"source"`
+	CreatedAt     string  `json:"created_at"`
+}
+
+// MeterUsage is the meter of one item at the start and the end of a period;
+// both are nil when the item has no readings by the end of it.
+type MeterUsage struct {
+	EquipmentID   int
+	EquipmentName string
+	WarehouseID   int
+	WarehouseName string
+	CategoryID    int
+	CategoryName  string
+	Status        string
+	Start         *float64
+	End           *float64
+}
+
+func (u MeterUsage) used() (float64, bool) {
+	if u.Start == nil || u.End == nil {
+		return 0, false
+	}
+	return *u.End - *u.Start, true
+}
+
+// UtilizationRow sums the meters of a warehouse, category or single item
+// over a period. Utilization is engine hours as a percentage of calendar
+// time, counted over the items that have engine hour readings.
+type UtilizationRow struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Equipment   int      `json:"equipment"`
+	WithHours   int      `json:"with_hours"`
+	EngineHours float64  `json:"engine_hours"`
+	Mileage     float64  `json:"mileage"`
+	Utilization *float64 `json:"utilization"`
+}
+
+var meterReadingListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":           intField,
+		"equipment_id": intField,
+		"warehouse_id": intField,
+		"category_id":  intField,
+		"meter":        textField,
+		"read_at":      dateField,
+		"created_at":   dateField,
+	},
+	DefaultSort: "-read_at,-id",
+	FilterOnly:  []string{"warehouse_id", "category_id"},
+}
+
+var meterImportColumns = []importColumn{
+	{Field: "equipment_id", Headers: []string{"equipment", "Оборудование", "Серийный номер"}, Ref: "equipment"},
+	{Field: "meter", Headers: []string{"Счетчик", "Счётчик"}},
+	{Field: "value", Headers: []string{"Показание", "Значение"}},
+	{Field: "read_at", Headers: []string{"Дата", "Дата показания"}},
+	{Field: "source", Headers: []string{"Источник"}},
+}
+
+func getMeterReadings(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r, meterReadingListSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		readings, total, err := store.Meters.Readings(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, ListPage{Items: readings, Total: total, NextCursor: q.nextCursor(readings, total)})
+	}
+}
+
+func addMeterReading(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req NewMeterReading
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		readings := []NewMeterReading{req}
+		if err := checkMeterReadings(ctx, store, readings, func(int) string { return "" }); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		id, err := store.Meters.Create(ctx, readings[0])
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Показание счетчика", "meter_readings",
+			fmt.Sprintf("Оборудование %d: %s %g", req.EquipmentID, req.Meter, *req.Value))
+		writeJSON(w, r, MessageResponse{Message: "Показание сохранено", ID: id})
+	}
+}
+
+func deleteMeterReading(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, ErrNotFound)
+			return
+		}
+		if err := store.Meters.Delete(r.Context(), id); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Удаление показания счетчика", "meter_readings", fmt.Sprintf("Показание %d", id))
+		writeJSON(w, r, MessageResponse{Message: "Показание удалено"})
+	}
+}
+
+// checkMeterReadings fills in read_at and makes sure every reading fits
+// between the stored readings of its meter and the other readings in the
+// request. prefix gives the field prefix of the reading with index i.
+func checkMeterReadings(ctx context.Context, store *Store, readings []NewMeterReading, prefix func(i int) string) error {
+	for i := range readings {
+		readings[i].ReadAt = normalizeTimestamp(readings[i].ReadAt)
+	}
+	order := make([]int, len(readings))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		x, y := readings[order[a]], readings[order[b]]
+		if x.EquipmentID != y.EquipmentID {
+			return x.EquipmentID < y.EquipmentID
+		}
+		if x.Meter != y.Meter {
+			return x.Meter < y.Meter
+		}
+		return x.ReadAt < y.ReadAt
+	})
+
+	var fields []FieldError
+	for n, i := range order {
+		m := readings[i]
+		prev, next, err := store.Meters.Around(ctx, m.EquipmentID, m.Meter, m.ReadAt)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			if p := readings[order[n-1]]; p.EquipmentID == m.EquipmentID && p.Meter == m.Meter && (prev == nil || p.ReadAt >= prev.ReadAt) {
+				prev = &MeterReading{Value: *p.Value, ReadAt: p.ReadAt}
+			}
+		}
+		switch {
+		case prev != nil && *m.Value < prev.Value:
+			fields = append(fields, fieldError(prefix(i)+"value", "meter_below_previous", prev.Value, prev.ReadAt))
+		case next != nil && *m.Value > next.Value:
+			fields = append(fields, fieldError(prefix(i)+"value", "meter_above_next", next.Value, next.ReadAt))
+		}
+	}
+	if len(fields) > 0 {
+		return validationError(fields...)
+	}
+	return nil
+}
+
+func importMeterReadings(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req, ok := readImportRequest(w, r)
+		if !ok {
+			return
+		}
+		refs, err := loadImportRefs(ctx, store.Reference)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		equipment, err := listAll(ctx, store.Equipment.List, nil)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		refs["equipment"] = map[string]int{}
+		for _, e := range equipment {
+			refs["equipment"][strconv.Itoa(e.ID)] = e.ID
+			if e.SerialNumber != "" {
+				refs["equipment"][normalizeHeader(e.SerialNumber)] = e.ID
+			}
+		}
+
+		result := ImportResult{DryRun: req.dryRun, Created: []int{}}
+		readings, lines, err := parseImport(req, meterImportColumns, refs, NewMeterReading{Meter: meterHours}, &result)
+		if err == nil {
+			err = checkMeterReadings(ctx, store, readings, func(i int) string { return fmt.Sprintf("rows[%d].", lines[i]) })
+		}
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if req.dryRun {
+			result.Preview = importPreview(readings, lines)
+			writeJSON(w, r, result)
+			return
+		}
+		if result.Created, err = store.Meters.CreateMany(ctx, readings); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Импорт показаний счетчиков", "meter_readings", fmt.Sprintf("Загружено показаний: %d", len(result.Created)))
+		writeJSON(w, r, result)
+	}
+}
+
+var utilizationParams = []apiParam{
+	{Name: "from", Type: "string", Description: "начало периода, YYYY-MM-DD; по умолчанию 30 дней назад"},
+	{Name: "to", Type: "string", Description: "конец периода включительно, YYYY-MM-DD; по умолчанию сегодня"},
+	{Name: "group_by", Type: "string", Enum: []string{"warehouse", "category", "equipment"}},
+	{Name: "warehouse_id", Type: "integer"},
+	{Name: "category_id", Type: "integer"},
+}
+
+// getUtilization reports engine hours and mileage over a period. Written-off
+// items count only if they were used in it.
+func getUtilization(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		y, m, d := time.Now().Date()
+		to := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+		from := to.AddDate(0, 0, -30)
+		for name, t := range map[string]*time.Time{"from": &from, "to": &to} {
+			if v := query.Get(name); v != "" {
+				parsed, ok := parseDate(v)
+				if !ok {
+					writeError(w, r, queryError(name, "invalid_date", v))
+					return
+				}
+				*t = parsed
+			}
+		}
+		to = to.AddDate(0, 0, 1)
+		if !from.Before(to) {
+			writeError(w, r, queryError("to", "invalid_value", query.Get("to")))
+			return
+		}
+		groupBy := query.Get("group_by")
+		if groupBy == "" {
+			groupBy = "warehouse"
+		}
+		if groupBy != "warehouse" && groupBy != "category" && groupBy != "equipment" {
+			writeError(w, r, queryError("group_by", "one_of", "warehouse, category, equipment"))
+			return
+		}
+		only := map[string]int{}
+		for _, name := range []string{"warehouse_id", "category_id"} {
+			if v := query.Get(name); v != "" {
+				id, err := strconv.Atoi(v)
+				if err != nil {
+					writeError(w, r, queryError(name, "invalid_value", v))
+					return
+				}
+				only[name] = id
+			}
+		}
+
+		fromAt, toAt := from.Format(time.RFC3339), to.Format(time.RFC3339)
+		hours, err := store.Meters.Usage(r.Context(), meterHours, fromAt, toAt)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		mileage, err := store.Meters.Usage(r.Context(), meterMileage, fromAt, toAt)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		km := map[int]float64{}
+		for _, u := range mileage {
+			km[u.EquipmentID], _ = u.used()
+		}
+
+		periodHours := to.Sub(from).Hours()
+		groups := map[int]*UtilizationRow{}
+		var rows []*UtilizationRow
+		for _, u := range hours {
+			if id, ok := only["warehouse_id"]; ok && u.WarehouseID != id {
+				continue
+			}
+			if id, ok := only["category_id"]; ok && u.CategoryID != id {
+				continue
+			}
+			used, reported := u.used()
+			if u.Status == equipmentWrittenOff && used == 0 && km[u.EquipmentID] == 0 {
+				continue
+			}
+			id, name := u.WarehouseID, u.WarehouseName
+			switch groupBy {
+			case "category":
+				id, name = u.CategoryID, u.CategoryName
+			case "equipment":
+				id, name = u.EquipmentID, u.EquipmentName
+			}
+			row := groups[id]
+			if row == nil {
+				row = &UtilizationRow{ID: id, Name: name}
+				groups[id] = row
+				rows = append(rows, row)
+			}
+			row.Equipment++
+			row.Mileage += km[u.EquipmentID]
+			if reported {
+				row.WithHours++
+				row.EngineHours += used
+			}
+		}
+		result := make([]UtilizationRow, 0, len(rows))
+		for _, row := range rows {
+			if row.WithHours > 0 {
+				pct := row.EngineHours / (float64(row.WithHours) * periodHours) * 100
+				row.Utilization = &pct
+			}
+			result = append(result, *row)
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+		writeJSON(w, r, result)
+	}
+}
+
+// normalizeTimestamp converts a date or time from a request to the format
+// of timestamp(), so that stored times compare correctly as strings. An
+// empty value becomes now.
+func normalizeTimestamp(v string) string {
+	if v == "" {
+		return timestamp()
+	}
+	if t, ok := parseDate(v); ok {
+		return t.Local().Format(time.RFC3339)
+	}
+	return v
+}
+
+// currentHours returns the engine hours reading of an item at the given
+// time, or nil if it has none.
+func currentHours(ctx context.Context, store *Store, equipmentID int, at string) (*float64, error) {
+	prev, _, err := store.Meters.Around(ctx, equipmentID, meterHours, at)
+	if err != nil || prev == nil {
+		return nil, err
+	}
+	return &prev.Value, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// TestMeterReadingsNeverDecrease checks new readings against the stored
+// 100 h on March 1 and 200 h on March 10, and against each other.
+func TestMeterReadingsNeverDecrease(t *testing.T) {
+	ctx := context.Background()
+	store := openTestSQLite(t)
+	refs := loadTestRefs(t, store)
+	categories, err := store.Reference.EquipmentCategories(ctx)
+	if err != nil || len(categories) == 0 {
+		t.Fatalf("no equipment categories: %v", err)
+	}
+	var equipment [2]int
+	for i := range equipment {
+		equipment[i], err = store.Equipment.Create(ctx, NewEquipment{Name: fmt.Sprint("Бульдозер ", i), CategoryID: categories[0].ID,
+			WarehouseID: refs.warehouses[0], UnitID: refs.unit, Quantity: 1, Status: equipmentInService})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, stored := range []struct {
+		value float64
+		at    string
+	}{{100, "2026-03-01T08:00:00Z"}, {200, "2026-03-10T08:00:00Z"}} {
+		value := stored.value
+		if _, err := store.Meters.Create(ctx, NewMeterReading{EquipmentID: equipment[0], Meter: meterHours, Value: &value, ReadAt: stored.at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type reading struct {
+		equipment int
+		meter     string
+		value     float64
+		at        string
+	}
+	tests := []struct {
+		name     string
+		readings []reading
+		want     []string
+	}{
+		{"after the last", []reading{{0, meterHours, 250, "2026-03-11"}}, nil},
+		{"same as the last", []reading{{0, meterHours, 200, "2026-03-11"}}, nil},
+		{"below the last", []reading{{0, meterHours, 150, "2026-03-11"}}, []string{"readings[0].value meter_below_previous"}},
+		{"in between", []reading{{0, meterHours, 150, "2026-03-05"}}, nil},
+		{"in between above the next", []reading{{0, meterHours, 210, "2026-03-05"}}, []string{"readings[0].value meter_above_next"}},
+		{"in between below the previous", []reading{{0, meterHours, 90, "2026-03-05"}}, []string{"readings[0].value meter_below_previous"}},
+		{"before the first above it", []reading{{0, meterHours, 120, "2026-02-01"}}, []string{"readings[0].value meter_above_next"}},
+		{"other meter", []reading{{0, meterMileage, 5, "2026-03-11"}}, nil},
+		{"other equipment", []reading{{1, meterHours, 5, "2026-03-11"}}, nil},
+		{"batch rising", []reading{{0, meterHours, 260, "2026-03-12"}, {0, meterHours, 230, "2026-03-11"}}, nil},
+		{"batch falling", []reading{{0, meterHours, 230, "2026-03-12"}, {0, meterHours, 260, "2026-03-11"}},
+			[]string{"readings[0].value meter_below_previous"}},
+		{"batch for new equipment", []reading{{1, meterHours, 10, "2026-03-01"}, {1, meterHours, 8, "2026-03-02"}},
+			[]string{"readings[1].value meter_below_previous"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readings := make([]NewMeterReading, len(tt.readings))
+			for i, r := range tt.readings {
+				value := r.value
+				readings[i] = NewMeterReading{EquipmentID: equipment[r.equipment], Meter: r.meter, Value: &value, ReadAt: r.at}
+			}
+			err := checkMeterReadings(ctx, store, readings, func(i int) string { return fmt.Sprintf("readings[%d].", i) })
+			var got []string
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				for _, f := range apiErr.Fields {
+					got = append(got, f.Field+" "+f.Code)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type migrationState struct {
+	migration
+	AppliedAt string
+}
+
+var migrationFilename = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrator applies the scripts in migrations/<dialect>. Each backend keeps
+// its own copy of the schema; versions are shared so status output lines up.
+type migrator struct {
+	db  *sqlDB
+	dir string
+}
+
+func newMigrator(db *sqlDB) *migrator {
+	return &migrator{db: db, dir: "migrations/" + db.dialect.name}
+}
+
+func loadMigrations(files fs.FS, dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*migration)
+	for _, entry := range entries {
+		m := migrationFilename.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(files, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func (m *migrator) ensureTable() error {
+	_, err := m.db.exec(context.Background(), `
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at TEXT NOT NULL
+    )`)
+	return err
+}
+
+func (m *migrator) status() ([]migrationState, error) {
+	migrations, err := loadMigrations(migrationFiles, m.dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.query(context.Background(), "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var at string
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	states := make([]migrationState, len(migrations))
+	for i, mig := range migrations {
+		states[i] = migrationState{migration: mig, AppliedAt: applied[mig.Version]}
+	}
+	return states, nil
+}
+
+// up applies every pending migration in version order, each in its own
+// transaction. With dryRun set it only reports what would be applied.
+func (m *migrator) up(dryRun bool) ([]migration, error) {
+	states, err := m.status()
+	if err != nil {
+		return nil, err
+	}
+	var pending []migration
+	for _, s := range states {
+		if s.AppliedAt == "" {
+			pending = append(pending, s.migration)
+		}
+	}
+	if dryRun {
+		return pending, nil
+	}
+	for i, mig := range pending {
+		if err := m.apply(mig, mig.Up, true); err != nil {
+			return pending[:i], fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+	}
+	return pending, nil
+}
+
+// down reverts the latest steps applied migrations.
+func (m *migrator) down(steps int) ([]migration, error) {
+	states, err := m.status()
+	if err != nil {
+		return nil, err
+	}
+	var reverted []migration
+	for i := len(states) - 1; i >= 0 && len(reverted) < steps; i-- {
+		s := states[i]
+		if s.AppliedAt == "" {
+			continue
+		}
+		if s.Down == "" {
+			return reverted, fmt.Errorf("migration %d_%s has no down script", s.Version, s.Name)
+		}
+		if err := m.apply(s.migration, s.Down, false); err != nil {
+			return reverted, fmt.Errorf("migration %d_%s: %w", s.Version, s.Name, err)
+		}
+		reverted = append(reverted, s.migration)
+	}
+	return reverted, nil
+}
+
+func (m *migrator) apply(mig migration, script string, up bool) error {
+	ctx := context.Background()
+	tx, err := m.db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+	// Scripts run as-is: placeholder rebinding would corrupt literals such as
+	// PostgreSQL's $$ function bodies.
+	if _, err := tx.tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if up {
+		_, err = tx.exec(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", mig.Version, mig.Name, time.Now().Format(time.RFC3339))
+	} else {
+		_, err = tx.exec(ctx, "DELETE FROM schema_migrations WHERE version = ?", mig.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+func runMigrateCommand(args []string) error {
+	cmd := flag.NewFlagSet("migrate", flag.ExitOnError)
+	config := bindConfigFlags(cmd)
+	dryRun := cmd.Bool("dry-run", false, "print pending migrations without applying them")
+	steps := cmd.Int("steps", 1, "number of migrations to revert with down")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: warehouse_app migrate [flags] status|up|down [flags]")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	// Parsing stops at the subcommand; flags may follow it as well.
+	subcommand := cmd.Arg(0)
+	if cmd.NArg() > 0 {
+		cmd.Parse(cmd.Args()[1:])
+	}
+	if cmd.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", cmd.Args())
+	}
+	if *steps < 1 {
+		return fmt.Errorf("-steps must be at least 1")
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	store, err := OpenStore(cfg.Database.Driver, cfg.Database.DSN)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch subcommand {
+	case "", "status":
+		states, err := store.migrator.status()
+		if err != nil {
+			return err
+		}
+		for _, s := range states {
+			applied := "pending"
+			if s.AppliedAt != "" {
+				applied = "applied " + s.AppliedAt
+			}
+			fmt.Printf("%04d %-40s %s\n", s.Version, s.Name, applied)
+		}
+	case "up":
+		applied, err := store.migrator.up(*dryRun)
+		for _, m := range applied {
+			prefix := "applied"
+			if *dryRun {
+				prefix = "pending"
+			}
+			fmt.Printf("%s %04d %s\n", prefix, m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "down":
+		reverted, err := store.migrator.down(*steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+	default:
+		cmd.Usage()
+		os.Exit(2)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS logs;
+DROP TABLE IF EXISTS shipments;
+DROP TABLE IF EXISTS transport;
+DROP TABLE IF EXISTS sales_order_items;
+DROP TABLE IF EXISTS sales_orders;
+DROP TABLE IF EXISTS contractors;
+DROP TABLE IF EXISTS equipment;
+DROP TABLE IF EXISTS equipment_categories;
+DROP TABLE IF EXISTS ore_batches;
+DROP TABLE IF EXISTS ore_types;
+DROP TABLE IF EXISTS warehouses;
+DROP TABLE IF EXISTS units;
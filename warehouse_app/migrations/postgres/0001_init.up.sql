@@ -0,0 +1,131 @@
+CREATE TABLE IF NOT EXISTS units (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    symbol TEXT NOT NULL,
+    created_at TEXT,
+    updated_at TEXT
+);
+CREATE TABLE IF NOT EXISTS warehouses (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    location TEXT,
+    supervisor TEXT,
+    capacity DOUBLE PRECISION,
+    created_at TEXT,
+    updated_at TEXT
+);
+CREATE TABLE IF NOT EXISTS ore_types (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    category TEXT,
+    description TEXT,
+    created_at TEXT,
+    updated_at TEXT
+);
+CREATE TABLE IF NOT EXISTS ore_batches (
+    id SERIAL PRIMARY KEY,
+    ore_type_id INTEGER NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    unit_id INTEGER NOT NULL,
+    batch_code TEXT,
+    quantity DOUBLE PRECISION NOT NULL,
+    quality DOUBLE PRECISION,
+    priority TEXT,
+    extraction_date TEXT,
+    status TEXT,
+    created_at TEXT,
+    updated_at TEXT,
+    FOREIGN KEY (ore_type_id) REFERENCES ore_types(id),
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+    FOREIGN KEY (unit_id) REFERENCES units(id)
+);
+CREATE TABLE IF NOT EXISTS equipment_categories (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TEXT,
+    updated_at TEXT
+);
+CREATE TABLE IF NOT EXISTS equipment (
+    id SERIAL PRIMARY KEY,
+    category_id INTEGER NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    unit_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    quantity DOUBLE PRECISION NOT NULL,
+    serial_number TEXT,
+    service_life_months INTEGER,
+    status TEXT,
+    purchase_date TEXT,
+    created_at TEXT,
+    updated_at TEXT,
+    FOREIGN KEY (category_id) REFERENCES equipment_categories(id),
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+    FOREIGN KEY (unit_id) REFERENCES units(id)
+);
+CREATE TABLE IF NOT EXISTS contractors (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    type TEXT,
+    contact_person TEXT,
+    phone TEXT,
+    email TEXT,
+    created_at TEXT,
+    updated_at TEXT
+);
+CREATE TABLE IF NOT EXISTS sales_orders (
+    id SERIAL PRIMARY KEY,
+    order_number TEXT NOT NULL,
+    contractor_id INTEGER NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    status TEXT,
+    order_date TEXT,
+    total_quantity DOUBLE PRECISION,
+    created_at TEXT,
+    updated_at TEXT,
+    FOREIGN KEY (contractor_id) REFERENCES contractors(id),
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(id)
+);
+CREATE TABLE IF NOT EXISTS sales_order_items (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL,
+    ore_batch_id INTEGER NOT NULL,
+    unit_id INTEGER NOT NULL,
+    quantity DOUBLE PRECISION NOT NULL,
+    price_per_unit DOUBLE PRECISION,
+    created_at TEXT,
+    updated_at TEXT,
+    FOREIGN KEY (order_id) REFERENCES sales_orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (ore_batch_id) REFERENCES ore_batches(id),
+    FOREIGN KEY (unit_id) REFERENCES units(id)
+);
+CREATE TABLE IF NOT EXISTS transport (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    type TEXT,
+    vehicle_number TEXT,
+    capacity DOUBLE PRECISION,
+    unit_id INTEGER,
+    created_at TEXT,
+    updated_at TEXT,
+    FOREIGN KEY (unit_id) REFERENCES units(id)
+);
+CREATE TABLE IF NOT EXISTS shipments (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL,
+    transport_id INTEGER,
+    planned_date TEXT,
+    actual_date TEXT,
+    status TEXT,
+    created_at TEXT,
+    updated_at TEXT,
+    FOREIGN KEY (order_id) REFERENCES sales_orders(id),
+    FOREIGN KEY (transport_id) REFERENCES transport(id)
+);
+CREATE TABLE IF NOT EXISTS logs (
+    id SERIAL PRIMARY KEY,
+    event_time TEXT,
+    "user" TEXT,
+    action TEXT,
+    entity TEXT,
+    details TEXT
+);
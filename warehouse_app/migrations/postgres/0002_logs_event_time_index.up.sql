@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_logs_event_time ON logs(event_time, id);
@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS ore_batches_search ON ore_batches;
+DROP FUNCTION IF EXISTS ore_batches_search();
+DROP TRIGGER IF EXISTS sales_orders_search ON sales_orders;
+DROP FUNCTION IF EXISTS sales_orders_search();
+DROP TRIGGER IF EXISTS contractors_search ON contractors;
+DROP FUNCTION IF EXISTS contractors_search();
+DROP TRIGGER IF EXISTS equipment_search ON equipment;
+DROP FUNCTION IF EXISTS equipment_search();
+DROP TRIGGER IF EXISTS transport_search ON transport;
+DROP FUNCTION IF EXISTS transport_search();
+DROP TRIGGER IF EXISTS logs_search ON logs;
+DROP FUNCTION IF EXISTS logs_search();
+DROP TABLE IF EXISTS search_index;
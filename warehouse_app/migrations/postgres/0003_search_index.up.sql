@@ -0,0 +1,106 @@
+CREATE TABLE IF NOT EXISTS search_index (
+    entity TEXT NOT NULL,
+    entity_id INTEGER NOT NULL,
+    title TEXT,
+    body TEXT,
+    document tsvector GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(body, ''))) STORED,
+    PRIMARY KEY (entity, entity_id)
+);
+CREATE INDEX IF NOT EXISTS idx_search_index_document ON search_index USING GIN (document);
+
+CREATE OR REPLACE FUNCTION ore_batches_search() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM search_index WHERE entity = 'ore_batch' AND entity_id = OLD.id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO search_index (entity, entity_id, title, body) VALUES ('ore_batch', NEW.id, COALESCE(NEW.batch_code, ''), COALESCE((SELECT name FROM ore_types WHERE id = NEW.ore_type_id), '') || ' ' || COALESCE(NEW.status, '') || ' ' || COALESCE(NEW.priority, ''));
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER ore_batches_search AFTER INSERT OR UPDATE OR DELETE ON ore_batches
+    FOR EACH ROW EXECUTE FUNCTION ore_batches_search();
+
+CREATE OR REPLACE FUNCTION sales_orders_search() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM search_index WHERE entity = 'order' AND entity_id = OLD.id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO search_index (entity, entity_id, title, body) VALUES ('order', NEW.id, NEW.order_number, COALESCE((SELECT name FROM contractors WHERE id = NEW.contractor_id), '') || ' ' || COALESCE(NEW.status, ''));
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER sales_orders_search AFTER INSERT OR UPDATE OR DELETE ON sales_orders
+    FOR EACH ROW EXECUTE FUNCTION sales_orders_search();
+
+CREATE OR REPLACE FUNCTION contractors_search() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM search_index WHERE entity = 'contractor' AND entity_id = OLD.id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO search_index (entity, entity_id, title, body) VALUES ('contractor', NEW.id, NEW.name, COALESCE(NEW.type, '') || ' ' || COALESCE(NEW.contact_person, '') || ' ' || COALESCE(NEW.phone, '') || ' ' || COALESCE(NEW.email, ''));
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER contractors_search AFTER INSERT OR UPDATE OR DELETE ON contractors
+    FOR EACH ROW EXECUTE FUNCTION contractors_search();
+
+CREATE OR REPLACE FUNCTION equipment_search() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM search_index WHERE entity = 'equipment' AND entity_id = OLD.id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO search_index (entity, entity_id, title, body) VALUES ('equipment', NEW.id, NEW.name, COALESCE(NEW.serial_number, '') || ' ' || COALESCE(NEW.status, ''));
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER equipment_search AFTER INSERT OR UPDATE OR DELETE ON equipment
+    FOR EACH ROW EXECUTE FUNCTION equipment_search();
+
+CREATE OR REPLACE FUNCTION transport_search() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM search_index WHERE entity = 'transport' AND entity_id = OLD.id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO search_index (entity, entity_id, title, body) VALUES ('transport', NEW.id, NEW.name, COALESCE(NEW.vehicle_number, '') || ' ' || COALESCE(NEW.type, ''));
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER transport_search AFTER INSERT OR UPDATE OR DELETE ON transport
+    FOR EACH ROW EXECUTE FUNCTION transport_search();
+
+CREATE OR REPLACE FUNCTION logs_search() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM search_index WHERE entity = 'log' AND entity_id = OLD.id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO search_index (entity, entity_id, title, body) VALUES ('log', NEW.id, COALESCE(NEW.action, ''), COALESCE(NEW.details, '') || ' ' || COALESCE(NEW.entity, '') || ' ' || COALESCE(NEW."user", ''));
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER logs_search AFTER INSERT OR UPDATE OR DELETE ON logs
+    FOR EACH ROW EXECUTE FUNCTION logs_search();
+
+INSERT INTO search_index (entity, entity_id, title, body)
+    SELECT 'ore_batch', t.id, COALESCE(t.batch_code, ''), COALESCE((SELECT name FROM ore_types WHERE id = t.ore_type_id), '') || ' ' || COALESCE(t.status, '') || ' ' || COALESCE(t.priority, '') FROM ore_batches t;
+INSERT INTO search_index (entity, entity_id, title, body)
+    SELECT 'order', t.id, t.order_number, COALESCE((SELECT name FROM contractors WHERE id = t.contractor_id), '') || ' ' || COALESCE(t.status, '') FROM sales_orders t;
+INSERT INTO search_index (entity, entity_id, title, body)
+    SELECT 'contractor', t.id, t.name, COALESCE(t.type, '') || ' ' || COALESCE(t.contact_person, '') || ' ' || COALESCE(t.phone, '') || ' ' || COALESCE(t.email, '') FROM contractors t;
+INSERT INTO search_index (entity, entity_id, title, body)
+    SELECT 'equipment', t.id, t.name, COALESCE(t.serial_number, '') || ' ' || COALESCE(t.status, '') FROM equipment t;
+INSERT INTO search_index (entity, entity_id, title, body)
+    SELECT 'transport', t.id, t.name, COALESCE(t.vehicle_number, '') || ' ' || COALESCE(t.type, '') FROM transport t;
+INSERT INTO search_index (entity, entity_id, title, body)
+    SELECT 'log', t.id, COALESCE(t.action, ''), COALESCE(t.details, '') || ' ' || COALESCE(t.entity, '') || ' ' || COALESCE(t."user", '') FROM logs t;
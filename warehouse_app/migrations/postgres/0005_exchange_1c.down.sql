@@ -0,0 +1,4 @@
+ALTER TABLE ore_types DROP COLUMN price_updated_at;
+ALTER TABLE ore_types DROP COLUMN price;
+DROP TABLE IF EXISTS exchange_log;
+DROP TABLE IF EXISTS exchange_ids;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS exchange_ids (
+    entity TEXT NOT NULL,
+    entity_id INTEGER NOT NULL,
+    guid TEXT NOT NULL UNIQUE,
+    created_at TEXT,
+    PRIMARY KEY (entity, entity_id)
+);
+CREATE TABLE IF NOT EXISTS exchange_log (
+    id SERIAL PRIMARY KEY,
+    direction TEXT NOT NULL,
+    file_name TEXT,
+    status TEXT NOT NULL,
+    summary TEXT,
+    errors TEXT,
+    document TEXT,
+    created_at TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_exchange_log_created_at ON exchange_log(created_at, id);
+ALTER TABLE ore_types ADD COLUMN price DOUBLE PRECISION;
+ALTER TABLE ore_types ADD COLUMN price_updated_at TEXT;
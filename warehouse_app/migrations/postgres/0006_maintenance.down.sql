@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS work_order_labor;
+DROP TABLE IF EXISTS work_order_parts;
+DROP TABLE IF EXISTS work_orders;
+DROP TABLE IF EXISTS maintenance_plans;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS meter_readings (
+    id SERIAL PRIMARY KEY,
+    equipment_id INTEGER NOT NULL,
+    meter TEXT NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    read_at TEXT NOT NULL,
+    source TEXT,
+    created_at TEXT,
+    FOREIGN KEY (equipment_id) REFERENCES equipment(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_meter_readings_equipment ON meter_readings(equipment_id, meter, read_at);
@@ -0,0 +1,6 @@
+ALTER TABLE equipment DROP COLUMN write_off_reason;
+ALTER TABLE equipment DROP COLUMN residual_value;
+ALTER TABLE equipment DROP COLUMN written_off_date;
+ALTER TABLE equipment DROP COLUMN depreciation_method;
+ALTER TABLE equipment DROP COLUMN salvage_value;
+ALTER TABLE equipment DROP COLUMN purchase_cost;
@@ -0,0 +1,6 @@
+ALTER TABLE equipment ADD COLUMN purchase_cost DOUBLE PRECISION;
+ALTER TABLE equipment ADD COLUMN salvage_value DOUBLE PRECISION;
+ALTER TABLE equipment ADD COLUMN depreciation_method TEXT;
+ALTER TABLE equipment ADD COLUMN written_off_date TEXT;
+ALTER TABLE equipment ADD COLUMN residual_value DOUBLE PRECISION;
+ALTER TABLE equipment ADD COLUMN write_off_reason TEXT;
@@ -0,0 +1,16 @@
+CREATE OR REPLACE FUNCTION equipment_search() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM search_index WHERE entity = 'equipment' AND entity_id = OLD.id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO search_index (entity, entity_id, title, body) VALUES ('equipment', NEW.id, NEW.name, COALESCE(NEW.serial_number, '') || ' ' || COALESCE(NEW.status, ''));
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_equipment_inventory_number;
+DROP INDEX IF EXISTS idx_equipment_serial_number;
+ALTER TABLE equipment DROP COLUMN tracking;
+ALTER TABLE equipment DROP COLUMN inventory_number;
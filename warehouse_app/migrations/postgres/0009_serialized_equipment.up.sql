@@ -0,0 +1,21 @@
+ALTER TABLE equipment ADD COLUMN inventory_number TEXT;
+ALTER TABLE equipment ADD COLUMN tracking TEXT NOT NULL DEFAULT 'bulk';
+-- Rows that already describe one unit with a serial of its own become
+-- serialized; the rest are converted with POST /api/v1/equipment/{id}/units.
+UPDATE equipment SET tracking = 'serial'
+WHERE quantity = 1 AND COALESCE(serial_number, '') <> ''
+  AND serial_number IN (SELECT serial_number FROM equipment GROUP BY serial_number HAVING COUNT(*) = 1);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_equipment_serial_number ON equipment(serial_number) WHERE tracking = 'serial';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_equipment_inventory_number ON equipment(inventory_number) WHERE COALESCE(inventory_number, '') <> '';
+
+CREATE OR REPLACE FUNCTION equipment_search() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM search_index WHERE entity = 'equipment' AND entity_id = OLD.id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO search_index (entity, entity_id, title, body) VALUES ('equipment', NEW.id, NEW.name, COALESCE(NEW.serial_number, '') || ' ' || COALESCE(NEW.inventory_number, '') || ' ' || COALESCE(NEW.status, ''));
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,15 @@
+ALTER TABLE sales_orders DROP COLUMN reporting_currency;
+ALTER TABLE sales_orders DROP COLUMN rate_date;
+ALTER TABLE sales_orders DROP COLUMN rate;
+ALTER TABLE sales_orders DROP COLUMN currency;
+ALTER TABLE price_lists DROP COLUMN currency;
+DROP TABLE IF EXISTS exchange_rates;
+ALTER TABLE price_lists ALTER COLUMN base_price TYPE DOUBLE PRECISION;
+ALTER TABLE price_lists ALTER COLUMN bonus_per_pct TYPE DOUBLE PRECISION;
+ALTER TABLE price_lists ALTER COLUMN penalty_per_pct TYPE DOUBLE PRECISION;
+ALTER TABLE sales_order_items ALTER COLUMN price_per_unit TYPE DOUBLE PRECISION;
+ALTER TABLE sales_order_items ALTER COLUMN list_price TYPE DOUBLE PRECISION;
+ALTER TABLE sales_order_items ALTER COLUMN amount TYPE DOUBLE PRECISION;
+ALTER TABLE sales_orders ALTER COLUMN amount TYPE DOUBLE PRECISION;
+ALTER TABLE sales_orders ALTER COLUMN vat_amount TYPE DOUBLE PRECISION;
+ALTER TABLE sales_orders ALTER COLUMN total_amount TYPE DOUBLE PRECISION;
@@ -0,0 +1,29 @@
+ALTER TABLE price_lists ALTER COLUMN base_price TYPE NUMERIC(20,6) USING ROUND(CAST(base_price AS NUMERIC), 6);
+ALTER TABLE price_lists ALTER COLUMN bonus_per_pct TYPE NUMERIC(20,6) USING ROUND(CAST(bonus_per_pct AS NUMERIC), 6);
+ALTER TABLE price_lists ALTER COLUMN penalty_per_pct TYPE NUMERIC(20,6) USING ROUND(CAST(penalty_per_pct AS NUMERIC), 6);
+ALTER TABLE sales_order_items ALTER COLUMN price_per_unit TYPE NUMERIC(20,6) USING ROUND(CAST(price_per_unit AS NUMERIC), 6);
+ALTER TABLE sales_order_items ALTER COLUMN list_price TYPE NUMERIC(20,6) USING ROUND(CAST(list_price AS NUMERIC), 6);
+ALTER TABLE sales_order_items ALTER COLUMN amount TYPE NUMERIC(20,2) USING ROUND(CAST(amount AS NUMERIC), 2);
+ALTER TABLE sales_orders ALTER COLUMN amount TYPE NUMERIC(20,2) USING ROUND(CAST(amount AS NUMERIC), 2);
+ALTER TABLE sales_orders ALTER COLUMN vat_amount TYPE NUMERIC(20,2) USING ROUND(CAST(vat_amount AS NUMERIC), 2);
+ALTER TABLE sales_orders ALTER COLUMN total_amount TYPE NUMERIC(20,2) USING ROUND(CAST(total_amount AS NUMERIC), 2);
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id SERIAL PRIMARY KEY,
+    currency TEXT NOT NULL,
+    rate_date TEXT NOT NULL,
+    rate NUMERIC(20,6) NOT NULL,
+    source TEXT,
+    created_at TEXT,
+    updated_at TEXT,
+    UNIQUE (currency, rate_date)
+);
+ALTER TABLE price_lists ADD COLUMN currency TEXT;
+ALTER TABLE sales_orders ADD COLUMN currency TEXT;
+ALTER TABLE sales_orders ADD COLUMN rate NUMERIC(20,6);
+ALTER TABLE sales_orders ADD COLUMN rate_date TEXT;
+ALTER TABLE sales_orders ADD COLUMN reporting_currency TEXT;
+-- Until now every price was in roubles; confirmed orders get the rate 1.
+UPDATE price_lists SET currency = 'RUB';
+UPDATE sales_orders SET currency = 'RUB';
+UPDATE sales_orders SET rate = 1, rate_date = COALESCE(NULLIF(order_date, ''), SUBSTR(created_at, 1, 10)), reporting_currency = 'RUB'
+    WHERE status IN ('Подтвержден', 'Отгружен', 'Закрыт');
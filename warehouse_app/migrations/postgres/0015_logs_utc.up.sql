@@ -0,0 +1,3 @@
+-- Event times are compared as strings, so they are kept in UTC.
+UPDATE logs SET event_time = to_char(event_time::timestamptz AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+WHERE event_time ~ '^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$';
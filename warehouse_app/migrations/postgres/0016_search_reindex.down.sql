@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS ore_types_search_batches ON ore_types;
+DROP FUNCTION IF EXISTS ore_types_search_batches();
+DROP TRIGGER IF EXISTS contractors_search_orders ON contractors;
+DROP FUNCTION IF EXISTS contractors_search_orders();
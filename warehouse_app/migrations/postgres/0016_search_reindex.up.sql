@@ -0,0 +1,29 @@
+-- Orders show the contractor's name and ore batches the ore type's, so their
+-- index rows are rebuilt when those are renamed.
+CREATE OR REPLACE FUNCTION contractors_search_orders() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM search_index WHERE entity = 'order' AND entity_id IN (SELECT id FROM sales_orders WHERE contractor_id = NEW.id);
+    INSERT INTO search_index (entity, entity_id, title, body)
+        SELECT 'order', t.id, t.order_number, COALESCE(NEW.name, '') || ' ' || COALESCE(t.status, '') FROM sales_orders t WHERE t.contractor_id = NEW.id;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER contractors_search_orders AFTER UPDATE OF name ON contractors
+    FOR EACH ROW EXECUTE FUNCTION contractors_search_orders();
+
+CREATE OR REPLACE FUNCTION ore_types_search_batches() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM search_index WHERE entity = 'ore_batch' AND entity_id IN (SELECT id FROM ore_batches WHERE ore_type_id = NEW.id);
+    INSERT INTO search_index (entity, entity_id, title, body)
+        SELECT 'ore_batch', t.id, COALESCE(t.batch_code, ''), COALESCE(NEW.name, '') || ' ' || COALESCE(t.status, '') || ' ' || COALESCE(t.priority, '') FROM ore_batches t WHERE t.ore_type_id = NEW.id;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER ore_types_search_batches AFTER UPDATE OF name ON ore_types
+    FOR EACH ROW EXECUTE FUNCTION ore_types_search_batches();
+
+-- 0009 added inventory numbers to the equipment body without reindexing the
+-- rows already there.
+DELETE FROM search_index WHERE entity = 'equipment';
+INSERT INTO search_index (entity, entity_id, title, body)
+    SELECT 'equipment', t.id, t.name, COALESCE(t.serial_number, '') || ' ' || COALESCE(t.inventory_number, '') || ' ' || COALESCE(t.status, '') FROM equipment t;
@@ -0,0 +1,3 @@
+-- ALTER COLUMN ... TYPE in 0014 kept NOT NULL on price_lists.base_price;
+-- this version only keeps the numbering in step with SQLite.
+SELECT 1;
@@ -0,0 +1,8 @@
+ALTER TABLE equipment ALTER COLUMN purchase_cost TYPE DOUBLE PRECISION;
+ALTER TABLE equipment ALTER COLUMN salvage_value TYPE DOUBLE PRECISION;
+ALTER TABLE equipment ALTER COLUMN residual_value TYPE DOUBLE PRECISION;
+ALTER TABLE ore_types ALTER COLUMN price TYPE DOUBLE PRECISION;
+ALTER TABLE spare_parts ALTER COLUMN unit_cost TYPE DOUBLE PRECISION;
+ALTER TABLE work_order_parts ALTER COLUMN unit_cost TYPE DOUBLE PRECISION;
+ALTER TABLE purchase_orders ALTER COLUMN total_amount TYPE DOUBLE PRECISION;
+ALTER TABLE purchase_order_items ALTER COLUMN price TYPE DOUBLE PRECISION;
@@ -0,0 +1,8 @@
+ALTER TABLE equipment ALTER COLUMN purchase_cost TYPE NUMERIC(20,6) USING ROUND(CAST(purchase_cost AS NUMERIC), 6);
+ALTER TABLE equipment ALTER COLUMN salvage_value TYPE NUMERIC(20,6) USING ROUND(CAST(salvage_value AS NUMERIC), 6);
+ALTER TABLE equipment ALTER COLUMN residual_value TYPE NUMERIC(20,6) USING ROUND(CAST(residual_value AS NUMERIC), 6);
+ALTER TABLE ore_types ALTER COLUMN price TYPE NUMERIC(20,6) USING ROUND(CAST(price AS NUMERIC), 6);
+ALTER TABLE spare_parts ALTER COLUMN unit_cost TYPE NUMERIC(20,6) USING ROUND(CAST(unit_cost AS NUMERIC), 6);
+ALTER TABLE work_order_parts ALTER COLUMN unit_cost TYPE NUMERIC(20,6) USING ROUND(CAST(unit_cost AS NUMERIC), 6);
+ALTER TABLE purchase_orders ALTER COLUMN total_amount TYPE NUMERIC(20,2) USING ROUND(CAST(total_amount AS NUMERIC), 2);
+ALTER TABLE purchase_order_items ALTER COLUMN price TYPE NUMERIC(20,6) USING ROUND(CAST(price AS NUMERIC), 6);
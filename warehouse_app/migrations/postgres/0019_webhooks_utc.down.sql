@@ -0,0 +1,2 @@
+-- UTC times are valid RFC 3339 and stay as they are.
+SELECT 1;
@@ -0,0 +1,8 @@
+-- Due and lease times are compared as strings, so they are kept in UTC like
+-- log event times (0015); attempt times follow them.
+UPDATE webhook_deliveries SET next_attempt_at = to_char(next_attempt_at::timestamptz AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+WHERE next_attempt_at ~ '^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$';
+UPDATE webhook_deliveries SET delivered_at = to_char(delivered_at::timestamptz AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+WHERE delivered_at ~ '^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$';
+UPDATE webhook_attempts SET attempted_at = to_char(attempted_at::timestamptz AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+WHERE attempted_at ~ '^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$';
@@ -0,0 +1,7 @@
+-- 0014 marked every existing price list and order as roubles regardless of
+-- business.currency. Rows created before it ran get their currency back as
+-- NULL; the server fills it from business.currency at startup.
+UPDATE price_lists SET currency = NULL
+    WHERE created_at IS NULL OR created_at < (SELECT applied_at FROM schema_migrations WHERE version = 14);
+UPDATE sales_orders SET currency = NULL, reporting_currency = NULL
+    WHERE created_at IS NULL OR created_at < (SELECT applied_at FROM schema_migrations WHERE version = 14);
@@ -0,0 +1,3 @@
+-- PostgreSQL has had its search index since 0003_search_index; this version
+-- creates the SQLite one.
+SELECT 1;
@@ -0,0 +1,54 @@
+CREATE TABLE IF NOT EXISTS maintenance_plans (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    equipment_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    interval_days INTEGER,
+    interval_hours REAL,
+    start_date TEXT NOT NULL,
+    start_hours REAL,
+    last_done_date TEXT,
+    last_done_hours REAL,
+    created_at TEXT,
+    updated_at TEXT,
+    FOREIGN KEY (equipment_id) REFERENCES equipment(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_maintenance_plans_equipment ON maintenance_plans(equipment_id);
+CREATE TABLE IF NOT EXISTS work_orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    equipment_id INTEGER NOT NULL,
+    plan_id INTEGER,
+    title TEXT NOT NULL,
+    description TEXT,
+    status TEXT NOT NULL,
+    planned_date TEXT,
+    started_at TEXT,
+    completed_at TEXT,
+    meter_hours REAL,
+    equipment_status_before TEXT,
+    created_at TEXT,
+    updated_at TEXT,
+    FOREIGN KEY (equipment_id) REFERENCES equipment(id),
+    FOREIGN KEY (plan_id) REFERENCES maintenance_plans(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_work_orders_equipment ON work_orders(equipment_id, status);
+CREATE TABLE IF NOT EXISTS work_order_parts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    work_order_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    unit_id INTEGER NOT NULL,
+    quantity REAL NOT NULL,
+    unit_cost REAL,
+    created_at TEXT,
+    FOREIGN KEY (work_order_id) REFERENCES work_orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (unit_id) REFERENCES units(id)
+);
+CREATE TABLE IF NOT EXISTS work_order_labor (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    work_order_id INTEGER NOT NULL,
+    worker TEXT NOT NULL,
+    hours REAL NOT NULL,
+    work_date TEXT,
+    note TEXT,
+    created_at TEXT,
+    FOREIGN KEY (work_order_id) REFERENCES work_orders(id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_equipment_inventory_number;
+DROP INDEX IF EXISTS idx_equipment_serial_number;
+ALTER TABLE equipment DROP COLUMN tracking;
+ALTER TABLE equipment DROP COLUMN inventory_number;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS equipment_assignments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    equipment_id INTEGER NOT NULL,
+    from_warehouse_id INTEGER NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    site TEXT,
+    crew TEXT,
+    responsible TEXT NOT NULL,
+    status TEXT NOT NULL,
+    checked_out_at TEXT NOT NULL,
+    due_date TEXT,
+    returned_at TEXT,
+    return_warehouse_id INTEGER,
+    note TEXT,
+    created_at TEXT,
+    updated_at TEXT,
+    FOREIGN KEY (equipment_id) REFERENCES equipment(id) ON DELETE CASCADE,
+    FOREIGN KEY (from_warehouse_id) REFERENCES warehouses(id),
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+    FOREIGN KEY (return_warehouse_id) REFERENCES warehouses(id)
+);
+CREATE INDEX IF NOT EXISTS idx_equipment_assignments_equipment ON equipment_assignments(equipment_id, checked_out_at);
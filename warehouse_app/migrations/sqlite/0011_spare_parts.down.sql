@@ -0,0 +1,5 @@
+ALTER TABLE work_order_parts DROP COLUMN warehouse_id;
+ALTER TABLE work_order_parts DROP COLUMN part_id;
+DROP TABLE IF EXISTS spare_part_movements;
+DROP TABLE IF EXISTS spare_part_stock;
+DROP TABLE IF EXISTS spare_parts;
@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS spare_parts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    part_number TEXT,
+    category TEXT,
+    unit_id INTEGER NOT NULL,
+    supplier_id INTEGER,
+    unit_cost REAL,
+    created_at TEXT,
+    updated_at TEXT,
+    FOREIGN KEY (unit_id) REFERENCES units(id),
+    FOREIGN KEY (supplier_id) REFERENCES contractors(id) ON DELETE SET NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_spare_parts_number ON spare_parts(part_number) WHERE part_number <> '';
+CREATE TABLE IF NOT EXISTS spare_part_stock (
+    part_id INTEGER NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    quantity REAL NOT NULL DEFAULT 0,
+    min_quantity REAL,
+    max_quantity REAL,
+    updated_at TEXT,
+    PRIMARY KEY (part_id, warehouse_id),
+    FOREIGN KEY (part_id) REFERENCES spare_parts(id) ON DELETE CASCADE,
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(id)
+);
+CREATE TABLE IF NOT EXISTS spare_part_movements (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    part_id INTEGER NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    quantity REAL NOT NULL,
+    balance REAL NOT NULL,
+    work_order_id INTEGER,
+    note TEXT,
+    moved_at TEXT NOT NULL,
+    created_at TEXT,
+    FOREIGN KEY (part_id) REFERENCES spare_parts(id) ON DELETE CASCADE,
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+    FOREIGN KEY (work_order_id) REFERENCES work_orders(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_spare_part_movements_part ON spare_part_movements(part_id, warehouse_id, moved_at);
+ALTER TABLE work_order_parts ADD COLUMN part_id INTEGER;
+ALTER TABLE work_order_parts ADD COLUMN warehouse_id INTEGER;
@@ -0,0 +1,57 @@
+CREATE TABLE IF NOT EXISTS purchase_orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_number TEXT NOT NULL UNIQUE,
+    supplier_id INTEGER NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    order_date TEXT,
+    expected_date TEXT,
+    note TEXT,
+    total_amount REAL NOT NULL DEFAULT 0,
+    created_at TEXT,
+    updated_at TEXT,
+    FOREIGN KEY (supplier_id) REFERENCES contractors(id),
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(id)
+);
+CREATE INDEX IF NOT EXISTS idx_purchase_orders_supplier ON purchase_orders(supplier_id, status);
+CREATE TABLE IF NOT EXISTS purchase_order_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL,
+    part_id INTEGER,
+    category_id INTEGER,
+    name TEXT NOT NULL,
+    unit_id INTEGER NOT NULL,
+    quantity REAL NOT NULL,
+    received_quantity REAL NOT NULL DEFAULT 0,
+    price REAL NOT NULL,
+    created_at TEXT,
+    FOREIGN KEY (order_id) REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (part_id) REFERENCES spare_parts(id),
+    FOREIGN KEY (category_id) REFERENCES equipment_categories(id),
+    FOREIGN KEY (unit_id) REFERENCES units(id)
+);
+CREATE TABLE IF NOT EXISTS goods_receipts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    received_at TEXT NOT NULL,
+    document_number TEXT,
+    note TEXT,
+    created_at TEXT,
+    FOREIGN KEY (order_id) REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(id)
+);
+CREATE INDEX IF NOT EXISTS idx_goods_receipts_order ON goods_receipts(order_id);
+CREATE TABLE IF NOT EXISTS goods_receipt_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    receipt_id INTEGER NOT NULL,
+    order_item_id INTEGER NOT NULL,
+    quantity REAL NOT NULL,
+    movement_id INTEGER,
+    equipment_id INTEGER,
+    FOREIGN KEY (receipt_id) REFERENCES goods_receipts(id) ON DELETE CASCADE,
+    FOREIGN KEY (order_item_id) REFERENCES purchase_order_items(id),
+    FOREIGN KEY (movement_id) REFERENCES spare_part_movements(id) ON DELETE SET NULL,
+    FOREIGN KEY (equipment_id) REFERENCES equipment(id) ON DELETE SET NULL
+);
+ALTER TABLE spare_part_movements ADD COLUMN receipt_id INTEGER;
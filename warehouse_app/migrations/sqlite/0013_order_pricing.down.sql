@@ -0,0 +1,11 @@
+ALTER TABLE sales_orders DROP COLUMN total_amount;
+ALTER TABLE sales_orders DROP COLUMN vat_amount;
+ALTER TABLE sales_orders DROP COLUMN vat_rate;
+ALTER TABLE sales_orders DROP COLUMN amount;
+ALTER TABLE sales_order_items DROP COLUMN price_reason;
+ALTER TABLE sales_order_items DROP COLUMN price_source;
+ALTER TABLE sales_order_items DROP COLUMN grade;
+ALTER TABLE sales_order_items DROP COLUMN price_list_id;
+ALTER TABLE sales_order_items DROP COLUMN list_price;
+ALTER TABLE sales_order_items DROP COLUMN amount;
+DROP TABLE IF EXISTS price_lists;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS price_lists (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ore_type_id INTEGER NOT NULL,
+    contractor_id INTEGER,
+    unit_id INTEGER NOT NULL,
+    base_price REAL NOT NULL,
+    base_grade REAL,
+    bonus_per_pct REAL,
+    penalty_per_pct REAL,
+    valid_from TEXT NOT NULL,
+    valid_to TEXT,
+    note TEXT,
+    created_at TEXT,
+    updated_at TEXT,
+    FOREIGN KEY (ore_type_id) REFERENCES ore_types(id) ON DELETE CASCADE,
+    FOREIGN KEY (contractor_id) REFERENCES contractors(id) ON DELETE CASCADE,
+    FOREIGN KEY (unit_id) REFERENCES units(id)
+);
+CREATE INDEX IF NOT EXISTS idx_price_lists_ore_type ON price_lists(ore_type_id, valid_from);
+ALTER TABLE sales_order_items ADD COLUMN amount REAL;
+ALTER TABLE sales_order_items ADD COLUMN list_price REAL;
+ALTER TABLE sales_order_items ADD COLUMN price_list_id INTEGER;
+ALTER TABLE sales_order_items ADD COLUMN grade REAL;
+ALTER TABLE sales_order_items ADD COLUMN price_source TEXT;
+ALTER TABLE sales_order_items ADD COLUMN price_reason TEXT;
+ALTER TABLE sales_orders ADD COLUMN amount REAL;
+ALTER TABLE sales_orders ADD COLUMN vat_rate REAL;
+ALTER TABLE sales_orders ADD COLUMN vat_amount REAL;
+ALTER TABLE sales_orders ADD COLUMN total_amount REAL;
+-- Prices of existing orders were entered by hand; their VAT is unknown.
+UPDATE sales_order_items SET amount = ROUND(quantity * COALESCE(price_per_unit, 0), 2), price_source = 'Вручную',
+    grade = (SELECT ob.quality FROM ore_batches ob WHERE ob.id = sales_order_items.ore_batch_id);
+UPDATE sales_orders SET amount = COALESCE((SELECT SUM(i.amount) FROM sales_order_items i WHERE i.order_id = sales_orders.id), 0);
+UPDATE sales_orders SET total_amount = amount;
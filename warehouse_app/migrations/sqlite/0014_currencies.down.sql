@@ -0,0 +1,36 @@
+ALTER TABLE sales_orders DROP COLUMN reporting_currency;
+ALTER TABLE sales_orders DROP COLUMN rate_date;
+ALTER TABLE sales_orders DROP COLUMN rate;
+ALTER TABLE sales_orders DROP COLUMN currency;
+ALTER TABLE price_lists DROP COLUMN currency;
+DROP TABLE IF EXISTS exchange_rates;
+ALTER TABLE price_lists RENAME COLUMN base_price TO base_price_old;
+ALTER TABLE price_lists ADD COLUMN base_price REAL;
+ALTER TABLE price_lists RENAME COLUMN bonus_per_pct TO bonus_per_pct_old;
+ALTER TABLE price_lists ADD COLUMN bonus_per_pct REAL;
+ALTER TABLE price_lists RENAME COLUMN penalty_per_pct TO penalty_per_pct_old;
+ALTER TABLE price_lists ADD COLUMN penalty_per_pct REAL;
+UPDATE price_lists SET base_price = CAST(base_price_old AS REAL), bonus_per_pct = CAST(bonus_per_pct_old AS REAL), penalty_per_pct = CAST(penalty_per_pct_old AS REAL);
+ALTER TABLE price_lists DROP COLUMN base_price_old;
+ALTER TABLE price_lists DROP COLUMN bonus_per_pct_old;
+ALTER TABLE price_lists DROP COLUMN penalty_per_pct_old;
+ALTER TABLE sales_order_items RENAME COLUMN price_per_unit TO price_per_unit_old;
+ALTER TABLE sales_order_items ADD COLUMN price_per_unit REAL;
+ALTER TABLE sales_order_items RENAME COLUMN list_price TO list_price_old;
+ALTER TABLE sales_order_items ADD COLUMN list_price REAL;
+ALTER TABLE sales_order_items RENAME COLUMN amount TO amount_old;
+ALTER TABLE sales_order_items ADD COLUMN amount REAL;
+UPDATE sales_order_items SET price_per_unit = CAST(price_per_unit_old AS REAL), list_price = CAST(list_price_old AS REAL), amount = CAST(amount_old AS REAL);
+ALTER TABLE sales_order_items DROP COLUMN price_per_unit_old;
+ALTER TABLE sales_order_items DROP COLUMN list_price_old;
+ALTER TABLE sales_order_items DROP COLUMN amount_old;
+ALTER TABLE sales_orders RENAME COLUMN amount TO amount_old;
+ALTER TABLE sales_orders ADD COLUMN amount REAL;
+ALTER TABLE sales_orders RENAME COLUMN vat_amount TO vat_amount_old;
+ALTER TABLE sales_orders ADD COLUMN vat_amount REAL;
+ALTER TABLE sales_orders RENAME COLUMN total_amount TO total_amount_old;
+ALTER TABLE sales_orders ADD COLUMN total_amount REAL;
+UPDATE sales_orders SET amount = CAST(amount_old AS REAL), vat_amount = CAST(vat_amount_old AS REAL), total_amount = CAST(total_amount_old AS REAL);
+ALTER TABLE sales_orders DROP COLUMN amount_old;
+ALTER TABLE sales_orders DROP COLUMN vat_amount_old;
+ALTER TABLE sales_orders DROP COLUMN total_amount_old;
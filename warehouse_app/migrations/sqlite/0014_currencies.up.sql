@@ -0,0 +1,51 @@
+-- SQLite has no decimal type: money is kept as text and read exactly.
+ALTER TABLE price_lists RENAME COLUMN base_price TO base_price_old;
+ALTER TABLE price_lists ADD COLUMN base_price TEXT;
+ALTER TABLE price_lists RENAME COLUMN bonus_per_pct TO bonus_per_pct_old;
+ALTER TABLE price_lists ADD COLUMN bonus_per_pct TEXT;
+ALTER TABLE price_lists RENAME COLUMN penalty_per_pct TO penalty_per_pct_old;
+ALTER TABLE price_lists ADD COLUMN penalty_per_pct TEXT;
+UPDATE price_lists SET base_price = CAST(base_price_old AS TEXT), bonus_per_pct = CAST(bonus_per_pct_old AS TEXT), penalty_per_pct = CAST(penalty_per_pct_old AS TEXT);
+ALTER TABLE price_lists DROP COLUMN base_price_old;
+ALTER TABLE price_lists DROP COLUMN bonus_per_pct_old;
+ALTER TABLE price_lists DROP COLUMN penalty_per_pct_old;
+ALTER TABLE sales_order_items RENAME COLUMN price_per_unit TO price_per_unit_old;
+ALTER TABLE sales_order_items ADD COLUMN price_per_unit TEXT;
+ALTER TABLE sales_order_items RENAME COLUMN list_price TO list_price_old;
+ALTER TABLE sales_order_items ADD COLUMN list_price TEXT;
+ALTER TABLE sales_order_items RENAME COLUMN amount TO amount_old;
+ALTER TABLE sales_order_items ADD COLUMN amount TEXT;
+UPDATE sales_order_items SET price_per_unit = CAST(price_per_unit_old AS TEXT), list_price = CAST(list_price_old AS TEXT), amount = CAST(amount_old AS TEXT);
+ALTER TABLE sales_order_items DROP COLUMN price_per_unit_old;
+ALTER TABLE sales_order_items DROP COLUMN list_price_old;
+ALTER TABLE sales_order_items DROP COLUMN amount_old;
+ALTER TABLE sales_orders RENAME COLUMN amount TO amount_old;
+ALTER TABLE sales_orders ADD COLUMN amount TEXT;
+ALTER TABLE sales_orders RENAME COLUMN vat_amount TO vat_amount_old;
+ALTER TABLE sales_orders ADD COLUMN vat_amount TEXT;
+ALTER TABLE sales_orders RENAME COLUMN total_amount TO total_amount_old;
+ALTER TABLE sales_orders ADD COLUMN total_amount TEXT;
+UPDATE sales_orders SET amount = CAST(amount_old AS TEXT), vat_amount = CAST(vat_amount_old AS TEXT), total_amount = CAST(total_amount_old AS TEXT);
+ALTER TABLE sales_orders DROP COLUMN amount_old;
+ALTER TABLE sales_orders DROP COLUMN vat_amount_old;
+ALTER TABLE sales_orders DROP COLUMN total_amount_old;
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    currency TEXT NOT NULL,
+    rate_date TEXT NOT NULL,
+    rate TEXT NOT NULL,
+    source TEXT,
+    created_at TEXT,
+    updated_at TEXT,
+    UNIQUE (currency, rate_date)
+);
+ALTER TABLE price_lists ADD COLUMN currency TEXT;
+ALTER TABLE sales_orders ADD COLUMN currency TEXT;
+ALTER TABLE sales_orders ADD COLUMN rate TEXT;
+ALTER TABLE sales_orders ADD COLUMN rate_date TEXT;
+ALTER TABLE sales_orders ADD COLUMN reporting_currency TEXT;
+-- Until now every price was in roubles; confirmed orders get the rate 1.
+UPDATE price_lists SET currency = 'RUB';
+UPDATE sales_orders SET currency = 'RUB';
+UPDATE sales_orders SET rate = 1, rate_date = COALESCE(NULLIF(order_date, ''), SUBSTR(created_at, 1, 10)), reporting_currency = 'RUB'
+    WHERE status IN ('Подтвержден', 'Отгружен', 'Закрыт');
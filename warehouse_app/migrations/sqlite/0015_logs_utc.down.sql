@@ -0,0 +1,2 @@
+-- UTC event times are valid RFC 3339 and stay as they are.
+SELECT 1;
@@ -0,0 +1,3 @@
+-- Event times are compared as strings, so they are kept in UTC.
+UPDATE logs SET event_time = strftime('%Y-%m-%dT%H:%M:%SZ', event_time)
+WHERE event_time IS NOT NULL AND strftime('%Y-%m-%dT%H:%M:%SZ', event_time) IS NOT NULL;
@@ -0,0 +1,3 @@
+-- SQLite keeps its search triggers in searchSources (search.go): they are
+-- recreated on start, and the index is rebuilt whenever their layout changes.
+SELECT 1;
@@ -0,0 +1,2 @@
+-- The rebuilt table has the same columns; only the constraint is new.
+SELECT 1;
@@ -0,0 +1,28 @@
+-- 0014 re-added base_price as text through ALTER TABLE, which cannot declare
+-- NOT NULL; the table is rebuilt to get the constraint back.
+CREATE TABLE price_lists_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ore_type_id INTEGER NOT NULL,
+    contractor_id INTEGER,
+    unit_id INTEGER NOT NULL,
+    base_price TEXT NOT NULL,
+    base_grade REAL,
+    bonus_per_pct TEXT,
+    penalty_per_pct TEXT,
+    currency TEXT,
+    valid_from TEXT NOT NULL,
+    valid_to TEXT,
+    note TEXT,
+    created_at TEXT,
+    updated_at TEXT,
+    FOREIGN KEY (ore_type_id) REFERENCES ore_types(id) ON DELETE CASCADE,
+    FOREIGN KEY (contractor_id) REFERENCES contractors(id) ON DELETE CASCADE,
+    FOREIGN KEY (unit_id) REFERENCES units(id)
+);
+INSERT INTO price_lists_new (id, ore_type_id, contractor_id, unit_id, base_price, base_grade, bonus_per_pct, penalty_per_pct,
+        currency, valid_from, valid_to, note, created_at, updated_at)
+    SELECT id, ore_type_id, contractor_id, unit_id, base_price, base_grade, bonus_per_pct, penalty_per_pct,
+        currency, valid_from, valid_to, note, created_at, updated_at FROM price_lists;
+DROP TABLE price_lists;
+ALTER TABLE price_lists_new RENAME TO price_lists;
+CREATE INDEX IF NOT EXISTS idx_price_lists_ore_type ON price_lists(ore_type_id, valid_from);
@@ -0,0 +1,32 @@
+ALTER TABLE equipment RENAME COLUMN purchase_cost TO purchase_cost_old;
+ALTER TABLE equipment ADD COLUMN purchase_cost REAL;
+UPDATE equipment SET purchase_cost = CAST(purchase_cost_old AS REAL);
+ALTER TABLE equipment DROP COLUMN purchase_cost_old;
+ALTER TABLE equipment RENAME COLUMN salvage_value TO salvage_value_old;
+ALTER TABLE equipment ADD COLUMN salvage_value REAL;
+UPDATE equipment SET salvage_value = CAST(salvage_value_old AS REAL);
+ALTER TABLE equipment DROP COLUMN salvage_value_old;
+ALTER TABLE equipment RENAME COLUMN residual_value TO residual_value_old;
+ALTER TABLE equipment ADD COLUMN residual_value REAL;
+UPDATE equipment SET residual_value = CAST(residual_value_old AS REAL);
+ALTER TABLE equipment DROP COLUMN residual_value_old;
+ALTER TABLE ore_types RENAME COLUMN price TO price_old;
+ALTER TABLE ore_types ADD COLUMN price REAL;
+UPDATE ore_types SET price = CAST(price_old AS REAL);
+ALTER TABLE ore_types DROP COLUMN price_old;
+ALTER TABLE spare_parts RENAME COLUMN unit_cost TO unit_cost_old;
+ALTER TABLE spare_parts ADD COLUMN unit_cost REAL;
+UPDATE spare_parts SET unit_cost = CAST(unit_cost_old AS REAL);
+ALTER TABLE spare_parts DROP COLUMN unit_cost_old;
+ALTER TABLE work_order_parts RENAME COLUMN unit_cost TO unit_cost_old;
+ALTER TABLE work_order_parts ADD COLUMN unit_cost REAL;
+UPDATE work_order_parts SET unit_cost = CAST(unit_cost_old AS REAL);
+ALTER TABLE work_order_parts DROP COLUMN unit_cost_old;
+ALTER TABLE purchase_orders RENAME COLUMN total_amount TO total_amount_old;
+ALTER TABLE purchase_orders ADD COLUMN total_amount REAL NOT NULL DEFAULT 0;
+UPDATE purchase_orders SET total_amount = CAST(total_amount_old AS REAL);
+ALTER TABLE purchase_orders DROP COLUMN total_amount_old;
+ALTER TABLE purchase_order_items RENAME COLUMN price TO price_old;
+ALTER TABLE purchase_order_items ADD COLUMN price REAL NOT NULL DEFAULT 0;
+UPDATE purchase_order_items SET price = CAST(price_old AS REAL);
+ALTER TABLE purchase_order_items DROP COLUMN price_old;
@@ -0,0 +1,8 @@
+-- Due and lease times are compared as strings, so they are kept in UTC like
+-- log event times (0015); attempt times follow them.
+UPDATE webhook_deliveries SET next_attempt_at = strftime('%Y-%m-%dT%H:%M:%SZ', next_attempt_at)
+WHERE next_attempt_at IS NOT NULL AND strftime('%Y-%m-%dT%H:%M:%SZ', next_attempt_at) IS NOT NULL;
+UPDATE webhook_deliveries SET delivered_at = strftime('%Y-%m-%dT%H:%M:%SZ', delivered_at)
+WHERE delivered_at IS NOT NULL AND strftime('%Y-%m-%dT%H:%M:%SZ', delivered_at) IS NOT NULL;
+UPDATE webhook_attempts SET attempted_at = strftime('%Y-%m-%dT%H:%M:%SZ', attempted_at)
+WHERE attempted_at IS NOT NULL AND strftime('%Y-%m-%dT%H:%M:%SZ', attempted_at) IS NOT NULL;
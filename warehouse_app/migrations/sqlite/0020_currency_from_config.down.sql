@@ -0,0 +1,2 @@
+-- The currencies filled in at startup are kept.
+SELECT 1;
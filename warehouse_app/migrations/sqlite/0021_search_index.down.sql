@@ -0,0 +1,22 @@
+DROP TRIGGER IF EXISTS ore_batches_search_ai;
+DROP TRIGGER IF EXISTS ore_batches_search_au;
+DROP TRIGGER IF EXISTS ore_batches_search_ad;
+DROP TRIGGER IF EXISTS ore_types_search_ore_batch_au;
+DROP TRIGGER IF EXISTS sales_orders_search_ai;
+DROP TRIGGER IF EXISTS sales_orders_search_au;
+DROP TRIGGER IF EXISTS sales_orders_search_ad;
+DROP TRIGGER IF EXISTS contractors_search_order_au;
+DROP TRIGGER IF EXISTS contractors_search_ai;
+DROP TRIGGER IF EXISTS contractors_search_au;
+DROP TRIGGER IF EXISTS contractors_search_ad;
+DROP TRIGGER IF EXISTS equipment_search_ai;
+DROP TRIGGER IF EXISTS equipment_search_au;
+DROP TRIGGER IF EXISTS equipment_search_ad;
+DROP TRIGGER IF EXISTS transport_search_ai;
+DROP TRIGGER IF EXISTS transport_search_au;
+DROP TRIGGER IF EXISTS transport_search_ad;
+DROP TRIGGER IF EXISTS logs_search_ai;
+DROP TRIGGER IF EXISTS logs_search_au;
+DROP TRIGGER IF EXISTS logs_search_ad;
+DROP TABLE IF EXISTS search_index_state;
+DROP TABLE IF EXISTS search_index;
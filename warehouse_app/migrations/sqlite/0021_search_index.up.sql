@@ -0,0 +1,20 @@
+-- Before this migration the server created the index tables itself, so
+-- databases that already have them keep them. Databases migrated while the
+-- tables were numbered 0003 have a row for it in schema_migrations; SQLite
+-- has no migration 0003, so the row goes.
+DELETE FROM schema_migrations WHERE version = 3;
+
+-- The plain table used when SQLite is built without FTS5. On start the
+-- server swaps it for an FTS5 table when FTS5 is available and installs the
+-- triggers from searchSources (search.go), which fill the index.
+CREATE TABLE IF NOT EXISTS search_index (
+    entity TEXT NOT NULL,
+    entity_id INTEGER NOT NULL,
+    title TEXT,
+    body TEXT
+);
+
+-- Layout of the indexed rows; the index is rebuilt when it changes.
+CREATE TABLE IF NOT EXISTS search_index_state (
+    fingerprint TEXT NOT NULL
+);
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Decimal is a fixed-point number with six decimal places, used for prices,
+// amounts and exchange rates so that they are stored and added up exactly.
+// It is written to JSON as a number and to the database as text, which
+// NUMERIC columns accept.
+type Decimal int64
+
+const (
+	decimalPlaces = 6
+	decimalScale  = 1_000_000
+)
+
+var decimalType = reflect.TypeOf(Decimal(0))
+
+// errDecimalRange is wrapped by the errors of operations whose result does
+// not fit in a Decimal; writeError answers it with 422.
+var errDecimalRange = errors.New("decimal out of range")
+
+// plainDecimal is what parseDecimal accepts: big.Rat alone would also take
+// fractions such as 1/3 and exponents such as 1e3.
+var plainDecimal = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+
+// parseDecimal reads a decimal number, rounding it half away from zero to
+// six places.
+func parseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if !plainDecimal.MatchString(s) {
+		return 0, fmt.Errorf("invalid decimal %q", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid decimal %q", s)
+	}
+	return decimalOfRat(r)
+}
+
+// decimalOf converts a float as it would be printed, so 0.1 becomes 0.1
+// rather than the nearest binary fraction.
+func decimalOf(f float64) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%g: %w", f, errDecimalRange)
+	}
+	return parseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+func decimalOfRat(r *big.Rat) (Decimal, error) {
+	n := new(big.Int).Mul(r.Num(), big.NewInt(decimalScale))
+	q, m := new(big.Int).QuoRem(n, r.Denom(), new(big.Int))
+	if m.Sign() != 0 && new(big.Int).Abs(new(big.Int).Mul(m, big.NewInt(2))).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(n.Sign())))
+	}
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("%s: %w", r.FloatString(decimalPlaces), errDecimalRange)
+	}
+	return Decimal(q.Int64()), nil
+}
+
+func (d Decimal) rat() *big.Rat {
+	return big.NewRat(int64(d), decimalScale)
+}
+
+// Add returns d+e.
+func (d Decimal) Add(e Decimal) (Decimal, error) {
+	sum := d + e
+	if (e > 0 && sum < d) || (e < 0 && sum > d) {
+		return 0, fmt.Errorf("%s + %s: %w", d, e, errDecimalRange)
+	}
+	return sum, nil
+}
+
+// Mul returns d·e rounded to six places.
+func (d Decimal) Mul(e Decimal) (Decimal, error) {
+	return decimalOfRat(new(big.Rat).Mul(d.rat(), e.rat()))
+}
+
+// MulFloat multiplies by a quantity or a percentage kept as a float.
+func (d Decimal) MulFloat(f float64) (Decimal, error) {
+	e, err := decimalOf(f)
+	if err != nil {
+		return 0, err
+	}
+	return d.Mul(e)
+}
+
+// Div returns d/e rounded to six places.
+func (d Decimal) Div(e Decimal) (Decimal, error) {
+	if e == 0 {
+		return 0, fmt.Errorf("%s / 0: %w", d, errDecimalRange)
+	}
+	return decimalOfRat(new(big.Rat).Quo(d.rat(), e.rat()))
+}
+
+// Round rounds half away from zero to the given number of places.
+func (d Decimal) Round(places int) Decimal {
+	unit := Decimal(math.Pow10(decimalPlaces - places))
+	q, m := d/unit, d%unit
+	if 2*m >= unit {
+		q++
+	} else if 2*m <= -unit {
+		q--
+	}
+	return q * unit
+}
+
+// Share divides d into n parts in whole cents; the first part takes what
+// is left over, so the parts add up to d.
+func (d Decimal) Share(n int) []Decimal {
+	const cent = decimalScale / 100
+	part := d / Decimal(n)
+	part -= part % cent
+	parts := make([]Decimal, n)
+	parts[0] = d - part*Decimal(n-1)
+	for i := 1; i < n; i++ {
+		parts[i] = part
+	}
+	return parts
+}
+
+func (d Decimal) Float64() float64 {
+	return float64(d) / decimalScale
+}
+
+// String prints d without trailing zeros.
+func (d Decimal) String() string {
+	sign := ""
+	v := int64(d)
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	s := sign + strconv.FormatInt(v/decimalScale, 10)
+	if frac := v % decimalScale; frac != 0 {
+		s += "." + strings.TrimRight(fmt.Sprintf("%0*d", decimalPlaces, frac), "0")
+	}
+	return s
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts a number or a string holding one.
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := parseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+func (d *Decimal) UnmarshalText(b []byte) error {
+	v, err := parseDecimal(string(b))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Scan reads text and NUMERIC columns exactly; REAL values written before
+// the columns were converted are read as they print, including the
+// exponent form SQLite gave very small and large ones when casting to text.
+func (d *Decimal) Scan(src interface{}) error {
+	var err error
+	switch v := src.(type) {
+	case nil:
+		*d = 0
+	case int64:
+		*d, err = decimalOfRat(new(big.Rat).SetInt64(v))
+	case float64:
+		*d, err = decimalOf(v)
+	case []byte:
+		*d, err = scanDecimalText(string(v))
+	case string:
+		*d, err = scanDecimalText(v)
+	default:
+		err = fmt.Errorf("cannot scan %T into Decimal", src)
+	}
+	return err
+}
+
+func scanDecimalText(s string) (Decimal, error) {
+	if d, err := parseDecimal(s); err == nil {
+		return d, nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid decimal %q", s)
+	}
+	return decimalOf(f)
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestDecimalOverflow(t *testing.T) {
+	huge := Decimal(math.MaxInt64 - 1)
+	two, _ := parseDecimal("2")
+	ops := map[string]func() (Decimal, error){
+		"add":       func() (Decimal, error) { return huge.Add(huge) },
+		"mul":       func() (Decimal, error) { return huge.Mul(two) },
+		"mul float": func() (Decimal, error) { return huge.MulFloat(1e6) },
+		"div zero":  func() (Decimal, error) { return two.Div(0) },
+		"nan":       func() (Decimal, error) { return decimalOf(math.NaN()) },
+	}
+	for name, op := range ops {
+		d, err := op()
+		if !errors.Is(err, errDecimalRange) {
+			t.Errorf("%s: got %s, %v; want errDecimalRange", name, d, err)
+			continue
+		}
+		if status, detail := errorEnvelope(t, err); status != http.StatusUnprocessableEntity || detail.Code != "amount_out_of_range" {
+			t.Errorf("%s: got %d %s, want 422 amount_out_of_range", name, status, detail.Code)
+		}
+	}
+
+	half, _ := parseDecimal("0.5")
+	if d, err := two.Mul(half); err != nil || d.String() != "1" {
+		t.Errorf("2 * 0.5 = %s, %v", d, err)
+	}
+	if d, err := two.Div(half); err != nil || d.String() != "4" {
+		t.Errorf("2 / 0.5 = %s, %v", d, err)
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"12.5", "12.5", true},
+		{" -0.1234565 ", "-0.123457", true},
+		{"+7", "7", true},
+		{".5", "0.5", true},
+		{"3.", "3", true},
+		{"1/3", "", false},
+		{"1e3", "", false},
+		{"0x10", "", false},
+		{"1,5", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		d, err := parseDecimal(tt.in)
+		if (err == nil) != tt.ok || tt.ok && d.String() != tt.want {
+			t.Errorf("parseDecimal(%q) = %s, %v; want %q, ok %v", tt.in, d, err, tt.want, tt.ok)
+		}
+	}
+
+	// Text columns converted from REAL may hold SQLite's exponent form.
+	var d Decimal
+	if err := d.Scan("1.0e-05"); err != nil || d.String() != "0.00001" {
+		t.Errorf("Scan(1.0e-05) = %s, %v", d, err)
+	}
+}
+
+func TestDecimalShare(t *testing.T) {
+	tests := []struct {
+		total string
+		n     int
+		want  string
+	}{
+		{"900", 3, "[300 300 300]"},
+		{"1000", 3, "[333.34 333.33 333.33]"},
+		{"0.05", 3, "[0.03 0.01 0.01]"},
+		{"10.000001", 2, "[5.000001 5]"},
+		{"7", 1, "[7]"},
+	}
+	for _, tt := range tests {
+		total, _ := parseDecimal(tt.total)
+		if got := fmt.Sprint(total.Share(tt.n)); got != tt.want {
+			t.Errorf("%s shared by %d = %s, want %s", tt.total, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type jsonObject = map[string]interface{}
+
+// openAPIDocument describes routes as served under version as an OpenAPI
+// 3.0 document. Schemas are derived from the Go types by reflection,
+// including their validate tags.
+func openAPIDocument(routes []apiRoute, version string) jsonObject {
+	b := &schemaBuilder{schemas: jsonObject{}}
+	paths := jsonObject{}
+	for _, rt := range routes {
+		path := openAPIPath(versionedPath(version, rt.Path))
+		item, _ := paths[path].(jsonObject)
+		if item == nil {
+			item = jsonObject{}
+			paths[path] = item
+		}
+		item[strings.ToLower(rt.Method)] = b.operation(rt)
+	}
+	b.schema(reflect.TypeOf(ErrorResponse{}))
+	return jsonObject{
+		"openapi": "3.0.3",
+		"info": jsonObject{
+			"title":       "ISUS2 API",
+			"version":     version,
+			"description": "Пути без версии (/api/...) — устаревшие псевдонимы " + legacyAPIVersion + ".",
+		},
+		"paths":      paths,
+		"components": jsonObject{"schemas": b.schemas},
+	}
+}
+
+// serveOpenAPI renders the document of each version once, on its first
+// request.
+func serveOpenAPI(routes *[]apiRoute) http.HandlerFunc {
+	var (
+		mu   sync.Mutex
+		docs = map[string][]byte{}
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		version := requestAPIVersion(r.Context())
+		mu.Lock()
+		doc, ok := docs[version]
+		if !ok {
+			doc, _ = json.MarshalIndent(openAPIDocument(*routes, version), "", "  ")
+			docs[version] = doc
+		}
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(doc)
+	}
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)(?::[^}]*)?\}`)
+
+// openAPIPath drops mux regexps from path parameters.
+func openAPIPath(path string) string {
+	return pathParam.ReplaceAllString(path, "{$1}")
+}
+
+type schemaBuilder struct {
+	schemas jsonObject
+}
+
+func schemaRef(name string) jsonObject {
+	return jsonObject{"$ref": "#/components/schemas/" + name}
+}
+
+func (b *schemaBuilder) operation(rt apiRoute) jsonObject {
+	op := jsonObject{
+		"operationId": rt.Operation,
+		"summary":     rt.Summary,
+		"tags":        []string{rt.Tag},
+	}
+
+	var params []jsonObject
+	for _, m := range pathParam.FindAllStringSubmatch(rt.Path, -1) {
+		params = append(params, jsonObject{"name": m[1], "in": "path", "required": true, "schema": jsonObject{"type": "integer"}})
+	}
+	for _, p := range append(listParams(rt.List), rt.Params...) {
+		schema := jsonObject{"type": p.Type}
+		if len(p.Enum) > 0 {
+			schema["enum"] = p.Enum
+		}
+		param := jsonObject{"name": p.Name, "in": "query", "schema": schema}
+		if p.Required {
+			param["required"] = true
+		}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		params = append(params, param)
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if rt.Request != nil {
+		op["requestBody"] = jsonObject{
+			"required": true,
+			"content":  jsonObject{"application/json": jsonObject{"schema": b.schema(reflect.TypeOf(rt.Request))}},
+		}
+	}
+	if len(rt.Consumes) > 0 {
+		content := jsonObject{}
+		for _, mediaType := range rt.Consumes {
+			content[mediaType] = jsonObject{"schema": jsonObject{"type": "string", "format": "binary"}}
+		}
+		op["requestBody"] = jsonObject{"required": true, "content": content}
+	}
+
+	content := jsonObject{}
+	switch {
+	case rt.Paged:
+		content["application/json"] = jsonObject{"schema": b.page(reflect.TypeOf(rt.Response))}
+	case rt.Response != nil:
+		content["application/json"] = jsonObject{"schema": b.schema(reflect.TypeOf(rt.Response))}
+	}
+	for _, mediaType := range rt.Produces {
+		if _, ok := content[mediaType]; !ok {
+			content[mediaType] = jsonObject{"schema": jsonObject{"type": "string"}}
+		}
+	}
+	op["responses"] = jsonObject{
+		"200": jsonObject{"description": "OK", "content": content},
+		"default": jsonObject{
+			"description": "Ошибка",
+			"content":     jsonObject{"application/json": jsonObject{"schema": schemaRef("ErrorResponse")}},
+		},
+	}
+	return op
+}
+
+// listParams mirrors parseListQuery and fieldFilters.
+func listParams(spec *listSpec) []apiParam {
+	if spec == nil {
+		return nil
+	}
+	names := make([]string, 0, len(spec.Fields))
+	var sortable []string
+	for name := range spec.Fields {
+		names = append(names, name)
+		if spec.sortable(name) {
+			sortable = append(sortable, name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(sortable)
+
+	params := []apiParam{
+		{Name: "limit", Type: "integer", Description: fmt.Sprintf("по умолчанию %d, не больше %d", defaultListLimit, maxListLimit)},
+		{Name: "cursor", Type: "string", Description: "next_cursor предыдущей страницы"},
+		{Name: "sort", Type: "string", Description: fmt.Sprintf("поля через запятую, - означает убывание; по умолчанию %s; поля: %s", spec.DefaultSort, strings.Join(sortable, ", "))},
+	}
+	for _, name := range names {
+		params = append(params, apiParam{Name: name, Type: "string", Description: "значения через запятую"})
+		switch spec.Fields[name] {
+		case intField, floatField:
+			params = append(params,
+				apiParam{Name: name + "_min", Type: "number"},
+				apiParam{Name: name + "_max", Type: "number"})
+		case dateField:
+			params = append(params,
+				apiParam{Name: name + "_from", Type: "string", Description: "YYYY-MM-DD или RFC3339"},
+				apiParam{Name: name + "_to", Type: "string", Description: "YYYY-MM-DD или RFC3339, включительно"})
+		}
+	}
+	return params
+}
+
+// page describes a ListPage whose items are of type t.
+func (b *schemaBuilder) page(t reflect.Type) jsonObject {
+	name := t.Name() + "Page"
+	if _, ok := b.schemas[name]; !ok {
+		b.schemas[name] = jsonObject{
+			"type":     "object",
+			"required": []string{"items", "total"},
+			"properties": jsonObject{
+				"items":       jsonObject{"type": "array", "items": b.schema(t)},
+				"total":       jsonObject{"type": "integer"},
+				"next_cursor": jsonObject{"type": "string"},
+			},
+		}
+	}
+	return schemaRef(name)
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) jsonObject {
+	if t == decimalType {
+		return jsonObject{"type": "number", "format": "decimal"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := b.schema(t.Elem())
+		s["nullable"] = true
+		return s
+	case reflect.Bool:
+		return jsonObject{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return jsonObject{"type": "integer"}
+	case reflect.Float64:
+		return jsonObject{"type": "number"}
+	case reflect.String:
+		return jsonObject{"type": "string"}
+	case reflect.Slice:
+		return jsonObject{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return jsonObject{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if _, ok := b.schemas[t.Name()]; !ok {
+			b.schemas[t.Name()] = jsonObject{} // guards against recursion
+			b.schemas[t.Name()] = b.object(t)
+		}
+		return schemaRef(t.Name())
+	}
+	return jsonObject{}
+}
+
+func (b *schemaBuilder) object(t reflect.Type) jsonObject {
+	properties := jsonObject{}
+	var required []string
+	for _, f := range jsonFields(t) {
+		s := b.schema(f.Type)
+		for _, rule := range f.Rules {
+			key, arg, _ := strings.Cut(rule, "=")
+			switch key {
+			case "required":
+				required = append(required, f.Name)
+			case "positive":
+				s["minimum"], s["exclusiveMinimum"] = 0, true
+			case "min":
+				s["minimum"] = json.Number(arg)
+			case "max":
+				s["maximum"] = json.Number(arg)
+			case "enum":
+				if f.Type.Kind() == reflect.Slice {
+					s["items"] = jsonObject{"type": "string", "enum": enums[arg]}
+				} else {
+					s["enum"] = enums[arg]
+				}
+			case "url":
+				s["format"] = "uri"
+			case "currency":
+				s["pattern"] = "^[A-Z]{3}$"
+			case "date":
+				s["description"] = "YYYY-MM-DD или RFC3339"
+			case "notfuture":
+				s["description"] = "YYYY-MM-DD или RFC3339, не позже сегодняшнего дня"
+			case "ref":
+				s["description"] = "id записи из " + arg
+			}
+		}
+		properties[f.Name] = s
+	}
+	schema := jsonObject{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+type jsonField struct {
+	GoName string
+	Name   string
+	Type   reflect.Type
+	Tag    string
+	Rules  []string
+}
+
+// jsonFields lists the exported fields of t as encoding/json sees them.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		var rules []string
+		if tag := f.Tag.Get("validate"); tag != "" {
+			rules = strings.Split(tag, ",")
+		}
+		fields = append(fields, jsonField{GoName: f.Name, Name: name, Type: f.Type, Tag: string(f.Tag), Rules: rules})
+	}
+	return fields
+}
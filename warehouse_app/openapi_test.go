@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// TestAPIMatchesOpenAPI calls every route in apiRoutes through the router
+// and checks the responses against the document served for the version.
+// The steps build on each other the way a client would; a route added
+// without a step fails the test.
+func TestAPIMatchesOpenAPI(t *testing.T) {
+	store := openTestSQLite(t)
+	cfg := defaultConfig()
+	if err := resolveBusinessConfig(store, &cfg.Business); err != nil {
+		t.Fatal(err)
+	}
+	events := newEventBus()
+	defer events.close()
+	store.Logs = publishingLogStore{LogStore: store.Logs, events: events}
+
+	router := mux.NewRouter()
+	routes := apiRoutes(store, cfg, events)
+	registerRoutes(router, routes, cfg.API)
+	if err := checkRoutes(router, routes); err != nil {
+		t.Fatal(err)
+	}
+	api := &apiClient{t: t, router: router, routes: routes, version: currentAPIVersion(), called: map[string]bool{}}
+	api.doc = mustJSON(t, openAPIDocument(routes, api.version))
+	refs := loadTestRefs(t, store)
+	today := time.Now().Format("2006-01-02")
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	categories, err := store.Reference.EquipmentCategories(context.Background())
+	if err != nil || len(categories) == 0 {
+		t.Fatalf("no equipment categories: %v", err)
+	}
+	category := categories[0].ID
+
+	api.call("getReferenceData", nil, nil)
+
+	api.call("createOreBatch", nil, jsonObject{"ore_type_id": refs.oreType, "warehouse_id": refs.warehouses[0], "unit_id": refs.unit,
+		"batch_code": "П-1", "quantity": 100, "quality": 62.5, "priority": "Высокий", "status": "На складе"})
+	api.upload("importOreBatches", "text/csv", fmt.Sprintf("ore_type_id,warehouse_id,unit_id,batch_code,quantity\n%d,%d,%d,П-2,50\n",
+		refs.oreType, refs.warehouses[1], refs.unit))
+	batch := api.lastID("listOreBatches")
+
+	api.call("createEquipment", nil, jsonObject{"name": "Экскаватор", "category_id": category, "warehouse_id": refs.warehouses[0], "unit_id": refs.unit,
+		"quantity": 1, "serial_number": "SN-1", "inventory_number": "ИНВ-1", "service_life_months": 60, "purchase_date": "2025-01-15",
+		"purchase_cost": 1200000, "salvage_value": 100000})
+	machine := api.lastID("listEquipment")
+	api.call("createEquipment", nil, jsonObject{"name": "Каска", "category_id": category, "warehouse_id": refs.warehouses[0], "unit_id": refs.unit, "quantity": 2})
+	api.call("splitEquipment", []int{api.lastID("listEquipment")}, jsonObject{"units": []jsonObject{
+		{"serial_number": "К-1", "inventory_number": "ИНВ-К-1"}, {"serial_number": "К-2", "inventory_number": "ИНВ-К-2"}}})
+	api.upload("importEquipment", "text/csv", fmt.Sprintf("name,category_id,warehouse_id,unit_id,quantity\nПогрузчик,%d,%d,%d,1\n", category, refs.warehouses[1], refs.unit))
+
+	api.call("createMeterReading", nil, jsonObject{"equipment_id": machine, "meter": meterHours, "value": 100, "read_at": yesterday})
+	api.upload("importMeterReadings", "text/csv", fmt.Sprintf("equipment_id,meter,value\n%d,%s,120\n", machine, meterHours))
+	api.call("createMeterReading", nil, jsonObject{"equipment_id": machine, "meter": meterMileage, "value": 10})
+	api.call("deleteMeterReading", []int{api.lastID("listMeterReadings")}, nil)
+	api.call("getUtilization", nil, nil)
+
+	api.call("checkOutEquipment", nil, jsonObject{"equipment_id": machine, "warehouse_id": refs.warehouses[1], "responsible": "Иванов", "site": "Карьер"})
+	api.call("returnEquipment", []int{api.lastID("listEquipmentAssignments")}, jsonObject{})
+	api.call("getEquipmentLocations", nil, nil, "date="+today)
+
+	api.call("createSparePart", nil, jsonObject{"name": "Фильтр", "part_number": "Ф-1", "unit_id": refs.unit, "supplier_id": refs.supplier, "unit_cost": 1500})
+	part := api.lastID("listSpareParts")
+	api.call("setPartLevels", []int{part}, jsonObject{"warehouse_id": refs.warehouses[0], "min_quantity": 5, "max_quantity": 10})
+	api.call("createPartMovement", nil, jsonObject{"part_id": part, "warehouse_id": refs.warehouses[0], "kind": partReceipt, "quantity": 3})
+	api.call("listPartStock", nil, nil)
+	api.call("listPartMovements", nil, nil)
+	var reorder []ReorderGroup
+	api.decode(api.call("getReorderReport", nil, nil), &reorder)
+	if len(reorder) != 1 || len(reorder[0].Lines) != 1 || reorder[0].Lines[0].Suggested != 7 {
+		t.Errorf("reorder report %+v, want 7 of part %d", reorder, part)
+	}
+
+	api.call("createPurchaseOrder", nil, jsonObject{"order_number": "ЗП-1", "supplier_id": refs.supplier, "warehouse_id": refs.warehouses[0],
+		"items": []jsonObject{{"part_id": part, "quantity": 4, "price": 1400}}})
+	purchase := api.lastID("listPurchaseOrders")
+	var order PurchaseOrder
+	api.decode(api.call("getPurchaseOrder", []int{purchase}, nil), &order)
+	api.call("updatePurchaseOrderStatus", []int{purchase}, jsonObject{"status": purchaseSent})
+	api.call("receivePurchaseOrder", []int{purchase}, jsonObject{"items": []jsonObject{{"order_item_id": order.Items[0].ID, "quantity": 2}}})
+	api.call("listGoodsReceipts", nil, nil)
+	// 5 in stock and 2 still on order reach the minimum of 5.
+	api.decode(api.call("getReorderReport", nil, nil), &reorder)
+	if len(reorder) != 0 {
+		t.Errorf("reorder report %+v, want nothing with the rest on order", reorder)
+	}
+
+	api.call("createMaintenancePlan", nil, jsonObject{"equipment_id": machine, "name": "ТО-1", "interval_days": 30, "start_date": "2026-01-01"})
+	plan := api.lastID("listMaintenancePlans")
+	api.call("createMaintenancePlan", nil, jsonObject{"equipment_id": machine, "name": "ТО-2", "interval_hours": 250})
+	api.call("deleteMaintenancePlan", []int{api.lastID("listMaintenancePlans")}, nil)
+	api.call("listOverdueMaintenance", nil, nil)
+	api.call("createWorkOrder", nil, jsonObject{"equipment_id": machine, "plan_id": plan, "title": "Плановое ТО"})
+	work := api.lastID("listWorkOrders")
+	api.call("addWorkOrderPart", []int{work}, jsonObject{"part_id": part, "quantity": 1})
+	api.call("addWorkOrderLabor", []int{work}, jsonObject{"worker": "Петров", "hours": 2.5})
+	api.call("updateWorkOrderStatus", []int{work}, jsonObject{"status": workOrderInProgress})
+	api.call("updateWorkOrderStatus", []int{work}, jsonObject{"status": workOrderDone, "meter_hours": 130})
+	api.call("getWorkOrder", []int{work}, nil)
+
+	api.call("createPriceList", nil, jsonObject{"ore_type_id": refs.oreType, "unit_id": refs.unit, "base_price": 3000, "base_grade": 60,
+		"bonus_per_pct": 50, "penalty_per_pct": 70, "valid_from": "2026-01-01"})
+	api.call("createPriceList", nil, jsonObject{"ore_type_id": refs.oreType, "contractor_id": refs.supplier, "unit_id": refs.unit, "base_price": 2900})
+	api.call("deletePriceList", []int{api.lastID("listPriceLists")}, nil)
+	api.call("getPriceQuote", nil, nil, fmt.Sprintf("ore_batch_id=%d&contractor_id=%d", batch, refs.contractor))
+	api.call("createWebhook", nil, jsonObject{"url": "http://127.0.0.1:1/hook", "events": []string{webhookOrderConfirmed, webhookShipmentCompleted}})
+	webhook := api.lastID("listWebhooks")
+	api.call("createOrder", nil, jsonObject{"order_number": "З-1", "contractor_id": refs.contractor, "warehouse_id": refs.warehouses[0],
+		"items": []jsonObject{
+			{"ore_batch_id": batch, "unit_id": refs.unit, "quantity": 10},
+			{"ore_batch_id": batch, "unit_id": refs.unit, "quantity": 5, "price_per_unit": 3100, "price_reason": "Скидка за объём"},
+		}})
+	sale := api.lastID("listOrders")
+	api.call("updateOrderStatus", []int{sale}, jsonObject{"status": orderConfirmed})
+	api.call("listPriceOverrides", nil, nil)
+	api.call("setExchangeRate", nil, jsonObject{"currency": "USD", "rate_date": "2026-01-10", "rate": 90.5})
+	api.upload("importExchangeRates", "text/csv", "currency,rate_date,rate,nominal\nCNY,2026-01-10,12.4,1\n")
+	api.call("listExchangeRates", nil, nil)
+	api.call("getSalesRevenue", nil, nil)
+
+	api.call("createShipment", nil, jsonObject{"order_id": sale, "planned_date": today})
+	api.call("updateShipmentStatus", []int{api.lastID("listShipments")}, jsonObject{"status": shipmentCompleted})
+
+	delivery := api.lastID("listWebhookDeliveries", webhook)
+	api.call("getWebhookDelivery", []int{delivery}, nil)
+	api.call("redeliverWebhook", []int{delivery}, nil)
+	api.call("deleteWebhook", []int{webhook}, nil)
+
+	api.call("exportTo1C", nil, nil)
+	api.upload("importFrom1C", "application/xml", `<?xml version="1.0" encoding="UTF-8"?>
+<КоммерческаяИнформация ВерсияСхемы="2.10">
+  <Контрагенты><Контрагент><Ид>8f1c2d3e-0000-4000-8000-000000000001</Ид><Наименование>ООО Руда</Наименование></Контрагент></Контрагенты>
+</КоммерческаяИнформация>`)
+	api.call("getExchangeDocument", []int{api.lastID("listExchangeLog")}, nil)
+
+	api.call("writeOffEquipment", []int{machine}, jsonObject{"date": today, "reason": "Износ"})
+	api.call("getEquipmentDepreciation", []int{machine}, nil)
+	api.call("getDepreciationReport", nil, nil, "group_by=category")
+
+	api.call("listLogs", nil, nil, "limit=5")
+	api.call("exportLogs", nil, nil, "format=jsonl")
+	var hits []SearchHit
+	api.decode(api.call("search", nil, nil, "q=Экскаватор&type=equipment"), &hits)
+	if len(hits) == 0 || hits[0].Link != versionedPath(api.version, fmt.Sprintf("/equipment?id=%d", machine)) {
+		t.Errorf("search returned %+v, want a link to equipment %d", hits, machine)
+	}
+	api.call("streamEvents", nil, nil)
+	api.call("getOpenAPI", nil, nil)
+
+	for _, rt := range routes {
+		if !api.called[rt.Operation] {
+			t.Errorf("%s %s (%s) has no step in the test", rt.Method, rt.Path, rt.Operation)
+		}
+	}
+
+	// Older versions shape the same data differently; their list pages
+	// must match their own documents.
+	for _, version := range apiVersions[:len(apiVersions)-1] {
+		old := &apiClient{t: t, router: router, routes: routes, version: version, called: map[string]bool{}}
+		old.doc = mustJSON(t, openAPIDocument(routes, version))
+		for _, rt := range routes {
+			if rt.Method == "GET" && rt.Paged && !strings.Contains(rt.Path, "{") {
+				old.call(rt.Operation, nil, nil)
+			}
+		}
+	}
+}
+
+type apiClient struct {
+	t       *testing.T
+	router  http.Handler
+	routes  []apiRoute
+	version string
+	doc     jsonObject
+	called  map[string]bool
+}
+
+func mustJSON(t *testing.T, doc jsonObject) jsonObject {
+	t.Helper()
+	// Round-trip so the document reads the way a client sees it.
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out jsonObject
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func (c *apiClient) route(operation string) apiRoute {
+	c.t.Helper()
+	for _, rt := range c.routes {
+		if rt.Operation == operation {
+			return rt
+		}
+	}
+	c.t.Fatalf("no route %s", operation)
+	return apiRoute{}
+}
+
+// call sends body as JSON to the route of operation with ids in place of
+// its path parameters and checks the response.
+func (c *apiClient) call(operation string, ids []int, body interface{}, query ...string) []byte {
+	c.t.Helper()
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	return c.send(operation, ids, "application/json", data, strings.Join(query, "&"))
+}
+
+func (c *apiClient) upload(operation, contentType, content string) []byte {
+	c.t.Helper()
+	return c.send(operation, nil, contentType, []byte(content), "")
+}
+
+// lastID lists the records of operation and returns the id of the newest
+// one: the first of a page sorted by -id, or the last of a plain array.
+func (c *apiClient) lastID(operation string, ids ...int) int {
+	c.t.Helper()
+	var items []struct {
+		ID int `json:"id"`
+	}
+	if c.route(operation).Paged {
+		var page struct {
+			Items interface{} `json:"items"`
+		}
+		page.Items = &items
+		c.decode(c.call(operation, ids, nil, "sort=-id&limit=1"), &page)
+	} else {
+		c.decode(c.call(operation, ids, nil), &items)
+		slices.Reverse(items)
+	}
+	if len(items) == 0 {
+		c.t.Fatalf("%s returned no records", operation)
+	}
+	return items[0].ID
+}
+
+func (c *apiClient) decode(data []byte, v interface{}) {
+	c.t.Helper()
+	if err := json.Unmarshal(data, v); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+var pathParamPattern = regexp.MustCompile(`\{\w+\}`)
+
+func (c *apiClient) send(operation string, ids []int, contentType string, body []byte, query string) []byte {
+	c.t.Helper()
+	rt := c.route(operation)
+	c.called[operation] = true
+	path := openAPIPath(versionedPath(c.version, rt.Path))
+	path = pathParamPattern.ReplaceAllStringFunc(path, func(string) string {
+		if len(ids) == 0 {
+			c.t.Fatalf("%s needs more ids", operation)
+		}
+		id := ids[0]
+		ids = ids[1:]
+		return fmt.Sprint(id)
+	})
+	if query != "" {
+		path += "?" + query
+	}
+
+	req := httptest.NewRequest(rt.Method, path, bytes.NewReader(body))
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if slices.Contains(rt.Produces, "text/event-stream") {
+		ctx, cancel := context.WithTimeout(req.Context(), 50*time.Millisecond)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+	w := httptest.NewRecorder()
+	c.router.ServeHTTP(w, req)
+	resp := w.Result()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		c.t.Fatalf("%s %s: %d %s", rt.Method, path, resp.StatusCode, data)
+	}
+
+	op := c.operation(rt)
+	content, _ := op["responses"].(jsonObject)["200"].(jsonObject)["content"].(jsonObject)
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	spec, ok := content[mediaType].(jsonObject)
+	if !ok {
+		c.t.Fatalf("%s %s: response type %q is not in the document", rt.Method, path, mediaType)
+	}
+	if mediaType != "application/json" || spec["schema"].(jsonObject)["type"] == "string" {
+		return data
+	}
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		c.t.Fatalf("%s %s: %v", rt.Method, path, err)
+	}
+	for _, problem := range c.validate(spec["schema"].(jsonObject), value, "") {
+		c.t.Errorf("%s %s: %s", rt.Method, path, problem)
+	}
+	return data
+}
+
+func (c *apiClient) operation(rt apiRoute) jsonObject {
+	c.t.Helper()
+	item, _ := c.doc["paths"].(jsonObject)[openAPIPath(versionedPath(c.version, rt.Path))].(jsonObject)
+	op, ok := item[strings.ToLower(rt.Method)].(jsonObject)
+	if !ok {
+		c.t.Fatalf("%s %s is not in the document", rt.Method, rt.Path)
+	}
+	return op
+}
+
+// validate checks value against the subset of JSON Schema openapi.go
+// produces. Properties the schema does not list are reported too, so
+// response fields can't go undocumented.
+func (c *apiClient) validate(schema jsonObject, value interface{}, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		target, ok := c.doc["components"].(jsonObject)["schemas"].(jsonObject)[name].(jsonObject)
+		if !ok {
+			return []string{fmt.Sprintf("%s: unknown schema %s", at, ref)}
+		}
+		return c.validate(target, value, at)
+	}
+	if value == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: null is not allowed", at)}
+	}
+
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, at+": "+fmt.Sprintf(format, args...))
+	}
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("want an object, got %T", value)
+			break
+		}
+		properties, _ := schema["properties"].(jsonObject)
+		extra, _ := schema["additionalProperties"].(jsonObject)
+		for _, name := range sortedKeys(obj) {
+			s, ok := properties[name].(jsonObject)
+			if !ok {
+				s = extra
+			}
+			if s == nil {
+				fail("property %q is not in the schema", name)
+				continue
+			}
+			problems = append(problems, c.validate(s, obj[name], at+"."+name)...)
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				fail("required property %q is missing", name)
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("want an array, got %T", value)
+			break
+		}
+		for i, item := range items {
+			problems = append(problems, c.validate(schema["items"].(jsonObject), item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "integer":
+		if n, ok := value.(json.Number); !ok {
+			fail("want an integer, got %T", value)
+		} else if _, err := n.Int64(); err != nil {
+			fail("want an integer, got %s", n)
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			fail("want a number, got %T", value)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			fail("want a string, got %T", value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("want a boolean, got %T", value)
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && !slices.Contains(enum, value) {
+		fail("%v is not one of %v", value, enum)
+	}
+	return problems
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
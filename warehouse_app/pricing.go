@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	priceFromList = "Прайс-лист"
+	priceManual   = "Вручную"
+)
+
+type PriceList struct {
+	ID             int      `json:"id"`
+	OreTypeID      int      `json:"ore_type_id"`
+	OreTypeName    string   `json:"ore_type_name"`
+	ContractorID   int      `json:"contractor_id,omitempty"`
+	ContractorName string   `json:"contractor_name"`
+	UnitID         int      `json:"unit_id"`
+	UnitSymbol     string   `json:"unit_symbol"`
+	Currency       string   `json:"currency"`
+	BasePrice      Decimal  `json:"base_price"`
+	BaseGrade      *float64 `json:"base_grade"`
+	BonusPerPct    *Decimal `json:"bonus_per_pct"`
+	PenaltyPerPct  *Decimal `json:"penalty_per_pct"`
+	ValidFrom      string   `json:"valid_from"`
+	ValidTo        string   `json:"valid_to"`
+	Note           string   `json:"note"`
+	CreatedAt      string   `json:"created_at"`
+}
+
+// priceFor returns the price of ore of the given grade.
+func (p PriceList) priceFor(grade float64) (Decimal, error) {
+	price := p.BasePrice
+	if p.BaseGrade != nil {
+		g, err := decimalOf(grade)
+		if err != nil {
+			return 0, err
+		}
+		base, err := decimalOf(*p.BaseGrade)
+		if err != nil {
+			return 0, err
+		}
+		diff := g - base
+		var perPct *Decimal
+		switch {
+		case diff > 0:
+			perPct = p.BonusPerPct
+		case diff < 0:
+			perPct = p.PenaltyPerPct
+		}
+		if perPct != nil {
+			adjustment, err := diff.Mul(*perPct)
+			if err != nil {
+				return 0, err
+			}
+			if price, err = price.Add(adjustment); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return max(price, 0).Round(2), nil
+}
+
+// PriceQuote is the list price of a batch for a buyer on a date.
+type PriceQuote struct {
+	PriceListID int     `json:"price_list_id"`
+	OreBatchID  int     `json:"ore_batch_id"`
+	UnitID      int     `json:"unit_id"`
+	Grade       float64 `json:"grade"`
+	Currency    string  `json:"currency"`
+	BasePrice   Decimal `json:"base_price"`
+	Price       Decimal `json:"price"`
+}
+
+// PriceOverride is an order item whose price was set by hand.
+type PriceOverride struct {
+	ItemID         int      `json:"item_id"`
+	OrderID        int      `json:"order_id"`
+	OrderNumber    string   `json:"order_number"`
+	ContractorID   int      `json:"contractor_id"`
+	ContractorName string   `json:"contractor_name"`
+	OreBatchID     int      `json:"ore_batch_id"`
+	OreBatchName   string   `json:"ore_batch_name"`
+	Currency       string   `json:"currency"`
+	ListPrice      *Decimal `json:"list_price"`
+	Price          Decimal  `json:"price"`
+	Difference     *Decimal `json:"difference"`
+	Reason         string   `json:"reason"`
+	CreatedAt      string   `json:"created_at"`
+}
+
+var priceListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":            intField,
+		"ore_type_id":   intField,
+		"contractor_id": intField,
+		"unit_id":       intField,
+		"currency":      textField,
+		"valid_from":    dateField,
+		"valid_to":      dateField,
+	},
+	DefaultSort: "ore_type_id,-valid_from,id",
+}
+
+var priceOverrideSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"item_id":       intField,
+		"order_id":      intField,
+		"contractor_id": intField,
+		"created_at":    dateField,
+	},
+	DefaultSort: "-created_at,-item_id",
+	Key:         []string{"item_id"},
+}
+
+// findPriceList picks the list for an ore type, buyer, unit, currency and
+// day: one of the buyer's own lists before the general ones, the latest
+// started first.
+func findPriceList(lists []PriceList, oreTypeID, contractorID, unitID int, currency, day string) (PriceList, bool) {
+	var found PriceList
+	ok := false
+	for _, p := range lists {
+		if p.OreTypeID != oreTypeID || p.UnitID != unitID || p.Currency != currency || p.ContractorID != 0 && p.ContractorID != contractorID {
+			continue
+		}
+		if p.ValidFrom > day || p.ValidTo != "" && p.ValidTo < day {
+			continue
+		}
+		own, foundOwn := p.ContractorID != 0, found.ContractorID != 0
+		later := p.ValidFrom > found.ValidFrom || p.ValidFrom == found.ValidFrom && p.ID > found.ID
+		if !ok || own && !foundOwn || own == foundOwn && later {
+			found, ok = p, true
+		}
+	}
+	return found, ok
+}
+
+// pricingDay is the day of an order date, today when it is empty.
+func pricingDay(date string) string {
+	if t, ok := parseDate(date); ok {
+		return t.In(time.Local).Format("2006-01-02")
+	}
+	return time.Now().Format("2006-01-02")
+}
+
+// priceOrder fills in the prices of the order items from the price lists
+// and marks the ones set by hand that differ from the list.
+func priceOrder(ctx context.Context, store *Store, o *NewOrder) error {
+	batchIDs := make([]interface{}, len(o.Items))
+	for i, item := range o.Items {
+		batchIDs[i] = item.OreBatchID
+	}
+	batches, err := listAll(ctx, store.Batches.List, []Filter{{Field: "id", Op: opIn, Values: batchIDs}})
+	if err != nil {
+		return err
+	}
+	byBatch := map[int]OreBatch{}
+	typeIDs := []interface{}{}
+	for _, b := range batches {
+		byBatch[b.ID] = b
+		typeIDs = append(typeIDs, b.OreTypeID)
+	}
+	lists, err := listAll(ctx, store.Prices.List, []Filter{{Field: "ore_type_id", Op: opIn, Values: typeIDs}})
+	if err != nil {
+		return err
+	}
+
+	day := pricingDay(o.OrderDate)
+	var problems []FieldError
+	for i := range o.Items {
+		item := &o.Items[i]
+		batch := byBatch[item.OreBatchID]
+		item.Grade = batch.Quality
+		list, ok := findPriceList(lists, batch.OreTypeID, o.ContractorID, item.UnitID, o.Currency, day)
+		var price Decimal
+		if ok {
+			if price, err = list.priceFor(batch.Quality); err != nil {
+				return err
+			}
+		}
+		switch {
+		case ok && (item.PricePerUnit == nil || *item.PricePerUnit == price):
+			item.PricePerUnit, item.PriceListID, item.PriceSource, item.PriceReason = &price, list.ID, priceFromList, ""
+		case ok:
+			item.ListPrice, item.PriceListID, item.PriceSource = &price, list.ID, priceManual
+			item.PriceReason = strings.TrimSpace(item.PriceReason)
+			if item.PriceReason == "" {
+				problems = append(problems, fieldError(fmt.Sprintf("items[%d].price_reason", i), "price_reason_required", price))
+			}
+		case item.PricePerUnit != nil:
+			item.PriceSource = priceManual
+		default:
+			problems = append(problems, fieldError(fmt.Sprintf("items[%d].price_per_unit", i), "no_price_list", batch.OreTypeName, o.Currency, day))
+		}
+	}
+	if len(problems) > 0 {
+		return validationError(problems...)
+	}
+	return nil
+}
+
+// logPriceOverrides records the items of a new order priced by hand.
+func logPriceOverrides(store *Store, o NewOrder) {
+	for _, item := range o.Items {
+		if item.PriceSource != priceManual {
+			continue
+		}
+		details := fmt.Sprintf("Заказ %s, партия %d: %s %s", o.OrderNumber, item.OreBatchID, item.PricePerUnit, o.Currency)
+		if item.ListPrice != nil {
+			details += fmt.Sprintf(" вместо %s по прайс-листу %d", item.ListPrice, item.PriceListID)
+		} else {
+			details += " без прайс-листа"
+		}
+		if item.PriceReason != "" {
+			details += ", " + item.PriceReason
+		}
+		logAction(store.Logs, "system", "Ручная цена в заказе", "sales_orders", details)
+	}
+}
+
+func getPriceLists(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r, priceListSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		lists, total, err := store.Prices.List(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, ListPage{Items: lists, Total: total, NextCursor: q.nextCursor(lists, total)})
+	}
+}
+
+func addPriceList(store *Store, business BusinessConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req NewPriceList
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if req.Currency == "" {
+			req.Currency = business.Currency
+		}
+		req.ValidFrom = pricingDay(req.ValidFrom)
+		if req.ValidTo != "" {
+			req.ValidTo = pricingDay(req.ValidTo)
+		}
+		var problems []FieldError
+		if req.ValidTo != "" && req.ValidTo < req.ValidFrom {
+			problems = append(problems, fieldError("valid_to", "before_valid_from", req.ValidFrom))
+		}
+		if req.BaseGrade == nil && (req.BonusPerPct != nil || req.PenaltyPerPct != nil) {
+			problems = append(problems, fieldError("base_grade", "required"))
+		}
+		if len(problems) > 0 {
+			writeError(w, r, validationError(problems...))
+			return
+		}
+		id, err := store.Prices.Create(ctx, req)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Добавление прайс-листа", "price_lists",
+			fmt.Sprintf("Тип руды %d: %s %s с %s", req.OreTypeID, req.BasePrice, req.Currency, req.ValidFrom))
+		writeJSON(w, r, MessageResponse{Message: "Прайс-лист добавлен", ID: id})
+	}
+}
+
+func deletePriceList(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, ErrNotFound)
+			return
+		}
+		if err := store.Prices.Delete(r.Context(), id); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Удаление прайс-листа", "price_lists", fmt.Sprintf("ID %d", id))
+		writeJSON(w, r, MessageResponse{Message: "Прайс-лист удалён"})
+	}
+}
+
+var quoteParams = []apiParam{
+	{Name: "ore_batch_id", Type: "integer", Required: true},
+	{Name: "contractor_id", Type: "integer", Required: true},
+	{Name: "unit_id", Type: "integer", Description: "единица партии по умолчанию"},
+	{Name: "currency", Type: "string", Description: "валюта учёта по умолчанию"},
+	{Name: "date", Type: "string", Description: "YYYY-MM-DD, по умолчанию сегодня"},
+}
+
+// getPriceQuote answers the price an order item would get.
+func getPriceQuote(store *Store, business BusinessConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		query := r.URL.Query()
+		ids := map[string]int{}
+		for _, name := range []string{"ore_batch_id", "contractor_id", "unit_id"} {
+			v := query.Get(name)
+			if v == "" && name == "unit_id" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				writeError(w, r, queryError(name, "invalid_value", v))
+				return
+			}
+			ids[name] = n
+		}
+		currency := query.Get("currency")
+		if currency == "" {
+			currency = business.Currency
+		} else if !isCurrencyCode(currency) {
+			writeError(w, r, queryError("currency", "invalid_currency", currency))
+			return
+		}
+		date := query.Get("date")
+		if _, ok := parseDate(date); date != "" && !ok {
+			writeError(w, r, queryError("date", "invalid_date", date))
+			return
+		}
+		batches, _, err := store.Batches.List(ctx, byID(ids["ore_batch_id"]))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if len(batches) == 0 {
+			writeError(w, r, ErrNotFound)
+			return
+		}
+		batch := batches[0]
+		unitID := ids["unit_id"]
+		if unitID == 0 {
+			unitID = batch.UnitID
+		}
+		lists, err := listAll(ctx, store.Prices.List, []Filter{{Field: "ore_type_id", Op: opIn, Values: []interface{}{batch.OreTypeID}}})
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		day := pricingDay(date)
+		list, ok := findPriceList(lists, batch.OreTypeID, ids["contractor_id"], unitID, currency, day)
+		if !ok {
+			writeError(w, r, newAPIError(http.StatusNotFound, "no_price_list", batch.OreTypeName, currency, day))
+			return
+		}
+		price, err := list.priceFor(batch.Quality)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, PriceQuote{PriceListID: list.ID, OreBatchID: batch.ID, UnitID: unitID, Grade: batch.Quality,
+			Currency: currency, BasePrice: list.BasePrice, Price: price})
+	}
+}
+
+func getPriceOverrides(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r, priceOverrideSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		q.Filters = append(q.Filters, Filter{Field: "price_source", Op: opIn, Values: []interface{}{priceManual}})
+		overrides, total, err := store.Prices.Overrides(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, ListPage{Items: overrides, Total: total, NextCursor: q.nextCursor(overrides, total)})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPriceOrderOverrideReason(t *testing.T) {
+	ctx := context.Background()
+	store := openTestSQLite(t)
+	refs := loadTestRefs(t, store)
+	batch := createTestBatches(t, store, refs, quality(60))[0]
+	base, _ := parseDecimal("3000")
+	if _, err := store.Prices.Create(ctx, NewPriceList{OreTypeID: refs.oreType, UnitID: refs.unit, Currency: "RUB", BasePrice: &base}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		price, reason string
+		wantSource    string
+		wantErr       bool
+	}{
+		{"", "", priceFromList, false},
+		{"3000", "", priceFromList, false},
+		{"2900", "", "", true},
+		{"2900", "  ", "", true},
+		{"2900", "Скидка за объём", priceManual, false},
+	}
+	for _, tt := range tests {
+		item := NewOrderItem{OreBatchID: batch, UnitID: refs.unit, Quantity: 1, PriceReason: tt.reason}
+		if tt.price != "" {
+			price, _ := parseDecimal(tt.price)
+			item.PricePerUnit = &price
+		}
+		o := NewOrder{ContractorID: refs.contractor, Currency: "RUB", OrderDate: "2026-02-01", Items: []NewOrderItem{item}}
+		err := priceOrder(ctx, store, &o)
+		var apiErr *APIError
+		switch {
+		case tt.wantErr:
+			if !errors.As(err, &apiErr) || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "items[0].price_reason" ||
+				apiErr.Fields[0].Code != "price_reason_required" {
+				t.Errorf("price %s, reason %q: got %v, want price_reason_required", tt.price, tt.reason, err)
+			}
+		case err != nil:
+			t.Errorf("price %s, reason %q: %v", tt.price, tt.reason, err)
+		case o.Items[0].PriceSource != tt.wantSource:
+			t.Errorf("price %s, reason %q: source %q, want %q", tt.price, tt.reason, o.Items[0].PriceSource, tt.wantSource)
+		}
+	}
+}
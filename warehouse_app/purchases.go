@@ -0,0 +1,407 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	purchaseDraft     = "Черновик"
+	purchaseSent      = "Отправлен"
+	purchasePartial   = "Частично получен"
+	purchaseReceived  = "Получен"
+	purchaseClosed    = "Закрыт"
+	purchaseCancelled = "Отменен"
+)
+
+// purchaseNext lists the statuses a purchase order may be moved to by hand.
+// Receipts move it to Частично получен and Получен; Закрыт ends a partly
+// received order that won't be delivered in full.
+var purchaseNext = map[string][]string{
+	purchaseDraft:   {purchaseSent, purchaseCancelled},
+	purchaseSent:    {purchaseCancelled},
+	purchasePartial: {purchaseClosed},
+}
+
+const partPurchase = "Поступление по заказу"
+
+type PurchaseOrder struct {
+	ID            int                 `json:"id"`
+	OrderNumber   string              `json:"order_number"`
+	SupplierID    int                 `json:"supplier_id"`
+	SupplierName  string              `json:"supplier_name"`
+	WarehouseID   int                 `json:"warehouse_id"`
+	WarehouseName string              `json:"warehouse_name"`
+	Status        string              `json:"status"`
+	OrderDate     string              `json:"order_date"`
+	ExpectedDate  string              `json:"expected_date"`
+	Note          string              `json:"note"`
+	TotalAmount   Decimal             `json:"total_amount"`
+	CreatedAt     string              `json:"created_at"`
+	Items         []PurchaseOrderItem `json:"items"`
+}
+
+// PurchaseOrderItem is a spare part (PartID) or equipment of a category
+// (CategoryID) ordered from the supplier.
+type PurchaseOrderItem struct {
+	ID         int     `json:"id"`
+	OrderID    int     `json:"order_id"`
+	PartID     int     `json:"part_id,omitempty"`
+	CategoryID int     `json:"category_id,omitempty"`
+	Name       string  `json:"name"`
+	UnitID     int     `json:"unit_id"`
+	UnitSymbol string  `json:"unit_symbol"`
+	Quantity   float64 `json:"quantity"`
+	Received   float64 `json:"received_quantity"`
+	Price      Decimal `json:"price"`
+	Amount     Decimal `json:"amount"`
+}
+
+type GoodsReceipt struct {
+	ID             int                `json:"id"`
+	OrderID        int                `json:"order_id"`
+	OrderNumber    string             `json:"order_number"`
+	SupplierID     int                `json:"supplier_id"`
+	SupplierName   string             `json:"supplier_name"`
+	WarehouseID    int                `json:"warehouse_id"`
+	WarehouseName  string             `json:"warehouse_name"`
+	ReceivedAt     string             `json:"received_at"`
+	DocumentNumber string             `json:"document_number"`
+	Note           string             `json:"note"`
+	CreatedAt      string             `json:"created_at"`
+	Items          []GoodsReceiptItem `json:"items"`
+}
+
+// GoodsReceiptItem is what a receipt brought for one order item: a stock
+// movement of a part or an equipment row, one per serialized unit.
+type GoodsReceiptItem struct {
+	ID          int     `json:"id"`
+	OrderItemID int     `json:"order_item_id"`
+	Name        string  `json:"name"`
+	Quantity    float64 `json:"quantity"`
+	UnitSymbol  string  `json:"unit_symbol"`
+	MovementID  int     `json:"movement_id,omitempty"`
+	EquipmentID int     `json:"equipment_id,omitempty"`
+}
+
+// receiptLine is one received order item: a part quantity put in stock, or
+// the equipment rows to create.
+type receiptLine struct {
+	OrderItemID int
+	Quantity    float64
+	PartID      int
+	Price       Decimal
+	Equipment   []NewEquipment
+}
+
+var purchaseOrderListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":            intField,
+		"order_number":  textField,
+		"supplier_id":   intField,
+		"warehouse_id":  intField,
+		"status":        textField,
+		"order_date":    dateField,
+		"expected_date": dateField,
+		"total_amount":  floatField,
+		"created_at":    dateField,
+	},
+	DefaultSort: "-order_date,-id",
+}
+
+var goodsReceiptListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":           intField,
+		"order_id":     intField,
+		"supplier_id":  intField,
+		"warehouse_id": intField,
+		"received_at":  dateField,
+	},
+	DefaultSort: "-received_at,-id",
+}
+
+func getPurchaseOrders(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r, purchaseOrderListSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		orders, total, err := store.Purchases.List(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, ListPage{Items: orders, Total: total, NextCursor: q.nextCursor(orders, total)})
+	}
+}
+
+func purchaseOrderByID(r *http.Request, store *Store) (PurchaseOrder, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return PurchaseOrder{}, ErrNotFound
+	}
+	orders, _, err := store.Purchases.List(r.Context(), byID(id))
+	if err != nil {
+		return PurchaseOrder{}, err
+	}
+	if len(orders) == 0 {
+		return PurchaseOrder{}, ErrNotFound
+	}
+	return orders[0], nil
+}
+
+func getPurchaseOrder(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		order, err := purchaseOrderByID(r, store)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, order)
+	}
+}
+
+func addPurchaseOrder(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req NewPurchaseOrder
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if err := checkSupplier(ctx, store, "supplier_id", req.SupplierID); err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		var partIDs []interface{}
+		for _, item := range req.Items {
+			if item.PartID != 0 {
+				partIDs = append(partIDs, item.PartID)
+			}
+		}
+		parts := map[int]SparePart{}
+		if len(partIDs) > 0 {
+			found, err := listAll(ctx, store.Parts.List, []Filter{{Field: "id", Op: opIn, Values: partIDs}})
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			for _, p := range found {
+				parts[p.ID] = p
+			}
+		}
+		var fields []FieldError
+		for i := range req.Items {
+			item := &req.Items[i]
+			prefix := fmt.Sprintf("items[%d].", i)
+			if (item.PartID == 0) == (item.CategoryID == 0) {
+				fields = append(fields, fieldError(prefix+"part_id", "part_or_category"))
+				continue
+			}
+			if p, ok := parts[item.PartID]; ok {
+				if item.Name == "" {
+					item.Name = p.Name
+				}
+				if item.UnitID == 0 {
+					item.UnitID = p.UnitID
+				}
+				continue
+			}
+			if item.Name == "" {
+				fields = append(fields, fieldError(prefix+"name", "required"))
+			}
+			if item.UnitID == 0 {
+				fields = append(fields, fieldError(prefix+"unit_id", "required"))
+			}
+		}
+		if len(fields) > 0 {
+			writeError(w, r, validationError(fields...))
+			return
+		}
+		if req.OrderDate == "" {
+			req.OrderDate = time.Now().Format("2006-01-02")
+		}
+		id, err := store.Purchases.Create(ctx, req)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Создание заказа поставщику", "purchase_orders",
+			fmt.Sprintf("%s, поставщик %d, позиций %d", req.OrderNumber, req.SupplierID, len(req.Items)))
+		writeJSON(w, r, MessageResponse{Message: "Заказ поставщику создан", ID: id})
+	}
+}
+
+func updatePurchaseStatus(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req PurchaseStatusUpdate
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		order, err := purchaseOrderByID(r, store)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if !slices.Contains(purchaseNext[order.Status], req.Status) {
+			writeError(w, r, newAPIError(http.StatusConflict, "invalid_transition", order.Status, req.Status))
+			return
+		}
+		if err := store.Purchases.UpdateStatus(ctx, order.ID, req.Status); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Обновление статуса заказа поставщику", "purchase_orders",
+			fmt.Sprintf("%s: %s -> %s", order.OrderNumber, order.Status, req.Status))
+		writeJSON(w, r, MessageResponse{Message: "Статус обновлён"})
+	}
+}
+
+func getGoodsReceipts(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r, goodsReceiptListSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		receipts, total, err := store.Purchases.Receipts(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, ListPage{Items: receipts, Total: total, NextCursor: q.nextCursor(receipts, total)})
+	}
+}
+
+// receivePurchaseOrder books goods delivered against an order. Parts are put
+// in stock at the receiving warehouse and equipment is added as new rows,
+// by unit when serial and inventory numbers are given.
+func receivePurchaseOrder(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req NewGoodsReceipt
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		order, err := purchaseOrderByID(r, store)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if order.Status != purchaseSent && order.Status != purchasePartial {
+			writeError(w, r, newAPIError(http.StatusConflict, "not_receivable", order.OrderNumber, order.Status))
+			return
+		}
+		if req.WarehouseID == 0 {
+			req.WarehouseID = order.WarehouseID
+		}
+		req.ReceivedAt = normalizeTimestamp(req.ReceivedAt)
+		receivedOn := req.ReceivedAt
+		if t, ok := parseDate(req.ReceivedAt); ok {
+			receivedOn = t.Format("2006-01-02")
+		}
+
+		items := map[int]PurchaseOrderItem{}
+		received := map[int]float64{}
+		for _, item := range order.Items {
+			items[item.ID] = item
+			received[item.ID] = item.Received
+		}
+		var fields []FieldError
+		var lines []receiptLine
+		var equipment []NewEquipment
+		var equipmentFields []string
+		for i, ri := range req.Items {
+			prefix := fmt.Sprintf("items[%d].", i)
+			item, ok := items[ri.OrderItemID]
+			if !ok {
+				fields = append(fields, fieldError(prefix+"order_item_id", "not_exists"))
+				continue
+			}
+			if left := item.Quantity - received[item.ID]; ri.Quantity > left+1e-9 {
+				fields = append(fields, fieldError(prefix+"quantity", "exceeds_ordered", math.Max(left, 0)))
+				continue
+			}
+			received[item.ID] += ri.Quantity
+			line := receiptLine{OrderItemID: item.ID, Quantity: ri.Quantity, PartID: item.PartID, Price: item.Price}
+			switch {
+			case item.PartID != 0:
+				if len(ri.Units) > 0 {
+					fields = append(fields, fieldError(prefix+"units", "bulk_numbered"))
+				}
+			case len(ri.Units) > 0:
+				if ri.Quantity != math.Trunc(ri.Quantity) || int(ri.Quantity) != len(ri.Units) {
+					fields = append(fields, fieldError(prefix+"units", "units_count", ri.Quantity))
+					continue
+				}
+				for j, u := range ri.Units {
+					cost := item.Price
+					line.Equipment = append(line.Equipment, NewEquipment{Name: item.Name, CategoryID: item.CategoryID,
+						WarehouseID: req.WarehouseID, UnitID: item.UnitID, Quantity: 1, SerialNumber: u.SerialNumber,
+						InventoryNumber: u.InventoryNumber, Tracking: trackingSerial, Status: equipmentInService,
+						PurchaseDate: receivedOn, PurchaseCost: &cost})
+					equipmentFields = append(equipmentFields, fmt.Sprintf("%sunits[%d].", prefix, j))
+				}
+			default:
+				cost, err := item.Price.MulFloat(ri.Quantity)
+				if err != nil {
+					writeError(w, r, err)
+					return
+				}
+				cost = cost.Round(2)
+				line.Equipment = []NewEquipment{{Name: item.Name, CategoryID: item.CategoryID, WarehouseID: req.WarehouseID,
+					UnitID: item.UnitID, Quantity: ri.Quantity, Tracking: trackingBulk, Status: equipmentInService,
+					PurchaseDate: receivedOn, PurchaseCost: &cost}}
+				equipmentFields = append(equipmentFields, prefix)
+			}
+			equipment = append(equipment, line.Equipment...)
+			lines = append(lines, line)
+		}
+		if len(fields) > 0 {
+			writeError(w, r, validationError(fields...))
+			return
+		}
+		if err := prepareEquipment(ctx, store, equipment, func(i int, name string) string { return equipmentFields[i] + name }); err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		status := purchaseReceived
+		for _, item := range order.Items {
+			if received[item.ID] < item.Quantity-1e-9 {
+				status = purchasePartial
+			}
+		}
+		id, err := store.Purchases.Receive(ctx, order.ID, req, lines, status)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		details := fmt.Sprintf("%s: поступление %d, позиций %d, склад %d", order.OrderNumber, id, len(lines), req.WarehouseID)
+		if req.DocumentNumber != "" {
+			details += ", документ " + req.DocumentNumber
+		}
+		logAction(store.Logs, "system", "Поступление по заказу поставщику", "goods_receipts", details)
+		writeJSON(w, r, MessageResponse{Message: "Поступление оформлено", ID: id})
+	}
+}
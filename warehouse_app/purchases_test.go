@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestPartialGoodsReceipts receives an order of 10 parts and 3 pumps in
+// several deliveries, with the receipts that must be refused in between.
+func TestPartialGoodsReceipts(t *testing.T) {
+	ctx := context.Background()
+	store := openTestSQLite(t)
+	refs := loadTestRefs(t, store)
+	cfg := defaultConfig()
+	events := newEventBus()
+	defer events.close()
+	router := mux.NewRouter()
+	registerRoutes(router, apiRoutes(store, cfg, events), cfg.API)
+
+	categories, err := store.Reference.EquipmentCategories(ctx)
+	if err != nil || len(categories) == 0 {
+		t.Fatalf("no equipment categories: %v", err)
+	}
+	part, err := store.Parts.Create(ctx, NewSparePart{Name: "Фильтр", UnitID: refs.unit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	partPrice, pumpPrice := Decimal(100*decimalScale), Decimal(5000*decimalScale)
+	orderID, err := store.Purchases.Create(ctx, NewPurchaseOrder{OrderNumber: "ЗП-7", SupplierID: refs.supplier, WarehouseID: refs.warehouses[0],
+		Items: []NewPurchaseItem{
+			{PartID: part, Name: "Фильтр", UnitID: refs.unit, Quantity: 10, Price: &partPrice},
+			{CategoryID: categories[0].ID, Name: "Насос", UnitID: refs.unit, Quantity: 3, Price: &pumpPrice},
+		}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Purchases.UpdateStatus(ctx, orderID, purchaseSent); err != nil {
+		t.Fatal(err)
+	}
+	order := func() PurchaseOrder {
+		orders, _, err := store.Purchases.List(ctx, byID(orderID))
+		if err != nil || len(orders) != 1 || len(orders[0].Items) != 2 {
+			t.Fatalf("order %+v (%v)", orders, err)
+		}
+		return orders[0]
+	}
+	items := order().Items
+	parts, pumps := items[0].ID, items[1].ID
+	if items[0].PartID != part {
+		parts, pumps = pumps, parts
+	}
+	units := func(serials ...string) []EquipmentUnit {
+		var list []EquipmentUnit
+		for _, s := range serials {
+			list = append(list, EquipmentUnit{SerialNumber: s, InventoryNumber: "ИНВ-" + s})
+		}
+		return list
+	}
+
+	steps := []struct {
+		name        string
+		items       []NewReceiptItem
+		status      int
+		code        string
+		wantStatus  string
+		wantParts   float64
+		wantPumps   float64
+		wantStock   float64
+		wantPumpsIn int
+	}{
+		{"some parts", []NewReceiptItem{{OrderItemID: parts, Quantity: 4}}, http.StatusOK, "", purchasePartial, 4, 0, 4, 0},
+		{"more parts than left", []NewReceiptItem{{OrderItemID: parts, Quantity: 7}}, http.StatusBadRequest, "exceeds_ordered", purchasePartial, 4, 0, 4, 0},
+		{"two pumps by unit", []NewReceiptItem{{OrderItemID: pumps, Quantity: 2, Units: units("P-1", "P-2")}}, http.StatusOK, "", purchasePartial, 4, 2, 4, 2},
+		{"units do not match", []NewReceiptItem{{OrderItemID: pumps, Quantity: 1, Units: units("P-3", "P-4")}}, http.StatusBadRequest, "units_count", purchasePartial, 4, 2, 4, 2},
+		{"serial taken", []NewReceiptItem{{OrderItemID: pumps, Quantity: 1, Units: units("P-1")}}, http.StatusBadRequest, "taken", purchasePartial, 4, 2, 4, 2},
+		{"numbered parts", []NewReceiptItem{{OrderItemID: parts, Quantity: 1, Units: units("F-1")}}, http.StatusBadRequest, "bulk_numbered", purchasePartial, 4, 2, 4, 2},
+		{"item of another order", []NewReceiptItem{{OrderItemID: pumps + 100, Quantity: 1}}, http.StatusBadRequest, "not_exists", purchasePartial, 4, 2, 4, 2},
+		{"one line too many", []NewReceiptItem{{OrderItemID: parts, Quantity: 6}, {OrderItemID: parts, Quantity: 1}}, http.StatusBadRequest, "exceeds_ordered", purchasePartial, 4, 2, 4, 2},
+		{"the rest", []NewReceiptItem{{OrderItemID: parts, Quantity: 6}, {OrderItemID: pumps, Quantity: 1}}, http.StatusOK, "", purchaseReceived, 10, 3, 10, 3},
+		{"after the last delivery", []NewReceiptItem{{OrderItemID: parts, Quantity: 1}}, http.StatusConflict, "not_receivable", purchaseReceived, 10, 3, 10, 3},
+	}
+	for _, step := range steps {
+		body, _ := json.Marshal(NewGoodsReceipt{DocumentNumber: step.name, Items: step.items})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/purchase-orders/%d/receipts", orderID), bytes.NewReader(body)))
+		if w.Code != step.status {
+			t.Fatalf("%s: status %d, want %d: %s", step.name, w.Code, step.status, w.Body)
+		}
+		var reply struct {
+			Error struct {
+				Code   string
+				Fields []struct{ Code string }
+			}
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+			t.Fatal(err)
+		}
+		code := reply.Error.Code
+		if len(reply.Error.Fields) > 0 {
+			code = reply.Error.Fields[0].Code
+		}
+		if code != step.code {
+			t.Errorf("%s: error %q, want %q", step.name, code, step.code)
+		}
+
+		o := order()
+		received := map[int]float64{}
+		for _, item := range o.Items {
+			received[item.ID] = item.Received
+		}
+		if o.Status != step.wantStatus || received[parts] != step.wantParts || received[pumps] != step.wantPumps {
+			t.Errorf("%s: order %s with %g parts and %g pumps received, want %s with %g and %g",
+				step.name, o.Status, received[parts], received[pumps], step.wantStatus, step.wantParts, step.wantPumps)
+		}
+		stock, _, err := store.Parts.Stock(ctx, listQuery(t, partStockListSpec, fmt.Sprintf("part_id=%d", part)))
+		if err != nil || len(stock) != 1 || stock[0].Quantity != step.wantStock {
+			t.Errorf("%s: stock %+v (%v), want %g", step.name, stock, err, step.wantStock)
+		}
+		pumpsIn, err := listAll(ctx, store.Equipment.List, []Filter{{Field: "category_id", Op: opIn, Values: []interface{}{categories[0].ID}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		for _, e := range pumpsIn {
+			if e.Name == "Насос" {
+				count += int(e.Quantity)
+				if cost := e.PurchaseCost.String(); cost != fmt.Sprint(5000*e.Quantity) {
+					t.Errorf("%s: %g pumps cost %s", step.name, e.Quantity, cost)
+				}
+			}
+		}
+		if count != step.wantPumpsIn {
+			t.Errorf("%s: %d pumps in stock, want %d", step.name, count, step.wantPumpsIn)
+		}
+	}
+
+	receipts, total, err := store.Purchases.Receipts(ctx, listQuery(t, goodsReceiptListSpec, fmt.Sprintf("order_id=%d", orderID)))
+	if err != nil || total != 3 || len(receipts) != 3 {
+		t.Errorf("got %d receipts %+v (%v), want 3", total, receipts, err)
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+type ListPage struct {
+	Items      interface{} `json:"items"`
+	Total      int         `json:"total"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+type fieldKind int
+
+// The zero fieldKind marks sort keys set in code rather than parsed from a
+// request; their columns are compared as they are.
+const (
+	textField fieldKind = iota + 1
+	intField
+	floatField
+	dateField
+)
+
+type filterOp string
+
+const (
+	opIn       filterOp = "in"
+	opAtLeast  filterOp = ">="
+	opAtMost   filterOp = "<="
+	opLessThan filterOp = "<"
+)
+
+type Filter struct {
+	Field  string
+	Op     filterOp
+	Values []interface{}
+}
+
+type SortKey struct {
+	Field string
+	Desc  bool
+	// Kind lets stores sort missing values as the zero value of the field,
+	// the way they appear in responses and cursors.
+	Kind fieldKind
+}
+
+// ListQuery is a backend-neutral description of a list request. Stores map
+// field names to their own columns.
+type ListQuery struct {
+	Filters []Filter
+	Sort    []SortKey
+	Limit   int
+	// After holds the sort key values of the last row of the previous page;
+	// only rows that sort after it are returned.
+	After []interface{}
+	// Offset skips rows; only code that reads a whole list in one go uses it.
+	Offset int
+}
+
+// listSpec describes which query parameters a list endpoint understands.
+// Every field can be matched exactly (comma-separated values become IN);
+// numeric fields also accept <name>_min/<name>_max and date fields
+// <name>_from/<name>_to.
+type listSpec struct {
+	Fields      map[string]fieldKind
+	DefaultSort string
+	// Key lists the fields that identify a row, "id" when empty. They end
+	// every sort so that cursors point at exactly one row.
+	Key []string
+	// FilterOnly lists fields that are not in the response and so can
+	// only be filtered on: a cursor could not hold their values.
+	FilterOnly []string
+}
+
+func (spec listSpec) sortable(field string) bool {
+	_, ok := spec.Fields[field]
+	return ok && !slices.Contains(spec.FilterOnly, field)
+}
+
+func parseListQuery(r *http.Request, spec listSpec) (ListQuery, error) {
+	values := r.URL.Query()
+	q := ListQuery{Limit: defaultListLimit}
+
+	for name, kind := range spec.Fields {
+		filters, err := fieldFilters(values, name, kind)
+		if err != nil {
+			return q, err
+		}
+		q.Filters = append(q.Filters, filters...)
+	}
+
+	sort := values.Get("sort")
+	if sort == "" {
+		sort = spec.DefaultSort
+	}
+	for _, key := range splitList(sort) {
+		desc := strings.HasPrefix(key, "-")
+		key = strings.TrimPrefix(key, "-")
+		if !spec.sortable(key) {
+			return q, queryError("sort", "unsupported_sort", key)
+		}
+		q.Sort = append(q.Sort, SortKey{Field: key, Desc: desc, Kind: spec.Fields[key]})
+	}
+	keys := spec.Key
+	if len(keys) == 0 {
+		keys = []string{"id"}
+	}
+	for _, key := range keys {
+		if !q.sortsBy(key) {
+			q.Sort = append(q.Sort, SortKey{Field: key, Kind: spec.Fields[key]})
+		}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return q, queryError("limit", "positive")
+		}
+		if limit > maxListLimit {
+			limit = maxListLimit
+		}
+		q.Limit = limit
+	}
+	if v := values.Get("cursor"); v != "" {
+		after, err := decodeKeysetCursor(v, q.Sort)
+		if err != nil {
+			return q, err
+		}
+		q.After = after
+	}
+	return q, nil
+}
+
+func (q ListQuery) sortsBy(field string) bool {
+	for _, key := range q.Sort {
+		if key.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldFilters(values url.Values, name string, kind fieldKind) ([]Filter, error) {
+	var filters []Filter
+
+	if raw := values.Get(name); raw != "" {
+		f := Filter{Field: name, Op: opIn}
+		for _, v := range splitList(raw) {
+			parsed, err := parseFieldValue(v, kind)
+			if err != nil {
+				return nil, queryError(name, "invalid_value", v)
+			}
+			f.Values = append(f.Values, parsed)
+		}
+		if len(f.Values) > 0 {
+			filters = append(filters, f)
+		}
+	}
+
+	if kind == textField {
+		return filters, nil
+	}
+	lower, upper := "_min", "_max"
+	if kind == dateField {
+		lower, upper = "_from", "_to"
+	}
+	if raw := values.Get(name + lower); raw != "" {
+		parsed, err := parseFieldValue(raw, kind)
+		if err != nil {
+			return nil, queryError(name+lower, "invalid_value", raw)
+		}
+		filters = append(filters, Filter{Field: name, Op: opAtLeast, Values: []interface{}{parsed}})
+	}
+	if raw := values.Get(name + upper); raw != "" {
+		parsed, err := parseFieldValue(raw, kind)
+		if err != nil {
+			return nil, queryError(name+upper, "invalid_value", raw)
+		}
+		if kind == dateField {
+			filters = append(filters, Filter{Field: name, Op: opLessThan, Values: []interface{}{dateUpperBound(parsed.(string))}})
+		} else {
+			filters = append(filters, Filter{Field: name, Op: opAtMost, Values: []interface{}{parsed}})
+		}
+	}
+	return filters, nil
+}
+
+func parseFieldValue(value string, kind fieldKind) (interface{}, error) {
+	switch kind {
+	case intField:
+		return strconv.Atoi(value)
+	case floatField:
+		return strconv.ParseFloat(value, 64)
+	case dateField:
+		if _, ok := parseDate(value); ok {
+			return value, nil
+		}
+		return nil, fmt.Errorf("invalid date %q", value)
+	default:
+		return value, nil
+	}
+}
+
+// Dates are stored either as YYYY-MM-DD or RFC3339 text, so a date-only upper
+// bound becomes "before the next day" to include timestamps within that day.
+func dateUpperBound(value string) string {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t.AddDate(0, 0, 1).Format("2006-01-02")
+	}
+	return value
+}
+
+// A cursor holds the sort key values of the last row of a page, so rows
+// added or removed between requests neither repeat nor get skipped.
+type keysetCursor struct {
+	Keys []interface{} `json:"k"`
+}
+
+func decodeKeysetCursor(value string, sort []SortKey) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, queryError("cursor", "invalid_cursor")
+	}
+	var c keysetCursor
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&c); err != nil || len(c.Keys) != len(sort) {
+		return nil, queryError("cursor", "invalid_cursor")
+	}
+	after := make([]interface{}, len(sort))
+	for i, key := range sort {
+		var ok bool
+		switch v := c.Keys[i].(type) {
+		case json.Number:
+			if key.Kind == intField {
+				n, err := v.Int64()
+				after[i], ok = int(n), err == nil
+			} else if key.Kind == floatField {
+				f, err := v.Float64()
+				after[i], ok = f, err == nil
+			}
+		case string:
+			after[i], ok = v, key.Kind == textField || key.Kind == dateField
+		}
+		if !ok {
+			return nil, queryError("cursor", "invalid_cursor")
+		}
+	}
+	return after, nil
+}
+
+// nextCursor points after the last of items, a slice of the structs the
+// page was read into. A short page is the last one.
+func (q ListQuery) nextCursor(items interface{}, total int) string {
+	list := reflect.ValueOf(items)
+	n := list.Len()
+	if n == 0 || n < q.Limit || q.After == nil && q.Offset == 0 && n >= total {
+		return ""
+	}
+	keys, err := sortValues(list.Index(n-1), q.Sort)
+	if err != nil {
+		panic(err)
+	}
+	data, _ := json.Marshal(keysetCursor{Keys: keys})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// sortValues reads the sort key values of row from the fields with the same
+// JSON names; missing values become the zero value of their kind.
+func sortValues(row reflect.Value, sort []SortKey) ([]interface{}, error) {
+	keys := make([]interface{}, len(sort))
+	for i, key := range sort {
+		field, ok := fieldByJSONName(row, key.Field)
+		if !ok {
+			return nil, fmt.Errorf("list cursor: %s has no field %q", row.Type(), key.Field)
+		}
+		for field.Kind() == reflect.Pointer && !field.IsNil() {
+			field = field.Elem()
+		}
+		switch {
+		case field.Kind() == reflect.Pointer:
+			keys[i] = zeroSortValue(key.Kind)
+		case field.Type() == decimalType:
+			keys[i] = field.Interface().(Decimal).Float64()
+		default:
+			keys[i] = field.Interface()
+		}
+	}
+	return keys, nil
+}
+
+func zeroSortValue(kind fieldKind) interface{} {
+	switch kind {
+	case intField:
+		return 0
+	case floatField:
+		return 0.0
+	default:
+		return ""
+	}
+}
+
+func fieldByJSONName(row reflect.Value, name string) (reflect.Value, bool) {
+	for row.Kind() == reflect.Pointer {
+		row = row.Elem()
+	}
+	for _, f := range jsonFields(row.Type()) {
+		if f.Name == name {
+			return row.FieldByName(f.GoName), true
+		}
+	}
+	return reflect.Value{}, false
+}
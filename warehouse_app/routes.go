@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// apiRoute describes one API endpoint; Path is relative to the version
+// prefix. The router, the OpenAPI document and the generated Go client are
+// all built from the table in apiRoutes.
+type apiRoute struct {
+	Method    string
+	Path      string
+	Operation string
+	Tag       string
+	Summary   string
+	// List adds limit, cursor, sort and per-field filter parameters.
+	List   *listSpec
+	Params []apiParam
+	// Request is the JSON body type; endpoints that read another format
+	// list its media types in Consumes. Response is the JSON result type;
+	// with Paged it is the item type of a ListPage. Endpoints that stream
+	// another format set Produces instead.
+	Request  interface{}
+	Consumes []string
+	Response interface{}
+	Paged    bool
+	Produces []string
+	Handler  http.HandlerFunc
+}
+
+type apiParam struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+	Enum        []string
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+	Warning string `json:"warning,omitempty"`
+	// ID is the id of the created record, where an endpoint reports it.
+	ID int `json:"id,omitempty"`
+}
+
+func apiRoutes(store *Store, cfg Config, events *eventBus) []apiRoute {
+	var routes []apiRoute
+	logParams := []apiParam{
+		{Name: "user", Type: "string", Description: "пользователи через запятую"},
+		{Name: "action", Type: "string", Description: "действия через запятую"},
+		{Name: "entity", Type: "string", Description: "сущности через запятую"},
+		{Name: "id", Type: "integer", Description: "идентификатор записи"},
+		{Name: "from", Type: "string", Description: "YYYY-MM-DD или RFC3339"},
+		{Name: "to", Type: "string", Description: "YYYY-MM-DD или RFC3339, включительно"},
+		{Name: "q", Type: "string", Description: "поиск по тексту"},
+		{Name: "sort", Type: "string", Enum: []string{"desc", "asc"}},
+	}
+	routes = []apiRoute{
+		{Method: "GET", Path: "/reference-data", Operation: "getReferenceData", Tag: "reference",
+			Summary: "Справочники", Response: ReferenceData{}, Handler: getReferenceData(store)},
+		{Method: "GET", Path: "/ore-batches", Operation: "listOreBatches", Tag: "ore-batches",
+			Summary: "Партии руды", List: &oreBatchListSpec, Response: OreBatch{}, Paged: true, Handler: getOreBatches(store)},
+		{Method: "POST", Path: "/ore-batches", Operation: "createOreBatch", Tag: "ore-batches",
+			Summary: "Приёмка партии руды", Request: NewOreBatch{}, Response: MessageResponse{}, Handler: addOreBatch(store, cfg.Business, events)},
+		{Method: "POST", Path: "/ore-batches/import", Operation: "importOreBatches", Tag: "ore-batches",
+			Summary: "Загрузка партий руды из CSV или XLSX", Params: importParams, Consumes: importMediaTypes,
+			Response: ImportResult{}, Handler: importOreBatches(store, cfg.Business, events)},
+		{Method: "GET", Path: "/equipment", Operation: "listEquipment", Tag: "equipment",
+			Summary: "Оборудование", List: &equipmentListSpec, Response: Equipment{}, Paged: true, Handler: getEquipment(store)},
+		{Method: "POST", Path: "/equipment", Operation: "createEquipment", Tag: "equipment",
+			Summary: "Добавление оборудования", Request: NewEquipment{}, Response: MessageResponse{}, Handler: addEquipment(store, cfg.Business)},
+		{Method: "POST", Path: "/equipment/import", Operation: "importEquipment", Tag: "equipment",
+			Summary: "Загрузка оборудования из CSV или XLSX", Params: importParams, Consumes: importMediaTypes,
+			Response: ImportResult{}, Handler: importEquipment(store, cfg.Business)},
+		{Method: "POST", Path: "/equipment/{id}/units", Operation: "splitEquipment", Tag: "equipment",
+			Summary: "Перевод количественной записи на поштучный учет", Request: EquipmentSplit{}, Response: EquipmentSplitResult{}, Handler: splitEquipment(store)},
+		{Method: "POST", Path: "/equipment/{id}/write-off", Operation: "writeOffEquipment", Tag: "equipment",
+			Summary: "Списание оборудования с датой и остаточной стоимостью", Request: EquipmentWriteOff{}, Response: MessageResponse{}, Handler: writeOffEquipment(store)},
+		{Method: "GET", Path: "/equipment/{id}/depreciation", Operation: "getEquipmentDepreciation", Tag: "equipment",
+			Summary: "График амортизации по месяцам", Response: DepreciationSchedule{}, Handler: getEquipmentDepreciation(store)},
+		{Method: "GET", Path: "/equipment/depreciation", Operation: "getDepreciationReport", Tag: "equipment",
+			Summary: "Амортизация и остаточная стоимость за месяц по складам и категориям", Params: depreciationReportParams,
+			Response: []DepreciationRow{}, Handler: getDepreciationReport(store)},
+		{Method: "GET", Path: "/meter-readings", Operation: "listMeterReadings", Tag: "equipment",
+			Summary: "Показания счетчиков моточасов и пробега", List: &meterReadingListSpec, Response: MeterReading{}, Paged: true, Handler: getMeterReadings(store)},
+		{Method: "POST", Path: "/meter-readings", Operation: "createMeterReading", Tag: "equipment",
+			Summary: "Новое показание счетчика", Request: NewMeterReading{}, Response: MessageResponse{}, Handler: addMeterReading(store)},
+		{Method: "POST", Path: "/meter-readings/import", Operation: "importMeterReadings", Tag: "equipment",
+			Summary: "Загрузка показаний счетчиков из CSV или XLSX", Params: importParams, Consumes: importMediaTypes,
+			Response: ImportResult{}, Handler: importMeterReadings(store)},
+		{Method: "DELETE", Path: "/meter-readings/{id}", Operation: "deleteMeterReading", Tag: "equipment",
+			Summary: "Удаление ошибочного показания", Response: MessageResponse{}, Handler: deleteMeterReading(store)},
+		{Method: "GET", Path: "/equipment/utilization", Operation: "getUtilization", Tag: "equipment",
+			Summary: "Использование оборудования за период", Params: utilizationParams, Response: []UtilizationRow{}, Handler: getUtilization(store)},
+		{Method: "GET", Path: "/equipment-assignments", Operation: "listEquipmentAssignments", Tag: "equipment",
+			Summary: "Выдачи оборудования на склады и участки", List: &assignmentListSpec, Response: Assignment{}, Paged: true, Handler: getAssignments(store)},
+		{Method: "POST", Path: "/equipment-assignments", Operation: "checkOutEquipment", Tag: "equipment",
+			Summary: "Выдача оборудования ответственному лицу", Request: NewAssignment{}, Response: MessageResponse{}, Handler: checkOutEquipment(store)},
+		{Method: "POST", Path: "/equipment-assignments/{id}/return", Operation: "returnEquipment", Tag: "equipment",
+			Summary: "Возврат выданного оборудования", Request: AssignmentReturn{}, Response: MessageResponse{}, Handler: returnEquipment(store)},
+		{Method: "GET", Path: "/equipment/locations", Operation: "getEquipmentLocations", Tag: "equipment",
+			Summary: "Местонахождение оборудования на дату", Params: locationParams, Response: []EquipmentLocation{}, Handler: getEquipmentLocations(store)},
+		{Method: "GET", Path: "/spare-parts", Operation: "listSpareParts", Tag: "spare-parts",
+			Summary: "Запчасти и расходные материалы", List: &sparePartListSpec, Response: SparePart{}, Paged: true, Handler: getSpareParts(store)},
+		{Method: "POST", Path: "/spare-parts", Operation: "createSparePart", Tag: "spare-parts",
+			Summary: "Добавление запчасти", Request: NewSparePart{}, Response: MessageResponse{}, Handler: addSparePart(store)},
+		{Method: "GET", Path: "/spare-parts/stock", Operation: "listPartStock", Tag: "spare-parts",
+			Summary: "Остатки запчастей по складам", List: &partStockListSpec, Response: PartStock{}, Paged: true, Handler: getPartStock(store)},
+		{Method: "PUT", Path: "/spare-parts/{id}/levels", Operation: "setPartLevels", Tag: "spare-parts",
+			Summary: "Минимальный и максимальный запас на складе", Request: PartStockLevels{}, Response: MessageResponse{}, Handler: setPartLevels(store)},
+		{Method: "GET", Path: "/spare-parts/movements", Operation: "listPartMovements", Tag: "spare-parts",
+			Summary: "Движение запчастей", List: &partMovementListSpec, Response: PartMovement{}, Paged: true, Handler: getPartMovements(store)},
+		{Method: "POST", Path: "/spare-parts/movements", Operation: "createPartMovement", Tag: "spare-parts",
+			Summary: "Приход, расход или инвентаризация запчастей", Request: NewPartMovement{}, Response: MessageResponse{}, Handler: addPartMovement(store)},
+		{Method: "GET", Path: "/spare-parts/reorder", Operation: "getReorderReport", Tag: "spare-parts",
+			Summary: "Что заказать у поставщиков", Params: reorderParams, Response: []ReorderGroup{}, Handler: getReorderReport(store)},
+		{Method: "GET", Path: "/purchase-orders", Operation: "listPurchaseOrders", Tag: "purchases",
+			Summary: "Заказы поставщикам", List: &purchaseOrderListSpec, Response: PurchaseOrder{}, Paged: true, Handler: getPurchaseOrders(store)},
+		{Method: "POST", Path: "/purchase-orders", Operation: "createPurchaseOrder", Tag: "purchases",
+			Summary: "Создание заказа поставщику", Request: NewPurchaseOrder{}, Response: MessageResponse{}, Handler: addPurchaseOrder(store)},
+		{Method: "GET", Path: "/purchase-orders/{id}", Operation: "getPurchaseOrder", Tag: "purchases",
+			Summary: "Заказ поставщику с позициями", Response: PurchaseOrder{}, Handler: getPurchaseOrder(store)},
+		{Method: "PUT", Path: "/purchase-orders/{id}/status", Operation: "updatePurchaseOrderStatus", Tag: "purchases",
+			Summary: "Отправка, отмена или закрытие заказа поставщику", Request: PurchaseStatusUpdate{}, Response: MessageResponse{}, Handler: updatePurchaseStatus(store)},
+		{Method: "POST", Path: "/purchase-orders/{id}/receipts", Operation: "receivePurchaseOrder", Tag: "purchases",
+			Summary: "Поступление товаров по заказу", Request: NewGoodsReceipt{}, Response: MessageResponse{}, Handler: receivePurchaseOrder(store)},
+		{Method: "GET", Path: "/goods-receipts", Operation: "listGoodsReceipts", Tag: "purchases",
+			Summary: "Поступления по заказам поставщикам", List: &goodsReceiptListSpec, Response: GoodsReceipt{}, Paged: true, Handler: getGoodsReceipts(store)},
+		{Method: "GET", Path: "/maintenance-plans", Operation: "listMaintenancePlans", Tag: "maintenance",
+			Summary: "Планы обслуживания", List: &maintenancePlanListSpec, Response: MaintenancePlan{}, Paged: true, Handler: getMaintenancePlans(store)},
+		{Method: "POST", Path: "/maintenance-plans", Operation: "createMaintenancePlan", Tag: "maintenance",
+			Summary: "Создание плана обслуживания", Request: NewMaintenancePlan{}, Response: MessageResponse{}, Handler: addMaintenancePlan(store)},
+		{Method: "DELETE", Path: "/maintenance-plans/{id}", Operation: "deleteMaintenancePlan", Tag: "maintenance",
+			Summary: "Удаление плана обслуживания", Response: MessageResponse{}, Handler: deleteMaintenancePlan(store)},
+		{Method: "GET", Path: "/maintenance/overdue", Operation: "listOverdueMaintenance", Tag: "maintenance",
+			Summary: "Просроченное и предстоящее обслуживание", Params: maintenanceDueParams, Response: []MaintenanceDue{}, Handler: getOverdueMaintenance(store)},
+		{Method: "GET", Path: "/work-orders", Operation: "listWorkOrders", Tag: "maintenance",
+			Summary: "Заказ-наряды", List: &workOrderListSpec, Response: WorkOrder{}, Paged: true, Handler: getWorkOrders(store)},
+		{Method: "POST", Path: "/work-orders", Operation: "createWorkOrder", Tag: "maintenance",
+			Summary: "Открытие заказ-наряда; оборудование переходит в статус «На обслуживании»", Request: NewWorkOrder{}, Response: MessageResponse{}, Handler: addWorkOrder(store)},
+		{Method: "GET", Path: "/work-orders/{id}", Operation: "getWorkOrder", Tag: "maintenance",
+			Summary: "Заказ-наряд с запчастями и работами", Response: WorkOrder{}, Handler: getWorkOrder(store)},
+		{Method: "PUT", Path: "/work-orders/{id}/status", Operation: "updateWorkOrderStatus", Tag: "maintenance",
+			Summary: "Смена статуса заказ-наряда", Request: WorkOrderStatusUpdate{}, Response: MessageResponse{}, Handler: updateWorkOrderStatus(store)},
+		{Method: "POST", Path: "/work-orders/{id}/parts", Operation: "addWorkOrderPart", Tag: "maintenance",
+			Summary: "Запчасти и материалы по заказ-наряду", Request: NewWorkOrderPart{}, Response: MessageResponse{}, Handler: addWorkOrderPart(store)},
+		{Method: "POST", Path: "/work-orders/{id}/labor", Operation: "addWorkOrderLabor", Tag: "maintenance",
+			Summary: "Трудозатраты по заказ-наряду", Request: NewWorkOrderLabor{}, Response: MessageResponse{}, Handler: addWorkOrderLabor(store)},
+		{Method: "GET", Path: "/orders", Operation: "listOrders", Tag: "orders",
+			Summary: "Заказы", List: &orderListSpec, Response: SalesOrder{}, Paged: true, Handler: getOrders(store)},
+		{Method: "POST", Path: "/orders", Operation: "createOrder", Tag: "orders",
+			Summary: "Создание заказа", Request: NewOrder{}, Response: MessageResponse{}, Handler: addOrder(store, cfg.Business, events)},
+		{Method: "PUT", Path: "/orders/{id}/status", Operation: "updateOrderStatus", Tag: "orders",
+			Summary: "Смена статуса заказа", Request: OrderStatusUpdate{}, Response: MessageResponse{}, Handler: updateOrderStatus(store, cfg.Business, events)},
+		{Method: "GET", Path: "/price-lists", Operation: "listPriceLists", Tag: "orders",
+			Summary: "Прайс-листы по типам руды", List: &priceListSpec, Response: PriceList{}, Paged: true, Handler: getPriceLists(store)},
+		{Method: "POST", Path: "/price-lists", Operation: "createPriceList", Tag: "orders",
+			Summary: "Добавление прайс-листа с надбавками и скидками за содержание Fe", Request: NewPriceList{}, Response: MessageResponse{}, Handler: addPriceList(store, cfg.Business)},
+		{Method: "DELETE", Path: "/price-lists/{id}", Operation: "deletePriceList", Tag: "orders",
+			Summary: "Удаление прайс-листа", Response: MessageResponse{}, Handler: deletePriceList(store)},
+		{Method: "GET", Path: "/price-lists/quote", Operation: "getPriceQuote", Tag: "orders",
+			Summary: "Цена партии для покупателя по прайс-листу", Params: quoteParams, Response: PriceQuote{}, Handler: getPriceQuote(store, cfg.Business)},
+		{Method: "GET", Path: "/price-overrides", Operation: "listPriceOverrides", Tag: "orders",
+			Summary: "Позиции заказов с ценой, заданной вручную", List: &priceOverrideSpec, Response: PriceOverride{}, Paged: true, Handler: getPriceOverrides(store)},
+		{Method: "GET", Path: "/exchange-rates", Operation: "listExchangeRates", Tag: "orders",
+			Summary: "Курсы валют к валюте учёта", List: &exchangeRateListSpec, Response: ExchangeRate{}, Paged: true, Handler: getExchangeRates(store)},
+		{Method: "POST", Path: "/exchange-rates", Operation: "setExchangeRate", Tag: "orders",
+			Summary: "Курс валюты на дату; заменяет введённый ранее на ту же дату", Request: NewExchangeRate{}, Response: MessageResponse{}, Handler: addExchangeRate(store, cfg.Business)},
+		{Method: "POST", Path: "/exchange-rates/import", Operation: "importExchangeRates", Tag: "orders",
+			Summary: "Загрузка курсов валют из CSV или XLSX", Params: importParams, Consumes: importMediaTypes,
+			Response: ImportResult{}, Handler: importExchangeRates(store, cfg.Business)},
+		{Method: "GET", Path: "/orders/revenue", Operation: "getSalesRevenue", Tag: "orders",
+			Summary: "Выручка по подтверждённым заказам в валюте учёта", Params: revenueParams, Response: SalesRevenue{}, Handler: getSalesRevenue(store, cfg.Business)},
+		{Method: "GET", Path: "/shipments", Operation: "listShipments", Tag: "shipments",
+			Summary: "Отгрузки", List: &shipmentListSpec, Response: Shipment{}, Paged: true, Handler: getShipments(store)},
+		{Method: "POST", Path: "/shipments", Operation: "createShipment", Tag: "shipments",
+			Summary: "Создание отгрузки", Request: NewShipment{}, Response: MessageResponse{}, Handler: addShipment(store, events)},
+		{Method: "PUT", Path: "/shipments/{id}/status", Operation: "updateShipmentStatus", Tag: "shipments",
+			Summary: "Смена статуса отгрузки", Request: ShipmentStatusUpdate{}, Response: MessageResponse{}, Handler: updateShipmentStatus(store, events)},
+		{Method: "GET", Path: "/webhooks", Operation: "listWebhooks", Tag: "webhooks",
+			Summary: "Подписки на вебхуки", Response: []Webhook{}, Handler: getWebhooks(store)},
+		{Method: "POST", Path: "/webhooks", Operation: "createWebhook", Tag: "webhooks",
+			Summary: "Подписка на вебхук; secret возвращается только в этом ответе", Request: NewWebhook{}, Response: Webhook{}, Handler: addWebhook(store)},
+		{Method: "DELETE", Path: "/webhooks/{id}", Operation: "deleteWebhook", Tag: "webhooks",
+			Summary: "Удаление подписки", Response: MessageResponse{}, Handler: deleteWebhook(store)},
+		{Method: "GET", Path: "/webhooks/{id}/deliveries", Operation: "listWebhookDeliveries", Tag: "webhooks",
+			Summary: "Доставки вебхука", List: &webhookDeliveryListSpec, Response: WebhookDelivery{}, Paged: true, Handler: getWebhookDeliveries(store)},
+		{Method: "GET", Path: "/webhook-deliveries/{id}", Operation: "getWebhookDelivery", Tag: "webhooks",
+			Summary: "Доставка с телом запроса и журналом попыток", Response: WebhookDelivery{}, Handler: getWebhookDelivery(store)},
+		{Method: "POST", Path: "/webhook-deliveries/{id}/redeliver", Operation: "redeliverWebhook", Tag: "webhooks",
+			Summary: "Повторная отправка доставки", Response: MessageResponse{}, Handler: redeliverWebhook(store)},
+		{Method: "GET", Path: "/exchange/1c/export", Operation: "exportTo1C", Tag: "exchange",
+			Summary: "Выгрузка заказов, отгрузок и контрагентов в 1С (CommerceML)", Params: []apiParam{
+				{Name: "since", Type: "string", Description: "только изменённые с даты (YYYY-MM-DD или RFC3339); last — с прошлой успешной выгрузки"},
+			}, Produces: []string{"application/xml"}, Handler: export1C(store)},
+		{Method: "POST", Path: "/exchange/1c/import", Operation: "importFrom1C", Tag: "exchange",
+			Summary: "Загрузка контрагентов и цен из 1С (CommerceML, UTF-8)", Params: []apiParam{
+				{Name: "file_name", Type: "string", Description: "имя файла для журнала обмена"},
+			}, Consumes: []string{"application/xml", "text/xml"}, Response: ExchangeResult{}, Handler: import1C(store)},
+		{Method: "GET", Path: "/exchange/1c/log", Operation: "listExchangeLog", Tag: "exchange",
+			Summary: "Журнал обмена с 1С", List: &exchangeLogListSpec, Response: ExchangeLogEntry{}, Paged: true, Handler: getExchangeLog(store)},
+		{Method: "GET", Path: "/exchange/1c/log/{id}/document", Operation: "getExchangeDocument", Tag: "exchange",
+			Summary: "Файл, переданный или полученный при обмене", Produces: []string{"application/xml"}, Handler: getExchangeDocument(store)},
+		{Method: "GET", Path: "/logs", Operation: "listLogs", Tag: "logs",
+			Summary: "Журнал действий", Params: append(logParams,
+				apiParam{Name: "limit", Type: "integer", Description: fmt.Sprintf("по умолчанию %d, не больше %d", defaultListLimit, maxListLimit)},
+				apiParam{Name: "cursor", Type: "string", Description: "next_cursor предыдущей страницы"}),
+			Response: LogEntry{}, Paged: true, Handler: getLogs(store)},
+		{Method: "GET", Path: "/logs/export", Operation: "exportLogs", Tag: "logs",
+			Summary: "Выгрузка журнала", Params: append(logParams,
+				apiParam{Name: "format", Type: "string", Enum: []string{"csv", "jsonl"}}),
+			Produces: []string{"text/csv", "application/x-ndjson"}, Handler: exportLogs(store)},
+		{Method: "GET", Path: "/search", Operation: "search", Tag: "search",
+			Summary: "Полнотекстовый поиск", Params: []apiParam{
+				{Name: "q", Type: "string", Required: true},
+				{Name: "type", Type: "string", Description: "типы результатов через запятую: ore_batch, order, contractor, equipment, transport, log"},
+				{Name: "limit", Type: "integer", Description: "по умолчанию 20"},
+			}, Response: []SearchHit{}, Handler: search(store)},
+		{Method: "GET", Path: "/events", Operation: "streamEvents", Tag: "events",
+			Summary: "Поток изменений (Server-Sent Events)", Params: []apiParam{
+				{Name: "warehouse_id", Type: "string", Description: "склады через запятую; без параметра — все склады"},
+				{Name: "last_event_id", Type: "string", Description: "продолжить после события с этим id, если нет заголовка Last-Event-ID"},
+			}, Produces: []string{"text/event-stream"}, Handler: streamEvents(events)},
+		// The document handler reads routes when it is first requested, by
+		// which time the table is complete.
+		{Method: "GET", Path: "/openapi.json", Operation: "getOpenAPI", Tag: "meta",
+			Summary: "Описание API в формате OpenAPI 3", Produces: []string{"application/json"}, Handler: serveOpenAPI(&routes)},
+	}
+	return routes
+}
+
+// checkRoutes fails when an /api route is registered on router without being
+// described in routes, so handlers added outside the table can't slip past
+// the OpenAPI document.
+func checkRoutes(router *mux.Router, routes []apiRoute) error {
+	documented := make(map[string]bool)
+	for _, rt := range routes {
+		for _, version := range apiVersions {
+			documented[rt.Method+" "+versionedPath(version, rt.Path)] = true
+		}
+		documented[rt.Method+" /api"+rt.Path] = true
+	}
+	// Cursors are read from the response fields named like the sort keys.
+	var unsortable []string
+	for _, rt := range routes {
+		if rt.List == nil || rt.Response == nil {
+			continue
+		}
+		row := reflect.New(reflect.TypeOf(rt.Response)).Elem()
+		for name := range rt.List.Fields {
+			if _, ok := fieldByJSONName(row, name); !ok && rt.List.sortable(name) {
+				unsortable = append(unsortable, rt.Path+" "+name)
+			}
+		}
+	}
+	if len(unsortable) > 0 {
+		sort.Strings(unsortable)
+		return fmt.Errorf("sort fields missing from the list items: %s", strings.Join(unsortable, ", "))
+	}
+	var missing []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(path, "/api/") || route.GetHandler() == nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			missing = append(missing, "* "+path)
+			return nil
+		}
+		for _, m := range methods {
+			if !documented[m+" "+path] {
+				missing = append(missing, m+" "+path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("routes missing from the API description: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type SearchHit struct {
+	Type    string  `json:"type"`
+	ID      int     `json:"id"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+	Link    string  `json:"link"`
+}
+
+// searchSource describes how one table is projected into search_index. Title
+// and Body are SQLite expressions over the NEW row of a trigger; the
+// PostgreSQL triggers live in migrations/postgres.
+type searchSource struct {
+	Type  string
+	Table string
+	Title string
+	Body  string
+	// Link is the path of the record under the API version prefix.
+	Link string
+	// Parents are the tables whose columns Title or Body read; rows are
+	// indexed again when the parent row they point to changes.
+	Parents []searchParent
+}
+
+type searchParent struct {
+	Table  string
+	Column string
+}
+
+var searchSources = []searchSource{
+	{
+		Type:    "ore_batch",
+		Table:   "ore_batches",
+		Title:   "IFNULL(NEW.batch_code, '')",
+		Body:    "IFNULL((SELECT name FROM ore_types WHERE id = NEW.ore_type_id), '') || ' ' || IFNULL(NEW.status, '') || ' ' || IFNULL(NEW.priority, '')",
+		Link:    "/ore-batches?id=%d",
+		Parents: []searchParent{{Table: "ore_types", Column: "ore_type_id"}},
+	},
+	{
+		Type:    "order",
+		Table:   "sales_orders",
+		Title:   "NEW.order_number",
+		Body:    "IFNULL((SELECT name FROM contractors WHERE id = NEW.contractor_id), '') || ' ' || IFNULL(NEW.status, '')",
+		Link:    "/orders?id=%d",
+		Parents: []searchParent{{Table: "contractors", Column: "contractor_id"}},
+	},
+	{
+		Type:  "contractor",
+		Table: "contractors",
+		Title: "NEW.name",
+		Body:  "IFNULL(NEW.type, '') || ' ' || IFNULL(NEW.contact_person, '') || ' ' || IFNULL(NEW.phone, '') || ' ' || IFNULL(NEW.email, '')",
+		Link:  "/orders?contractor_id=%d",
+	},
+	{
+		Type:  "equipment",
+		Table: "equipment",
+		Title: "NEW.name",
+		Body:  "IFNULL(NEW.serial_number, '') || ' ' || IFNULL(NEW.inventory_number, '') || ' ' || IFNULL(NEW.status, '')",
+		Link:  "/equipment?id=%d",
+	},
+	{
+		Type:  "transport",
+		Table: "transport",
+		Title: "NEW.name",
+		Body:  "IFNULL(NEW.vehicle_number, '') || ' ' || IFNULL(NEW.type, '')",
+		Link:  "/shipments?transport_id=%d",
+	},
+	{
+		Type:  "log",
+		Table: "logs",
+		Title: "IFNULL(NEW.action, '')",
+		Body:  "IFNULL(NEW.details, '') || ' ' || IFNULL(NEW.entity, '') || ' ' || IFNULL(NEW.user, '')",
+		Link:  "/logs?id=%d",
+	},
+}
+
+func searchSourceByType(t string) (searchSource, bool) {
+	for _, src := range searchSources {
+		if src.Type == t {
+			return src, true
+		}
+	}
+	return searchSource{}, false
+}
+
+func searchTerms(q string) []string {
+	return strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func search(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		terms := searchTerms(r.URL.Query().Get("q"))
+		if len(terms) == 0 {
+			writeError(w, r, queryError("q", "required"))
+			return
+		}
+		limit := 20
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				writeError(w, r, queryError("limit", "positive"))
+				return
+			}
+			if n > maxListLimit {
+				n = maxListLimit
+			}
+			limit = n
+		}
+
+		hits, err := store.Search.Search(r.Context(), terms, splitList(r.URL.Query().Get("type")), limit)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		version := requestAPIVersion(r.Context())
+		for i := range hits {
+			if hits[i].Link != "" {
+				hits[i].Link = versionedPath(version, hits[i].Link)
+			}
+		}
+		writeJSON(w, r, hits)
+	}
+}
+
+// scanSearchHits reads (entity, entity_id, title, snippet, rank) rows and
+// fills in each hit's link.
+func scanSearchHits(rows *sql.Rows) ([]SearchHit, error) {
+	hits := []SearchHit{}
+	for rows.Next() {
+		var hit SearchHit
+		if err := rows.Scan(&hit.Type, &hit.ID, &hit.Title, &hit.Snippet, &hit.Rank); err != nil {
+			return nil, err
+		}
+		if src, ok := searchSourceByType(hit.Type); ok {
+			hit.Link = fmt.Sprintf(src.Link, hit.ID)
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
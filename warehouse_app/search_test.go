@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// TestSQLiteSearchModes runs the same queries through the LIKE fallback of
+// the default build and, when built with -tags sqlite_fts5, through FTS5.
+func TestSQLiteSearchModes(t *testing.T) {
+	ctx := context.Background()
+	store := openTestSQLite(t)
+	refs := loadTestRefs(t, store)
+	categories, err := store.Reference.EquipmentCategories(ctx)
+	if err != nil || len(categories) == 0 {
+		t.Fatalf("no equipment categories: %v", err)
+	}
+	ids := map[string]int{}
+	for _, name := range []string{"Запасная Дробилка", "Дробилка щековая", "<img src=x onerror=alert(1)> Насос"} {
+		id, err := store.Equipment.Create(ctx, NewEquipment{Name: name, CategoryID: categories[0].ID,
+			WarehouseID: refs.warehouses[0], UnitID: refs.unit, Quantity: 1, SerialNumber: "SN-" + fmt.Sprint(len(ids))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = id
+	}
+
+	built := store.Search.(*sqliteSearchStore)
+	modes := map[string]*sqliteSearchStore{"like": {db: built.db}}
+	if built.fts5 {
+		modes["fts5"] = built
+	}
+	// want is in LIKE rank order: a title starting with the term first.
+	// FTS5 ranks by bm25, so only the set of hits is compared there.
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"Дробилка", []string{"Дробилка щековая", "Запасная Дробилка"}},
+		{"щековая Дробилка", []string{"Дробилка щековая"}},
+		{"Насос", []string{"<img src=x onerror=alert(1)> Насос"}},
+		{"Экскаватор", nil},
+	}
+	for mode, search := range modes {
+		for _, tt := range tests {
+			hits, err := search.Search(ctx, searchTerms(tt.query), []string{"equipment"}, 10)
+			if err != nil {
+				t.Fatalf("%s %q: %v", mode, tt.query, err)
+			}
+			got := map[int]int{}
+			for i, h := range hits {
+				got[h.ID] = i
+			}
+			if len(hits) != len(tt.want) {
+				t.Errorf("%s %q: got %+v, want %v", mode, tt.query, hits, tt.want)
+				continue
+			}
+			for i, name := range tt.want {
+				pos, ok := got[ids[name]]
+				if !ok || mode == "like" && pos != i {
+					t.Errorf("%s %q: got %+v, want %v", mode, tt.query, hits, tt.want)
+				}
+				if ok && hits[pos].Title != name {
+					t.Errorf("%s %q: title %q, want it unchanged as %q", mode, tt.query, hits[pos].Title, name)
+				}
+			}
+		}
+	}
+}
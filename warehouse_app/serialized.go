@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	trackingSerial = "serial"
+	trackingBulk   = "bulk"
+)
+
+// prepareEquipment fills in the tracking of new items and checks that
+// serialized items are single units with serial and inventory numbers not
+// used elsewhere. field names a field of the item with index i.
+func prepareEquipment(ctx context.Context, store *Store, items []NewEquipment, field func(i int, name string) string) error {
+	var fields []FieldError
+	var units []EquipmentUnit
+	var unitItems []int
+	for i := range items {
+		e := &items[i]
+		if e.Tracking == "" {
+			e.Tracking = trackingBulk
+			if e.SerialNumber != "" || e.InventoryNumber != "" {
+				e.Tracking = trackingSerial
+			}
+		}
+		if e.Tracking == trackingBulk {
+			if e.SerialNumber != "" {
+				fields = append(fields, fieldError(field(i, "serial_number"), "bulk_numbered"))
+			}
+			if e.InventoryNumber != "" {
+				fields = append(fields, fieldError(field(i, "inventory_number"), "bulk_numbered"))
+			}
+			continue
+		}
+		if e.Quantity != 1 {
+			fields = append(fields, fieldError(field(i, "quantity"), "serial_quantity"))
+		}
+		if e.SerialNumber == "" {
+			fields = append(fields, fieldError(field(i, "serial_number"), "required"))
+		}
+		if e.InventoryNumber == "" {
+			fields = append(fields, fieldError(field(i, "inventory_number"), "required"))
+		}
+		units = append(units, EquipmentUnit{SerialNumber: e.SerialNumber, InventoryNumber: e.InventoryNumber})
+		unitItems = append(unitItems, i)
+	}
+	taken, err := checkUnitNumbers(ctx, store, units, func(i int, name string) string { return field(unitItems[i], name) })
+	if err != nil {
+		return err
+	}
+	fields = append(fields, taken...)
+	if len(fields) > 0 {
+		return validationError(fields...)
+	}
+	return nil
+}
+
+// checkUnitNumbers reports serial and inventory numbers that repeat within
+// units or are already used by other equipment.
+func checkUnitNumbers(ctx context.Context, store *Store, units []EquipmentUnit, field func(i int, name string) string) ([]FieldError, error) {
+	var serials, inventory []interface{}
+	for _, u := range units {
+		if u.SerialNumber != "" {
+			serials = append(serials, u.SerialNumber)
+		}
+		if u.InventoryNumber != "" {
+			inventory = append(inventory, u.InventoryNumber)
+		}
+	}
+	usedSerials, usedInventory := map[string]bool{}, map[string]bool{}
+	if len(serials) > 0 {
+		existing, err := listAll(ctx, store.Equipment.List, []Filter{
+			{Field: "serial_number", Op: opIn, Values: serials},
+			{Field: "tracking", Op: opIn, Values: []interface{}{trackingSerial}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range existing {
+			usedSerials[e.SerialNumber] = true
+		}
+	}
+	if len(inventory) > 0 {
+		existing, err := listAll(ctx, store.Equipment.List, []Filter{{Field: "inventory_number", Op: opIn, Values: inventory}})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range existing {
+			usedInventory[e.InventoryNumber] = true
+		}
+	}
+
+	var fields []FieldError
+	for i, u := range units {
+		if u.SerialNumber != "" {
+			if usedSerials[u.SerialNumber] {
+				fields = append(fields, fieldError(field(i, "serial_number"), "taken"))
+			}
+			usedSerials[u.SerialNumber] = true
+		}
+		if u.InventoryNumber != "" {
+			if usedInventory[u.InventoryNumber] {
+				fields = append(fields, fieldError(field(i, "inventory_number"), "taken"))
+			}
+			usedInventory[u.InventoryNumber] = true
+		}
+	}
+	return fields, nil
+}
+
+// EquipmentSplitResult lists the ids of the units, the first being the
+// converted row.
+type EquipmentSplitResult struct {
+	IDs []int `json:"ids"`
+}
+
+// splitEquipment converts a bulk row, typically one created before serial
+// tracking, into serialized units.
+func splitEquipment(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req EquipmentSplit
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		e, err := equipmentByID(r, store)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if e.Status == equipmentWrittenOff {
+			writeError(w, r, newAPIError(http.StatusConflict, "equipment_written_off", e.Name))
+			return
+		}
+		if e.Tracking == trackingSerial {
+			writeError(w, r, newAPIError(http.StatusConflict, "already_serialized", e.Name))
+			return
+		}
+		if e.Quantity != math.Trunc(e.Quantity) || int(e.Quantity) != len(req.Units) {
+			writeError(w, r, validationError(fieldError("units", "units_count", e.Quantity)))
+			return
+		}
+		taken, err := checkUnitNumbers(ctx, store, req.Units, func(i int, name string) string { return fmt.Sprintf("units[%d].%s", i, name) })
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if len(taken) > 0 {
+			writeError(w, r, validationError(taken...))
+			return
+		}
+		ids, err := store.Equipment.Split(ctx, e.ID, req.Units)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		idList := make([]string, len(ids))
+		for i, id := range ids {
+			idList[i] = strconv.Itoa(id)
+		}
+		logAction(store.Logs, "system", "Перевод оборудования на поштучный учет", "equipment",
+			fmt.Sprintf("%s: %d ед., записи %s", e.Name, len(ids), strings.Join(idList, ", ")))
+		writeJSON(w, r, EquipmentSplitResult{IDs: ids})
+	}
+}
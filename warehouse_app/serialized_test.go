@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestSerialNumbersUnique(t *testing.T) {
+	ctx := context.Background()
+	store := openTestSQLite(t)
+	refs := loadTestRefs(t, store)
+	categories, err := store.Reference.EquipmentCategories(ctx)
+	if err != nil || len(categories) == 0 {
+		t.Fatalf("no equipment categories: %v", err)
+	}
+	item := func(serial, inventory string, quantity float64) NewEquipment {
+		return NewEquipment{Name: "Перфоратор", CategoryID: categories[0].ID, WarehouseID: refs.warehouses[0], UnitID: refs.unit,
+			Quantity: quantity, SerialNumber: serial, InventoryNumber: inventory}
+	}
+	if _, err := store.Equipment.Create(ctx, withTracking(item("SN-1", "ИНВ-1", 1), trackingSerial)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		items    []NewEquipment
+		want     []string
+		tracking []string
+	}{
+		{"new unit", []NewEquipment{item("SN-2", "ИНВ-2", 1)}, nil, []string{trackingSerial}},
+		{"bulk row", []NewEquipment{item("", "", 5)}, nil, []string{trackingBulk}},
+		{"serial taken", []NewEquipment{item("SN-1", "ИНВ-2", 1)}, []string{"items[0].serial_number taken"}, nil},
+		{"inventory number taken", []NewEquipment{item("SN-2", "ИНВ-1", 1)}, []string{"items[0].inventory_number taken"}, nil},
+		{"repeated in the request", []NewEquipment{item("SN-2", "ИНВ-2", 1), item("", "", 2), item("SN-2", "ИНВ-3", 1)},
+			[]string{"items[2].serial_number taken"}, nil},
+		{"without inventory number", []NewEquipment{item("SN-2", "", 1)}, []string{"items[0].inventory_number required"}, nil},
+		{"more than one unit", []NewEquipment{item("SN-2", "ИНВ-2", 3)}, []string{"items[0].quantity serial_quantity"}, nil},
+		{"numbered bulk row", []NewEquipment{withTracking(item("SN-2", "", 3), trackingBulk)}, []string{"items[0].serial_number bulk_numbered"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := append([]NewEquipment(nil), tt.items...)
+			err := prepareEquipment(ctx, store, items, func(i int, name string) string { return fmt.Sprintf("items[%d].%s", i, name) })
+			var got []string
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				for _, f := range apiErr.Fields {
+					got = append(got, f.Field+" "+f.Code)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			for i, tracking := range tt.tracking {
+				if items[i].Tracking != tracking {
+					t.Errorf("item %d tracked as %q, want %q", i, items[i].Tracking, tracking)
+				}
+			}
+		})
+	}
+}
+
+func withTracking(e NewEquipment, tracking string) NewEquipment {
+	e.Tracking = tracking
+	return e
+}
+
+func TestSplitEquipment(t *testing.T) {
+	ctx := context.Background()
+	store := openTestSQLite(t)
+	refs := loadTestRefs(t, store)
+	cfg := defaultConfig()
+	events := newEventBus()
+	defer events.close()
+	router := mux.NewRouter()
+	registerRoutes(router, apiRoutes(store, cfg, events), cfg.API)
+	categories, err := store.Reference.EquipmentCategories(ctx)
+	if err != nil || len(categories) == 0 {
+		t.Fatalf("no equipment categories: %v", err)
+	}
+	if _, err := store.Equipment.Create(ctx, NewEquipment{Name: "Рация", CategoryID: categories[0].ID, WarehouseID: refs.warehouses[0],
+		UnitID: refs.unit, Quantity: 1, SerialNumber: "SN-1", InventoryNumber: "ИНВ-1", Tracking: trackingSerial}); err != nil {
+		t.Fatal(err)
+	}
+	unit := func(n int) EquipmentUnit {
+		return EquipmentUnit{SerialNumber: fmt.Sprint("SN-", n), InventoryNumber: fmt.Sprint("ИНВ-", n)}
+	}
+
+	tests := []struct {
+		name      string
+		quantity  float64
+		cost      string
+		units     []EquipmentUnit
+		status    int
+		fields    []string
+		wantCosts []string
+	}{
+		{"into units", 3, "900", []EquipmentUnit{unit(10), unit(11), unit(12)}, http.StatusOK, nil, []string{"300", "300", "300"}},
+		{"cost not divisible", 3, "1000", []EquipmentUnit{unit(20), unit(21), unit(22)}, http.StatusOK, nil, []string{"333.34", "333.33", "333.33"}},
+		{"too few units", 3, "900", []EquipmentUnit{unit(30), unit(31)}, http.StatusBadRequest, []string{"units units_count"}, nil},
+		{"fractional quantity", 2.5, "900", []EquipmentUnit{unit(40), unit(41)}, http.StatusBadRequest, []string{"units units_count"}, nil},
+		{"serial taken", 2, "900", []EquipmentUnit{unit(1), unit(51)}, http.StatusBadRequest,
+			[]string{"units[0].serial_number taken", "units[0].inventory_number taken"}, nil},
+		{"repeated serial", 2, "900", []EquipmentUnit{unit(60), unit(60)}, http.StatusBadRequest,
+			[]string{"units[1].serial_number taken", "units[1].inventory_number taken"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost, _ := parseDecimal(tt.cost)
+			id, err := store.Equipment.Create(ctx, NewEquipment{Name: "Рация " + tt.name, CategoryID: categories[0].ID, WarehouseID: refs.warehouses[0],
+				UnitID: refs.unit, Quantity: tt.quantity, Tracking: trackingBulk, PurchaseCost: &cost})
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := json.Marshal(EquipmentSplit{Units: tt.units})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/equipment/%d/units", id), bytes.NewReader(body)))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				var envelope struct {
+					Error struct {
+						Fields []struct{ Field, Code string }
+					}
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, f := range envelope.Error.Fields {
+					got = append(got, f.Field+" "+f.Code)
+				}
+				if !reflect.DeepEqual(got, tt.fields) {
+					t.Errorf("fields %v, want %v", got, tt.fields)
+				}
+				return
+			}
+			var result EquipmentSplitResult
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || len(result.IDs) != len(tt.units) || result.IDs[0] != id {
+				t.Fatalf("result %s (%v), want %d ids starting with %d", w.Body, err, len(tt.units), id)
+			}
+			var costs []string
+			for i, unitID := range result.IDs {
+				items, _, err := store.Equipment.List(ctx, byID(unitID))
+				if err != nil || len(items) != 1 {
+					t.Fatalf("unit %d: %v (%v)", unitID, items, err)
+				}
+				e := items[0]
+				if e.Tracking != trackingSerial || e.Quantity != 1 || e.SerialNumber != tt.units[i].SerialNumber || e.InventoryNumber != tt.units[i].InventoryNumber {
+					t.Errorf("unit %d read back as %+v", unitID, e)
+				}
+				costs = append(costs, e.PurchaseCost.String())
+			}
+			if !reflect.DeepEqual(costs, tt.wantCosts) {
+				t.Errorf("unit costs %v, want %v", costs, tt.wantCosts)
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const contractorSupplier = "Поставщик"
+
+const (
+	partReceipt   = "Приход"
+	partIssue     = "Расход"
+	partCount     = "Инвентаризация"
+	partWorkOrder = "Расход по наряду"
+)
+
+// SparePart is a spare part or consumable kept in stock at the warehouses;
+// Quantity is the stock over all of them.
+type SparePart struct {
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	PartNumber   string   `json:"part_number"`
+	Category     string   `json:"category"`
+	UnitID       int      `json:"unit_id"`
+	UnitSymbol   string   `json:"unit_symbol"`
+	SupplierID   int      `json:"supplier_id,omitempty"`
+	SupplierName string   `json:"supplier_name"`
+	UnitCost     *Decimal `json:"unit_cost"`
+	Quantity     float64  `json:"quantity"`
+	CreatedAt    string   `json:"created_at"`
+}
+
+// PartStock is the stock of a part at one warehouse. It is reordered once
+// the quantity falls to MinQuantity, up to MaxQuantity.
+type PartStock struct {
+	PartID        int      `json:"part_id"`
+	PartName      string   `json:"part_name"`
+	PartNumber    string   `json:"part_number"`
+	Category      string   `json:"category"`
+	UnitSymbol    string   `json:"unit_symbol"`
+	SupplierID    int      `json:"supplier_id,omitempty"`
+	SupplierName  string   `json:"supplier_name"`
+	UnitCost      *Decimal `json:"unit_cost"`
+	WarehouseID   int      `json:"warehouse_id"`
+	WarehouseName string   `json:"warehouse_name"`
+	Quantity      float64  `json:"quantity"`
+	MinQuantity   *float64 `json:"min_quantity"`
+	MaxQuantity   *float64 `json:"max_quantity"`
+	UpdatedAt     string   `json:"updated_at"`
+}
+
+// PartMovement changes the stock of a part at a warehouse by Quantity,
+// negative for issues; Balance is the stock after it.
+type PartMovement struct {
+	ID            int     `json:"id"`
+	PartID        int     `json:"part_id"`
+	PartName      string  `json:"part_name"`
+	WarehouseID   int     `json:"warehouse_id"`
+	WarehouseName string  `json:"warehouse_name"`
+	Kind          string  `json:"kind"`
+	Quantity      float64 `json:"quantity"`
+	Balance       float64 `json:"balance"`
+	UnitSymbol    string  `json:"unit_symbol"`
+	WorkOrderID   int     `json:"work_order_id,omitempty"`
+	ReceiptID     int     `json:"receipt_id,omitempty"`
+	Note          string  `json:"note"`
+	MovedAt       string  `json:"moved_at"`
+	CreatedAt     string  `json:"created_at"`
+}
+
+type ReorderLine struct {
+	PartID        int      `json:"part_id"`
+	PartName      string   `json:"part_name"`
+	PartNumber    string   `json:"part_number"`
+	WarehouseID   int      `json:"warehouse_id"`
+	WarehouseName string   `json:"warehouse_name"`
+	UnitSymbol    string   `json:"unit_symbol"`
+	Quantity      float64  `json:"quantity"`
+	MinQuantity   float64  `json:"min_quantity"`
+	MaxQuantity   *float64 `json:"max_quantity"`
+	// OnOrder is ordered from suppliers and not received yet; Suggested
+	// counts it as if it were in stock.
+	OnOrder   float64  `json:"on_order"`
+	Suggested float64  `json:"suggested"`
+	UnitCost  *Decimal `json:"unit_cost"`
+	Cost      *Decimal `json:"cost"`
+}
+
+type partWarehouse struct {
+	PartID      int
+	WarehouseID int
+}
+
+// ReorderGroup lists what to order from one supplier. Parts without a
+// supplier are grouped under SupplierID 0. Total sums the lines with a cost.
+type ReorderGroup struct {
+	SupplierID    int           `json:"supplier_id"`
+	SupplierName  string        `json:"supplier_name"`
+	ContactPerson string        `json:"contact_person"`
+	Phone         string        `json:"phone"`
+	Email         string        `json:"email"`
+	Lines         []ReorderLine `json:"lines"`
+	Total         Decimal       `json:"total"`
+}
+
+var sparePartListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":          intField,
+		"name":        textField,
+		"part_number": textField,
+		"category":    textField,
+		"supplier_id": intField,
+	},
+	DefaultSort: "name,id",
+}
+
+var partStockListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"part_id":      intField,
+		"warehouse_id": intField,
+		"supplier_id":  intField,
+		"category":     textField,
+		"quantity":     floatField,
+	},
+	DefaultSort: "part_id,warehouse_id",
+	Key:         []string{"part_id", "warehouse_id"},
+}
+
+var partMovementListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":            intField,
+		"part_id":       intField,
+		"warehouse_id":  intField,
+		"kind":          textField,
+		"work_order_id": intField,
+		"receipt_id":    intField,
+		"moved_at":      dateField,
+	},
+	DefaultSort: "-moved_at,-id",
+}
+
+// checkSupplier makes sure contractor id, when set, is a supplier.
+func checkSupplier(ctx context.Context, store *Store, field string, id int) error {
+	if id == 0 {
+		return nil
+	}
+	contractors, err := store.Reference.Contractors(ctx)
+	if err != nil {
+		return err
+	}
+	for _, c := range contractors {
+		if c.ID == id && c.Type == contractorSupplier {
+			return nil
+		}
+	}
+	return validationError(fieldError(field, "not_supplier"))
+}
+
+func getSpareParts(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r, sparePartListSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		parts, total, err := store.Parts.List(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, ListPage{Items: parts, Total: total, NextCursor: q.nextCursor(parts, total)})
+	}
+}
+
+func addSparePart(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req NewSparePart
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if err := checkSupplier(ctx, store, "supplier_id", req.SupplierID); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		id, err := store.Parts.Create(ctx, req)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Добавление запчасти", "spare_parts", fmt.Sprintf("Запчасть %d: %s", id, req.Name))
+		writeJSON(w, r, MessageResponse{Message: "Запчасть добавлена", ID: id})
+	}
+}
+
+func getPartStock(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r, partStockListSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		stock, total, err := store.Parts.Stock(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, ListPage{Items: stock, Total: total, NextCursor: q.nextCursor(stock, total)})
+	}
+}
+
+func setPartLevels(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, ErrNotFound)
+			return
+		}
+		var req PartStockLevels
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if req.MinQuantity != nil && req.MaxQuantity != nil && *req.MaxQuantity < *req.MinQuantity {
+			writeError(w, r, validationError(fieldError("max_quantity", "below_min")))
+			return
+		}
+		parts, _, err := store.Parts.List(ctx, byID(id))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if len(parts) == 0 {
+			writeError(w, r, ErrNotFound)
+			return
+		}
+		if err := store.Parts.SetLevels(ctx, id, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Изменение уровней запаса", "spare_parts",
+			fmt.Sprintf("%s, склад %d: мин. %s, макс. %s", parts[0].Name, req.WarehouseID, formatLevel(req.MinQuantity), formatLevel(req.MaxQuantity)))
+		writeJSON(w, r, MessageResponse{Message: "Уровни запаса сохранены"})
+	}
+}
+
+func formatLevel(v *float64) string {
+	if v == nil {
+		return "—"
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func getPartMovements(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r, partMovementListSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		movements, total, err := store.Parts.Movements(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, ListPage{Items: movements, Total: total, NextCursor: q.nextCursor(movements, total)})
+	}
+}
+
+func addPartMovement(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var req NewPartMovement
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(ctx, store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if req.Kind != partCount && *req.Quantity == 0 {
+			writeError(w, r, validationError(fieldError("quantity", "positive")))
+			return
+		}
+		req.MovedAt = normalizeTimestamp(req.MovedAt)
+		id, err := store.Parts.Move(ctx, req)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Движение запчастей", "spare_parts",
+			fmt.Sprintf("%s: запчасть %d, склад %d, %g", req.Kind, req.PartID, req.WarehouseID, *req.Quantity))
+		writeJSON(w, r, MessageResponse{Message: "Движение сохранено", ID: id})
+	}
+}
+
+var reorderParams = []apiParam{
+	{Name: "warehouse_id", Type: "integer"},
+	{Name: "supplier_id", Type: "integer"},
+}
+
+// getReorderReport suggests orders for the parts at or below their minimum
+// level, bringing the stock up to the maximum, or to the minimum when no
+// maximum is set. Quantities on open purchase orders count as stock, so
+// parts already ordered are not suggested again.
+func getReorderReport(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		query := r.URL.Query()
+		var filters []Filter
+		for _, name := range []string{"warehouse_id", "supplier_id"} {
+			if v := query.Get(name); v != "" {
+				id, err := strconv.Atoi(v)
+				if err != nil {
+					writeError(w, r, queryError(name, "invalid_value", v))
+					return
+				}
+				filters = append(filters, Filter{Field: name, Op: opIn, Values: []interface{}{id}})
+			}
+		}
+		stock, err := listAll(ctx, func(ctx context.Context, q ListQuery) ([]PartStock, int, error) {
+			q.Sort = []SortKey{{Field: "part_id"}, {Field: "warehouse_id"}}
+			return store.Parts.Stock(ctx, q)
+		}, filters)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		onOrder, err := store.Purchases.OnOrder(ctx)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		contractors, err := store.Reference.Contractors(ctx)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		groups := map[int]*ReorderGroup{}
+		for _, st := range stock {
+			ordered := onOrder[partWarehouse{PartID: st.PartID, WarehouseID: st.WarehouseID}]
+			expected := st.Quantity + ordered
+			if st.MinQuantity == nil || expected > *st.MinQuantity {
+				continue
+			}
+			target := *st.MinQuantity
+			if st.MaxQuantity != nil && *st.MaxQuantity > target {
+				target = *st.MaxQuantity
+			}
+			if target <= expected {
+				continue
+			}
+			g := groups[st.SupplierID]
+			if g == nil {
+				g = &ReorderGroup{SupplierID: st.SupplierID, SupplierName: st.SupplierName, Lines: []ReorderLine{}}
+				for _, c := range contractors {
+					if c.ID == st.SupplierID {
+						g.ContactPerson, g.Phone, g.Email = c.ContactPerson, c.Phone, c.Email
+					}
+				}
+				groups[st.SupplierID] = g
+			}
+			line := ReorderLine{PartID: st.PartID, PartName: st.PartName, PartNumber: st.PartNumber,
+				WarehouseID: st.WarehouseID, WarehouseName: st.WarehouseName, UnitSymbol: st.UnitSymbol,
+				Quantity: st.Quantity, MinQuantity: *st.MinQuantity, MaxQuantity: st.MaxQuantity,
+				OnOrder: ordered, Suggested: target - expected, UnitCost: st.UnitCost}
+			if st.UnitCost != nil {
+				cost, err := st.UnitCost.MulFloat(line.Suggested)
+				if err == nil {
+					cost = cost.Round(2)
+					g.Total, err = g.Total.Add(cost)
+				}
+				if err != nil {
+					writeError(w, r, err)
+					return
+				}
+				line.Cost = &cost
+			}
+			g.Lines = append(g.Lines, line)
+		}
+		result := make([]ReorderGroup, 0, len(groups))
+		for _, g := range groups {
+			result = append(result, *g)
+		}
+		sort.Slice(result, func(i, j int) bool {
+			if (result[i].SupplierID == 0) != (result[j].SupplierID == 0) {
+				return result[j].SupplierID == 0
+			}
+			return result[i].SupplierName < result[j].SupplierName
+		})
+		writeJSON(w, r, result)
+	}
+}
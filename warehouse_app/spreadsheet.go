@@ -0,0 +1,288 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// sheetRow is one non-empty row of a spreadsheet; Line is its row number as
+// the user sees it in the file.
+type sheetRow struct {
+	Line  int
+	Cells []string
+}
+
+// readSpreadsheet reads a CSV or XLSX file. XLSX is recognised by its ZIP
+// signature, so a wrong Content-Type doesn't matter.
+func readSpreadsheet(data []byte, sheet string) ([]sheetRow, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return readXLSX(data, sheet)
+	}
+	return readCSV(data)
+}
+
+// readCSV accepts comma, semicolon and tab separated files in UTF-8 (with or
+// without BOM) or Windows-1251, which Excel uses for CSV on Russian systems.
+func readCSV(data []byte) ([]sheetRow, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		data = decodeWindows1251(data)
+	}
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.Comma = ','
+	for _, sep := range []rune{';', '\t'} {
+		if bytes.Count(header, []byte(string(sep))) > bytes.Count(header, []byte(string(r.Comma))) {
+			r.Comma = sep
+		}
+	}
+
+	var rows []sheetRow
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		if !blankRow(record) {
+			rows = append(rows, sheetRow{Line: line, Cells: record})
+		}
+	}
+}
+
+func blankRow(cells []string) bool {
+	for _, c := range cells {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// windows1251High maps bytes 0x80-0xBF; 0xC0-0xFF are А-я in order.
+var windows1251High = []rune("ЂЃ‚ѓ„…†‡€‰Љ‹ЊЌЋЏђ‘’“”•–—\u0098™љ›њќћџ\u00a0ЎўЈ¤Ґ¦§Ё©Є«¬\u00ad®Ї°±Ііґµ¶·ё№є»јЅѕї")
+
+func decodeWindows1251(data []byte) []byte {
+	var b strings.Builder
+	b.Grow(len(data) * 2)
+	for _, c := range data {
+		switch {
+		case c < 0x80:
+			b.WriteByte(c)
+		case c < 0xC0:
+			b.WriteRune(windows1251High[c-0x80])
+		default:
+			b.WriteRune(rune(c) - 0xC0 + 'А')
+		}
+	}
+	return []byte(b.String())
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Items []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+// xlsxText is plain text in <t> or rich text split into <r> runs.
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R      string   `xml:"r,attr"`
+			T      string   `xml:"t,attr"`
+			V      string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX reads the named sheet, or the first one. Cells keep their stored
+// values: numbers as written by Excel and dates as serial day numbers (see
+// parseImportDate).
+func readXLSX(data []byte, sheet string) ([]sheetRow, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	var wb xlsxWorkbook
+	if err := readZipXML(zr, "xl/workbook.xml", &wb); err != nil {
+		return nil, err
+	}
+	var rels xlsxRelationships
+	if err := readZipXML(zr, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	if len(wb.Sheets) == 0 {
+		return nil, errors.New("в книге нет листов")
+	}
+	rid := wb.Sheets[0].RID
+	if sheet != "" {
+		rid = ""
+		for _, s := range wb.Sheets {
+			if s.Name == sheet {
+				rid = s.RID
+			}
+		}
+		if rid == "" {
+			return nil, fmt.Errorf("лист %q не найден", sheet)
+		}
+	}
+	target := ""
+	for _, rel := range rels.Items {
+		if rel.ID == rid {
+			target = rel.Target
+		}
+	}
+	if strings.HasPrefix(target, "/") {
+		target = strings.TrimPrefix(target, "/")
+	} else {
+		target = path.Join("xl", target)
+	}
+
+	var shared xlsxSharedStrings
+	if err := readZipXML(zr, "xl/sharedStrings.xml", &shared); err != nil && !errors.Is(err, errZipEntryMissing) {
+		return nil, err
+	}
+	var ws xlsxSheet
+	if err := readZipXML(zr, target, &ws); err != nil {
+		return nil, err
+	}
+
+	var rows []sheetRow
+	total := 0
+	for i, row := range ws.Rows {
+		line := row.R
+		if line == 0 {
+			line = i + 1
+		}
+		var cells []string
+		for j, c := range row.Cells {
+			col := j
+			if c.R != "" {
+				var ok bool
+				if col, ok = xlsxColumn(c.R); !ok {
+					return nil, fmt.Errorf("строка %d: некорректная ссылка на ячейку %q", line, c.R)
+				}
+			} else if col >= xlsxMaxColumns {
+				return nil, fmt.Errorf("строка %d: больше %d столбцов", line, xlsxMaxColumns)
+			}
+			var value string
+			switch c.T {
+			case "s":
+				n, err := strconv.Atoi(c.V)
+				if err != nil || n < 0 || n >= len(shared.Items) {
+					return nil, fmt.Errorf("ячейка %s: некорректная ссылка на строку", c.R)
+				}
+				value = shared.Items[n].String()
+			case "inlineStr":
+				value = c.Inline.String()
+			default:
+				value = c.V
+			}
+			// Empty cells far to the right would only pad the row.
+			if value == "" {
+				continue
+			}
+			if col >= len(cells) {
+				total += col + 1 - len(cells)
+				if total > xlsxMaxCells {
+					return nil, fmt.Errorf("строка %d: в листе больше %d ячеек", line, xlsxMaxCells)
+				}
+				cells = append(cells, make([]string, col+1-len(cells))...)
+			}
+			cells[col] = value
+		}
+		if !blankRow(cells) {
+			rows = append(rows, sheetRow{Line: line, Cells: cells})
+		}
+	}
+	return rows, nil
+}
+
+var errZipEntryMissing = errors.New("zip entry missing")
+
+func readZipXML(zr *zip.Reader, name string, v interface{}) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, errZipEntryMissing)
+	}
+	defer f.Close()
+	if err := xml.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// Excel sheets have at most 16384 columns (A to XFD). Rows are padded up to
+// their last filled cell, so the cells of a sheet are limited as a whole.
+const (
+	xlsxMaxColumns = 16384
+	xlsxMaxCells   = 1 << 20
+)
+
+// xlsxColumn converts the letters of a cell reference such as "AB12" to a
+// zero-based column index. References without letters or beyond the last
+// Excel column are rejected.
+func xlsxColumn(ref string) (int, bool) {
+	col := 0
+	for _, c := range ref {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			col = col*26 + int(c-'A'+1)
+		case c >= 'a' && c <= 'z':
+			col = col*26 + int(c-'a'+1)
+		default:
+			return col - 1, col > 0
+		}
+		if col > xlsxMaxColumns {
+			return 0, false
+		}
+	}
+	return col - 1, col > 0
+}
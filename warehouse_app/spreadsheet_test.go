@@ -0,0 +1,93 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestXLSXColumn(t *testing.T) {
+	tests := []struct {
+		ref  string
+		col  int
+		want bool
+	}{
+		{"A1", 0, true},
+		{"a1", 0, true},
+		{"Ab12", 27, true},
+		{"XFD1", xlsxMaxColumns - 1, true},
+		{"xfd7", xlsxMaxColumns - 1, true},
+		{"XFE1", 0, false},
+		{"ZZZZZZ1", 0, false},
+		{strings.Repeat("Z", 40) + "1", 0, false},
+		{"12", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		col, ok := xlsxColumn(tt.ref)
+		if ok != tt.want || ok && col != tt.col {
+			t.Errorf("xlsxColumn(%q) = %d, %v; want %d, %v", tt.ref, col, ok, tt.col, tt.want)
+		}
+	}
+}
+
+// testWorkbook builds a one-sheet XLSX file with inline string cells.
+func testWorkbook(t *testing.T, rows ...[]string) []byte {
+	t.Helper()
+	var sheet strings.Builder
+	for i, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for _, cell := range row {
+			ref, value, _ := strings.Cut(cell, "=")
+			fmt.Fprintf(&sheet, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, value)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	files := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Лист1" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml":   `<worksheet><sheetData>` + sheet.String() + `</sheetData></worksheet>`,
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSXCellReferences(t *testing.T) {
+	rows, err := readXLSX(testWorkbook(t, []string{"a1=Склад", "c1=Количество"}, []string{"A2=1", "c2=5"}), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || strings.Join(rows[1].Cells, "|") != "1||5" {
+		t.Fatalf("got %+v", rows)
+	}
+
+	for _, ref := range []string{"ZZZZZZ2", "XFE2", "12"} {
+		_, err := readXLSX(testWorkbook(t, []string{"A1=Склад"}, []string{ref + "=1"}), "")
+		if err == nil || !strings.Contains(err.Error(), "строка 2") {
+			t.Errorf("%s: got %v, want an error for row 2", ref, err)
+		}
+	}
+
+	// A far cell is allowed once, but not on every row.
+	var many [][]string
+	for i := 1; i <= xlsxMaxCells/xlsxMaxColumns+1; i++ {
+		many = append(many, []string{fmt.Sprintf("XFD%d=x", i)})
+	}
+	if _, err := readXLSX(testWorkbook(t, many...), ""); err == nil {
+		t.Error("a sheet over the cell limit was read")
+	}
+}
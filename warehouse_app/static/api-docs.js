@@ -0,0 +1,148 @@
+// Просмотр документа OpenAPI: операции по тегам, параметры, схемы и
+// отправка пробного запроса.
+let apiDoc = null;
+
+function resolveRef(schema) {
+  if (schema && schema.$ref) {
+    return apiDoc.components.schemas[schema.$ref.split('/').pop()];
+  }
+  return schema;
+}
+
+// schemaText описывает схему в виде псевдо-JSON с типами и ограничениями полей.
+function schemaText(schema, indent = '', seen = []) {
+  if (!schema) return '';
+  if (schema.$ref) {
+    const name = schema.$ref.split('/').pop();
+    if (seen.includes(name)) return name;
+    return schemaText(resolveRef(schema), indent, seen.concat(name));
+  }
+  if (schema.type === 'array') {
+    return '[' + schemaText(schema.items, indent, seen) + ']';
+  }
+  if (schema.type === 'object' && schema.properties) {
+    const required = schema.required || [];
+    const lines = Object.keys(schema.properties).map(name => {
+      const prop = schema.properties[name];
+      const notes = [];
+      if (required.includes(name)) notes.push('обязательное');
+      if (prop.enum) notes.push(prop.enum.join(' | '));
+      if (prop.minimum !== undefined) notes.push((prop.exclusiveMinimum ? '> ' : '>= ') + prop.minimum);
+      if (prop.maximum !== undefined) notes.push('<= ' + prop.maximum);
+      if (prop.nullable) notes.push('может быть null');
+      if (prop.description) notes.push(prop.description);
+      const comment = notes.length ? '  // ' + notes.join('; ') : '';
+      return `${indent}  "${name}": ${schemaText(prop, indent + '  ', seen)}${comment}`;
+    });
+    return '{\n' + lines.join('\n') + '\n' + indent + '}';
+  }
+  return schema.type || 'any';
+}
+
+function renderOperation(path, method, op) {
+  const el = document.createElement('details');
+  el.className = 'block api-operation';
+  const params = op.parameters || [];
+  const body = op.requestBody ? op.requestBody.content['application/json'].schema : null;
+  const okContent = op.responses['200'].content || {};
+  const okTypes = Object.keys(okContent);
+
+  el.innerHTML = `
+    <summary>
+      <span class="api-method ${method}">${method.toUpperCase()}</span>
+      <span class="api-path">${path}</span>
+      <span>${op.summary || ''}</span>
+    </summary>
+    ${params.length ? `<h4>Параметры</h4>
+    <table class="table">
+      <thead><tr><th>Имя</th><th>Где</th><th>Тип</th><th>Описание</th><th>Значение</th></tr></thead>
+      <tbody>${params.map(p => `
+        <tr>
+          <td>${p.name}${p.required ? ' *' : ''}</td>
+          <td>${p.in}</td>
+          <td>${p.schema.type}${p.schema.enum ? ': ' + p.schema.enum.join(', ') : ''}</td>
+          <td>${p.description || ''}</td>
+          <td><input class="input" data-param="${p.name}" data-in="${p.in}" /></td>
+        </tr>`).join('')}
+      </tbody>
+    </table>` : ''}
+    ${body ? `<h4>Тело запроса</h4><pre>${schemaText(body)}</pre>
+    <textarea class="input" data-body></textarea>` : ''}
+    <h4>Ответ 200 (${okTypes.join(', ') || '—'})</h4>
+    ${okContent['application/json'] && okContent['application/json'].schema.type !== 'string'
+      ? `<pre>${schemaText(okContent['application/json'].schema)}</pre>` : ''}
+    <h4>Ошибка</h4>
+    <pre>${schemaText(op.responses.default.content['application/json'].schema)}</pre>
+    <button class="button" type="button">Выполнить</button>
+    <pre data-result hidden></pre>
+  `;
+  el.querySelector('button').onclick = () => tryOperation(el, path, method);
+  return el;
+}
+
+function tryOperation(el, path, method) {
+  const query = new URLSearchParams();
+  let url = path;
+  el.querySelectorAll('[data-param]').forEach(input => {
+    const value = input.value.trim();
+    if (!value) return;
+    if (input.dataset.in === 'path') {
+      url = url.replace(`{${input.dataset.param}}`, encodeURIComponent(value));
+    } else {
+      query.set(input.dataset.param, value);
+    }
+  });
+  if (query.toString()) url += '?' + query.toString();
+  const options = { method: method.toUpperCase(), headers: {} };
+  const body = el.querySelector('[data-body]');
+  if (body) {
+    options.headers['Content-Type'] = 'application/json';
+    options.body = body.value || '{}';
+  }
+  const result = el.querySelector('[data-result]');
+  result.hidden = false;
+  result.textContent = '...';
+  fetch(url, options)
+    .then(response => response.text().then(text => {
+      let pretty = text;
+      try {
+        pretty = JSON.stringify(JSON.parse(text), null, 2);
+      } catch (e) {
+        // не JSON — показываем как есть
+      }
+      result.textContent = `${response.status} ${response.statusText}\n\n${pretty}`;
+    }))
+    .catch(error => {
+      result.textContent = 'Ошибка: ' + error;
+    });
+}
+
+function renderApiDocs() {
+  document.getElementById('api-version').textContent = apiDoc.info.version;
+  const container = document.getElementById('api-operations');
+  const byTag = {};
+  Object.keys(apiDoc.paths).sort().forEach(path => {
+    Object.entries(apiDoc.paths[path]).forEach(([method, op]) => {
+      const tag = (op.tags && op.tags[0]) || 'other';
+      (byTag[tag] = byTag[tag] || []).push(renderOperation(path, method, op));
+    });
+  });
+  Object.keys(byTag).forEach(tag => {
+    const heading = document.createElement('h2');
+    heading.textContent = tag;
+    container.appendChild(heading);
+    byTag[tag].forEach(el => container.appendChild(el));
+  });
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+  fetch('/api/v1/openapi.json')
+    .then(response => response.json())
+    .then(doc => {
+      apiDoc = doc;
+      renderApiDocs();
+    })
+    .catch(error => {
+      document.getElementById('api-operations').textContent = 'Не удалось загрузить описание API: ' + error;
+    });
+});
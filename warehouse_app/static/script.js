@@ -11,12 +11,25 @@ let equipmentList = [];
 let orders = [];
 let shipments = [];
 
+// Загружает весь список, проходя по next_cursor: один запрос отдаёт не больше 1000 записей.
+function fetchAllPages(path, items = [], cursor = '') {
+  const params = new URLSearchParams({ limit: '1000' });
+  if (cursor) params.set('cursor', cursor);
+  return fetch(`${path}?${params}`)
+    .then(response => response.json())
+    .then(page => {
+      items.push(...page.items);
+      return page.next_cursor ? fetchAllPages(path, items, page.next_cursor) : items;
+    });
+}
+
 // Навигация
 function showPage(pageId) {
   document.querySelectorAll('.page').forEach(page => page.classList.remove('active'));
   document.getElementById(pageId).classList.add('active');
   document.querySelectorAll('.nav-button').forEach(btn => btn.classList.remove('active'));
-  document.querySelector(`[onclick="showPage('${pageId}')"]`).classList.add('active');
+  const navButton = document.querySelector(`.nav-button[onclick="showPage('${pageId}')"]`);
+  if (navButton) navButton.classList.add('active');
   if (window.innerWidth <= 768) {
     document.getElementById('sidebar').classList.remove('active');
     document.getElementById('content').classList.add('full');
@@ -77,9 +90,124 @@ function filterTable(input, tableId) {
   }
 }
 
+// Глобальный поиск
+const searchTypeLabels = {
+  ore_batch: 'Партия руды',
+  order: 'Заказ',
+  contractor: 'Контрагент',
+  equipment: 'Оборудование',
+  transport: 'Транспорт',
+  log: 'Лог'
+};
+
+const searchTypePages = {
+  ore_batch: 'input-ore',
+  order: 'orders',
+  contractor: 'orders',
+  equipment: 'input-equipment',
+  transport: 'shipments',
+  log: 'logs'
+};
+
+function runGlobalSearch() {
+  const query = document.getElementById('global-search-input').value.trim();
+  if (!query) return;
+  fetch('/api/v1/search?q=' + encodeURIComponent(query))
+    .then(response => response.json())
+    .then(hits => {
+      const tbody = document.getElementById('search-table-body');
+      tbody.innerHTML = '';
+      if (hits.length === 0) {
+        tbody.innerHTML = '<tr><td colspan="3">Ничего не найдено</td></tr>';
+      }
+      hits.forEach(hit => {
+        const row = document.createElement('tr');
+        row.style.cursor = 'pointer';
+        row.onclick = () => showPage(searchTypePages[hit.type] || 'dashboard');
+        // Названия и заметки вводят пользователи, поэтому только textContent.
+        [searchTypeLabels[hit.type] || hit.type, hit.title || '—', hit.snippet || '—'].forEach(text => {
+          const cell = document.createElement('td');
+          cell.textContent = text;
+          row.appendChild(cell);
+        });
+        tbody.appendChild(row);
+      });
+      showPage('search-results');
+    })
+    .catch(error => console.error('Ошибка поиска:', error));
+}
+
+// Отправка форм и ошибки API
+function postForm(form, url, data) {
+  clearFieldErrors(form);
+  return fetch(url, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(data)
+  }).then(response => response.json().then(body => {
+    if (!response.ok) throw body.error || { message: response.statusText };
+    return body;
+  }));
+}
+
+// importFile отправляет CSV или XLSX на загрузку; при dryRun файл только
+// проверяется. Ошибки по строкам приходят в error.fields как rows[N].поле.
+function importFile(inputId, url, dryRun, reload) {
+  const input = document.getElementById(inputId);
+  const file = input.files[0];
+  if (!file) {
+    alert('Выберите файл!');
+    return;
+  }
+  const container = input.parentElement;
+  clearFieldErrors(container);
+  fetch(url + (dryRun ? '?dry_run=true' : ''), {
+    method: 'POST',
+    headers: { 'Content-Type': file.type || 'text/csv' },
+    body: file
+  }).then(response => response.json().then(body => {
+    if (!response.ok) throw body.error || { message: response.statusText };
+    return body;
+  })).then(result => {
+    const lines = [dryRun ? `Проверено строк: ${result.rows}, ошибок нет` : `Загружено записей: ${result.created.length}`];
+    if (result.ignored_columns) lines.push('Пропущены колонки: ' + result.ignored_columns.join(', '));
+    (result.warnings || []).forEach(warning => lines.push(warning));
+    alert(lines.join('\n'));
+    if (!dryRun) {
+      input.value = '';
+      reload();
+    }
+  }).catch(error => showApiError(container, error));
+}
+
+function clearFieldErrors(form) {
+  form.querySelectorAll('.field-error').forEach(el => el.remove());
+  form.querySelectorAll('.invalid').forEach(el => el.classList.remove('invalid'));
+}
+
+// showApiError отмечает поля формы из error.fields; остальное выводится в alert.
+function showApiError(form, error) {
+  const unmatched = [];
+  (error.fields || []).forEach(field => {
+    const input = form.querySelector(`[name="${field.field}"]`);
+    if (!input) {
+      unmatched.push(`${field.field}: ${field.message}`);
+      return;
+    }
+    input.classList.add('invalid');
+    const hint = document.createElement('div');
+    hint.className = 'field-error';
+    hint.textContent = field.message;
+    input.insertAdjacentElement('afterend', hint);
+  });
+  if (!error.fields || unmatched.length > 0) {
+    alert(['Ошибка: ' + error.message].concat(unmatched).join('\n'));
+  }
+}
+
 // Справочники
 function loadReferenceData() {
-  return fetch('/api/reference-data')
+  return fetch('/api/v1/reference-data')
     .then(response => response.json())
     .then(data => {
       referenceData = data;
@@ -124,10 +252,9 @@ function populateSelect(elementId, items, valueFn, labelFn) {
 
 // Руды
 function loadOreBatches() {
-  return fetch('/api/ore-batches')
-    .then(response => response.json())
-    .then(data => {
-      oreBatches = data;
+  return fetchAllPages('/api/v1/ore-batches')
+    .then(items => {
+      oreBatches = items;
       renderDashboard();
       renderOreBatchTable();
       refreshOrderItemRows();
@@ -140,22 +267,25 @@ function renderDashboard() {
   const tbody = document.getElementById('dashboard-batches-body');
   if (!tbody) return;
   tbody.innerHTML = '';
-  oreBatches.forEach(batch => {
-    const quality = batch.quality ? `${batch.quality.toFixed(2)}%` : '—';
-    const statusClass = batch.status === 'Критический' || batch.priority === 'Критический' ? 'critical' : '';
-    const row = document.createElement('tr');
-    row.className = statusClass;
-    row.innerHTML = `
-      <td>${batch.batch_code || 'Партия ' + batch.id}</td>
-      <td>${batch.ore_type_name}</td>
-      <td>${batch.warehouse_name}</td>
-      <td>${batch.quantity.toFixed(2)}</td>
-      <td>${batch.unit_symbol || batch.unit_name}</td>
-      <td>${quality}</td>
-      <td>${batch.status || '—'}</td>
-    `;
-    tbody.appendChild(row);
-  });
+  oreBatches.forEach(batch => tbody.appendChild(dashboardRow(batch)));
+}
+
+function dashboardRow(batch) {
+  const quality = batch.quality ? `${batch.quality.toFixed(2)}%` : '—';
+  const statusClass = batch.status === 'Критический' || batch.priority === 'Критический' ? 'critical' : '';
+  const row = document.createElement('tr');
+  row.className = statusClass;
+  row.dataset.id = batch.id;
+  row.innerHTML = `
+    <td>${batch.batch_code || 'Партия ' + batch.id}</td>
+    <td>${batch.ore_type_name}</td>
+    <td>${batch.warehouse_name}</td>
+    <td>${batch.quantity.toFixed(2)}</td>
+    <td>${batch.unit_symbol || batch.unit_name}</td>
+    <td>${quality}</td>
+    <td>${batch.status || '—'}</td>
+  `;
+  return row;
 }
 
 function renderOreBatchTable() {
@@ -197,26 +327,20 @@ function saveOreBatch() {
     alert('Заполните обязательные поля!');
     return;
   }
-  fetch('/api/ore-batches', {
-    method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify(data)
-  })
-    .then(response => response.json())
+  postForm(form, '/api/v1/ore-batches', data)
     .then(result => {
       alert(result.message);
       form.reset();
       loadOreBatches();
     })
-    .catch(error => alert('Ошибка: ' + error));
+    .catch(error => showApiError(form, error));
 }
 
 // Оборудование
 function loadEquipment() {
-  return fetch('/api/equipment')
-    .then(response => response.json())
-    .then(data => {
-      equipmentList = data;
+  return fetchAllPages('/api/v1/equipment')
+    .then(items => {
+      equipmentList = items;
       renderEquipmentTable();
     })
     .catch(error => console.error('Ошибка загрузки оборудования:', error));
@@ -236,6 +360,9 @@ function renderEquipmentTable() {
       <td>${item.unit_symbol || item.unit_name}</td>
       <td>${item.status || '—'}</td>
       <td>${item.serial_number || '—'}</td>
+      <td>${item.inventory_number || '—'}</td>
+      <td>${item.service_end_date || '—'}${item.end_of_life ? ' (истек)' : ''}</td>
+      <td>${item.book_value != null ? item.book_value.toFixed(2) : '—'}</td>
     `;
     tbody.appendChild(row);
   });
@@ -250,34 +377,31 @@ function saveEquipment() {
     quantity: parseFloat(form.querySelector('[name="quantity"]').value),
     unit_id: parseInt(form.querySelector('[name="unit_id"]').value, 10),
     serial_number: form.querySelector('[name="serial_number"]').value.trim(),
+    inventory_number: form.querySelector('[name="inventory_number"]').value.trim(),
     service_life_months: form.querySelector('[name="service_life_months"]').value ? parseInt(form.querySelector('[name="service_life_months"]').value, 10) : null,
     status: form.querySelector('[name="status"]').value,
-    purchase_date: form.querySelector('[name="purchase_date"]').value
+    purchase_date: form.querySelector('[name="purchase_date"]').value,
+    purchase_cost: form.querySelector('[name="purchase_cost"]').value ? parseFloat(form.querySelector('[name="purchase_cost"]').value) : null,
+    depreciation_method: form.querySelector('[name="depreciation_method"]').value
   };
   if (!data.name || !data.category_id || !data.warehouse_id || !data.unit_id || !data.quantity) {
     alert('Заполните обязательные поля!');
     return;
   }
-  fetch('/api/equipment', {
-    method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify(data)
-  })
-    .then(response => response.json())
+  postForm(form, '/api/v1/equipment', data)
     .then(result => {
       alert(result.message);
       form.reset();
       loadEquipment();
     })
-    .catch(error => alert('Ошибка: ' + error));
+    .catch(error => showApiError(form, error));
 }
 
 // Заказы
 function loadOrders() {
-  return fetch('/api/orders')
-    .then(response => response.json())
-    .then(data => {
-      orders = data;
+  return fetchAllPages('/api/v1/orders')
+    .then(items => {
+      orders = items;
       renderOrdersTable();
       populateSelect('shipment-order-select', orders, order => order.id, order => `${order.order_number} (${order.contractor_name})`);
       renderShipmentsTable();
@@ -299,6 +423,7 @@ function renderOrdersTable() {
       <td>${order.status || '—'}</td>
       <td>${order.order_date ? new Date(order.order_date).toLocaleDateString() : '—'}</td>
       <td>${order.total_quantity ? order.total_quantity.toFixed(2) : '0.00'}</td>
+      <td>${order.total_amount ? order.total_amount.toFixed(2) : '0.00'} ${order.currency || ''}</td>
     `;
     tbody.appendChild(row);
   });
@@ -309,14 +434,15 @@ function addOrderItemRow() {
   const row = document.createElement('div');
   row.className = 'order-item-row';
   row.style.display = 'grid';
-  row.style.gridTemplateColumns = '2fr 1fr 1fr 1fr auto';
+  row.style.gridTemplateColumns = '2fr 1fr 1fr 1fr 2fr auto';
   row.style.gap = '10px';
   row.style.marginBottom = '10px';
   row.innerHTML = `
     <select class="select order-item-ore"></select>
     <select class="select order-item-unit"></select>
     <input class="input order-item-qty" type="number" step="0.01" min="0" placeholder="Кол-во" />
-    <input class="input order-item-price" type="number" step="0.01" min="0" placeholder="Цена за ед." />
+    <input class="input order-item-price" type="number" step="0.01" min="0" placeholder="Цена (по прайсу)" />
+    <input class="input order-item-reason" type="text" placeholder="Причина цены не по прайсу" />
     <div class="button danger" style="padding: 8px;" onclick="removeOrderItemRow(this)"><i class="fas fa-trash"></i></div>
   `;
   container.appendChild(row);
@@ -385,12 +511,16 @@ function saveOrder() {
     const unit = row.querySelector('.order-item-unit').value;
     const qty = row.querySelector('.order-item-qty').value;
     if (ore && unit && qty) {
-      items.push({
+      const item = {
         ore_batch_id: parseInt(ore, 10),
         unit_id: parseInt(unit, 10),
-        quantity: parseFloat(qty),
-        price_per_unit: row.querySelector('.order-item-price').value ? parseFloat(row.querySelector('.order-item-price').value) : 0
-      });
+        quantity: parseFloat(qty)
+      };
+      const price = row.querySelector('.order-item-price').value;
+      if (price) item.price_per_unit = parseFloat(price);
+      const reason = row.querySelector('.order-item-reason').value.trim();
+      if (reason) item.price_reason = reason;
+      items.push(item);
     }
   });
   const data = {
@@ -401,16 +531,13 @@ function saveOrder() {
     status: form.querySelector('[name="status"]').value,
     items
   };
+  const currency = form.querySelector('[name="currency"]').value.trim().toUpperCase();
+  if (currency) data.currency = currency;
   if (!data.order_number || !data.contractor_id || !data.warehouse_id || data.items.length === 0) {
     alert('Заполните обязательные поля и добавьте хотя бы одну позицию!');
     return;
   }
-  fetch('/api/orders', {
-    method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify(data)
-  })
-    .then(response => response.json())
+  postForm(form, '/api/v1/orders', data)
     .then(result => {
       alert(result.message);
       form.reset();
@@ -418,15 +545,14 @@ function saveOrder() {
       addOrderItemRow();
       loadOrders();
     })
-    .catch(error => alert('Ошибка: ' + error));
+    .catch(error => showApiError(form, error));
 }
 
 // Отгрузки
 function loadShipments() {
-  return fetch('/api/shipments')
-    .then(response => response.json())
-    .then(data => {
-      shipments = data;
+  return fetchAllPages('/api/v1/shipments')
+    .then(items => {
+      shipments = items;
       renderShipmentsTable();
       loadReports();
     })
@@ -463,18 +589,13 @@ function saveShipment() {
     alert('Выберите заказ для отгрузки!');
     return;
   }
-  fetch('/api/shipments', {
-    method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify(data)
-  })
-    .then(response => response.json())
+  postForm(form, '/api/v1/shipments', data)
     .then(result => {
       alert(result.message);
       form.reset();
       loadShipments();
     })
-    .catch(error => alert('Ошибка: ' + error));
+    .catch(error => showApiError(form, error));
 }
 
 // Отчеты
@@ -499,31 +620,150 @@ function loadReports() {
     tr.innerHTML = `<td>${row.label}</td><td>${row.value}</td>`;
     tbody.appendChild(tr);
   });
+  fetch('/api/v1/orders/revenue')
+    .then(response => response.json())
+    .then(revenue => {
+      const tr = document.createElement('tr');
+      tr.innerHTML = `<td>Выручка по подтверждённым заказам (${revenue.currency})</td><td>${revenue.total.toFixed(2)}</td>`;
+      tbody.appendChild(tr);
+    })
+    .catch(error => console.error('Ошибка загрузки выручки:', error));
 }
 
 // Логи
-function loadLogs() {
-  return fetch('/api/logs')
+let logsNextCursor = '';
+
+function logFilterParams() {
+  const params = new URLSearchParams();
+  const form = document.getElementById('logs-filter-form');
+  if (!form) return params;
+  ['q', 'user', 'action', 'entity', 'from', 'to', 'sort'].forEach(name => {
+    const value = form.querySelector(`[name="${name}"]`).value.trim();
+    if (value) params.set(name, value);
+  });
+  return params;
+}
+
+function fetchLogs(append) {
+  const params = logFilterParams();
+  if (append && logsNextCursor) params.set('cursor', logsNextCursor);
+  return fetch('/api/v1/logs?' + params.toString())
     .then(response => response.json())
-    .then(logs => {
+    .then(page => {
       const tbody = document.getElementById('logs-table-body');
       if (!tbody) return;
-      tbody.innerHTML = '';
-      logs.forEach(log => {
-        const row = document.createElement('tr');
-        row.innerHTML = `
-          <td>${log.event_time ? new Date(log.event_time).toLocaleString() : '—'}</td>
-          <td>${log.user || '—'}</td>
-          <td>${log.action || '—'}</td>
-          <td>${log.entity || '—'}</td>
-          <td>${log.details || '—'}</td>
-        `;
-        tbody.appendChild(row);
-      });
+      if (!append) tbody.innerHTML = '';
+      page.items.forEach(log => tbody.appendChild(logRow(log)));
+      logsNextCursor = page.next_cursor || '';
+      const more = document.getElementById('logs-more');
+      if (more) more.style.display = logsNextCursor ? '' : 'none';
     })
     .catch(error => console.error('Ошибка загрузки логов:', error));
 }
 
+function logRow(log) {
+  const row = document.createElement('tr');
+  row.innerHTML = `
+    <td>${log.event_time ? new Date(log.event_time).toLocaleString() : '—'}</td>
+    <td>${log.user || '—'}</td>
+    <td>${log.action || '—'}</td>
+    <td>${log.entity || '—'}</td>
+    <td>${log.details || '—'}</td>
+  `;
+  return row;
+}
+
+function loadLogs() {
+  return fetchLogs(false);
+}
+
+function loadMoreLogs() {
+  return fetchLogs(true);
+}
+
+function exportLogs(format) {
+  const params = logFilterParams();
+  params.set('format', format);
+  window.location.href = '/api/v1/logs/export?' + params.toString();
+}
+
+// Обновления в реальном времени
+function upsertById(items, item) {
+  const index = items.findIndex(existing => existing.id === item.id);
+  if (index >= 0) {
+    items[index] = item;
+  } else {
+    items.unshift(item);
+  }
+}
+
+function highlight(row) {
+  row.classList.add('live-updated');
+  setTimeout(() => row.classList.remove('live-updated'), 2000);
+}
+
+function subscribeToEvents() {
+  if (!window.EventSource) return;
+  // При переподключении браузер сам передаёт Last-Event-ID, и сервер
+  // досылает пропущенные события.
+  const source = new EventSource('/api/v1/events');
+
+  source.addEventListener('ore_batch.created', event => {
+    const batch = JSON.parse(event.data);
+    upsertById(oreBatches, batch);
+    const tbody = document.getElementById('dashboard-batches-body');
+    if (tbody) {
+      const row = dashboardRow(batch);
+      const existing = tbody.querySelector(`tr[data-id="${batch.id}"]`);
+      if (existing) {
+        existing.replaceWith(row);
+      } else {
+        tbody.prepend(row);
+      }
+      highlight(row);
+    }
+    renderOreBatchTable();
+    refreshOrderItemRows();
+    loadReports();
+  });
+
+  const onOrder = event => {
+    upsertById(orders, JSON.parse(event.data));
+    renderOrdersTable();
+    populateSelect('shipment-order-select', orders, order => order.id, order => `${order.order_number} (${order.contractor_name})`);
+    loadReports();
+  };
+  source.addEventListener('order.created', onOrder);
+  source.addEventListener('order.updated', onOrder);
+
+  const onShipment = event => {
+    upsertById(shipments, JSON.parse(event.data));
+    renderShipmentsTable();
+    loadReports();
+  };
+  source.addEventListener('shipment.created', onShipment);
+  source.addEventListener('shipment.updated', onShipment);
+
+  source.addEventListener('log.created', event => {
+    // Новая запись попадает в начало журнала, только если он не отфильтрован.
+    const params = logFilterParams();
+    params.delete('sort');
+    const sort = document.querySelector('#logs-filter-form [name="sort"]');
+    if (params.toString() || (sort && sort.value === 'asc')) return;
+    const tbody = document.getElementById('logs-table-body');
+    if (!tbody) return;
+    const row = logRow(JSON.parse(event.data));
+    tbody.prepend(row);
+    highlight(row);
+  });
+
+  // Пропущенные события недоступны (например, сервер перезапускался) —
+  // перечитываем данные целиком.
+  source.addEventListener('reset', () => {
+    Promise.all([loadOreBatches(), loadOrders(), loadShipments(), loadLogs()]);
+  });
+}
+
 // Инициализация
 document.addEventListener('DOMContentLoaded', () => {
   loadReferenceData()
@@ -532,5 +772,6 @@ document.addEventListener('DOMContentLoaded', () => {
       if (document.querySelectorAll('.order-item-row').length === 0) {
         addOrderItemRow();
       }
+      subscribeToEvents();
     });
 });
@@ -0,0 +1,583 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+type NewOreBatch struct {
+	OreTypeID      int      `json:"ore_type_id" validate:"required,ref=ore_types"`
+	WarehouseID    int      `json:"warehouse_id" validate:"required,ref=warehouses"`
+	UnitID         int      `json:"unit_id" validate:"required,ref=units"`
+	BatchCode      string   `json:"batch_code"`
+	Quantity       float64  `json:"quantity" validate:"required,positive"`
+	Quality        *float64 `json:"quality" validate:"min=0,max=100"`
+	Priority       string   `json:"priority" validate:"enum=ore_batch_priority"`
+	ExtractionDate string   `json:"extraction_date" validate:"date,notfuture"`
+	Status         string   `json:"status" validate:"enum=ore_batch_status"`
+}
+
+type NewEquipment struct {
+	Name         string  `json:"name" validate:"required"`
+	CategoryID   int     `json:"category_id" validate:"required,ref=equipment_categories"`
+	WarehouseID  int     `json:"warehouse_id" validate:"required,ref=warehouses"`
+	UnitID       int     `json:"unit_id" validate:"required,ref=units"`
+	Quantity     float64 `json:"quantity" validate:"required,positive"`
+	SerialNumber string  `json:"serial_number"`
+	// Tracking defaults to serial when a serial or inventory number is
+	// given; serial items are one unit per row with both numbers set.
+	InventoryNumber string `json:"inventory_number"`
+	Tracking        string `json:"tracking" validate:"enum=equipment_tracking"`
+	ServiceLife     *int   `json:"service_life_months" validate:"positive"`
+	Status          string `json:"status" validate:"enum=equipment_status"`
+	PurchaseDate    string `json:"purchase_date" validate:"date,notfuture"`
+	// Depreciation is counted when the cost, service life and purchase date
+	// are all set; straight-line unless another method is given.
+	PurchaseCost       *Decimal `json:"purchase_cost" validate:"min=0"`
+	SalvageValue       *Decimal `json:"salvage_value" validate:"min=0"`
+	DepreciationMethod string   `json:"depreciation_method" validate:"enum=depreciation_method"`
+}
+
+type EquipmentUnit struct {
+	SerialNumber    string `json:"serial_number" validate:"required"`
+	InventoryNumber string `json:"inventory_number" validate:"required"`
+}
+
+// EquipmentSplit converts a bulk row into serialized units, one per unit of
+// its quantity.
+type EquipmentSplit struct {
+	Units []EquipmentUnit `json:"units" validate:"required,dive"`
+}
+
+type EquipmentWriteOff struct {
+	// Date is today by default; ResidualValue is the book value on Date.
+	Date          string   `json:"date" validate:"date,notfuture"`
+	ResidualValue *Decimal `json:"residual_value" validate:"min=0"`
+	Reason        string   `json:"reason"`
+}
+
+type NewOrderItem struct {
+	OreBatchID int     `json:"ore_batch_id" validate:"required,ref=ore_batches"`
+	UnitID     int     `json:"unit_id" validate:"required,ref=units"`
+	Quantity   float64 `json:"quantity" validate:"required,positive"`
+	// PricePerUnit, in the order currency, is taken from the price list
+	// when omitted. A price different from the list's is an override;
+	// priceOrder rejects it without a PriceReason.
+	PricePerUnit *Decimal `json:"price_per_unit" validate:"min=0"`
+	PriceReason  string   `json:"price_reason"`
+
+	ListPrice   *Decimal `json:"-"`
+	PriceListID int      `json:"-"`
+	Grade       float64  `json:"-"`
+	PriceSource string   `json:"-"`
+}
+
+type NewOrder struct {
+	OrderNumber  string `json:"order_number" validate:"required"`
+	ContractorID int    `json:"contractor_id" validate:"required,ref=contractors"`
+	WarehouseID  int    `json:"warehouse_id" validate:"required,ref=warehouses"`
+	Status       string `json:"status" validate:"enum=order_status"`
+	OrderDate    string `json:"order_date" validate:"date"`
+	// Currency is the reporting currency by default.
+	Currency string         `json:"currency" validate:"currency"`
+	Items    []NewOrderItem `json:"items" validate:"required,dive"`
+
+	VATRate float64     `json:"-"`
+	Rate    *frozenRate `json:"-"`
+}
+
+type NewShipment struct {
+	OrderID     int    `json:"order_id" validate:"required,ref=sales_orders"`
+	TransportID int    `json:"transport_id" validate:"ref=transport"`
+	PlannedDate string `json:"planned_date" validate:"date"`
+	ActualDate  string `json:"actual_date" validate:"date,notfuture"`
+	Status      string `json:"status" validate:"enum=shipment_status"`
+}
+
+type OrderStatusUpdate struct {
+	Status string `json:"status" validate:"required,enum=order_status"`
+
+	// Rate is frozen when the order is confirmed.
+	Rate *frozenRate `json:"-"`
+}
+
+type ShipmentStatusUpdate struct {
+	Status string `json:"status" validate:"required,enum=shipment_status"`
+}
+
+type NewMaintenancePlan struct {
+	EquipmentID   int      `json:"equipment_id" validate:"required,ref=equipment"`
+	Name          string   `json:"name" validate:"required"`
+	IntervalDays  *int     `json:"interval_days" validate:"positive"`
+	IntervalHours *float64 `json:"interval_hours" validate:"positive"`
+	// StartDate and StartHours are where the first interval is counted
+	// from: today and the latest engine hours reading by default.
+	StartDate  string   `json:"start_date" validate:"date"`
+	StartHours *float64 `json:"start_hours" validate:"min=0"`
+}
+
+type NewWorkOrder struct {
+	EquipmentID int    `json:"equipment_id" validate:"required,ref=equipment"`
+	PlanID      int    `json:"plan_id" validate:"ref=maintenance_plans"`
+	Title       string `json:"title" validate:"required"`
+	Description string `json:"description"`
+	PlannedDate string `json:"planned_date" validate:"date"`
+}
+
+type WorkOrderStatusUpdate struct {
+	Status string `json:"status" validate:"required,enum=work_order_status"`
+	// Date is when the change happened; now by default.
+	Date string `json:"date" validate:"date,notfuture"`
+	// MeterHours is the engine hours reading at completion.
+	MeterHours *float64 `json:"meter_hours" validate:"min=0"`
+}
+
+type NewWorkOrderPart struct {
+	// PartID takes the part from stock of WarehouseID, by default the
+	// warehouse of the item; name, unit and cost default to the part's.
+	// Without it Name and UnitID are required.
+	PartID      int      `json:"part_id" validate:"ref=spare_parts"`
+	WarehouseID int      `json:"warehouse_id" validate:"ref=warehouses"`
+	Name        string   `json:"name"`
+	UnitID      int      `json:"unit_id" validate:"ref=units"`
+	Quantity    float64  `json:"quantity" validate:"required,positive"`
+	UnitCost    *Decimal `json:"unit_cost" validate:"min=0"`
+}
+
+type NewWorkOrderLabor struct {
+	Worker   string  `json:"worker" validate:"required"`
+	Hours    float64 `json:"hours" validate:"required,positive"`
+	WorkDate string  `json:"work_date" validate:"date,notfuture"`
+	Note     string  `json:"note"`
+}
+
+type NewMeterReading struct {
+	EquipmentID int      `json:"equipment_id" validate:"required,ref=equipment"`
+	Meter       string   `json:"meter" validate:"required,enum=meter_type"`
+	Value       *float64 `json:"value" validate:"required,min=0"`
+	// ReadAt is when the meter was read; now by default.
+	ReadAt string `json:"read_at" validate:"date,notfuture"`
+	Source string `json:"source"`
+}
+
+type NewAssignment struct {
+	EquipmentID int    `json:"equipment_id" validate:"required,ref=equipment"`
+	WarehouseID int    `json:"warehouse_id" validate:"required,ref=warehouses"`
+	Site        string `json:"site"`
+	Crew        string `json:"crew"`
+	Responsible string `json:"responsible" validate:"required"`
+	// CheckedOutAt is now by default; DueDate is the planned return.
+	CheckedOutAt string `json:"checked_out_at" validate:"date,notfuture"`
+	DueDate      string `json:"due_date" validate:"date"`
+	Note         string `json:"note"`
+}
+
+type AssignmentReturn struct {
+	// ReturnedAt is now by default. WarehouseID is where the item goes
+	// back to, the warehouse it was checked out from by default.
+	ReturnedAt  string `json:"returned_at" validate:"date,notfuture"`
+	WarehouseID int    `json:"warehouse_id" validate:"ref=warehouses"`
+	Note        string `json:"note"`
+}
+
+type NewSparePart struct {
+	Name       string `json:"name" validate:"required"`
+	PartNumber string `json:"part_number"`
+	Category   string `json:"category"`
+	UnitID     int    `json:"unit_id" validate:"required,ref=units"`
+	// SupplierID is a contractor of type Поставщик.
+	SupplierID int      `json:"supplier_id" validate:"ref=contractors"`
+	UnitCost   *Decimal `json:"unit_cost" validate:"min=0"`
+}
+
+// PartStockLevels sets the reorder point and the level to reorder up to of
+// a part at a warehouse; a nil level is cleared.
+type PartStockLevels struct {
+	WarehouseID int      `json:"warehouse_id" validate:"required,ref=warehouses"`
+	MinQuantity *float64 `json:"min_quantity" validate:"min=0"`
+	MaxQuantity *float64 `json:"max_quantity" validate:"min=0"`
+}
+
+type NewPartMovement struct {
+	PartID      int    `json:"part_id" validate:"required,ref=spare_parts"`
+	WarehouseID int    `json:"warehouse_id" validate:"required,ref=warehouses"`
+	Kind        string `json:"kind" validate:"required,enum=part_movement_kind"`
+	// Quantity is received or issued, or for a stock count the quantity
+	// counted.
+	Quantity *float64 `json:"quantity" validate:"required,min=0"`
+	// MovedAt is now by default.
+	MovedAt string `json:"moved_at" validate:"date,notfuture"`
+	Note    string `json:"note"`
+}
+
+// NewPriceList prices an ore type in a unit from ValidFrom to ValidTo, for
+// one buyer or, without ContractorID, for all. With BaseGrade the price is
+// for ore of that grade (% Fe) and every percent above or below it adds
+// BonusPerPct or takes off PenaltyPerPct.
+type NewPriceList struct {
+	OreTypeID     int      `json:"ore_type_id" validate:"required,ref=ore_types"`
+	ContractorID  int      `json:"contractor_id" validate:"ref=contractors"`
+	UnitID        int      `json:"unit_id" validate:"required,ref=units"`
+	Currency      string   `json:"currency" validate:"currency"`
+	BasePrice     *Decimal `json:"base_price" validate:"required,min=0"`
+	BaseGrade     *float64 `json:"base_grade" validate:"min=0,max=100"`
+	BonusPerPct   *Decimal `json:"bonus_per_pct" validate:"min=0"`
+	PenaltyPerPct *Decimal `json:"penalty_per_pct" validate:"min=0"`
+	ValidFrom     string   `json:"valid_from" validate:"date"`
+	ValidTo       string   `json:"valid_to" validate:"date"`
+	Note          string   `json:"note"`
+}
+
+// NewExchangeRate is the price of Nominal units of Currency in the reporting
+// currency on RateDate.
+type NewExchangeRate struct {
+	Currency string   `json:"currency" validate:"required,currency"`
+	RateDate string   `json:"rate_date" validate:"required,date"`
+	Rate     *Decimal `json:"rate" validate:"required,positive"`
+	Nominal  int      `json:"nominal" validate:"min=1"`
+}
+
+type NewPurchaseOrder struct {
+	OrderNumber string `json:"order_number" validate:"required"`
+	// SupplierID is a contractor of type Поставщик; WarehouseID is where
+	// the goods are delivered.
+	SupplierID   int               `json:"supplier_id" validate:"required,ref=contractors"`
+	WarehouseID  int               `json:"warehouse_id" validate:"required,ref=warehouses"`
+	OrderDate    string            `json:"order_date" validate:"date"`
+	ExpectedDate string            `json:"expected_date" validate:"date"`
+	Note         string            `json:"note"`
+	Items        []NewPurchaseItem `json:"items" validate:"required,dive"`
+}
+
+// NewPurchaseItem orders either a spare part (PartID) or equipment of a
+// category (CategoryID, with Name and UnitID). Name and unit of a part
+// default to the part's.
+type NewPurchaseItem struct {
+	PartID     int      `json:"part_id" validate:"ref=spare_parts"`
+	CategoryID int      `json:"category_id" validate:"ref=equipment_categories"`
+	Name       string   `json:"name"`
+	UnitID     int      `json:"unit_id" validate:"ref=units"`
+	Quantity   float64  `json:"quantity" validate:"required,positive"`
+	Price      *Decimal `json:"price" validate:"required,min=0"`
+}
+
+type PurchaseStatusUpdate struct {
+	Status string `json:"status" validate:"required,enum=purchase_order_status"`
+}
+
+type NewGoodsReceipt struct {
+	// ReceivedAt is now by default, WarehouseID the warehouse of the
+	// order.
+	ReceivedAt     string           `json:"received_at" validate:"date,notfuture"`
+	WarehouseID    int              `json:"warehouse_id" validate:"ref=warehouses"`
+	DocumentNumber string           `json:"document_number"`
+	Note           string           `json:"note"`
+	Items          []NewReceiptItem `json:"items" validate:"required,dive"`
+}
+
+type NewReceiptItem struct {
+	OrderItemID int     `json:"order_item_id" validate:"required"`
+	Quantity    float64 `json:"quantity" validate:"required,positive"`
+	// Units lists the serial and inventory numbers of received equipment
+	// to put it on unit tracking, one per unit of Quantity.
+	Units []EquipmentUnit `json:"units" validate:"dive"`
+}
+
+type NewWebhook struct {
+	URL    string   `json:"url" validate:"required,url"`
+	Events []string `json:"events" validate:"required,enum=webhook_event"`
+	// Secret signs the payloads; one is generated when it is empty.
+	Secret string `json:"secret"`
+}
+
+type BatchStore interface {
+	List(ctx context.Context, q ListQuery) ([]OreBatch, int, error)
+	Create(ctx context.Context, b NewOreBatch) (int, error)
+	// CreateMany inserts all batches in one transaction, or none of them.
+	CreateMany(ctx context.Context, batches []NewOreBatch) ([]int, error)
+	// WarehouseStock sums the quantity of all batches stored in a warehouse.
+	WarehouseStock(ctx context.Context, warehouseID int) (float64, error)
+}
+
+type EquipmentStore interface {
+	List(ctx context.Context, q ListQuery) ([]Equipment, int, error)
+	Create(ctx context.Context, e NewEquipment) (int, error)
+	// CreateMany inserts all items in one transaction, or none of them.
+	CreateMany(ctx context.Context, items []NewEquipment) ([]int, error)
+	// WriteOff sets the status to Списано and records w; ErrNotFound for an
+	// unknown id.
+	WriteOff(ctx context.Context, id int, w EquipmentWriteOff) error
+	// Split turns the bulk row id into len(units) serialized rows, sharing
+	// its cost in cents (see Decimal.Share). The row itself becomes the first unit, so records
+	// that refer to it stay with that unit. It returns the ids of all units.
+	Split(ctx context.Context, id int, units []EquipmentUnit) ([]int, error)
+}
+
+// Orders and shipments queue their webhook events in the transaction that
+// changes them (see enqueueWebhook).
+
+type OrderStore interface {
+	List(ctx context.Context, q ListQuery) ([]SalesOrder, int, error)
+	Create(ctx context.Context, o NewOrder) (int, error)
+	// UpdateStatus returns ErrNotFound when no order has the given id.
+	UpdateStatus(ctx context.Context, id int, u OrderStatusUpdate) error
+}
+
+type ShipmentStore interface {
+	List(ctx context.Context, q ListQuery) ([]Shipment, int, error)
+	Create(ctx context.Context, s NewShipment) (int, error)
+	// UpdateStatus returns ErrNotFound when no shipment has the given id.
+	UpdateStatus(ctx context.Context, id int, status string) error
+}
+
+type ReferenceStore interface {
+	Units(ctx context.Context) ([]Unit, error)
+	Warehouses(ctx context.Context) ([]Warehouse, error)
+	OreTypes(ctx context.Context) ([]OreType, error)
+	EquipmentCategories(ctx context.Context) ([]EquipmentCategory, error)
+	Contractors(ctx context.Context) ([]Contractor, error)
+	Transport(ctx context.Context) ([]Transport, error)
+	// Exists reports whether a row with the given id is present in table.
+	Exists(ctx context.Context, table string, id int) (bool, error)
+	// Seed fills empty reference tables with the default dataset.
+	Seed(ctx context.Context) error
+}
+
+type LogStore interface {
+	List(ctx context.Context, f logFilter) ([]LogEntry, int, error)
+	// Each streams every entry matching f, ignoring its limit and cursor.
+	Each(ctx context.Context, f logFilter, fn func(LogEntry) error) error
+	Add(ctx context.Context, entry LogEntry) error
+	// Archive streams entries older than before to write one at a time,
+	// calls done once all were written and deletes them in the same
+	// transaction only if both succeed.
+	Archive(ctx context.Context, before string, write func(LogEntry) error, done func() error) (int, error)
+}
+
+type MaintenanceStore interface {
+	Plans(ctx context.Context, q ListQuery) ([]MaintenancePlan, int, error)
+	CreatePlan(ctx context.Context, p NewMaintenancePlan) (int, error)
+	// DeletePlan returns ErrNotFound for an unknown id. Work orders raised
+	// for the plan are kept.
+	DeletePlan(ctx context.Context, id int) error
+	WorkOrders(ctx context.Context, q ListQuery) ([]WorkOrder, int, error)
+	// WorkOrder returns one work order with its parts and labor, or
+	// ErrNotFound.
+	WorkOrder(ctx context.Context, id int) (WorkOrder, error)
+	// CreateWorkOrder opens a work order and puts the equipment on
+	// maintenance.
+	CreateWorkOrder(ctx context.Context, w NewWorkOrder) (int, error)
+	// UpdateWorkOrderStatus moves a work order to u.Status, or returns a
+	// *StatusConflict when workOrderNext does not allow the move. Completing
+	// it records the service on its plan, and completing the last open work
+	// order of an item gives the item back the status it had before.
+	UpdateWorkOrderStatus(ctx context.Context, id int, u WorkOrderStatusUpdate) error
+	AddPart(ctx context.Context, workOrderID int, p NewWorkOrderPart) (int, error)
+	AddLabor(ctx context.Context, workOrderID int, l NewWorkOrderLabor) (int, error)
+}
+
+type MeterStore interface {
+	Readings(ctx context.Context, q ListQuery) ([]MeterReading, int, error)
+	// Around returns the readings of the meter taken last at or before at and
+	// first after it; either is nil when there is none.
+	Around(ctx context.Context, equipmentID int, meter, at string) (prev, next *MeterReading, err error)
+	Create(ctx context.Context, m NewMeterReading) (int, error)
+	CreateMany(ctx context.Context, readings []NewMeterReading) ([]int, error)
+	// Delete returns ErrNotFound for an unknown id.
+	Delete(ctx context.Context, id int) error
+	// Usage returns, for every item, the meter at the start and the end of
+	// the period [from, to).
+	Usage(ctx context.Context, meter, from, to string) ([]MeterUsage, error)
+}
+
+type AssignmentStore interface {
+	List(ctx context.Context, q ListQuery) ([]Assignment, int, error)
+	// CheckOut records the assignment and moves the equipment to its
+	// warehouse.
+	CheckOut(ctx context.Context, a NewAssignment) (int, error)
+	// Return closes the assignment, replacing its note with r.Note, and
+	// moves the equipment to r.WarehouseID; ErrNotFound for an unknown id.
+	Return(ctx context.Context, id int, r AssignmentReturn) error
+}
+
+type PartStore interface {
+	// List returns parts with their stock summed over all warehouses.
+	List(ctx context.Context, q ListQuery) ([]SparePart, int, error)
+	Create(ctx context.Context, p NewSparePart) (int, error)
+	// Stock returns the stock of parts by warehouse, including warehouses
+	// that only have levels set.
+	Stock(ctx context.Context, q ListQuery) ([]PartStock, int, error)
+	SetLevels(ctx context.Context, partID int, l PartStockLevels) error
+	Movements(ctx context.Context, q ListQuery) ([]PartMovement, int, error)
+	// Move records a receipt, an issue or a stock count and returns a
+	// *StockShortage when an issue exceeds the stock.
+	Move(ctx context.Context, m NewPartMovement) (int, error)
+}
+
+type PriceStore interface {
+	List(ctx context.Context, q ListQuery) ([]PriceList, int, error)
+	Create(ctx context.Context, p NewPriceList) (int, error)
+	// Delete returns ErrNotFound when no price list has the given id.
+	Delete(ctx context.Context, id int) error
+	// Overrides returns order items priced by hand.
+	Overrides(ctx context.Context, q ListQuery) ([]PriceOverride, int, error)
+}
+
+type RateStore interface {
+	List(ctx context.Context, q ListQuery) ([]ExchangeRate, int, error)
+	// Set adds the rates, replacing those already entered for the same
+	// currency and day.
+	Set(ctx context.Context, rates []NewExchangeRate, source string) error
+	// FillCurrency gives price lists and orders without a currency the
+	// reporting currency and returns how many rows it changed.
+	FillCurrency(ctx context.Context, currency string) (int, error)
+}
+
+type PurchaseStore interface {
+	List(ctx context.Context, q ListQuery) ([]PurchaseOrder, int, error)
+	Create(ctx context.Context, o NewPurchaseOrder) (int, error)
+	// UpdateStatus returns ErrNotFound for an unknown id.
+	UpdateStatus(ctx context.Context, id int, status string) error
+	Receipts(ctx context.Context, q ListQuery) ([]GoodsReceipt, int, error)
+	// Receive records a receipt against an order: it adds the received
+	// quantities to the order items, puts parts in stock, creates the
+	// equipment of the lines and moves the order to status.
+	Receive(ctx context.Context, orderID int, r NewGoodsReceipt, lines []receiptLine, status string) (int, error)
+	// OnOrder sums the part quantities ordered and not yet received on
+	// draft, sent and partly received orders, by delivery warehouse.
+	OnOrder(ctx context.Context) (map[partWarehouse]float64, error)
+}
+
+type WebhookStore interface {
+	List(ctx context.Context) ([]Webhook, error)
+	// Get and Delete return ErrNotFound for an unknown id.
+	Get(ctx context.Context, id int) (Webhook, error)
+	Create(ctx context.Context, w NewWebhook) (int, error)
+	Delete(ctx context.Context, id int) error
+	Deliveries(ctx context.Context, q ListQuery) ([]WebhookDelivery, int, error)
+	// Delivery returns one delivery with its payload and attempt log.
+	Delivery(ctx context.Context, id int) (WebhookDelivery, error)
+	// Claim picks up to limit pending deliveries due at now and hides them
+	// from other callers until leaseUntil, when an unfinished attempt is
+	// retried.
+	Claim(ctx context.Context, now, leaseUntil string, limit int) ([]webhookJob, error)
+	// Record logs an attempt and moves the delivery to status, to be tried
+	// again at next while it stays pending.
+	Record(ctx context.Context, deliveryID int, attempt WebhookAttempt, status, next string) error
+	// Redeliver queues a delivery again regardless of its status.
+	Redeliver(ctx context.Context, id int) error
+}
+
+type ExchangeStore interface {
+	// GUIDs returns the 1C identifiers of the given rows of entity and
+	// assigns new ones to rows exported for the first time.
+	GUIDs(ctx context.Context, entity string, ids []int) (map[int]string, error)
+	// Import applies contractor and price updates in one transaction.
+	// Records that can't be matched are skipped and listed in the result.
+	Import(ctx context.Context, contractors []exchangeContractor, prices []exchangePrice) (ExchangeResult, error)
+	AddLog(ctx context.Context, entry ExchangeLogEntry) (int, error)
+	Log(ctx context.Context, q ListQuery) ([]ExchangeLogEntry, int, error)
+	// Document returns the file of a log entry, or ErrNotFound.
+	Document(ctx context.Context, id int) (ExchangeLogEntry, error)
+}
+
+type SearchStore interface {
+	Search(ctx context.Context, terms, types []string, limit int) ([]SearchHit, error)
+}
+
+// Store groups the repositories handlers depend on. Every backend provides
+// all of them.
+type Store struct {
+	Batches     BatchStore
+	Equipment   EquipmentStore
+	Orders      OrderStore
+	Shipments   ShipmentStore
+	Reference   ReferenceStore
+	Logs        LogStore
+	Search      SearchStore
+	Webhooks    WebhookStore
+	Exchange    ExchangeStore
+	Maintenance MaintenanceStore
+	Meters      MeterStore
+	Assignments AssignmentStore
+	Parts       PartStore
+	Purchases   PurchaseStore
+	Prices      PriceStore
+	Rates       RateStore
+
+	migrator *migrator
+	// afterMigrate runs backend setup that needs the migrated schema.
+	afterMigrate func(ctx context.Context) error
+	close        func() error
+}
+
+var ErrNotFound = fmt.Errorf("not found")
+
+const (
+	constraintForeignKey = "foreign_key"
+	constraintUnique     = "unique"
+)
+
+// ConstraintError is returned by stores when a write violates a foreign key
+// or unique constraint. Field is the column name when the backend reports it.
+type ConstraintError struct {
+	Kind  string
+	Field string
+	Err   error
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%s constraint violated on %q: %v", e.Kind, e.Field, e.Err)
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// StockShortage is returned by stores when an issue of a part exceeds its
+// stock at the warehouse.
+type StockShortage struct {
+	PartID      int
+	WarehouseID int
+	Available   float64
+}
+
+func (e *StockShortage) Error() string {
+	return fmt.Sprintf("part %d at warehouse %d: only %g in stock", e.PartID, e.WarehouseID, e.Available)
+}
+
+// StatusConflict is returned by stores when a record can't move from its
+// current status to the requested one.
+type StatusConflict struct {
+	From string
+	To   string
+}
+
+func (e *StatusConflict) Error() string {
+	return fmt.Sprintf("can't change status from %q to %q", e.From, e.To)
+}
+
+func (s *Store) Migrate(dryRun bool) ([]migration, error) {
+	applied, err := s.migrator.up(dryRun)
+	if err != nil || dryRun || s.afterMigrate == nil {
+		return applied, err
+	}
+	return applied, s.afterMigrate(context.Background())
+}
+
+func (s *Store) Close() error {
+	return s.close()
+}
+
+// OpenStore connects to the backend selected by driver ("sqlite" or
+// "postgres").
+func OpenStore(driver, dsn string) (*Store, error) {
+	switch driver {
+	case "sqlite", "sqlite3":
+		return openSQLiteStore(dsn)
+	case "postgres", "postgresql":
+		return openPostgresStore(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var postgresDialect = dialect{name: "postgres", numbered: true, ilike: "ILIKE", constraint: postgresConstraintError}
+
+// postgresConstraintError reads the column from the error detail, e.g.
+// `Key (ore_type_id)=(99) is not present in table "ore_types".`
+func postgresConstraintError(err error) error {
+	var e *pq.Error
+	if !errors.As(err, &e) {
+		return err
+	}
+	var kind string
+	switch e.Code {
+	case "23503":
+		kind = constraintForeignKey
+	case "23505":
+		kind = constraintUnique
+	default:
+		return err
+	}
+	field := ""
+	if start := strings.Index(e.Detail, "Key ("); start >= 0 {
+		rest := e.Detail[start+len("Key ("):]
+		if end := strings.Index(rest, ")"); end >= 0 && !strings.Contains(rest[:end], ",") {
+			field = rest[:end]
+		}
+	}
+	return &ConstraintError{Kind: kind, Field: field, Err: err}
+}
+
+func openPostgresStore(dsn string) (*Store, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	sdb := &sqlDB{db: db, dialect: postgresDialect}
+	store := newSQLStore(sdb)
+	store.Search = &postgresSearchStore{db: sdb}
+	return store, nil
+}
+
+// postgresSearchStore queries the tsvector index maintained by the triggers
+// from migrations/postgres/0003_search_index.
+type postgresSearchStore struct {
+	db *sqlDB
+}
+
+func (s *postgresSearchStore) Search(ctx context.Context, terms, types []string, limit int) ([]SearchHit, error) {
+	prefixes := make([]string, len(terms))
+	for i, t := range terms {
+		prefixes[i] = t + ":*"
+	}
+	args := []interface{}{strings.Join(prefixes, " & ")}
+	query := `
+        SELECT entity, entity_id, COALESCE(title, ''),
+               ts_headline('simple', COALESCE(body, ''), q, 'MaxWords=12, MinWords=3, StartSel="", StopSel=""'),
+               ts_rank(document, q)
+        FROM search_index, to_tsquery('simple', ?) q
+        WHERE document @@ q`
+	if len(types) > 0 {
+		query += " AND entity IN (" + placeholders(len(types)) + ")"
+		for _, t := range types {
+			args = append(args, t)
+		}
+	}
+	query += " ORDER BY ts_rank(document, q) DESC, entity_id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSearchHits(rows)
+}
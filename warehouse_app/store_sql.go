@@ -0,0 +1,2957 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dialect captures the few places where SQLite and PostgreSQL disagree. All
+// shared queries are written with ? placeholders and COALESCE.
+type dialect struct {
+	name string
+	// numbered placeholders ($1, $2, ...) instead of ?
+	numbered bool
+	// case-insensitive LIKE operator
+	ilike string
+	// constraint turns driver constraint violations into *ConstraintError
+	// and returns other errors unchanged.
+	constraint func(error) error
+}
+
+func (d dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+type queryer interface {
+	wrap(err error) error
+	exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type sqlDB struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+func (d *sqlDB) wrap(err error) error {
+	if err == nil || d.dialect.constraint == nil {
+		return err
+	}
+	return d.dialect.constraint(err)
+}
+
+func (d *sqlDB) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	res, err := d.db.ExecContext(ctx, d.dialect.rebind(query), args...)
+	return res, d.wrap(err)
+}
+
+func (d *sqlDB) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return d.db.QueryContext(ctx, d.dialect.rebind(query), args...)
+}
+
+func (d *sqlDB) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return d.db.QueryRowContext(ctx, d.dialect.rebind(query), args...)
+}
+
+func (d *sqlDB) begin(ctx context.Context) (*sqlTx, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{tx: tx, dialect: d.dialect}, nil
+}
+
+type sqlTx struct {
+	tx      *sql.Tx
+	dialect dialect
+}
+
+func (t *sqlTx) wrap(err error) error {
+	if err == nil || t.dialect.constraint == nil {
+		return err
+	}
+	return t.dialect.constraint(err)
+}
+
+func (t *sqlTx) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	res, err := t.tx.ExecContext(ctx, t.dialect.rebind(query), args...)
+	return res, t.wrap(err)
+}
+
+func (t *sqlTx) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, t.dialect.rebind(query), args...)
+}
+
+func (t *sqlTx) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRowContext(ctx, t.dialect.rebind(query), args...)
+}
+
+func (t *sqlTx) commit() error   { return t.wrap(t.tx.Commit()) }
+func (t *sqlTx) rollback() error { return t.tx.Rollback() }
+
+// insertReturningID runs an INSERT and returns the generated id. Both SQLite
+// (3.35+) and PostgreSQL support RETURNING, unlike LastInsertId.
+func insertReturningID(ctx context.Context, q queryer, query string, args ...interface{}) (int, error) {
+	var id int
+	err := q.queryRow(ctx, query+" RETURNING id", args...).Scan(&id)
+	return id, q.wrap(err)
+}
+
+// newSQLStore wires the shared SQL repositories. Callers add the
+// backend-specific SearchStore.
+func newSQLStore(sdb *sqlDB) *Store {
+	return &Store{
+		Batches:     &sqlBatchStore{db: sdb},
+		Equipment:   &sqlEquipmentStore{db: sdb},
+		Orders:      &sqlOrderStore{db: sdb},
+		Shipments:   &sqlShipmentStore{db: sdb},
+		Reference:   &sqlReferenceStore{db: sdb},
+		Logs:        &sqlLogStore{db: sdb},
+		Webhooks:    &sqlWebhookStore{db: sdb},
+		Exchange:    &sqlExchangeStore{db: sdb},
+		Maintenance: &sqlMaintenanceStore{db: sdb},
+		Meters:      &sqlMeterStore{db: sdb},
+		Assignments: &sqlAssignmentStore{db: sdb},
+		Parts:       &sqlPartStore{db: sdb},
+		Purchases:   &sqlPurchaseStore{db: sdb},
+		Prices:      &sqlPriceStore{db: sdb},
+		Rates:       &sqlRateStore{db: sdb},
+		migrator:    newMigrator(sdb),
+		close:       sdb.db.Close,
+	}
+}
+
+func listClauses(q ListQuery, columns map[string]string) (string, string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	for _, f := range q.Filters {
+		column, ok := columns[f.Field]
+		if !ok || len(f.Values) == 0 {
+			continue
+		}
+		switch {
+		case f.Op == opIn && len(f.Values) == 1:
+			conds = append(conds, column+" = ?")
+		case f.Op == opIn:
+			conds = append(conds, column+" IN ("+placeholders(len(f.Values))+")")
+		default:
+			conds = append(conds, column+" "+string(f.Op)+" ?")
+		}
+		args = append(args, f.Values...)
+	}
+
+	var order, sortColumns []string
+	for _, key := range q.Sort {
+		column, ok := columns[key.Field]
+		if !ok {
+			continue
+		}
+		column = sortColumn(column, key.Kind)
+		sortColumns = append(sortColumns, column)
+		if key.Desc {
+			order = append(order, column+" DESC")
+		} else {
+			order = append(order, column+" ASC")
+		}
+	}
+	if len(q.After) > 0 && len(sortColumns) == len(q.Sort) {
+		cond, keyArgs := keysetCondition(q.Sort, sortColumns, q.After)
+		conds = append(conds, cond)
+		args = append(args, keyArgs...)
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+	orderBy := ""
+	if len(order) > 0 {
+		orderBy = " ORDER BY " + strings.Join(order, ", ")
+	}
+	return where, orderBy, args
+}
+
+// sortColumn sorts missing values as the zero value of the field, which is
+// how they are shown and what a cursor holds for them.
+func sortColumn(column string, kind fieldKind) string {
+	switch kind {
+	case textField, dateField:
+		return "COALESCE(" + column + ", '')"
+	case intField, floatField:
+		return "COALESCE(" + column + ", 0)"
+	}
+	return column
+}
+
+// keysetCondition selects the rows that sort after the values in after:
+// (a > ?) OR (a = ? AND b > ?) OR ..., with < for descending keys.
+func keysetCondition(sort []SortKey, columns []string, after []interface{}) (string, []interface{}) {
+	var terms []string
+	var args []interface{}
+	for i, key := range sort {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, columns[j]+" = ?")
+			args = append(args, after[j])
+		}
+		op := " > ?"
+		if key.Desc {
+			op = " < ?"
+		}
+		parts = append(parts, columns[i]+op)
+		args = append(args, after[i])
+		terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(terms, " OR ") + ")", args
+}
+
+// pagedList counts rows matching q and returns the query and arguments for
+// the requested page. selectList is prepended to from.
+func pagedList(ctx context.Context, db queryer, q ListQuery, columns map[string]string, selectList, from string) (string, []interface{}, int, error) {
+	where, orderBy, args := listClauses(q, columns)
+	var total int
+	if err := db.queryRow(ctx, "SELECT COUNT(*) "+from+where, args...).Scan(&total); err != nil {
+		return "", nil, 0, err
+	}
+	query := selectList + from + where + orderBy + " LIMIT ? OFFSET ?"
+	return query, append(args, q.Limit, q.Offset), total, nil
+}
+
+func nullableInt(value int) interface{} {
+	if value == 0 {
+		return nil
+	}
+	return value
+}
+
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	result := "?"
+	for i := 1; i < n; i++ {
+		result += ",?"
+	}
+	return result
+}
+
+func timestamp() string {
+	return time.Now().Format(time.RFC3339)
+}
+
+type sqlBatchStore struct {
+	db *sqlDB
+}
+
+var oreBatchColumns = map[string]string{
+	"id":              "ob.id",
+	"batch_code":      "ob.batch_code",
+	"ore_type_id":     "ob.ore_type_id",
+	"warehouse_id":    "ob.warehouse_id",
+	"unit_id":         "ob.unit_id",
+	"quantity":        "ob.quantity",
+	"quality":         "ob.quality",
+	"priority":        "ob.priority",
+	"status":          "ob.status",
+	"extraction_date": "ob.extraction_date",
+	"created_at":      "ob.created_at",
+}
+
+func (s *sqlBatchStore) List(ctx context.Context, q ListQuery) ([]OreBatch, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, oreBatchColumns, `
+            SELECT ob.id, COALESCE(ob.batch_code, ''), ob.ore_type_id, ot.name, ob.warehouse_id, w.name,
+                   ob.unit_id, u.name, u.symbol, ob.quantity, COALESCE(ob.quality, 0), COALESCE(ob.priority, ''),
+                   COALESCE(ob.extraction_date, ''), COALESCE(ob.status, ''), COALESCE(ob.created_at, '')`, `
+            FROM ore_batches ob
+            JOIN ore_types ot ON ob.ore_type_id = ot.id
+            JOIN warehouses w ON ob.warehouse_id = w.id
+            JOIN units u ON ob.unit_id = u.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	batches := []OreBatch{}
+	for rows.Next() {
+		var ob OreBatch
+		if err := rows.Scan(&ob.ID, &ob.BatchCode, &ob.OreTypeID, &ob.OreTypeName, &ob.WarehouseID, &ob.WarehouseName,
+			&ob.UnitID, &ob.UnitName, &ob.UnitSymbol, &ob.Quantity, &ob.Quality, &ob.Priority,
+			&ob.ExtractionDate, &ob.Status, &ob.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		batches = append(batches, ob)
+	}
+	return batches, total, rows.Err()
+}
+
+func (s *sqlBatchStore) Create(ctx context.Context, b NewOreBatch) (int, error) {
+	return insertOreBatch(ctx, s.db, b)
+}
+
+func (s *sqlBatchStore) CreateMany(ctx context.Context, batches []NewOreBatch) ([]int, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.rollback()
+
+	ids := make([]int, 0, len(batches))
+	for _, b := range batches {
+		id, err := insertOreBatch(ctx, tx, b)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, tx.commit()
+}
+
+func insertOreBatch(ctx context.Context, q queryer, b NewOreBatch) (int, error) {
+	ts := timestamp()
+	return insertReturningID(ctx, q, `
+            INSERT INTO ore_batches (ore_type_id, warehouse_id, unit_id, batch_code, quantity, quality, priority, extraction_date, status, created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		b.OreTypeID, b.WarehouseID, b.UnitID, b.BatchCode, b.Quantity, b.Quality, b.Priority, b.ExtractionDate, b.Status, ts, ts)
+}
+
+func (s *sqlBatchStore) WarehouseStock(ctx context.Context, warehouseID int) (float64, error) {
+	var stock float64
+	err := s.db.queryRow(ctx, "SELECT COALESCE(SUM(quantity), 0) FROM ore_batches WHERE warehouse_id = ?", warehouseID).Scan(&stock)
+	return stock, err
+}
+
+type sqlEquipmentStore struct {
+	db *sqlDB
+}
+
+// purchase_cost is text in SQLite and is cast to sort and filter by value.
+var equipmentColumns = map[string]string{
+	"id":                  "e.id",
+	"name":                "e.name",
+	"category_id":         "e.category_id",
+	"warehouse_id":        "e.warehouse_id",
+	"unit_id":             "e.unit_id",
+	"quantity":            "e.quantity",
+	"serial_number":       "e.serial_number",
+	"inventory_number":    "e.inventory_number",
+	"tracking":            "e.tracking",
+	"service_life_months": "e.service_life_months",
+	"status":              "e.status",
+	"purchase_date":       "e.purchase_date",
+	"purchase_cost":       "CAST(e.purchase_cost AS NUMERIC)",
+	"written_off_date":    "e.written_off_date",
+	"created_at":          "e.created_at",
+}
+
+func (s *sqlEquipmentStore) List(ctx context.Context, q ListQuery) ([]Equipment, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, equipmentColumns, `
+            SELECT e.id, e.name, e.category_id, c.name, e.warehouse_id, w.name, e.unit_id, u.name, u.symbol,
+                   e.quantity, COALESCE(e.serial_number, ''), COALESCE(e.inventory_number, ''), e.tracking,
+                   COALESCE(e.service_life_months, 0), COALESCE(e.status, ''),
+                   COALESCE(e.purchase_date, ''), e.purchase_cost, COALESCE(e.salvage_value, 0), COALESCE(e.depreciation_method, ''),
+                   COALESCE(e.written_off_date, ''), e.residual_value, COALESCE(e.write_off_reason, ''), COALESCE(e.created_at, '')`, `
+            FROM equipment e
+            JOIN equipment_categories c ON e.category_id = c.id
+            JOIN warehouses w ON e.warehouse_id = w.id
+            JOIN units u ON e.unit_id = u.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	items := []Equipment{}
+	for rows.Next() {
+		var eq Equipment
+		if err := rows.Scan(&eq.ID, &eq.Name, &eq.CategoryID, &eq.CategoryName, &eq.WarehouseID, &eq.WarehouseName,
+			&eq.UnitID, &eq.UnitName, &eq.UnitSymbol, &eq.Quantity, &eq.SerialNumber, &eq.InventoryNumber, &eq.Tracking, &eq.ServiceLife,
+			&eq.Status, &eq.PurchaseDate, &eq.PurchaseCost, &eq.SalvageValue, &eq.DepreciationMethod,
+			&eq.WrittenOffDate, &eq.ResidualValue, &eq.WriteOffReason, &eq.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		eq.setLifecycle(time.Now())
+		items = append(items, eq)
+	}
+	return items, total, rows.Err()
+}
+
+func (s *sqlEquipmentStore) Create(ctx context.Context, e NewEquipment) (int, error) {
+	return insertEquipment(ctx, s.db, e)
+}
+
+func (s *sqlEquipmentStore) CreateMany(ctx context.Context, items []NewEquipment) ([]int, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.rollback()
+
+	ids := make([]int, 0, len(items))
+	for _, e := range items {
+		id, err := insertEquipment(ctx, tx, e)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, tx.commit()
+}
+
+func insertEquipment(ctx context.Context, q queryer, e NewEquipment) (int, error) {
+	ts := timestamp()
+	return insertReturningID(ctx, q, `
+            INSERT INTO equipment (name, category_id, warehouse_id, unit_id, quantity, serial_number, inventory_number, tracking,
+                                   service_life_months, status, purchase_date, purchase_cost, salvage_value, depreciation_method, created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Name, e.CategoryID, e.WarehouseID, e.UnitID, e.Quantity, e.SerialNumber, e.InventoryNumber, e.Tracking,
+		e.ServiceLife, e.Status, e.PurchaseDate, e.PurchaseCost, e.SalvageValue, e.DepreciationMethod, ts, ts)
+}
+
+func (s *sqlEquipmentStore) Split(ctx context.Context, id int, units []EquipmentUnit) ([]int, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.rollback()
+
+	var cost, salvage *Decimal
+	err = tx.queryRow(ctx, "SELECT purchase_cost, salvage_value FROM equipment WHERE id = ? AND tracking = ?", id, trackingBulk).Scan(&cost, &salvage)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Costs are shared in cents so that the units add up to the row.
+	share := func(d *Decimal) []*Decimal {
+		parts := make([]*Decimal, len(units))
+		if d != nil {
+			for i, part := range d.Share(len(units)) {
+				parts[i] = &part
+			}
+		}
+		return parts
+	}
+	costs, salvages := share(cost), share(salvage)
+
+	ts := timestamp()
+	if _, err := tx.exec(ctx, `
+            UPDATE equipment SET quantity = 1, tracking = ?, serial_number = ?, inventory_number = ?,
+                   purchase_cost = ?, salvage_value = ?, updated_at = ?
+            WHERE id = ?`,
+		trackingSerial, units[0].SerialNumber, units[0].InventoryNumber, costs[0], salvages[0], ts, id); err != nil {
+		return nil, err
+	}
+	ids := []int{id}
+	for i, u := range units[1:] {
+		unitID, err := insertReturningID(ctx, tx, `
+                INSERT INTO equipment (name, category_id, warehouse_id, unit_id, quantity, serial_number, inventory_number, tracking,
+                                       service_life_months, status, purchase_date, purchase_cost, salvage_value, depreciation_method, created_at, updated_at)
+                SELECT name, category_id, warehouse_id, unit_id, 1, ?, ?, tracking,
+                       service_life_months, status, purchase_date, ?, ?, depreciation_method, ?, ?
+                FROM equipment WHERE id = ?`,
+			u.SerialNumber, u.InventoryNumber, costs[i+1], salvages[i+1], ts, ts, id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, unitID)
+	}
+	return ids, tx.commit()
+}
+
+func (s *sqlEquipmentStore) WriteOff(ctx context.Context, id int, w EquipmentWriteOff) error {
+	res, err := s.db.exec(ctx, `
+            UPDATE equipment SET status = ?, written_off_date = ?, residual_value = ?, write_off_reason = ?, updated_at = ?
+            WHERE id = ?`, equipmentWrittenOff, w.Date, w.ResidualValue, w.Reason, timestamp(), id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type sqlOrderStore struct {
+	db *sqlDB
+}
+
+var orderColumns = map[string]string{
+	"id":             "o.id",
+	"order_number":   "o.order_number",
+	"contractor_id":  "o.contractor_id",
+	"warehouse_id":   "o.warehouse_id",
+	"status":         "o.status",
+	"order_date":     "o.order_date",
+	"total_quantity": "o.total_quantity",
+	"currency":       "o.currency",
+	"created_at":     "o.created_at",
+	"updated_at":     "o.updated_at",
+}
+
+func (s *sqlOrderStore) List(ctx context.Context, q ListQuery) ([]SalesOrder, int, error) {
+	return listOrders(ctx, s.db, q)
+}
+
+func listOrders(ctx context.Context, db queryer, q ListQuery) ([]SalesOrder, int, error) {
+	query, args, total, err := pagedList(ctx, db, q, orderColumns, `
+            SELECT o.id, o.order_number, o.contractor_id, c.name, o.warehouse_id, w.name, COALESCE(o.status, ''), COALESCE(o.order_date, ''), COALESCE(o.total_quantity, 0),
+                   COALESCE(o.currency, ''), COALESCE(o.amount, 0), o.vat_rate, COALESCE(o.vat_amount, 0), COALESCE(o.total_amount, 0),
+                   o.rate, COALESCE(o.rate_date, ''), COALESCE(o.reporting_currency, ''), COALESCE(o.created_at, '')`, `
+            FROM sales_orders o
+            JOIN contractors c ON o.contractor_id = c.id
+            JOIN warehouses w ON o.warehouse_id = w.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	ordersRows, err := db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer ordersRows.Close()
+
+	orders := []SalesOrder{}
+	index := make(map[int]int)
+	var orderIDs []interface{}
+	for ordersRows.Next() {
+		o := SalesOrder{Items: []SalesOrderItem{}}
+		if err := ordersRows.Scan(&o.ID, &o.OrderNumber, &o.ContractorID, &o.ContractorName, &o.WarehouseID, &o.WarehouseName, &o.Status, &o.OrderDate, &o.TotalQuantity,
+			&o.Currency, &o.Amount, &o.VATRate, &o.VATAmount, &o.TotalAmount, &o.Rate, &o.RateDate, &o.ReportingCurrency, &o.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if o.Rate != nil {
+			amount, err := o.TotalAmount.Mul(*o.Rate)
+			if err != nil {
+				return nil, 0, err
+			}
+			amount = amount.Round(2)
+			o.ReportingAmount = &amount
+		}
+		index[o.ID] = len(orders)
+		orders = append(orders, o)
+		orderIDs = append(orderIDs, o.ID)
+	}
+	if err := ordersRows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(orderIDs) == 0 {
+		return orders, total, nil
+	}
+
+	rows, err := db.query(ctx, `
+                SELECT i.id, i.order_id, i.ore_batch_id, COALESCE(ob.batch_code, ''), i.unit_id, u.name, u.symbol, i.quantity, COALESCE(i.price_per_unit, 0),
+                       i.list_price, COALESCE(i.price_list_id, 0), COALESCE(i.grade, 0), COALESCE(i.price_source, ''), COALESCE(i.price_reason, ''), COALESCE(i.amount, 0)
+                FROM sales_order_items i
+                LEFT JOIN ore_batches ob ON i.ore_batch_id = ob.id
+                LEFT JOIN units u ON i.unit_id = u.id
+                WHERE i.order_id IN (`+placeholders(len(orderIDs))+`)
+                ORDER BY i.id`, orderIDs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item SalesOrderItem
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.OreBatchID, &item.OreBatchName, &item.UnitID, &item.UnitName, &item.UnitSymbol, &item.Quantity, &item.PricePerUnit,
+			&item.ListPrice, &item.PriceListID, &item.Grade, &item.PriceSource, &item.PriceReason, &item.Amount); err != nil {
+			return nil, 0, err
+		}
+		if i, ok := index[item.OrderID]; ok {
+			orders[i].Items = append(orders[i].Items, item)
+		}
+	}
+	return orders, total, rows.Err()
+}
+
+func (s *sqlOrderStore) Create(ctx context.Context, o NewOrder) (int, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.rollback()
+
+	ts := timestamp()
+	total, amount := 0.0, Decimal(0)
+	amounts := make([]Decimal, len(o.Items))
+	for i, item := range o.Items {
+		total += item.Quantity
+		itemAmount, err := item.PricePerUnit.MulFloat(item.Quantity)
+		if err != nil {
+			return 0, err
+		}
+		amounts[i] = itemAmount.Round(2)
+		if amount, err = amount.Add(amounts[i]); err != nil {
+			return 0, err
+		}
+	}
+	vat, err := amount.MulFloat(o.VATRate / 100)
+	if err != nil {
+		return 0, err
+	}
+	vat = vat.Round(2)
+	totalAmount, err := amount.Add(vat)
+	if err != nil {
+		return 0, err
+	}
+	orderID, err := insertReturningID(ctx, tx, `
+            INSERT INTO sales_orders (order_number, contractor_id, warehouse_id, status, order_date, total_quantity, currency,
+                                      amount, vat_rate, vat_amount, total_amount, created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		o.OrderNumber, o.ContractorID, o.WarehouseID, o.Status, o.OrderDate, total, o.Currency,
+		amount, o.VATRate, vat, totalAmount, ts, ts)
+	if err != nil {
+		return 0, err
+	}
+	for i, item := range o.Items {
+		if _, err := tx.exec(ctx, `
+                INSERT INTO sales_order_items (order_id, ore_batch_id, unit_id, quantity, price_per_unit, list_price, price_list_id,
+                                               grade, price_source, price_reason, amount, created_at, updated_at)
+                VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			orderID, item.OreBatchID, item.UnitID, item.Quantity, *item.PricePerUnit, item.ListPrice, nullableInt(item.PriceListID),
+			item.Grade, item.PriceSource, item.PriceReason, amounts[i], ts, ts); err != nil {
+			return 0, err
+		}
+	}
+	if o.Status == orderConfirmed {
+		if err := freezeRate(ctx, tx, orderID, o.Rate); err != nil {
+			return 0, err
+		}
+		if err := enqueueOrderWebhook(ctx, tx, orderID); err != nil {
+			return 0, err
+		}
+	}
+	return orderID, tx.commit()
+}
+
+func (s *sqlOrderStore) UpdateStatus(ctx context.Context, id int, u OrderStatusUpdate) error {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
+	var previous string
+	err = tx.queryRow(ctx, "SELECT COALESCE(status, '') FROM sales_orders WHERE id = ?", id).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.exec(ctx, "UPDATE sales_orders SET status = ?, updated_at = ? WHERE id = ?", u.Status, timestamp(), id); err != nil {
+		return err
+	}
+	if u.Status == orderConfirmed && previous != orderConfirmed {
+		if err := freezeRate(ctx, tx, id, u.Rate); err != nil {
+			return err
+		}
+		if err := enqueueOrderWebhook(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+	return tx.commit()
+}
+
+// freezeRate keeps the rate an order was confirmed at; confirming it again
+// after a step back leaves the first rate.
+func freezeRate(ctx context.Context, tx *sqlTx, id int, r *frozenRate) error {
+	if r == nil {
+		return nil
+	}
+	_, err := tx.exec(ctx, `
+            UPDATE sales_orders SET rate = ?, rate_date = ?, reporting_currency = ?
+            WHERE id = ? AND rate IS NULL`, r.Rate, r.Date, r.Currency, id)
+	return err
+}
+
+func enqueueOrderWebhook(ctx context.Context, tx *sqlTx, id int) error {
+	orders, _, err := listOrders(ctx, tx, byID(id))
+	if err != nil || len(orders) == 0 {
+		return err
+	}
+	return enqueueWebhook(ctx, tx, webhookOrderConfirmed, orders[0])
+}
+
+type sqlShipmentStore struct {
+	db *sqlDB
+}
+
+var shipmentColumns = map[string]string{
+	"id":           "s.id",
+	"order_id":     "s.order_id",
+	"warehouse_id": "o.warehouse_id",
+	"transport_id": "s.transport_id",
+	"status":       "s.status",
+	"planned_date": "s.planned_date",
+	"actual_date":  "s.actual_date",
+	"created_at":   "s.created_at",
+	"updated_at":   "s.updated_at",
+}
+
+func (s *sqlShipmentStore) List(ctx context.Context, q ListQuery) ([]Shipment, int, error) {
+	return listShipments(ctx, s.db, q)
+}
+
+func listShipments(ctx context.Context, db queryer, q ListQuery) ([]Shipment, int, error) {
+	query, args, total, err := pagedList(ctx, db, q, shipmentColumns, `
+            SELECT s.id, s.order_id, o.order_number, o.warehouse_id, COALESCE(s.transport_id, 0), COALESCE(t.name, ''),
+                   COALESCE(s.planned_date, ''), COALESCE(s.actual_date, ''), COALESCE(s.status, ''), COALESCE(s.created_at, '')`, `
+            FROM shipments s
+            JOIN sales_orders o ON s.order_id = o.id
+            LEFT JOIN transport t ON s.transport_id = t.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	shipments := []Shipment{}
+	for rows.Next() {
+		var sh Shipment
+		if err := rows.Scan(&sh.ID, &sh.OrderID, &sh.OrderNumber, &sh.WarehouseID, &sh.TransportID, &sh.TransportName, &sh.PlannedDate, &sh.ActualDate, &sh.Status, &sh.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		shipments = append(shipments, sh)
+	}
+	return shipments, total, rows.Err()
+}
+
+func (s *sqlShipmentStore) Create(ctx context.Context, sh NewShipment) (int, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.rollback()
+
+	ts := timestamp()
+	id, err := insertReturningID(ctx, tx, `
+            INSERT INTO shipments (order_id, transport_id, planned_date, actual_date, status, created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		sh.OrderID, nullableInt(sh.TransportID), sh.PlannedDate, sh.ActualDate, sh.Status, ts, ts)
+	if err != nil {
+		return 0, err
+	}
+	if sh.Status == shipmentCompleted {
+		if err := enqueueShipmentWebhook(ctx, tx, id); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.commit()
+}
+
+func (s *sqlShipmentStore) UpdateStatus(ctx context.Context, id int, status string) error {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
+	var previous string
+	err = tx.queryRow(ctx, "SELECT COALESCE(status, '') FROM shipments WHERE id = ?", id).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.exec(ctx, "UPDATE shipments SET status = ?, updated_at = ? WHERE id = ?", status, timestamp(), id); err != nil {
+		return err
+	}
+	if status == shipmentCompleted && previous != shipmentCompleted {
+		if err := enqueueShipmentWebhook(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+	return tx.commit()
+}
+
+func enqueueShipmentWebhook(ctx context.Context, tx *sqlTx, id int) error {
+	shipments, _, err := listShipments(ctx, tx, byID(id))
+	if err != nil || len(shipments) == 0 {
+		return err
+	}
+	return enqueueWebhook(ctx, tx, webhookShipmentCompleted, shipments[0])
+}
+
+type sqlReferenceStore struct {
+	db *sqlDB
+}
+
+func (s *sqlReferenceStore) Units(ctx context.Context) ([]Unit, error) {
+	rows, err := s.db.query(ctx, "SELECT id, name, symbol FROM units ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var units []Unit
+	for rows.Next() {
+		var u Unit
+		if err := rows.Scan(&u.ID, &u.Name, &u.Symbol); err != nil {
+			return nil, err
+		}
+		units = append(units, u)
+	}
+	return units, rows.Err()
+}
+
+func (s *sqlReferenceStore) Warehouses(ctx context.Context) ([]Warehouse, error) {
+	rows, err := s.db.query(ctx, "SELECT id, name, COALESCE(location, ''), COALESCE(capacity, 0), COALESCE(supervisor, '') FROM warehouses ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var warehouses []Warehouse
+	for rows.Next() {
+		var w Warehouse
+		if err := rows.Scan(&w.ID, &w.Name, &w.Location, &w.Capacity, &w.Supervisor); err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, w)
+	}
+	return warehouses, rows.Err()
+}
+
+func (s *sqlReferenceStore) OreTypes(ctx context.Context) ([]OreType, error) {
+	rows, err := s.db.query(ctx, "SELECT id, name, COALESCE(category, ''), COALESCE(description, ''), price FROM ore_types ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ores []OreType
+	for rows.Next() {
+		var o OreType
+		if err := rows.Scan(&o.ID, &o.Name, &o.Category, &o.Description, &o.Price); err != nil {
+			return nil, err
+		}
+		ores = append(ores, o)
+	}
+	return ores, rows.Err()
+}
+
+func (s *sqlReferenceStore) EquipmentCategories(ctx context.Context) ([]EquipmentCategory, error) {
+	rows, err := s.db.query(ctx, "SELECT id, name FROM equipment_categories ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var categories []EquipmentCategory
+	for rows.Next() {
+		var c EquipmentCategory
+		if err := rows.Scan(&c.ID, &c.Name); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+func (s *sqlReferenceStore) Contractors(ctx context.Context) ([]Contractor, error) {
+	rows, err := s.db.query(ctx, "SELECT id, name, COALESCE(type, ''), COALESCE(contact_person, ''), COALESCE(phone, ''), COALESCE(email, '') FROM contractors ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var contractors []Contractor
+	for rows.Next() {
+		var c Contractor
+		if err := rows.Scan(&c.ID, &c.Name, &c.Type, &c.ContactPerson, &c.Phone, &c.Email); err != nil {
+			return nil, err
+		}
+		contractors = append(contractors, c)
+	}
+	return contractors, rows.Err()
+}
+
+func (s *sqlReferenceStore) Transport(ctx context.Context) ([]Transport, error) {
+	rows, err := s.db.query(ctx, `
+        SELECT t.id, t.name, COALESCE(t.type, ''), COALESCE(t.vehicle_number, ''), COALESCE(t.capacity, 0), COALESCE(t.unit_id, 0), COALESCE(u.name, ''), COALESCE(u.symbol, '')
+        FROM transport t
+        LEFT JOIN units u ON t.unit_id = u.id
+        ORDER BY t.name
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var transport []Transport
+	for rows.Next() {
+		var t Transport
+		if err := rows.Scan(&t.ID, &t.Name, &t.Type, &t.VehicleNumber, &t.Capacity, &t.UnitID, &t.UnitName, &t.UnitSymbol); err != nil {
+			return nil, err
+		}
+		transport = append(transport, t)
+	}
+	return transport, rows.Err()
+}
+
+func (s *sqlReferenceStore) Exists(ctx context.Context, table string, id int) (bool, error) {
+	if !referenceTables[table] {
+		return false, fmt.Errorf("unknown reference table %q", table)
+	}
+	var exists bool
+	err := s.db.queryRow(ctx, "SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id = ?)", id).Scan(&exists)
+	return exists, err
+}
+
+func (s *sqlReferenceStore) Seed(ctx context.Context) error {
+	ts := timestamp()
+	if err := s.seedUnits(ctx, ts); err != nil {
+		return err
+	}
+	if err := s.seedWarehouses(ctx, ts); err != nil {
+		return err
+	}
+	if err := s.seedOreTypes(ctx, ts); err != nil {
+		return err
+	}
+	if err := s.seedEquipmentCategories(ctx, ts); err != nil {
+		return err
+	}
+	if err := s.seedContractors(ctx, ts); err != nil {
+		return err
+	}
+	if err := s.seedTransport(ctx, ts); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *sqlReferenceStore) seedUnits(ctx context.Context, now string) error {
+	count, err := s.countRecords(ctx, "units")
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	units := []struct{ name, symbol string }{
+		{"Тонны", "т"},
+		{"Килограммы", "кг"},
+		{"Штуки", "шт"},
+		{"Метры", "м"},
+	}
+	for _, u := range units {
+		if _, err := s.db.exec(ctx, "INSERT INTO units (name, symbol, created_at, updated_at) VALUES (?, ?, ?, ?)", u.name, u.symbol, now, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlReferenceStore) seedWarehouses(ctx context.Context, now string) error {
+	count, err := s.countRecords(ctx, "warehouses")
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	warehouses := []struct {
+		name, location, supervisor string
+		capacity                   float64
+	}{
+		{"Склад №1", "Карьер Северный", "Иван Петров", 1200},
+		{"Склад №2", "Карьер Южный", "Анна Смирнова", 800},
+	}
+	for _, w := range warehouses {
+		if _, err := s.db.exec(ctx, "INSERT INTO warehouses (name, location, supervisor, capacity, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)", w.name, w.location, w.supervisor, w.capacity, now, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlReferenceStore) seedOreTypes(ctx context.Context, now string) error {
+	count, err := s.countRecords(ctx, "ore_types")
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	ores := []struct {
+		name, category, description string
+	}{
+		{"Железная руда 65%", "Железные руды", "Высокое содержание железа"},
+		{"Медная руда", "Цветные руды", "Содержание меди до 25%"},
+		{"Золотосодержащая руда", "Драгоценные", "Руда с повышенным содержанием золота"},
+	}
+	for _, o := range ores {
+		if _, err := s.db.exec(ctx, "INSERT INTO ore_types (name, category, description, created_at, updated_at) VALUES (?, ?, ?, ?, ?)", o.name, o.category, o.description, now, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlReferenceStore) seedEquipmentCategories(ctx context.Context, now string) error {
+	count, err := s.countRecords(ctx, "equipment_categories")
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	categories := []string{"Погрузочная техника", "Буровое оборудование", "Транспортировка", "Контроль качества"}
+	for _, c := range categories {
+		if _, err := s.db.exec(ctx, "INSERT INTO equipment_categories (name, created_at, updated_at) VALUES (?, ?, ?)", c, now, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlReferenceStore) seedContractors(ctx context.Context, now string) error {
+	count, err := s.countRecords(ctx, "contractors")
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	contractors := []struct {
+		name, ctype, contact, phone, email string
+	}{
+		{"ООО МеталлИнвест", "Покупатель", "Дмитрий Кузнецов", "+7 (921) 123-45-67", "sales@metallinvest.ru"},
+		{"ЗАО РудаТрейд", "Покупатель", "Алексей Морозов", "+7 (812) 555-12-34", "info@rudatrade.ru"},
+		{"АО Горные Машины", "Поставщик", "Екатерина Соколова", "+7 (495) 777-77-77", "supply@gormash.ru"},
+	}
+	for _, c := range contractors {
+		if _, err := s.db.exec(ctx, "INSERT INTO contractors (name, type, contact_person, phone, email, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)", c.name, c.ctype, c.contact, c.phone, c.email, now, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlReferenceStore) seedTransport(ctx context.Context, now string) error {
+	count, err := s.countRecords(ctx, "transport")
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	transports := []struct {
+		name, ttype, number string
+		capacity            float64
+		unitID              int
+	}{
+		{"БелАЗ 7513", "Самосвал", "A123BC", 90, 1},
+		{"MAN TGX", "Тягач", "B456DE", 40, 1},
+		{"ЖД состав №12", "Железная дорога", "TR-12", 200, 1},
+	}
+	for _, t := range transports {
+		if _, err := s.db.exec(ctx, "INSERT INTO transport (name, type, vehicle_number, capacity, unit_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)", t.name, t.ttype, t.number, t.capacity, t.unitID, now, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlReferenceStore) countRecords(ctx context.Context, table string) (int, error) {
+	return countRecords(ctx, s.db, table)
+}
+
+func countRecords(ctx context.Context, q queryer, table string) (int, error) {
+	row := q.queryRow(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", table))
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+type sqlLogStore struct {
+	db *sqlDB
+}
+
+const logColumns = `SELECT id, COALESCE(event_time, ''), COALESCE("user", ''), COALESCE(action, ''), COALESCE(entity, ''), COALESCE(details, '') FROM logs`
+
+func (s *sqlLogStore) where(f logFilter, withCursor bool) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	in := func(column string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		conds = append(conds, column+" IN ("+placeholders(len(values))+")")
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+	if f.ID != 0 {
+		conds = append(conds, "id = ?")
+		args = append(args, f.ID)
+	}
+	in(`"user"`, f.Users)
+	in("action", f.Actions)
+	in("entity", f.Entities)
+	if f.From != "" {
+		conds = append(conds, "event_time >= ?")
+		args = append(args, f.From)
+	}
+	if f.To != "" {
+		conds = append(conds, "event_time < ?")
+		args = append(args, f.To)
+	}
+	if f.Query != "" {
+		like := "%" + f.Query + "%"
+		op := s.db.dialect.ilike
+		conds = append(conds, fmt.Sprintf(`("user" %[1]s ? OR action %[1]s ? OR entity %[1]s ? OR details %[1]s ?)`, op))
+		args = append(args, like, like, like, like)
+	}
+	if withCursor && f.Cursor != nil {
+		op := ">"
+		if f.Desc {
+			op = "<"
+		}
+		conds = append(conds, fmt.Sprintf("(event_time %s ? OR (event_time = ? AND id %s ?))", op, op))
+		args = append(args, f.Cursor.EventTime, f.Cursor.EventTime, f.Cursor.ID)
+	}
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+func logOrderBy(f logFilter) string {
+	if f.Desc {
+		return " ORDER BY event_time DESC, id DESC"
+	}
+	return " ORDER BY event_time ASC, id ASC"
+}
+
+func scanLogEntry(rows *sql.Rows) (LogEntry, error) {
+	var entry LogEntry
+	err := rows.Scan(&entry.ID, &entry.EventTime, &entry.User, &entry.Action, &entry.Entity, &entry.Details)
+	return entry, err
+}
+
+func (s *sqlLogStore) List(ctx context.Context, f logFilter) ([]LogEntry, int, error) {
+	where, args := s.where(f, false)
+	var total int
+	if err := s.db.queryRow(ctx, "SELECT COUNT(*) FROM logs"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	where, args = s.where(f, true)
+	rows, err := s.db.query(ctx, logColumns+where+logOrderBy(f)+" LIMIT ?", append(args, f.Limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	entries := []LogEntry{}
+	for rows.Next() {
+		entry, err := scanLogEntry(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
+
+func (s *sqlLogStore) Each(ctx context.Context, f logFilter, fn func(LogEntry) error) error {
+	where, args := s.where(f, false)
+	rows, err := s.db.query(ctx, logColumns+where+logOrderBy(f), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		entry, err := scanLogEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *sqlLogStore) Add(ctx context.Context, entry LogEntry) error {
+	if entry.EventTime == "" {
+		entry.EventTime = logTime(time.Now())
+	} else if t, err := time.Parse(time.RFC3339, entry.EventTime); err == nil {
+		entry.EventTime = logTime(t)
+	}
+	_, err := s.db.exec(ctx, `INSERT INTO logs (event_time, "user", action, entity, details) VALUES (?, ?, ?, ?, ?)`,
+		entry.EventTime, entry.User, entry.Action, entry.Entity, entry.Details)
+	return err
+}
+
+func (s *sqlLogStore) Archive(ctx context.Context, before string, write func(LogEntry) error, done func() error) (int, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.rollback()
+
+	rows, err := tx.query(ctx, logColumns+" WHERE event_time < ? ORDER BY event_time, id", before)
+	if err != nil {
+		return 0, err
+	}
+	n, lastID := 0, 0
+	for rows.Next() {
+		entry, err := scanLogEntry(rows)
+		if err == nil {
+			err = write(entry)
+		}
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		n, lastID = n+1, max(lastID, entry.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	if err := done(); err != nil {
+		return 0, err
+	}
+	// Entries written after the scan stay for the next run.
+	if _, err := tx.exec(ctx, "DELETE FROM logs WHERE event_time < ? AND id <= ?", before, lastID); err != nil {
+		return 0, err
+	}
+	return n, tx.commit()
+}
+
+// enqueueWebhook writes event to the outbox with one pending delivery per
+// subscribed webhook. It runs in the caller's transaction, so the event is
+// stored exactly when the change that raised it is.
+func enqueueWebhook(ctx context.Context, tx *sqlTx, event string, data interface{}) error {
+	rows, err := tx.query(ctx, "SELECT id FROM webhooks WHERE (',' || events || ',') LIKE ?", "%,"+event+",%")
+	if err != nil {
+		return err
+	}
+	var webhookIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		webhookIDs = append(webhookIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(webhookIDs) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	ts := timestamp()
+	eventID, err := insertReturningID(ctx, tx, "INSERT INTO webhook_outbox (event_type, payload, created_at) VALUES (?, ?, ?)", event, string(payload), ts)
+	if err != nil {
+		return err
+	}
+	for _, webhookID := range webhookIDs {
+		if _, err := tx.exec(ctx, `
+                INSERT INTO webhook_deliveries (event_id, webhook_id, status, attempts, next_attempt_at, created_at, updated_at)
+                VALUES (?, ?, ?, 0, ?, ?, ?)`,
+			eventID, webhookID, deliveryPending, webhookTime(time.Now()), ts, ts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type sqlWebhookStore struct {
+	db *sqlDB
+}
+
+const webhookColumns = "SELECT id, url, events, created_at FROM webhooks"
+
+func scanWebhook(scan func(...interface{}) error) (Webhook, error) {
+	var w Webhook
+	var events string
+	if err := scan(&w.ID, &w.URL, &events, &w.CreatedAt); err != nil {
+		return w, err
+	}
+	w.Events = strings.Split(events, ",")
+	return w, nil
+}
+
+func (s *sqlWebhookStore) List(ctx context.Context) ([]Webhook, error) {
+	rows, err := s.db.query(ctx, webhookColumns+" ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	webhooks := []Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+func (s *sqlWebhookStore) Get(ctx context.Context, id int) (Webhook, error) {
+	w, err := scanWebhook(s.db.queryRow(ctx, webhookColumns+" WHERE id = ?", id).Scan)
+	if err == sql.ErrNoRows {
+		return w, ErrNotFound
+	}
+	return w, err
+}
+
+func (s *sqlWebhookStore) Create(ctx context.Context, w NewWebhook) (int, error) {
+	ts := timestamp()
+	return insertReturningID(ctx, s.db, "INSERT INTO webhooks (url, secret, events, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+		w.URL, w.Secret, strings.Join(w.Events, ","), ts, ts)
+}
+
+func (s *sqlWebhookStore) Delete(ctx context.Context, id int) error {
+	res, err := s.db.exec(ctx, "DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+var webhookDeliveryColumns = map[string]string{
+	"id":         "d.id",
+	"webhook_id": "d.webhook_id",
+	"event":      "e.event_type",
+	"status":     "d.status",
+	"created_at": "d.created_at",
+}
+
+func (s *sqlWebhookStore) Deliveries(ctx context.Context, q ListQuery) ([]WebhookDelivery, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, webhookDeliveryColumns, `
+            SELECT d.id, d.webhook_id, d.event_id, e.event_type, d.status, d.attempts, COALESCE(d.next_attempt_at, ''),
+                   COALESCE(d.last_status_code, 0), COALESCE(d.last_error, ''), COALESCE(d.delivered_at, ''), d.created_at`, `
+            FROM webhook_deliveries d
+            JOIN webhook_outbox e ON d.event_id = e.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.Event, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastStatusCode, &d.LastError, &d.DeliveredAt, &d.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if d.Status != deliveryPending {
+			d.NextAttemptAt = ""
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, total, rows.Err()
+}
+
+func (s *sqlWebhookStore) Delivery(ctx context.Context, id int) (WebhookDelivery, error) {
+	deliveries, _, err := s.Deliveries(ctx, byID(id))
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	if len(deliveries) == 0 {
+		return WebhookDelivery{}, ErrNotFound
+	}
+	d := deliveries[0]
+
+	var payload, createdAt string
+	if err := s.db.queryRow(ctx, "SELECT payload, created_at FROM webhook_outbox WHERE id = ?", d.EventID).Scan(&payload, &createdAt); err != nil {
+		return d, err
+	}
+	d.Payload = json.RawMessage(webhookBody(d.EventID, d.Event, createdAt, []byte(payload)))
+
+	rows, err := s.db.query(ctx, `
+            SELECT id, attempted_at, COALESCE(status_code, 0), COALESCE(error, ''), COALESCE(response, ''), duration_ms
+            FROM webhook_attempts WHERE delivery_id = ? ORDER BY id`, id)
+	if err != nil {
+		return d, err
+	}
+	defer rows.Close()
+	d.Log = []WebhookAttempt{}
+	for rows.Next() {
+		var a WebhookAttempt
+		if err := rows.Scan(&a.ID, &a.AttemptedAt, &a.StatusCode, &a.Error, &a.Response, &a.DurationMS); err != nil {
+			return d, err
+		}
+		d.Log = append(d.Log, a)
+	}
+	return d, rows.Err()
+}
+
+func (s *sqlWebhookStore) Claim(ctx context.Context, now, leaseUntil string, limit int) ([]webhookJob, error) {
+	rows, err := s.db.query(ctx, `
+            SELECT d.id, d.attempts, d.next_attempt_at, w.url, w.secret, e.id, e.event_type, e.payload, e.created_at
+            FROM webhook_deliveries d
+            JOIN webhooks w ON d.webhook_id = w.id
+            JOIN webhook_outbox e ON d.event_id = e.id
+            WHERE d.status = ? AND d.next_attempt_at <= ?
+            ORDER BY d.next_attempt_at, d.id
+            LIMIT ?`, deliveryPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	var due []webhookJob
+	var dueAt []string
+	for rows.Next() {
+		var job webhookJob
+		var at, payload string
+		if err := rows.Scan(&job.DeliveryID, &job.Attempts, &at, &job.URL, &job.Secret, &job.EventID, &job.Event, &payload, &job.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		job.Payload = []byte(payload)
+		due = append(due, job)
+		dueAt = append(dueAt, at)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// A delivery belongs to whoever moves its next_attempt_at first, so
+	// several instances can share one database.
+	var claimed []webhookJob
+	for i, job := range due {
+		res, err := s.db.exec(ctx, "UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ? AND status = ? AND next_attempt_at = ?",
+			leaseUntil, job.DeliveryID, deliveryPending, dueAt[i])
+		if err != nil {
+			return claimed, err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 1 {
+			claimed = append(claimed, job)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *sqlWebhookStore) Record(ctx context.Context, deliveryID int, attempt WebhookAttempt, status, next string) error {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
+	if _, err := tx.exec(ctx, `
+            INSERT INTO webhook_attempts (delivery_id, attempted_at, status_code, error, response, duration_ms)
+            VALUES (?, ?, ?, ?, ?, ?)`,
+		deliveryID, attempt.AttemptedAt, nullableInt(attempt.StatusCode), attempt.Error, attempt.Response, attempt.DurationMS); err != nil {
+		return err
+	}
+	var deliveredAt interface{}
+	if status == deliveryDelivered {
+		deliveredAt = attempt.AttemptedAt
+	}
+	if _, err := tx.exec(ctx, `
+            UPDATE webhook_deliveries
+            SET status = ?, attempts = attempts + 1, next_attempt_at = ?, last_status_code = ?, last_error = ?,
+                delivered_at = COALESCE(?, delivered_at), updated_at = ?
+            WHERE id = ?`,
+		status, next, nullableInt(attempt.StatusCode), attempt.Error, deliveredAt, timestamp(), deliveryID); err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+func (s *sqlWebhookStore) Redeliver(ctx context.Context, id int) error {
+	ts := timestamp()
+	res, err := s.db.exec(ctx, "UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ?, updated_at = ? WHERE id = ?",
+		deliveryPending, webhookTime(time.Now()), ts, id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type sqlExchangeStore struct {
+	db *sqlDB
+}
+
+func (s *sqlExchangeStore) GUIDs(ctx context.Context, entity string, ids []int) (map[int]string, error) {
+	guids := make(map[int]string, len(ids))
+	if len(ids) == 0 {
+		return guids, nil
+	}
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.rollback()
+
+	args := []interface{}{entity}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := tx.query(ctx, "SELECT entity_id, guid FROM exchange_ids WHERE entity = ? AND entity_id IN ("+placeholders(len(ids))+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		var guid string
+		if err := rows.Scan(&id, &guid); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		guids[id] = guid
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ts := timestamp()
+	for _, id := range ids {
+		if _, ok := guids[id]; ok {
+			continue
+		}
+		guid := newGUID()
+		if _, err := tx.exec(ctx, "INSERT INTO exchange_ids (entity, entity_id, guid, created_at) VALUES (?, ?, ?, ?)", entity, id, guid, ts); err != nil {
+			return nil, err
+		}
+		guids[id] = guid
+	}
+	return guids, tx.commit()
+}
+
+// exchangeID finds the row mapped to guid, falling back to a row of table
+// with the same name, which is then mapped. It returns 0 when neither
+// exists.
+func exchangeID(ctx context.Context, tx *sqlTx, entity, table, guid, name string) (int, error) {
+	var id int
+	err := tx.queryRow(ctx, "SELECT entity_id FROM exchange_ids WHERE entity = ? AND guid = ?", entity, guid).Scan(&id)
+	if err != sql.ErrNoRows {
+		return id, err
+	}
+	err = tx.queryRow(ctx, "SELECT id FROM "+table+" WHERE name = ? ORDER BY id LIMIT 1", name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.exec(ctx, "INSERT INTO exchange_ids (entity, entity_id, guid, created_at) VALUES (?, ?, ?, ?)", entity, id, guid, timestamp())
+	return id, err
+}
+
+func (s *sqlExchangeStore) Import(ctx context.Context, contractors []exchangeContractor, prices []exchangePrice) (ExchangeResult, error) {
+	result := ExchangeResult{}
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer tx.rollback()
+
+	ts := timestamp()
+	for _, c := range contractors {
+		id, err := exchangeID(ctx, tx, exchangeContractorEntity, "contractors", c.GUID, c.Name)
+		if err != nil {
+			return result, err
+		}
+		if id == 0 {
+			id, err = insertReturningID(ctx, tx, "INSERT INTO contractors (name, type, contact_person, phone, email, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+				c.Name, c.Type, c.ContactPerson, c.Phone, c.Email, ts, ts)
+			if err != nil {
+				return result, err
+			}
+			if _, err := tx.exec(ctx, "INSERT INTO exchange_ids (entity, entity_id, guid, created_at) VALUES (?, ?, ?, ?)", exchangeContractorEntity, id, c.GUID, ts); err != nil {
+				return result, err
+			}
+			result.ContractorsCreated++
+			continue
+		}
+		// Fields missing from the file keep their values.
+		if _, err := tx.exec(ctx, `
+                UPDATE contractors SET name = ?, type = COALESCE(NULLIF(?, ''), type), contact_person = COALESCE(NULLIF(?, ''), contact_person),
+                    phone = COALESCE(NULLIF(?, ''), phone), email = COALESCE(NULLIF(?, ''), email), updated_at = ?
+                WHERE id = ?`,
+			c.Name, c.Type, c.ContactPerson, c.Phone, c.Email, ts, id); err != nil {
+			return result, err
+		}
+		result.ContractorsUpdated++
+	}
+
+	for _, p := range prices {
+		id, err := exchangeID(ctx, tx, exchangeOreTypeEntity, "ore_types", p.GUID, p.Name)
+		if err != nil {
+			return result, err
+		}
+		if id == 0 {
+			result.Errors = append(result.Errors, fmt.Sprintf("Номенклатура «%s» (Ид %s) не найдена", p.Name, p.GUID))
+			continue
+		}
+		if _, err := tx.exec(ctx, "UPDATE ore_types SET price = ?, price_updated_at = ?, updated_at = ? WHERE id = ?", p.Price, ts, ts, id); err != nil {
+			return result, err
+		}
+		result.PricesUpdated++
+	}
+	return result, tx.commit()
+}
+
+func (s *sqlExchangeStore) AddLog(ctx context.Context, entry ExchangeLogEntry) (int, error) {
+	return insertReturningID(ctx, s.db, `
+            INSERT INTO exchange_log (direction, file_name, status, summary, errors, document, created_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.Direction, entry.FileName, entry.Status, entry.Summary, strings.Join(entry.Errors, "\n"), entry.Document, timestamp())
+}
+
+var exchangeLogColumns = map[string]string{
+	"id":         "id",
+	"direction":  "direction",
+	"status":     "status",
+	"created_at": "created_at",
+}
+
+func (s *sqlExchangeStore) Log(ctx context.Context, q ListQuery) ([]ExchangeLogEntry, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, exchangeLogColumns, `
+            SELECT id, direction, COALESCE(file_name, ''), status, COALESCE(summary, ''), COALESCE(errors, ''), LENGTH(COALESCE(document, '')), created_at`, `
+            FROM exchange_log`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []ExchangeLogEntry{}
+	for rows.Next() {
+		var e ExchangeLogEntry
+		var errs string
+		if err := rows.Scan(&e.ID, &e.Direction, &e.FileName, &e.Status, &e.Summary, &errs, &e.Size, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if errs != "" {
+			e.Errors = strings.Split(errs, "\n")
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+func (s *sqlExchangeStore) Document(ctx context.Context, id int) (ExchangeLogEntry, error) {
+	var e ExchangeLogEntry
+	err := s.db.queryRow(ctx, "SELECT id, direction, COALESCE(file_name, ''), COALESCE(document, '') FROM exchange_log WHERE id = ?", id).
+		Scan(&e.ID, &e.Direction, &e.FileName, &e.Document)
+	if err == sql.ErrNoRows {
+		return e, ErrNotFound
+	}
+	return e, err
+}
+
+type sqlMaintenanceStore struct {
+	db *sqlDB
+}
+
+var maintenancePlanColumns = map[string]string{
+	"id":           "p.id",
+	"equipment_id": "p.equipment_id",
+	"warehouse_id": "e.warehouse_id",
+	"name":         "p.name",
+	"created_at":   "p.created_at",
+}
+
+func (s *sqlMaintenanceStore) Plans(ctx context.Context, q ListQuery) ([]MaintenancePlan, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, maintenancePlanColumns, `
+            SELECT p.id, p.equipment_id, e.name, e.warehouse_id, p.name, p.interval_days, p.interval_hours,
+                   p.start_date, p.start_hours, COALESCE(p.last_done_date, ''), p.last_done_hours,
+                   (SELECT r.value FROM meter_readings r WHERE r.equipment_id = p.equipment_id AND r.meter = '`+meterHours+`'
+                    ORDER BY r.read_at DESC, r.id DESC LIMIT 1),
+                   COALESCE(p.created_at, '')`, `
+            FROM maintenance_plans p
+            JOIN equipment e ON p.equipment_id = e.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	plans := []MaintenancePlan{}
+	for rows.Next() {
+		var p MaintenancePlan
+		if err := rows.Scan(&p.ID, &p.EquipmentID, &p.EquipmentName, &p.WarehouseID, &p.Name, &p.IntervalDays, &p.IntervalHours,
+			&p.StartDate, &p.StartHours, &p.LastDoneDate, &p.LastDoneHours, &p.CurrentHours, &p.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		p.setDue()
+		plans = append(plans, p)
+	}
+	return plans, total, rows.Err()
+}
+
+func (s *sqlMaintenanceStore) CreatePlan(ctx context.Context, p NewMaintenancePlan) (int, error) {
+	ts := timestamp()
+	return insertReturningID(ctx, s.db, `
+            INSERT INTO maintenance_plans (equipment_id, name, interval_days, interval_hours, start_date, start_hours, created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.EquipmentID, p.Name, p.IntervalDays, p.IntervalHours, p.StartDate, p.StartHours, ts, ts)
+}
+
+func (s *sqlMaintenanceStore) DeletePlan(ctx context.Context, id int) error {
+	res, err := s.db.exec(ctx, "DELETE FROM maintenance_plans WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+var workOrderColumns = map[string]string{
+	"id":           "wo.id",
+	"equipment_id": "wo.equipment_id",
+	"warehouse_id": "e.warehouse_id",
+	"plan_id":      "wo.plan_id",
+	"status":       "wo.status",
+	"planned_date": "wo.planned_date",
+	"completed_at": "wo.completed_at",
+	"created_at":   "wo.created_at",
+}
+
+func (s *sqlMaintenanceStore) WorkOrders(ctx context.Context, q ListQuery) ([]WorkOrder, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, workOrderColumns, `
+            SELECT wo.id, wo.equipment_id, e.name, e.warehouse_id, COALESCE(wo.plan_id, 0), COALESCE(p.name, ''),
+                   wo.title, COALESCE(wo.description, ''), wo.status, COALESCE(wo.planned_date, ''),
+                   COALESCE(wo.started_at, ''), COALESCE(wo.completed_at, ''), wo.meter_hours,
+                   COALESCE((SELECT SUM(wp.quantity * COALESCE(wp.unit_cost, 0)) FROM work_order_parts wp WHERE wp.work_order_id = wo.id), 0),
+                   COALESCE((SELECT SUM(wl.hours) FROM work_order_labor wl WHERE wl.work_order_id = wo.id), 0),
+                   COALESCE(wo.created_at, '')`, `
+            FROM work_orders wo
+            JOIN equipment e ON wo.equipment_id = e.id
+            LEFT JOIN maintenance_plans p ON wo.plan_id = p.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	orders := []WorkOrder{}
+	for rows.Next() {
+		var wo WorkOrder
+		if err := rows.Scan(&wo.ID, &wo.EquipmentID, &wo.EquipmentName, &wo.WarehouseID, &wo.PlanID, &wo.PlanName,
+			&wo.Title, &wo.Description, &wo.Status, &wo.PlannedDate,
+			&wo.StartedAt, &wo.CompletedAt, &wo.MeterHours, &wo.PartsCost, &wo.LaborHours, &wo.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		orders = append(orders, wo)
+	}
+	return orders, total, rows.Err()
+}
+
+func (s *sqlMaintenanceStore) WorkOrder(ctx context.Context, id int) (WorkOrder, error) {
+	orders, _, err := s.WorkOrders(ctx, byID(id))
+	if err != nil {
+		return WorkOrder{}, err
+	}
+	if len(orders) == 0 {
+		return WorkOrder{}, ErrNotFound
+	}
+	wo := orders[0]
+
+	rows, err := s.db.query(ctx, `
+            SELECT wp.id, COALESCE(wp.part_id, 0), COALESCE(wp.warehouse_id, 0), wp.name, wp.unit_id, u.symbol, wp.quantity, wp.unit_cost,
+                   COALESCE(wp.created_at, '')
+            FROM work_order_parts wp
+            JOIN units u ON wp.unit_id = u.id
+            WHERE wp.work_order_id = ?
+            ORDER BY wp.id`, id)
+	if err != nil {
+		return wo, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p WorkOrderPart
+		if err := rows.Scan(&p.ID, &p.PartID, &p.WarehouseID, &p.Name, &p.UnitID, &p.UnitSymbol, &p.Quantity, &p.UnitCost, &p.CreatedAt); err != nil {
+			return wo, err
+		}
+		wo.Parts = append(wo.Parts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return wo, err
+	}
+
+	labor, err := s.db.query(ctx, `
+            SELECT id, worker, hours, COALESCE(work_date, ''), COALESCE(note, ''), COALESCE(created_at, '')
+            FROM work_order_labor
+            WHERE work_order_id = ?
+            ORDER BY id`, id)
+	if err != nil {
+		return wo, err
+	}
+	defer labor.Close()
+	for labor.Next() {
+		var l WorkOrderLabor
+		if err := labor.Scan(&l.ID, &l.Worker, &l.Hours, &l.WorkDate, &l.Note, &l.CreatedAt); err != nil {
+			return wo, err
+		}
+		wo.Labor = append(wo.Labor, l)
+	}
+	return wo, labor.Err()
+}
+
+func (s *sqlMaintenanceStore) CreateWorkOrder(ctx context.Context, w NewWorkOrder) (int, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.rollback()
+
+	var before string
+	if err := tx.queryRow(ctx, "SELECT COALESCE(status, '') FROM equipment WHERE id = ?", w.EquipmentID).Scan(&before); err != nil {
+		return 0, err
+	}
+	if before == equipmentMaintenance {
+		// Another open work order already switched the item; keep the
+		// status it saved.
+		err := tx.queryRow(ctx, "SELECT COALESCE(equipment_status_before, '') FROM work_orders WHERE equipment_id = ? AND status <> ? ORDER BY id LIMIT 1",
+			w.EquipmentID, workOrderDone).Scan(&before)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, err
+		}
+	}
+
+	ts := timestamp()
+	id, err := insertReturningID(ctx, tx, `
+            INSERT INTO work_orders (equipment_id, plan_id, title, description, status, planned_date, equipment_status_before, created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		w.EquipmentID, nullableInt(w.PlanID), w.Title, w.Description, workOrderOpen, w.PlannedDate, before, ts, ts)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.exec(ctx, "UPDATE equipment SET status = ?, updated_at = ? WHERE id = ?", equipmentMaintenance, ts, w.EquipmentID); err != nil {
+		return 0, err
+	}
+	return id, tx.commit()
+}
+
+func (s *sqlMaintenanceStore) UpdateWorkOrderStatus(ctx context.Context, id int, u WorkOrderStatusUpdate) error {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
+	var equipmentID int
+	var planID sql.NullInt64
+	var status, before string
+	err = tx.queryRow(ctx, "SELECT equipment_id, plan_id, status, COALESCE(equipment_status_before, '') FROM work_orders WHERE id = ?", id).
+		Scan(&equipmentID, &planID, &status, &before)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(workOrderNext[status], u.Status) {
+		return &StatusConflict{From: status, To: u.Status}
+	}
+
+	// The update only applies to the status read above; a concurrent change
+	// makes it a conflict. started_at is kept from the first start.
+	ts := timestamp()
+	var res sql.Result
+	if u.Status != workOrderDone {
+		res, err = tx.exec(ctx, "UPDATE work_orders SET status = ?, started_at = COALESCE(started_at, ?), updated_at = ? WHERE id = ? AND status = ?",
+			u.Status, u.Date, ts, id, status)
+	} else {
+		res, err = tx.exec(ctx, `
+            UPDATE work_orders SET status = ?, started_at = COALESCE(started_at, ?), completed_at = ?, meter_hours = ?, updated_at = ?
+            WHERE id = ? AND status = ?`, u.Status, u.Date, u.Date, u.MeterHours, ts, id, status)
+	}
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		if err := tx.queryRow(ctx, "SELECT status FROM work_orders WHERE id = ?", id).Scan(&status); err != nil {
+			return err
+		}
+		return &StatusConflict{From: status, To: u.Status}
+	}
+	if u.Status != workOrderDone {
+		return tx.commit()
+	}
+
+	if planID.Valid {
+		doneDate := u.Date
+		if t, ok := parseDate(u.Date); ok {
+			doneDate = t.Format("2006-01-02")
+		}
+		if _, err := tx.exec(ctx, `
+                UPDATE maintenance_plans SET last_done_date = ?, last_done_hours = COALESCE(?, last_done_hours), updated_at = ?
+                WHERE id = ?`, doneDate, u.MeterHours, ts, planID.Int64); err != nil {
+			return err
+		}
+	}
+
+	var stillOpen bool
+	if err := tx.queryRow(ctx, "SELECT EXISTS (SELECT 1 FROM work_orders WHERE equipment_id = ? AND status <> ?)", equipmentID, workOrderDone).
+		Scan(&stillOpen); err != nil {
+		return err
+	}
+	if !stillOpen {
+		if before == "" || before == equipmentMaintenance {
+			before = equipmentInService
+		}
+		// An item written off while it was being serviced stays written off.
+		if _, err := tx.exec(ctx, "UPDATE equipment SET status = ?, updated_at = ? WHERE id = ? AND status = ?",
+			before, ts, equipmentID, equipmentMaintenance); err != nil {
+			return err
+		}
+	}
+	return tx.commit()
+}
+
+func (s *sqlMaintenanceStore) AddPart(ctx context.Context, workOrderID int, p NewWorkOrderPart) (int, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.rollback()
+
+	ts := timestamp()
+	id, err := insertReturningID(ctx, tx, `
+            INSERT INTO work_order_parts (work_order_id, part_id, warehouse_id, name, unit_id, quantity, unit_cost, created_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		workOrderID, nullableInt(p.PartID), nullableInt(p.WarehouseID), p.Name, p.UnitID, p.Quantity, p.UnitCost, ts)
+	if err != nil {
+		return 0, err
+	}
+	if p.PartID != 0 {
+		if _, err := movePart(ctx, tx, partMove{PartID: p.PartID, WarehouseID: p.WarehouseID, Kind: partWorkOrder,
+			Delta: -p.Quantity, WorkOrderID: workOrderID, MovedAt: ts}); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.commit()
+}
+
+func (s *sqlMaintenanceStore) AddLabor(ctx context.Context, workOrderID int, l NewWorkOrderLabor) (int, error) {
+	return insertReturningID(ctx, s.db, `
+            INSERT INTO work_order_labor (work_order_id, worker, hours, work_date, note, created_at)
+            VALUES (?, ?, ?, ?, ?, ?)`,
+		workOrderID, l.Worker, l.Hours, l.WorkDate, l.Note, timestamp())
+}
+
+type sqlMeterStore struct {
+	db *sqlDB
+}
+
+var meterReadingColumns = map[string]string{
+	"id":           "r.id",
+	"equipment_id": "r.equipment_id",
+	"warehouse_id": "e.warehouse_id",
+	"category_id":  "e.category_id",
+	"meter":        "r.meter",
+	"read_at":      "r.read_at",
+	"created_at":   "r.created_at",
+}
+
+const meterReadingSelect = `
+            SELECT r.id, r.equipment_id, e.name, r.meter, r.value, r.read_at, COALESCE(r.source, ''), COALESCE(r.created_at, '')`
+
+func scanMeterReading(row interface{ Scan(...interface{}) error }) (MeterReading, error) {
+	var m MeterReading
+	err := row.Scan(&m.ID, &m.EquipmentID, &m.EquipmentName, &m.Meter, &m.Value, &m.ReadAt, &m.Source, &m.CreatedAt)
+	return m, err
+}
+
+func (s *sqlMeterStore) Readings(ctx context.Context, q ListQuery) ([]MeterReading, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, meterReadingColumns, meterReadingSelect, `
+            FROM meter_readings r
+            JOIN equipment e ON r.equipment_id = e.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	readings := []MeterReading{}
+	for rows.Next() {
+		m, err := scanMeterReading(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		readings = append(readings, m)
+	}
+	return readings, total, rows.Err()
+}
+
+func (s *sqlMeterStore) Around(ctx context.Context, equipmentID int, meter, at string) (*MeterReading, *MeterReading, error) {
+	neighbour := func(cond, order string) (*MeterReading, error) {
+		m, err := scanMeterReading(s.db.queryRow(ctx, meterReadingSelect+`
+            FROM meter_readings r
+            JOIN equipment e ON r.equipment_id = e.id
+            WHERE r.equipment_id = ? AND r.meter = ? AND r.read_at `+cond+` ?
+            ORDER BY `+order+`
+            LIMIT 1`, equipmentID, meter, at))
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &m, nil
+	}
+	prev, err := neighbour("<=", "r.read_at DESC, r.id DESC")
+	if err != nil {
+		return nil, nil, err
+	}
+	next, err := neighbour(">", "r.read_at, r.id")
+	return prev, next, err
+}
+
+func (s *sqlMeterStore) Create(ctx context.Context, m NewMeterReading) (int, error) {
+	return insertMeterReading(ctx, s.db, m)
+}
+
+func (s *sqlMeterStore) CreateMany(ctx context.Context, readings []NewMeterReading) ([]int, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.rollback()
+
+	ids := make([]int, 0, len(readings))
+	for _, m := range readings {
+		id, err := insertMeterReading(ctx, tx, m)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, tx.commit()
+}
+
+func insertMeterReading(ctx context.Context, q queryer, m NewMeterReading) (int, error) {
+	return insertReturningID(ctx, q, `
+            INSERT INTO meter_readings (equipment_id, meter, value, read_at, source, created_at)
+            VALUES (?, ?, ?, ?, ?, ?)`,
+		m.EquipmentID, m.Meter, *m.Value, m.ReadAt, m.Source, timestamp())
+}
+
+func (s *sqlMeterStore) Delete(ctx context.Context, id int) error {
+	res, err := s.db.exec(ctx, "DELETE FROM meter_readings WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *sqlMeterStore) Usage(ctx context.Context, meter, from, to string) ([]MeterUsage, error) {
+	// The start is the last reading before the period or, for items first
+	// read during it, the first reading in it.
+	rows, err := s.db.query(ctx, `
+            SELECT e.id, e.name, e.warehouse_id, w.name, e.category_id, c.name, COALESCE(e.status, ''),
+                   COALESCE(
+                       (SELECT r.value FROM meter_readings r WHERE r.equipment_id = e.id AND r.meter = ? AND r.read_at <= ?
+                        ORDER BY r.read_at DESC, r.id DESC LIMIT 1),
+                       (SELECT r.value FROM meter_readings r WHERE r.equipment_id = e.id AND r.meter = ? AND r.read_at > ? AND r.read_at < ?
+                        ORDER BY r.read_at, r.id LIMIT 1)),
+                   (SELECT r.value FROM meter_readings r WHERE r.equipment_id = e.id AND r.meter = ? AND r.read_at < ?
+                    ORDER BY r.read_at DESC, r.id DESC LIMIT 1)
+            FROM equipment e
+            JOIN equipment_categories c ON e.category_id = c.id
+            JOIN warehouses w ON e.warehouse_id = w.id
+            ORDER BY e.id`,
+		meter, from, meter, from, to, meter, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []MeterUsage
+	for rows.Next() {
+		var u MeterUsage
+		if err := rows.Scan(&u.EquipmentID, &u.EquipmentName, &u.WarehouseID, &u.WarehouseName, &u.CategoryID, &u.CategoryName,
+			&u.Status, &u.Start, &u.End); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+type sqlAssignmentStore struct {
+	db *sqlDB
+}
+
+var assignmentColumns = map[string]string{
+	"id":             "a.id",
+	"equipment_id":   "a.equipment_id",
+	"warehouse_id":   "a.warehouse_id",
+	"responsible":    "a.responsible",
+	"crew":           "a.crew",
+	"status":         "a.status",
+	"checked_out_at": "a.checked_out_at",
+	"due_date":       "a.due_date",
+	"returned_at":    "a.returned_at",
+}
+
+func (s *sqlAssignmentStore) List(ctx context.Context, q ListQuery) ([]Assignment, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, assignmentColumns, `
+            SELECT a.id, a.equipment_id, e.name, COALESCE(e.inventory_number, ''), a.from_warehouse_id, fw.name, a.warehouse_id, w.name,
+                   COALESCE(a.site, ''), COALESCE(a.crew, ''), a.responsible, a.status, a.checked_out_at, COALESCE(a.due_date, ''),
+                   COALESCE(a.returned_at, ''), COALESCE(a.return_warehouse_id, 0), COALESCE(rw.name, ''), COALESCE(a.note, ''),
+                   COALESCE(a.created_at, '')`, `
+            FROM equipment_assignments a
+            JOIN equipment e ON a.equipment_id = e.id
+            JOIN warehouses fw ON a.from_warehouse_id = fw.id
+            JOIN warehouses w ON a.warehouse_id = w.id
+            LEFT JOIN warehouses rw ON a.return_warehouse_id = rw.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	assignments := []Assignment{}
+	for rows.Next() {
+		var a Assignment
+		if err := rows.Scan(&a.ID, &a.EquipmentID, &a.EquipmentName, &a.InventoryNumber, &a.FromWarehouseID, &a.FromWarehouseName,
+			&a.WarehouseID, &a.WarehouseName, &a.Site, &a.Crew, &a.Responsible, &a.Status, &a.CheckedOutAt, &a.DueDate,
+			&a.ReturnedAt, &a.ReturnWarehouseID, &a.ReturnWarehouseName, &a.Note, &a.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, total, rows.Err()
+}
+
+func (s *sqlAssignmentStore) CheckOut(ctx context.Context, a NewAssignment) (int, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.rollback()
+
+	var from int
+	if err := tx.queryRow(ctx, "SELECT warehouse_id FROM equipment WHERE id = ?", a.EquipmentID).Scan(&from); err != nil {
+		return 0, err
+	}
+	ts := timestamp()
+	id, err := insertReturningID(ctx, tx, `
+            INSERT INTO equipment_assignments (equipment_id, from_warehouse_id, warehouse_id, site, crew, responsible, status,
+                                               checked_out_at, due_date, note, created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.EquipmentID, from, a.WarehouseID, a.Site, a.Crew, a.Responsible, assignmentOut, a.CheckedOutAt, a.DueDate, a.Note, ts, ts)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.exec(ctx, "UPDATE equipment SET warehouse_id = ?, updated_at = ? WHERE id = ?", a.WarehouseID, ts, a.EquipmentID); err != nil {
+		return 0, err
+	}
+	return id, tx.commit()
+}
+
+func (s *sqlAssignmentStore) Return(ctx context.Context, id int, r AssignmentReturn) error {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
+	var equipmentID int
+	err = tx.queryRow(ctx, "SELECT equipment_id FROM equipment_assignments WHERE id = ?", id).Scan(&equipmentID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	ts := timestamp()
+	if _, err := tx.exec(ctx, `
+            UPDATE equipment_assignments SET status = ?, returned_at = ?, return_warehouse_id = ?, note = ?, updated_at = ?
+            WHERE id = ?`, assignmentReturned, r.ReturnedAt, r.WarehouseID, r.Note, ts, id); err != nil {
+		return err
+	}
+	if _, err := tx.exec(ctx, "UPDATE equipment SET warehouse_id = ?, updated_at = ? WHERE id = ?", r.WarehouseID, ts, equipmentID); err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+type sqlPartStore struct {
+	db *sqlDB
+}
+
+var sparePartColumns = map[string]string{
+	"id":          "p.id",
+	"name":        "p.name",
+	"part_number": "p.part_number",
+	"category":    "p.category",
+	"supplier_id": "p.supplier_id",
+}
+
+func (s *sqlPartStore) List(ctx context.Context, q ListQuery) ([]SparePart, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, sparePartColumns, `
+            SELECT p.id, p.name, COALESCE(p.part_number, ''), COALESCE(p.category, ''), p.unit_id, u.symbol,
+                   COALESCE(p.supplier_id, 0), COALESCE(c.name, ''), p.unit_cost,
+                   COALESCE((SELECT SUM(st.quantity) FROM spare_part_stock st WHERE st.part_id = p.id), 0), COALESCE(p.created_at, '')`, `
+            FROM spare_parts p
+            JOIN units u ON p.unit_id = u.id
+            LEFT JOIN contractors c ON p.supplier_id = c.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	parts := []SparePart{}
+	for rows.Next() {
+		var p SparePart
+		if err := rows.Scan(&p.ID, &p.Name, &p.PartNumber, &p.Category, &p.UnitID, &p.UnitSymbol,
+			&p.SupplierID, &p.SupplierName, &p.UnitCost, &p.Quantity, &p.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		parts = append(parts, p)
+	}
+	return parts, total, rows.Err()
+}
+
+func (s *sqlPartStore) Create(ctx context.Context, p NewSparePart) (int, error) {
+	ts := timestamp()
+	return insertReturningID(ctx, s.db, `
+            INSERT INTO spare_parts (name, part_number, category, unit_id, supplier_id, unit_cost, created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.Name, p.PartNumber, p.Category, p.UnitID, nullableInt(p.SupplierID), p.UnitCost, ts, ts)
+}
+
+var partStockColumns = map[string]string{
+	"part_id":      "st.part_id",
+	"warehouse_id": "st.warehouse_id",
+	"supplier_id":  "p.supplier_id",
+	"category":     "p.category",
+	"quantity":     "st.quantity",
+}
+
+func (s *sqlPartStore) Stock(ctx context.Context, q ListQuery) ([]PartStock, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, partStockColumns, `
+            SELECT st.part_id, p.name, COALESCE(p.part_number, ''), COALESCE(p.category, ''), u.symbol,
+                   COALESCE(p.supplier_id, 0), COALESCE(c.name, ''), p.unit_cost, st.warehouse_id, w.name,
+                   st.quantity, st.min_quantity, st.max_quantity, COALESCE(st.updated_at, '')`, `
+            FROM spare_part_stock st
+            JOIN spare_parts p ON st.part_id = p.id
+            JOIN units u ON p.unit_id = u.id
+            JOIN warehouses w ON st.warehouse_id = w.id
+            LEFT JOIN contractors c ON p.supplier_id = c.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	stock := []PartStock{}
+	for rows.Next() {
+		var st PartStock
+		if err := rows.Scan(&st.PartID, &st.PartName, &st.PartNumber, &st.Category, &st.UnitSymbol,
+			&st.SupplierID, &st.SupplierName, &st.UnitCost, &st.WarehouseID, &st.WarehouseName,
+			&st.Quantity, &st.MinQuantity, &st.MaxQuantity, &st.UpdatedAt); err != nil {
+			return nil, 0, err
+		}
+		stock = append(stock, st)
+	}
+	return stock, total, rows.Err()
+}
+
+func (s *sqlPartStore) SetLevels(ctx context.Context, partID int, l PartStockLevels) error {
+	_, err := s.db.exec(ctx, `
+            INSERT INTO spare_part_stock (part_id, warehouse_id, quantity, min_quantity, max_quantity, updated_at)
+            VALUES (?, ?, 0, ?, ?, ?)
+            ON CONFLICT (part_id, warehouse_id) DO UPDATE
+            SET min_quantity = excluded.min_quantity, max_quantity = excluded.max_quantity, updated_at = excluded.updated_at`,
+		partID, l.WarehouseID, l.MinQuantity, l.MaxQuantity, timestamp())
+	return err
+}
+
+var partMovementColumns = map[string]string{
+	"id":            "m.id",
+	"part_id":       "m.part_id",
+	"warehouse_id":  "m.warehouse_id",
+	"kind":          "m.kind",
+	"work_order_id": "m.work_order_id",
+	"receipt_id":    "m.receipt_id",
+	"moved_at":      "m.moved_at",
+}
+
+func (s *sqlPartStore) Movements(ctx context.Context, q ListQuery) ([]PartMovement, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, partMovementColumns, `
+            SELECT m.id, m.part_id, p.name, m.warehouse_id, w.name, m.kind, m.quantity, m.balance, u.symbol,
+                   COALESCE(m.work_order_id, 0), COALESCE(m.receipt_id, 0), COALESCE(m.note, ''), m.moved_at, COALESCE(m.created_at, '')`, `
+            FROM spare_part_movements m
+            JOIN spare_parts p ON m.part_id = p.id
+            JOIN units u ON p.unit_id = u.id
+            JOIN warehouses w ON m.warehouse_id = w.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	movements := []PartMovement{}
+	for rows.Next() {
+		var m PartMovement
+		if err := rows.Scan(&m.ID, &m.PartID, &m.PartName, &m.WarehouseID, &m.WarehouseName, &m.Kind, &m.Quantity, &m.Balance,
+			&m.UnitSymbol, &m.WorkOrderID, &m.ReceiptID, &m.Note, &m.MovedAt, &m.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, total, rows.Err()
+}
+
+func (s *sqlPartStore) Move(ctx context.Context, m NewPartMovement) (int, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.rollback()
+
+	move := partMove{PartID: m.PartID, WarehouseID: m.WarehouseID, Kind: m.Kind, Delta: *m.Quantity, Note: m.Note, MovedAt: m.MovedAt}
+	switch m.Kind {
+	case partIssue:
+		move.Delta = -*m.Quantity
+	case partCount:
+		if err := ensurePartStock(ctx, tx, m.PartID, m.WarehouseID); err != nil {
+			return 0, err
+		}
+		var current float64
+		if err := tx.queryRow(ctx, "SELECT quantity FROM spare_part_stock WHERE part_id = ? AND warehouse_id = ?",
+			m.PartID, m.WarehouseID).Scan(&current); err != nil {
+			return 0, err
+		}
+		move.Delta = *m.Quantity - current
+	}
+	id, err := movePart(ctx, tx, move)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.commit()
+}
+
+type partMove struct {
+	PartID      int
+	WarehouseID int
+	Kind        string
+	Delta       float64
+	WorkOrderID int
+	ReceiptID   int
+	Note        string
+	MovedAt     string
+}
+
+func ensurePartStock(ctx context.Context, q queryer, partID, warehouseID int) error {
+	_, err := q.exec(ctx, `
+            INSERT INTO spare_part_stock (part_id, warehouse_id, quantity, updated_at) VALUES (?, ?, 0, ?)
+            ON CONFLICT (part_id, warehouse_id) DO NOTHING`, partID, warehouseID, timestamp())
+	return err
+}
+
+// movePart changes the stock of a part at a warehouse by m.Delta and records
+// the movement with the balance after it. A negative balance is refused with
+// a *StockShortage.
+func movePart(ctx context.Context, q queryer, m partMove) (int, error) {
+	if err := ensurePartStock(ctx, q, m.PartID, m.WarehouseID); err != nil {
+		return 0, err
+	}
+	if _, err := q.exec(ctx, `
+            UPDATE spare_part_stock SET quantity = quantity + ?, updated_at = ?
+            WHERE part_id = ? AND warehouse_id = ?`, m.Delta, timestamp(), m.PartID, m.WarehouseID); err != nil {
+		return 0, err
+	}
+	var balance float64
+	if err := q.queryRow(ctx, "SELECT quantity FROM spare_part_stock WHERE part_id = ? AND warehouse_id = ?",
+		m.PartID, m.WarehouseID).Scan(&balance); err != nil {
+		return 0, err
+	}
+	if balance < -1e-9 {
+		return 0, &StockShortage{PartID: m.PartID, WarehouseID: m.WarehouseID, Available: balance - m.Delta}
+	}
+	return insertReturningID(ctx, q, `
+            INSERT INTO spare_part_movements (part_id, warehouse_id, kind, quantity, balance, work_order_id, receipt_id, note, moved_at, created_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.PartID, m.WarehouseID, m.Kind, m.Delta, balance, nullableInt(m.WorkOrderID), nullableInt(m.ReceiptID), m.Note, m.MovedAt, timestamp())
+}
+
+type sqlPurchaseStore struct {
+	db *sqlDB
+}
+
+// total_amount is cast like equipment purchase_cost.
+var purchaseOrderColumns = map[string]string{
+	"id":            "o.id",
+	"order_number":  "o.order_number",
+	"supplier_id":   "o.supplier_id",
+	"warehouse_id":  "o.warehouse_id",
+	"status":        "o.status",
+	"order_date":    "o.order_date",
+	"expected_date": "o.expected_date",
+	"total_amount":  "CAST(o.total_amount AS NUMERIC)",
+	"created_at":    "o.created_at",
+}
+
+func (s *sqlPurchaseStore) List(ctx context.Context, q ListQuery) ([]PurchaseOrder, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, purchaseOrderColumns, `
+            SELECT o.id, o.order_number, o.supplier_id, c.name, o.warehouse_id, w.name, o.status, COALESCE(o.order_date, ''),
+                   COALESCE(o.expected_date, ''), COALESCE(o.note, ''), o.total_amount, COALESCE(o.created_at, '')`, `
+            FROM purchase_orders o
+            JOIN contractors c ON o.supplier_id = c.id
+            JOIN warehouses w ON o.warehouse_id = w.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	orderRows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer orderRows.Close()
+
+	orders := []PurchaseOrder{}
+	index := make(map[int]int)
+	var orderIDs []interface{}
+	for orderRows.Next() {
+		o := PurchaseOrder{Items: []PurchaseOrderItem{}}
+		if err := orderRows.Scan(&o.ID, &o.OrderNumber, &o.SupplierID, &o.SupplierName, &o.WarehouseID, &o.WarehouseName, &o.Status,
+			&o.OrderDate, &o.ExpectedDate, &o.Note, &o.TotalAmount, &o.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		index[o.ID] = len(orders)
+		orders = append(orders, o)
+		orderIDs = append(orderIDs, o.ID)
+	}
+	if err := orderRows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(orderIDs) == 0 {
+		return orders, total, nil
+	}
+
+	rows, err := s.db.query(ctx, `
+            SELECT i.id, i.order_id, COALESCE(i.part_id, 0), COALESCE(i.category_id, 0), i.name, i.unit_id, u.symbol,
+                   i.quantity, i.received_quantity, i.price
+            FROM purchase_order_items i
+            JOIN units u ON i.unit_id = u.id
+            WHERE i.order_id IN (`+placeholders(len(orderIDs))+`)
+            ORDER BY i.id`, orderIDs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item PurchaseOrderItem
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.PartID, &item.CategoryID, &item.Name, &item.UnitID, &item.UnitSymbol,
+			&item.Quantity, &item.Received, &item.Price); err != nil {
+			return nil, 0, err
+		}
+		amount, err := item.Price.MulFloat(item.Quantity)
+		if err != nil {
+			return nil, 0, err
+		}
+		item.Amount = amount.Round(2)
+		if i, ok := index[item.OrderID]; ok {
+			orders[i].Items = append(orders[i].Items, item)
+		}
+	}
+	return orders, total, rows.Err()
+}
+
+func (s *sqlPurchaseStore) Create(ctx context.Context, o NewPurchaseOrder) (int, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.rollback()
+
+	ts := timestamp()
+	total := Decimal(0)
+	for _, item := range o.Items {
+		amount, err := item.Price.MulFloat(item.Quantity)
+		if err != nil {
+			return 0, err
+		}
+		if total, err = total.Add(amount.Round(2)); err != nil {
+			return 0, err
+		}
+	}
+	orderID, err := insertReturningID(ctx, tx, `
+            INSERT INTO purchase_orders (order_number, supplier_id, warehouse_id, status, order_date, expected_date, note, total_amount,
+                                         created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		o.OrderNumber, o.SupplierID, o.WarehouseID, purchaseDraft, o.OrderDate, o.ExpectedDate, o.Note, total, ts, ts)
+	if err != nil {
+		return 0, err
+	}
+	for _, item := range o.Items {
+		if _, err := tx.exec(ctx, `
+                INSERT INTO purchase_order_items (order_id, part_id, category_id, name, unit_id, quantity, price, created_at)
+                VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			orderID, nullableInt(item.PartID), nullableInt(item.CategoryID), item.Name, item.UnitID, item.Quantity, *item.Price, ts); err != nil {
+			return 0, err
+		}
+	}
+	return orderID, tx.commit()
+}
+
+func (s *sqlPurchaseStore) UpdateStatus(ctx context.Context, id int, status string) error {
+	res, err := s.db.exec(ctx, "UPDATE purchase_orders SET status = ?, updated_at = ? WHERE id = ?", status, timestamp(), id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *sqlPurchaseStore) OnOrder(ctx context.Context) (map[partWarehouse]float64, error) {
+	rows, err := s.db.query(ctx, `
+            SELECT i.part_id, o.warehouse_id, SUM(i.quantity - i.received_quantity)
+            FROM purchase_order_items i JOIN purchase_orders o ON o.id = i.order_id
+            WHERE i.part_id IS NOT NULL AND i.quantity > i.received_quantity AND o.status IN (?, ?, ?)
+            GROUP BY i.part_id, o.warehouse_id`, purchaseDraft, purchaseSent, purchasePartial)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	onOrder := map[partWarehouse]float64{}
+	for rows.Next() {
+		var key partWarehouse
+		var quantity float64
+		if err := rows.Scan(&key.PartID, &key.WarehouseID, &quantity); err != nil {
+			return nil, err
+		}
+		onOrder[key] = quantity
+	}
+	return onOrder, rows.Err()
+}
+
+var goodsReceiptColumns = map[string]string{
+	"id":           "r.id",
+	"order_id":     "r.order_id",
+	"supplier_id":  "o.supplier_id",
+	"warehouse_id": "r.warehouse_id",
+	"received_at":  "r.received_at",
+}
+
+func (s *sqlPurchaseStore) Receipts(ctx context.Context, q ListQuery) ([]GoodsReceipt, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, goodsReceiptColumns, `
+            SELECT r.id, r.order_id, o.order_number, o.supplier_id, c.name, r.warehouse_id, w.name, r.received_at,
+                   COALESCE(r.document_number, ''), COALESCE(r.note, ''), COALESCE(r.created_at, '')`, `
+            FROM goods_receipts r
+            JOIN purchase_orders o ON r.order_id = o.id
+            JOIN contractors c ON o.supplier_id = c.id
+            JOIN warehouses w ON r.warehouse_id = w.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	receiptRows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer receiptRows.Close()
+
+	receipts := []GoodsReceipt{}
+	index := make(map[int]int)
+	var receiptIDs []interface{}
+	for receiptRows.Next() {
+		g := GoodsReceipt{Items: []GoodsReceiptItem{}}
+		if err := receiptRows.Scan(&g.ID, &g.OrderID, &g.OrderNumber, &g.SupplierID, &g.SupplierName, &g.WarehouseID, &g.WarehouseName,
+			&g.ReceivedAt, &g.DocumentNumber, &g.Note, &g.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		index[g.ID] = len(receipts)
+		receipts = append(receipts, g)
+		receiptIDs = append(receiptIDs, g.ID)
+	}
+	if err := receiptRows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(receiptIDs) == 0 {
+		return receipts, total, nil
+	}
+
+	rows, err := s.db.query(ctx, `
+            SELECT gi.id, gi.receipt_id, gi.order_item_id, i.name, gi.quantity, u.symbol, COALESCE(gi.movement_id, 0), COALESCE(gi.equipment_id, 0)
+            FROM goods_receipt_items gi
+            JOIN purchase_order_items i ON gi.order_item_id = i.id
+            JOIN units u ON i.unit_id = u.id
+            WHERE gi.receipt_id IN (`+placeholders(len(receiptIDs))+`)
+            ORDER BY gi.id`, receiptIDs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item GoodsReceiptItem
+		var receiptID int
+		if err := rows.Scan(&item.ID, &receiptID, &item.OrderItemID, &item.Name, &item.Quantity, &item.UnitSymbol,
+			&item.MovementID, &item.EquipmentID); err != nil {
+			return nil, 0, err
+		}
+		if i, ok := index[receiptID]; ok {
+			receipts[i].Items = append(receipts[i].Items, item)
+		}
+	}
+	return receipts, total, rows.Err()
+}
+
+func (s *sqlPurchaseStore) Receive(ctx context.Context, orderID int, r NewGoodsReceipt, lines []receiptLine, status string) (int, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.rollback()
+
+	var number string
+	err = tx.queryRow(ctx, "SELECT order_number FROM purchase_orders WHERE id = ?", orderID).Scan(&number)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	ts := timestamp()
+	receiptID, err := insertReturningID(ctx, tx, `
+            INSERT INTO goods_receipts (order_id, warehouse_id, received_at, document_number, note, created_at)
+            VALUES (?, ?, ?, ?, ?, ?)`,
+		orderID, r.WarehouseID, r.ReceivedAt, r.DocumentNumber, r.Note, ts)
+	if err != nil {
+		return 0, err
+	}
+	note := "Заказ " + number
+	if r.DocumentNumber != "" {
+		note += ", документ " + r.DocumentNumber
+	}
+	for _, line := range lines {
+		if _, err := tx.exec(ctx, "UPDATE purchase_order_items SET received_quantity = received_quantity + ? WHERE id = ?",
+			line.Quantity, line.OrderItemID); err != nil {
+			return 0, err
+		}
+		if line.PartID != 0 {
+			movementID, err := movePart(ctx, tx, partMove{PartID: line.PartID, WarehouseID: r.WarehouseID, Kind: partPurchase,
+				Delta: line.Quantity, ReceiptID: receiptID, Note: note, MovedAt: r.ReceivedAt})
+			if err != nil {
+				return 0, err
+			}
+			// Parts are valued at the last purchase price.
+			if _, err := tx.exec(ctx, "UPDATE spare_parts SET unit_cost = ?, updated_at = ? WHERE id = ?", line.Price, ts, line.PartID); err != nil {
+				return 0, err
+			}
+			if _, err := tx.exec(ctx, `
+                    INSERT INTO goods_receipt_items (receipt_id, order_item_id, quantity, movement_id) VALUES (?, ?, ?, ?)`,
+				receiptID, line.OrderItemID, line.Quantity, movementID); err != nil {
+				return 0, err
+			}
+		}
+		for _, e := range line.Equipment {
+			equipmentID, err := insertEquipment(ctx, tx, e)
+			if err != nil {
+				return 0, err
+			}
+			if _, err := tx.exec(ctx, `
+                    INSERT INTO goods_receipt_items (receipt_id, order_item_id, quantity, equipment_id) VALUES (?, ?, ?, ?)`,
+				receiptID, line.OrderItemID, e.Quantity, equipmentID); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if _, err := tx.exec(ctx, "UPDATE purchase_orders SET status = ?, updated_at = ? WHERE id = ?", status, ts, orderID); err != nil {
+		return 0, err
+	}
+	return receiptID, tx.commit()
+}
+
+type sqlPriceStore struct {
+	db *sqlDB
+}
+
+var priceListColumns = map[string]string{
+	"id":            "p.id",
+	"ore_type_id":   "p.ore_type_id",
+	"contractor_id": "p.contractor_id",
+	"unit_id":       "p.unit_id",
+	"currency":      "p.currency",
+	"valid_from":    "p.valid_from",
+	"valid_to":      "p.valid_to",
+}
+
+func (s *sqlPriceStore) List(ctx context.Context, q ListQuery) ([]PriceList, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, priceListColumns, `
+            SELECT p.id, p.ore_type_id, t.name, COALESCE(p.contractor_id, 0), COALESCE(c.name, ''), p.unit_id, u.symbol,
+                   COALESCE(p.currency, ''), p.base_price, p.base_grade, p.bonus_per_pct, p.penalty_per_pct, p.valid_from, COALESCE(p.valid_to, ''),
+                   COALESCE(p.note, ''), COALESCE(p.created_at, '')`, `
+            FROM price_lists p
+            JOIN ore_types t ON p.ore_type_id = t.id
+            JOIN units u ON p.unit_id = u.id
+            LEFT JOIN contractors c ON p.contractor_id = c.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	lists := []PriceList{}
+	for rows.Next() {
+		var p PriceList
+		if err := rows.Scan(&p.ID, &p.OreTypeID, &p.OreTypeName, &p.ContractorID, &p.ContractorName, &p.UnitID, &p.UnitSymbol,
+			&p.Currency, &p.BasePrice, &p.BaseGrade, &p.BonusPerPct, &p.PenaltyPerPct, &p.ValidFrom, &p.ValidTo,
+			&p.Note, &p.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		lists = append(lists, p)
+	}
+	return lists, total, rows.Err()
+}
+
+func (s *sqlPriceStore) Create(ctx context.Context, p NewPriceList) (int, error) {
+	ts := timestamp()
+	var validTo interface{}
+	if p.ValidTo != "" {
+		validTo = p.ValidTo
+	}
+	return insertReturningID(ctx, s.db, `
+            INSERT INTO price_lists (ore_type_id, contractor_id, unit_id, currency, base_price, base_grade, bonus_per_pct, penalty_per_pct,
+                                     valid_from, valid_to, note, created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.OreTypeID, nullableInt(p.ContractorID), p.UnitID, p.Currency, *p.BasePrice, p.BaseGrade, p.BonusPerPct, p.PenaltyPerPct,
+		p.ValidFrom, validTo, p.Note, ts, ts)
+}
+
+func (s *sqlPriceStore) Delete(ctx context.Context, id int) error {
+	res, err := s.db.exec(ctx, "DELETE FROM price_lists WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+var priceOverrideColumns = map[string]string{
+	"order_id":      "i.order_id",
+	"contractor_id": "o.contractor_id",
+	"price_source":  "i.price_source",
+	"created_at":    "i.created_at",
+	"item_id":       "i.id",
+}
+
+func (s *sqlPriceStore) Overrides(ctx context.Context, q ListQuery) ([]PriceOverride, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, priceOverrideColumns, `
+            SELECT i.id, i.order_id, o.order_number, o.contractor_id, c.name, i.ore_batch_id, COALESCE(ob.batch_code, ''),
+                   COALESCE(o.currency, ''), i.list_price, COALESCE(i.price_per_unit, 0), COALESCE(i.price_reason, ''), COALESCE(i.created_at, '')`, `
+            FROM sales_order_items i
+            JOIN sales_orders o ON i.order_id = o.id
+            JOIN contractors c ON o.contractor_id = c.id
+            LEFT JOIN ore_batches ob ON i.ore_batch_id = ob.id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	overrides := []PriceOverride{}
+	for rows.Next() {
+		var o PriceOverride
+		if err := rows.Scan(&o.ItemID, &o.OrderID, &o.OrderNumber, &o.ContractorID, &o.ContractorName, &o.OreBatchID, &o.OreBatchName,
+			&o.Currency, &o.ListPrice, &o.Price, &o.Reason, &o.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if o.ListPrice != nil {
+			diff := o.Price - *o.ListPrice
+			o.Difference = &diff
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, total, rows.Err()
+}
+
+type sqlRateStore struct {
+	db *sqlDB
+}
+
+var exchangeRateColumns = map[string]string{
+	"id":        "r.id",
+	"currency":  "r.currency",
+	"rate_date": "r.rate_date",
+}
+
+func (s *sqlRateStore) List(ctx context.Context, q ListQuery) ([]ExchangeRate, int, error) {
+	query, args, total, err := pagedList(ctx, s.db, q, exchangeRateColumns, `
+            SELECT r.id, r.currency, r.rate_date, r.rate, COALESCE(r.source, ''), COALESCE(r.updated_at, '')`, `
+            FROM exchange_rates r`)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	rates := []ExchangeRate{}
+	for rows.Next() {
+		var r ExchangeRate
+		if err := rows.Scan(&r.ID, &r.Currency, &r.RateDate, &r.Rate, &r.Source, &r.UpdatedAt); err != nil {
+			return nil, 0, err
+		}
+		rates = append(rates, r)
+	}
+	return rates, total, rows.Err()
+}
+
+func (s *sqlRateStore) Set(ctx context.Context, rates []NewExchangeRate, source string) error {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
+	ts := timestamp()
+	for _, r := range rates {
+		if _, err := tx.exec(ctx, `
+                INSERT INTO exchange_rates (currency, rate_date, rate, source, created_at, updated_at)
+                VALUES (?, ?, ?, ?, ?, ?)
+                ON CONFLICT (currency, rate_date) DO UPDATE SET rate = excluded.rate, source = excluded.source, updated_at = excluded.updated_at`,
+			r.Currency, r.RateDate, *r.Rate, source, ts, ts); err != nil {
+			return err
+		}
+	}
+	return tx.commit()
+}
+
+func (s *sqlRateStore) FillCurrency(ctx context.Context, currency string) (int, error) {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.rollback()
+
+	filled := 0
+	for _, query := range []string{
+		"UPDATE price_lists SET currency = ? WHERE currency IS NULL",
+		"UPDATE sales_orders SET currency = ? WHERE currency IS NULL",
+		"UPDATE sales_orders SET reporting_currency = ? WHERE reporting_currency IS NULL AND rate IS NOT NULL",
+	} {
+		res, err := tx.exec(ctx, query, currency)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		filled += int(n)
+	}
+	return filled, tx.commit()
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var sqliteDialect = dialect{name: "sqlite", ilike: "LIKE", constraint: sqliteConstraintError}
+
+// sqliteConstraintError recognizes constraint failures. SQLite names the
+// column for unique violations ("UNIQUE constraint failed: table.column")
+// but not for foreign keys.
+func sqliteConstraintError(err error) error {
+	var e sqlite3.Error
+	if !errors.As(err, &e) || e.Code != sqlite3.ErrConstraint {
+		return err
+	}
+	switch e.ExtendedCode {
+	case sqlite3.ErrConstraintForeignKey:
+		return &ConstraintError{Kind: constraintForeignKey, Err: err}
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		field := ""
+		if i := strings.LastIndex(e.Error(), "."); i >= 0 && !strings.Contains(e.Error()[i:], ",") {
+			field = e.Error()[i+1:]
+		}
+		return &ConstraintError{Kind: constraintUnique, Field: field, Err: err}
+	}
+	return err
+}
+
+func openSQLiteStore(dsn string) (*Store, error) {
+	if !strings.Contains(dsn, "_foreign_keys") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "_foreign_keys=on"
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	sdb := &sqlDB{db: db, dialect: sqliteDialect}
+	store := newSQLStore(sdb)
+	search := &sqliteSearchStore{db: sdb}
+	store.Search = search
+	store.afterMigrate = search.init
+	return store, nil
+}
+
+type sqliteSearchStore struct {
+	db   *sqlDB
+	fts5 bool
+}
+
+// init installs the triggers keeping search_index in sync. It runs after
+// migrations because the table layout depends on whether SQLite was built
+// with FTS5 (see README): migration 0021 creates a plain table, queried with
+// LIKE, which is replaced by an FTS5 table when FTS5 is available.
+func (s *sqliteSearchStore) init(ctx context.Context) error {
+	if err := s.db.queryRow(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&s.fts5); err != nil {
+		return err
+	}
+	var existing string
+	if err := s.db.queryRow(ctx, "SELECT sql FROM sqlite_master WHERE name = 'search_index'").Scan(&existing); err != nil {
+		return err
+	}
+	if s.fts5 && !strings.Contains(strings.ToLower(existing), "fts5") {
+		for _, stmt := range []string{
+			"DROP TABLE search_index",
+			`CREATE VIRTUAL TABLE search_index USING fts5(entity UNINDEXED, entity_id UNINDEXED, title, body, tokenize = 'unicode61 remove_diacritics 2')`,
+			"DELETE FROM search_index_state",
+		} {
+			if _, err := s.db.exec(ctx, stmt); err != nil {
+				return err
+			}
+		}
+	} else if !s.fts5 {
+		slog.Warn("SQLite built without FTS5, /api/search falls back to LIKE matching")
+	}
+
+	for _, src := range searchSources {
+		insert := fmt.Sprintf("INSERT INTO search_index (entity, entity_id, title, body) VALUES ('%s', NEW.id, %s, %s);", src.Type, src.Title, src.Body)
+		remove := fmt.Sprintf("DELETE FROM search_index WHERE entity = '%s' AND entity_id = OLD.id;", src.Type)
+		// Triggers are recreated on every start so changes to searchSources
+		// take effect on existing databases.
+		triggers := []string{
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s_search_ai", src.Table),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s_search_au", src.Table),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s_search_ad", src.Table),
+			fmt.Sprintf("CREATE TRIGGER %[1]s_search_ai AFTER INSERT ON %[1]s BEGIN %[2]s END", src.Table, insert),
+			fmt.Sprintf("CREATE TRIGGER %[1]s_search_au AFTER UPDATE ON %[1]s BEGIN %[2]s %[3]s END", src.Table, remove, insert),
+			fmt.Sprintf("CREATE TRIGGER %[1]s_search_ad AFTER DELETE ON %[1]s BEGIN %[2]s END", src.Table, remove),
+		}
+		// A renamed contractor or ore type changes the body of the rows
+		// that show its name.
+		for _, parent := range src.Parents {
+			name := fmt.Sprintf("%s_search_%s_au", parent.Table, src.Type)
+			where := fmt.Sprintf("%s = NEW.id", parent.Column)
+			triggers = append(triggers,
+				"DROP TRIGGER IF EXISTS "+name,
+				fmt.Sprintf("CREATE TRIGGER %s AFTER UPDATE ON %s BEGIN DELETE FROM search_index WHERE entity = '%s' AND entity_id IN (SELECT id FROM %s WHERE %s); %s; END",
+					name, parent.Table, src.Type, src.Table, where, indexSelect(src, where)))
+		}
+		for _, trigger := range triggers {
+			if _, err := s.db.exec(ctx, trigger); err != nil {
+				return err
+			}
+		}
+	}
+
+	var indexed string
+	err := s.db.queryRow(ctx, "SELECT fingerprint FROM search_index_state").Scan(&indexed)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if fingerprint := s.fingerprint(); indexed != fingerprint {
+		return s.rebuild(ctx, fingerprint)
+	}
+	return nil
+}
+
+// fingerprint identifies the layout of the index, so that rows written by
+// an older version of searchSources are replaced.
+func (s *sqliteSearchStore) fingerprint() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "fts5=%t\n", s.fts5)
+	for _, src := range searchSources {
+		fmt.Fprintf(h, "%s\n%s\n%s\n%s\n", src.Type, src.Table, src.Title, src.Body)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// indexSelect inserts the index rows of src for the rows matching where.
+func indexSelect(src searchSource, where string) string {
+	title := strings.ReplaceAll(src.Title, "NEW.", "t.")
+	body := strings.ReplaceAll(src.Body, "NEW.", "t.")
+	return fmt.Sprintf("INSERT INTO search_index (entity, entity_id, title, body) SELECT '%s', t.id, %s, %s FROM %s t WHERE %s",
+		src.Type, title, body, src.Table, where)
+}
+
+// rebuild indexes every row again, for a new database, one indexed before
+// the triggers were installed or one indexed with another layout.
+func (s *sqliteSearchStore) rebuild(ctx context.Context, fingerprint string) error {
+	tx, err := s.db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+	if _, err := tx.exec(ctx, "DELETE FROM search_index"); err != nil {
+		return err
+	}
+	for _, src := range searchSources {
+		if _, err := tx.exec(ctx, indexSelect(src, "1 = 1")); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.exec(ctx, "DELETE FROM search_index_state"); err != nil {
+		return err
+	}
+	if _, err := tx.exec(ctx, "INSERT INTO search_index_state (fingerprint) VALUES (?)", fingerprint); err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+func (s *sqliteSearchStore) Search(ctx context.Context, terms, types []string, limit int) ([]SearchHit, error) {
+	var conds []string
+	var args []interface{}
+	var query string
+	if s.fts5 {
+		quoted := make([]string, len(terms))
+		for i, t := range terms {
+			quoted[i] = `"` + t + `"*`
+		}
+		conds = append(conds, "search_index MATCH ?")
+		args = append(args, strings.Join(quoted, " "))
+		query = "SELECT entity, entity_id, IFNULL(title, ''), snippet(search_index, 3, '', '', '…', 12), -bm25(search_index) FROM search_index"
+	} else {
+		// Without FTS5 a hit ranks by where its terms occur: a term in the
+		// title counts more than one in the body, and a title starting with
+		// it more still.
+		var scores []string
+		var scoreArgs []interface{}
+		for _, t := range terms {
+			like := "%" + t + "%"
+			conds = append(conds, "(title LIKE ? OR body LIKE ?)")
+			args = append(args, like, like)
+			scores = append(scores, "(CASE WHEN title LIKE ? THEN 3 WHEN title LIKE ? THEN 2 ELSE 0 END) + (CASE WHEN body LIKE ? THEN 1 ELSE 0 END)")
+			scoreArgs = append(scoreArgs, t+"%", like, like)
+		}
+		query = "SELECT entity, entity_id, IFNULL(title, ''), IFNULL(body, ''), " + strings.Join(scores, " + ") + " AS score FROM search_index"
+		args = append(scoreArgs, args...)
+	}
+	if len(types) > 0 {
+		conds = append(conds, "entity IN ("+placeholders(len(types))+")")
+		for _, t := range types {
+			args = append(args, t)
+		}
+	}
+	query += " WHERE " + strings.Join(conds, " AND ")
+	if s.fts5 {
+		query += " ORDER BY bm25(search_index), entity_id DESC LIMIT ?"
+	} else {
+		query += " ORDER BY score DESC, entity_id DESC LIMIT ?"
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSearchHits(rows)
+}
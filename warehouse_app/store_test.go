@@ -0,0 +1,547 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The conformance suite runs the same cases against every backend through
+// the Store interfaces. SQLite always runs; PostgreSQL runs when
+// WAREHOUSE_TEST_POSTGRES_DSN points at a database the tests may create
+// schemas in.
+
+type testBackend struct {
+	name string
+	open func(t *testing.T) *Store
+}
+
+func testBackends(t *testing.T) []testBackend {
+	backends := []testBackend{{name: "sqlite", open: openTestSQLite}}
+	if dsn := os.Getenv("WAREHOUSE_TEST_POSTGRES_DSN"); dsn != "" {
+		backends = append(backends, testBackend{name: "postgres", open: func(t *testing.T) *Store {
+			return openTestPostgres(t, dsn)
+		}})
+	} else {
+		t.Log("WAREHOUSE_TEST_POSTGRES_DSN is not set, skipping PostgreSQL")
+	}
+	return backends
+}
+
+func openTestSQLite(t *testing.T) *Store {
+	t.Helper()
+	store, err := OpenStore("sqlite", filepath.Join(t.TempDir(), "warehouse.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prepareTestStore(t, store)
+}
+
+// openTestPostgres migrates a fresh schema, dropped when the test ends.
+func openTestPostgres(t *testing.T, dsn string) *Store {
+	t.Helper()
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("warehouse_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+	})
+	store, err := OpenStore("postgres", withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prepareTestStore(t, store)
+}
+
+func withSearchPath(dsn, schema string) string {
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " search_path=" + schema
+}
+
+func prepareTestStore(t *testing.T, store *Store) *Store {
+	t.Helper()
+	t.Cleanup(func() { store.Close() })
+	if _, err := store.Migrate(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reference.Seed(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// testRefs holds ids of seeded reference rows.
+type testRefs struct {
+	unit, oreType, contractor, supplier int
+	warehouses                          []int
+}
+
+func loadTestRefs(t *testing.T, store *Store) testRefs {
+	t.Helper()
+	ctx := context.Background()
+	units, err := store.Reference.Units(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	warehouses, err := store.Reference.Warehouses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oreTypes, err := store.Reference.OreTypes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contractors, err := store.Reference.Contractors(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := testRefs{}
+	for _, c := range contractors {
+		switch {
+		case c.Type == contractorSupplier && refs.supplier == 0:
+			refs.supplier = c.ID
+		case c.Type != contractorSupplier && refs.contractor == 0:
+			refs.contractor = c.ID
+		}
+	}
+	if len(units) == 0 || len(warehouses) < 2 || len(oreTypes) == 0 || refs.contractor == 0 || refs.supplier == 0 {
+		t.Fatal("seed data is missing reference rows")
+	}
+	refs.unit, refs.oreType = units[0].ID, oreTypes[0].ID
+	for _, w := range warehouses {
+		refs.warehouses = append(refs.warehouses, w.ID)
+	}
+	return refs
+}
+
+func quality(v float64) *float64 {
+	return &v
+}
+
+func createTestBatches(t *testing.T, store *Store, refs testRefs, qualities ...*float64) []int {
+	t.Helper()
+	var ids []int
+	for i, q := range qualities {
+		id, err := store.Batches.Create(context.Background(), NewOreBatch{
+			OreTypeID:   refs.oreType,
+			WarehouseID: refs.warehouses[i%2],
+			UnitID:      refs.unit,
+			BatchCode:   fmt.Sprintf("T-%d", i),
+			Quantity:    float64(10 + i),
+			Quality:     q,
+			Status:      "На складе",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// listQuery parses a list request the way handlers do.
+func listQuery(t *testing.T, spec listSpec, query string) ListQuery {
+	t.Helper()
+	q, err := parseListQuery(httptest.NewRequest(http.MethodGet, "/?"+query, nil), spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func batchIDs(batches []OreBatch) []int {
+	ids := make([]int, len(batches))
+	for i, b := range batches {
+		ids[i] = b.ID
+	}
+	return ids
+}
+
+// errorEnvelope renders err as the API would.
+func errorEnvelope(t *testing.T, err error) (int, ErrorDetail) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	writeError(w, httptest.NewRequest(http.MethodPost, "/", nil), err)
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, resp.Error
+}
+
+var storeConformanceCases = []struct {
+	name string
+	run  func(t *testing.T, store *Store, refs testRefs)
+}{
+	{"create and list", func(t *testing.T, store *Store, refs testRefs) {
+		ids := createTestBatches(t, store, refs, quality(60), quality(65), nil, quality(70))
+		batches, total, err := store.Batches.List(context.Background(), listQuery(t, oreBatchListSpec, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 4 || len(batches) != 4 {
+			t.Fatalf("got %d of %d batches, want 4", len(batches), total)
+		}
+		for _, b := range batches {
+			if b.ID == ids[1] && (b.Quality != 65 || b.BatchCode != "T-1" || b.Quantity != 11) {
+				t.Errorf("batch %d read back as %+v", b.ID, b)
+			}
+		}
+	}},
+	{"filter and sort", func(t *testing.T, store *Store, refs testRefs) {
+		ids := createTestBatches(t, store, refs, quality(60), quality(65), nil, quality(70), quality(65))
+		tests := []struct {
+			query string
+			want  []int
+		}{
+			{"sort=id", ids},
+			{"sort=-quality", []int{ids[3], ids[1], ids[4], ids[0], ids[2]}},
+			{"sort=quality,-id", []int{ids[2], ids[0], ids[4], ids[1], ids[3]}},
+			{"quality_min=65&sort=id", []int{ids[1], ids[3], ids[4]}},
+			{"quality=60,70&sort=id", []int{ids[0], ids[3]}},
+			{fmt.Sprintf("warehouse_id=%d&sort=-id", refs.warehouses[1]), []int{ids[3], ids[1]}},
+			{"batch_code=T-2", []int{ids[2]}},
+		}
+		for _, tt := range tests {
+			batches, total, err := store.Batches.List(context.Background(), listQuery(t, oreBatchListSpec, tt.query))
+			if err != nil {
+				t.Fatalf("%s: %v", tt.query, err)
+			}
+			if got := batchIDs(batches); fmt.Sprint(got) != fmt.Sprint(tt.want) || total != len(tt.want) {
+				t.Errorf("%s: got %v (total %d), want %v", tt.query, got, total, tt.want)
+			}
+		}
+	}},
+	{"paginate with cursors", func(t *testing.T, store *Store, refs testRefs) {
+		ctx := context.Background()
+		createTestBatches(t, store, refs, quality(60), quality(65), nil, quality(70), quality(65), quality(60), nil)
+		all, _, err := store.Batches.List(ctx, listQuery(t, oreBatchListSpec, "sort=-quality"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		q := listQuery(t, oreBatchListSpec, "sort=-quality&limit=2")
+		var seen []int
+		for page := 0; ; page++ {
+			batches, total, err := store.Batches.List(ctx, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen = append(seen, batchIDs(batches)...)
+			if page == 0 {
+				// A row that sorts before the cursor must not shift the
+				// following pages.
+				createTestBatches(t, store, refs, quality(99))
+			}
+			cursor := q.nextCursor(batches, total)
+			if cursor == "" {
+				break
+			}
+			if q.After, err = decodeKeysetCursor(cursor, q.Sort); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if fmt.Sprint(seen) != fmt.Sprint(batchIDs(all)) {
+			t.Errorf("pages returned %v, want %v", seen, batchIDs(all))
+		}
+	}},
+	{"foreign key violation", func(t *testing.T, store *Store, refs testRefs) {
+		_, err := store.Batches.Create(context.Background(), NewOreBatch{OreTypeID: 999999, WarehouseID: refs.warehouses[0], UnitID: refs.unit, Quantity: 1})
+		var constraint *ConstraintError
+		if !errors.As(err, &constraint) || constraint.Kind != constraintForeignKey {
+			t.Fatalf("got %v, want a foreign key ConstraintError", err)
+		}
+		status, detail := errorEnvelope(t, err)
+		if status != http.StatusUnprocessableEntity || detail.Code != "reference_not_found" {
+			t.Errorf("got %d %s, want 422 reference_not_found", status, detail.Code)
+		}
+	}},
+	{"unique violation", func(t *testing.T, store *Store, refs testRefs) {
+		ctx := context.Background()
+		part := NewSparePart{Name: "Фильтр", PartNumber: "F-1", UnitID: refs.unit}
+		if _, err := store.Parts.Create(ctx, part); err != nil {
+			t.Fatal(err)
+		}
+		_, err := store.Parts.Create(ctx, part)
+		var constraint *ConstraintError
+		if !errors.As(err, &constraint) || constraint.Kind != constraintUnique {
+			t.Fatalf("got %v, want a unique ConstraintError", err)
+		}
+		status, detail := errorEnvelope(t, err)
+		if status != http.StatusConflict || detail.Code != "duplicate" {
+			t.Errorf("got %d %s, want 409 duplicate", status, detail.Code)
+		}
+		if len(detail.Fields) != 1 || detail.Fields[0].Field != "part_number" || detail.Fields[0].Code != "taken" {
+			t.Errorf("got fields %+v, want part_number taken", detail.Fields)
+		}
+	}},
+	{"not found", func(t *testing.T, store *Store, refs testRefs) {
+		err := store.Orders.UpdateStatus(context.Background(), 999999, OrderStatusUpdate{Status: "Закрыт"})
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("got %v, want ErrNotFound", err)
+		}
+		if status, detail := errorEnvelope(t, err); status != http.StatusNotFound || detail.Code != "not_found" {
+			t.Errorf("got %d %s, want 404 not_found", status, detail.Code)
+		}
+	}},
+	{"transaction rolls back", func(t *testing.T, store *Store, refs testRefs) {
+		ctx := context.Background()
+		_, err := store.Batches.CreateMany(ctx, []NewOreBatch{
+			{OreTypeID: refs.oreType, WarehouseID: refs.warehouses[0], UnitID: refs.unit, Quantity: 1},
+			{OreTypeID: 999999, WarehouseID: refs.warehouses[0], UnitID: refs.unit, Quantity: 1},
+		})
+		if err == nil {
+			t.Fatal("CreateMany with a bad reference succeeded")
+		}
+		if _, total, err := store.Batches.List(ctx, listQuery(t, oreBatchListSpec, "")); err != nil || total != 0 {
+			t.Errorf("got %d batches (%v) after a failed CreateMany, want 0", total, err)
+		}
+
+		ids, err := store.Batches.CreateMany(ctx, []NewOreBatch{
+			{OreTypeID: refs.oreType, WarehouseID: refs.warehouses[0], UnitID: refs.unit, Quantity: 1},
+			{OreTypeID: refs.oreType, WarehouseID: refs.warehouses[1], UnitID: refs.unit, Quantity: 2},
+		})
+		if err != nil || len(ids) != 2 {
+			t.Fatalf("CreateMany: %v %v", ids, err)
+		}
+		if stock, err := store.Batches.WarehouseStock(ctx, refs.warehouses[1]); err != nil || stock != 2 {
+			t.Errorf("got stock %v (%v), want 2", stock, err)
+		}
+	}},
+	{"work order transitions", func(t *testing.T, store *Store, refs testRefs) {
+		ctx := context.Background()
+		categories, err := store.Reference.EquipmentCategories(ctx)
+		if err != nil || len(categories) == 0 {
+			t.Fatalf("no equipment categories: %v", err)
+		}
+		equipment, err := store.Equipment.Create(ctx, NewEquipment{Name: "Самосвал", CategoryID: categories[0].ID,
+			WarehouseID: refs.warehouses[0], UnitID: refs.unit, Quantity: 1, Status: equipmentInService})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := store.Maintenance.CreateWorkOrder(ctx, NewWorkOrder{EquipmentID: equipment, Title: "Замена масла"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		equipmentStatus := func() string {
+			items, _, err := store.Equipment.List(ctx, listQuery(t, equipmentListSpec, fmt.Sprintf("id=%d", equipment)))
+			if err != nil || len(items) != 1 {
+				t.Fatalf("got %v (%v), want the equipment", items, err)
+			}
+			return items[0].Status
+		}
+		if got := equipmentStatus(); got != equipmentMaintenance {
+			t.Fatalf("equipment is %q with an open work order", got)
+		}
+
+		steps := []struct {
+			status, date string
+			conflict     bool
+		}{
+			{workOrderInProgress, "2026-03-01T08:00:00Z", false},
+			{workOrderInProgress, "2026-03-02T08:00:00Z", true},
+			{workOrderOpen, "2026-03-02T08:00:00Z", true},
+			{workOrderDone, "2026-03-03T08:00:00Z", false},
+			{workOrderOpen, "2026-03-04T08:00:00Z", true},
+			{workOrderInProgress, "2026-03-04T08:00:00Z", true},
+		}
+		for _, step := range steps {
+			err := store.Maintenance.UpdateWorkOrderStatus(ctx, id, WorkOrderStatusUpdate{Status: step.status, Date: step.date})
+			var conflict *StatusConflict
+			if step.conflict != errors.As(err, &conflict) || !step.conflict && err != nil {
+				t.Fatalf("move to %s: got %v, want conflict %v", step.status, err, step.conflict)
+			}
+			if step.conflict {
+				if status, detail := errorEnvelope(t, err); status != http.StatusConflict || detail.Code != "invalid_transition" {
+					t.Errorf("got %d %s, want 409 invalid_transition", status, detail.Code)
+				}
+			}
+		}
+		wo, err := store.Maintenance.WorkOrder(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wo.Status != workOrderDone || !strings.HasPrefix(wo.StartedAt, "2026-03-01") || !strings.HasPrefix(wo.CompletedAt, "2026-03-03") {
+			t.Errorf("work order read back as %s, started %s, completed %s", wo.Status, wo.StartedAt, wo.CompletedAt)
+		}
+		if got := equipmentStatus(); got != equipmentInService {
+			t.Errorf("equipment is %q after the work order was done", got)
+		}
+	}},
+	{"parts on order", func(t *testing.T, store *Store, refs testRefs) {
+		ctx := context.Background()
+		part, err := store.Parts.Create(ctx, NewSparePart{Name: "Ремень", UnitID: refs.unit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		price := Decimal(100 * decimalScale)
+		orders := []struct {
+			warehouse int
+			quantity  float64
+			status    string
+		}{
+			{refs.warehouses[0], 4, purchaseDraft},
+			{refs.warehouses[0], 3, purchaseSent},
+			{refs.warehouses[0], 10, purchaseCancelled},
+			{refs.warehouses[1], 2, purchaseDraft},
+		}
+		for i, o := range orders {
+			id, err := store.Purchases.Create(ctx, NewPurchaseOrder{OrderNumber: fmt.Sprintf("ЗП-%d", i), SupplierID: refs.supplier, WarehouseID: o.warehouse,
+				Items: []NewPurchaseItem{{PartID: part, Name: "Ремень", UnitID: refs.unit, Quantity: o.quantity, Price: &price}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Purchases.UpdateStatus(ctx, id, o.status); err != nil {
+				t.Fatal(err)
+			}
+		}
+		onOrder, err := store.Purchases.OnOrder(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[partWarehouse]float64{{PartID: part, WarehouseID: refs.warehouses[0]}: 7, {PartID: part, WarehouseID: refs.warehouses[1]}: 2}
+		if fmt.Sprint(onOrder) != fmt.Sprint(want) {
+			t.Errorf("got %v, want %v", onOrder, want)
+		}
+	}},
+	{"orders with items", func(t *testing.T, store *Store, refs testRefs) {
+		ctx := context.Background()
+		batch := createTestBatches(t, store, refs, quality(62))[0]
+		price, _ := parseDecimal("10.10")
+		id, err := store.Orders.Create(ctx, NewOrder{
+			OrderNumber: "Т-1", ContractorID: refs.contractor, WarehouseID: refs.warehouses[0],
+			Status: "Черновик", Currency: "RUB", VATRate: 20,
+			Items: []NewOrderItem{{OreBatchID: batch, UnitID: refs.unit, Quantity: 3, PricePerUnit: &price, PriceSource: priceManual}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rate := &frozenRate{Rate: decimalScale, Date: "2026-01-01", Currency: "RUB"}
+		if err := store.Orders.UpdateStatus(ctx, id, OrderStatusUpdate{Status: "Подтвержден", Rate: rate}); err != nil {
+			t.Fatal(err)
+		}
+		orders, _, err := store.Orders.List(ctx, listQuery(t, orderListSpec, fmt.Sprintf("id=%d", id)))
+		if err != nil || len(orders) != 1 {
+			t.Fatalf("got %v (%v), want the order", orders, err)
+		}
+		o := orders[0]
+		if o.Status != "Подтвержден" || len(o.Items) != 1 || o.Amount.String() != "30.3" || o.TotalAmount.String() != "36.36" {
+			t.Errorf("order read back as %+v", o)
+		}
+		if o.Rate == nil || *o.Rate != decimalScale || o.ReportingAmount == nil || *o.ReportingAmount != o.TotalAmount {
+			t.Errorf("rate not frozen: %+v", o)
+		}
+	}},
+	{"costs are exact and sort as numbers", func(t *testing.T, store *Store, refs testRefs) {
+		ctx := context.Background()
+		categories, err := store.Reference.EquipmentCategories(ctx)
+		if err != nil || len(categories) == 0 {
+			t.Fatalf("no equipment categories: %v", err)
+		}
+		ids := map[string]int{}
+		for _, c := range []string{"900.123456", "1000", "0.1"} {
+			cost, _ := parseDecimal(c)
+			id, err := store.Equipment.Create(ctx, NewEquipment{Name: "Насос " + c, CategoryID: categories[0].ID,
+				WarehouseID: refs.warehouses[0], UnitID: refs.unit, Quantity: 1, PurchaseCost: &cost})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids[c] = id
+		}
+		items, _, err := store.Equipment.List(ctx, listQuery(t, equipmentListSpec, "sort=-purchase_cost&purchase_cost_min=0.5"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range items {
+			got = append(got, e.PurchaseCost.String())
+		}
+		if strings.Join(got, " ") != "1000 900.123456" || items[0].ID != ids["1000"] {
+			t.Errorf("got costs %v, want 1000 900.123456", got)
+		}
+
+		price, _ := parseDecimal("0.1")
+		id, err := store.Purchases.Create(ctx, NewPurchaseOrder{OrderNumber: "ЗП-Т", SupplierID: refs.supplier, WarehouseID: refs.warehouses[0],
+			Items: []NewPurchaseItem{{Name: "Шайба", CategoryID: categories[0].ID, UnitID: refs.unit, Quantity: 3, Price: &price}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		orders, _, err := store.Purchases.List(ctx, listQuery(t, purchaseOrderListSpec, fmt.Sprintf("id=%d", id)))
+		if err != nil || len(orders) != 1 || len(orders[0].Items) != 1 {
+			t.Fatalf("got %v (%v), want the order", orders, err)
+		}
+		if o := orders[0]; o.TotalAmount.String() != "0.3" || o.Items[0].Price != price || o.Items[0].Amount.String() != "0.3" {
+			t.Errorf("order read back as %+v", o)
+		}
+	}},
+	{"exchange rates replace the same day", func(t *testing.T, store *Store, refs testRefs) {
+		ctx := context.Background()
+		first, _ := parseDecimal("90.5")
+		second, _ := parseDecimal("91.25")
+		for _, rate := range []*Decimal{&first, &second} {
+			if err := store.Rates.Set(ctx, []NewExchangeRate{{Currency: "USD", RateDate: "2026-01-01", Rate: rate}}, rateManual); err != nil {
+				t.Fatal(err)
+			}
+		}
+		rates, total, err := store.Rates.List(ctx, listQuery(t, exchangeRateListSpec, "currency=USD"))
+		if err != nil || total != 1 || rates[0].Rate != second {
+			t.Errorf("got %+v (%v), want one rate of 91.25", rates, err)
+		}
+	}},
+	{"logs", func(t *testing.T, store *Store, refs testRefs) {
+		ctx := context.Background()
+		for i, at := range []string{"2026-01-01T02:00:00+03:00", "2026-01-01T00:30:00Z", "2026-01-02T10:00:00+05:00"} {
+			if err := store.Logs.Add(ctx, LogEntry{EventTime: at, User: "test", Action: fmt.Sprintf("a%d", i), Entity: "logs"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		from, _ := parseLogTime("2026-01-01T03:00:00+03:00", false)
+		entries, total, err := store.Logs.List(ctx, logFilter{From: from, Desc: true, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actions []string
+		for _, e := range entries {
+			actions = append(actions, e.Action)
+		}
+		if total != 2 || strings.Join(actions, ",") != "a2,a1" {
+			t.Errorf("got %v (total %d), want a2,a1", actions, total)
+		}
+	}},
+}
+
+func TestStoreConformance(t *testing.T) {
+	for _, backend := range testBackends(t) {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, tc := range storeConformanceCases {
+				t.Run(tc.name, func(t *testing.T) {
+					store := backend.open(t)
+					tc.run(t, store, loadTestRefs(t, store))
+				})
+			}
+		})
+	}
+}
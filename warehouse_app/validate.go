@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request bodies declare their constraints in `validate` struct tags:
+//
+//	required      the value must be set (non-zero, non-nil, non-empty)
+//	positive      number greater than zero
+//	min=N, max=N  inclusive number range
+//	enum=name     one of enums[name]; on a slice, checked for every element
+//	url           absolute http or https URL
+//	currency      three-letter ISO currency code such as USD
+//	date          YYYY-MM-DD or RFC3339
+//	notfuture     date not later than today
+//	ref=table     id of an existing row in table
+//	dive          validate every element of a slice of structs
+//
+// Optional fields that are not set skip all other rules. Referenced ids are
+// looked up only once the rest of the request is valid.
+
+var enums = map[string][]string{
+	"ore_batch_priority":    {"Стандарт", "Высокий", "Критический"},
+	"ore_batch_status":      {"На складе", "Зарезервирована", "Отгружена"},
+	"equipment_status":      {"В эксплуатации", "На обслуживании", "Списано"},
+	"order_status":          {"Черновик", "Подтвержден", "Отгружен", "Закрыт"},
+	"shipment_status":       {"Планируется", "В пути", "Завершена"},
+	"webhook_event":         {webhookOrderConfirmed, webhookShipmentCompleted},
+	"work_order_status":     {workOrderOpen, workOrderInProgress, workOrderDone},
+	"meter_type":            {meterHours, meterMileage},
+	"depreciation_method":   {depreciationLinear, depreciationDeclining},
+	"equipment_tracking":    {trackingSerial, trackingBulk},
+	"part_movement_kind":    {partReceipt, partIssue, partCount},
+	"purchase_order_status": {purchaseDraft, purchaseSent, purchasePartial, purchaseReceived, purchaseClosed, purchaseCancelled},
+}
+
+// referenceTables lists the tables ref= may point to.
+var referenceTables = map[string]bool{
+	"units":                true,
+	"warehouses":           true,
+	"ore_types":            true,
+	"equipment_categories": true,
+	"contractors":          true,
+	"transport":            true,
+	"ore_batches":          true,
+	"sales_orders":         true,
+	"equipment":            true,
+	"maintenance_plans":    true,
+	"spare_parts":          true,
+}
+
+type reference struct {
+	field string
+	table string
+	id    int
+}
+
+type validator struct {
+	fields []FieldError
+	refs   []reference
+}
+
+// validateRequest checks req against its validate tags and returns a
+// validation_failed APIError listing every invalid field.
+func validateRequest(ctx context.Context, store ReferenceStore, req interface{}) error {
+	var v validator
+	v.walk(reflect.ValueOf(req), "")
+	if len(v.fields) == 0 {
+		for _, ref := range v.refs {
+			ok, err := store.Exists(ctx, ref.table, ref.id)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				v.fail(ref.field, "not_exists")
+			}
+		}
+	}
+	if len(v.fields) > 0 {
+		return validationError(v.fields...)
+	}
+	return nil
+}
+
+func (v *validator) fail(field, code string, params ...interface{}) {
+	v.fields = append(v.fields, fieldError(field, code, params...))
+}
+
+func (v *validator) walk(rv reflect.Value, prefix string) {
+	rv = reflect.Indirect(rv)
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		v.field(prefix+name, rv.Field(i), strings.Split(tag, ","))
+	}
+}
+
+func (v *validator) field(name string, fv reflect.Value, rules []string) {
+	if fv.Kind() == reflect.Ptr && !fv.IsNil() {
+		fv = fv.Elem()
+	} else if fv.IsZero() || fv.Kind() == reflect.Slice && fv.Len() == 0 {
+		if slices.Contains(rules, "required") {
+			v.fail(name, "required")
+		}
+		return
+	}
+	for _, rule := range rules {
+		key, arg, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+		case "positive":
+			if number(fv) <= 0 {
+				v.fail(name, "positive")
+				return
+			}
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				panic(fmt.Sprintf("validate: bad %s on %s", rule, name))
+			}
+			if key == "min" && number(fv) < limit || key == "max" && number(fv) > limit {
+				v.fail(name, key, arg)
+				return
+			}
+		case "enum":
+			values, ok := enums[arg]
+			if !ok {
+				panic(fmt.Sprintf("validate: unknown enum %q on %s", arg, name))
+			}
+			if fv.Kind() == reflect.Slice {
+				for j := 0; j < fv.Len(); j++ {
+					if !slices.Contains(values, fv.Index(j).String()) {
+						v.fail(fmt.Sprintf("%s[%d]", name, j), "one_of", strings.Join(values, ", "))
+					}
+				}
+			} else if !slices.Contains(values, fv.String()) {
+				v.fail(name, "one_of", strings.Join(values, ", "))
+				return
+			}
+		case "url":
+			if u, err := url.Parse(fv.String()); err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+				v.fail(name, "invalid_value", fv.String())
+				return
+			}
+		case "currency":
+			if !isCurrencyCode(fv.String()) {
+				v.fail(name, "invalid_currency", fv.String())
+				return
+			}
+		case "date":
+			if _, ok := parseDate(fv.String()); !ok {
+				v.fail(name, "invalid_date", fv.String())
+				return
+			}
+		case "notfuture":
+			if t, ok := parseDate(fv.String()); ok && t.After(endOfToday()) {
+				v.fail(name, "future_date")
+				return
+			}
+		case "ref":
+			if !referenceTables[arg] {
+				panic(fmt.Sprintf("validate: unknown reference table %q on %s", arg, name))
+			}
+			v.refs = append(v.refs, reference{field: name, table: arg, id: int(fv.Int())})
+		case "dive":
+			for j := 0; j < fv.Len(); j++ {
+				v.walk(fv.Index(j), fmt.Sprintf("%s[%d].", name, j))
+			}
+		default:
+			panic(fmt.Sprintf("validate: unknown rule %q on %s", rule, name))
+		}
+	}
+}
+
+func number(v reflect.Value) float64 {
+	if d, ok := v.Interface().(Decimal); ok {
+		return d.Float64()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Float64:
+		return v.Float()
+	}
+	panic("validate: numeric rule on " + v.Kind().String())
+}
+
+// parseDate accepts the two date formats stored in the database.
+func parseDate(value string) (time.Time, bool) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func endOfToday() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.Local).Add(-time.Nanosecond)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// apiVersions lists the served API versions, oldest first. Every route in
+// apiRoutes is registered under /api/<version>; the last one is current.
+var apiVersions = []string{"v1"}
+
+func currentAPIVersion() string {
+	return apiVersions[len(apiVersions)-1]
+}
+
+// The unversioned /api/... paths are aliases of legacyAPIVersion, kept for
+// clients written before versioning. They announce their deprecation and,
+// only if a sunset date is configured, answer 410 Gone after it.
+const legacyAPIVersion = "v1"
+
+type APIConfig struct {
+	// LegacyDeprecated is the date (YYYY-MM-DD) sent in the Deprecation
+	// header of unversioned paths; empty sends none.
+	LegacyDeprecated string `yaml:"legacy_deprecated"`
+	// LegacySunset is the date (YYYY-MM-DD) after which unversioned paths
+	// stop working. Empty keeps them forever.
+	LegacySunset string `yaml:"legacy_sunset"`
+}
+
+func (a APIConfig) deprecated() (time.Time, bool) {
+	t, err := time.Parse("2006-01-02", a.LegacyDeprecated)
+	return t, err == nil
+}
+
+func (a APIConfig) sunset() (time.Time, bool) {
+	t, err := time.Parse("2006-01-02", a.LegacySunset)
+	return t, err == nil
+}
+
+func versionedPath(version, path string) string {
+	return "/api/" + version + path
+}
+
+type apiVersionKey struct{}
+
+func requestAPIVersion(ctx context.Context) string {
+	if v, ok := ctx.Value(apiVersionKey{}).(string); ok {
+		return v
+	}
+	return currentAPIVersion()
+}
+
+func withAPIVersion(version string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version)))
+		})
+	}
+}
+
+func legacyAlias(cfg APIConfig) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			successor := versionedPath(legacyAPIVersion, strings.TrimPrefix(r.URL.Path, "/api"))
+			if deprecated, ok := cfg.deprecated(); ok {
+				w.Header().Set("Deprecation", fmt.Sprintf("@%d", deprecated.Unix()))
+			}
+			w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+			if sunset, ok := cfg.sunset(); ok {
+				w.Header().Set("Sunset", sunset.Format(http.TimeFormat))
+				if !time.Now().Before(sunset) {
+					writeError(w, r, newAPIError(http.StatusGone, "gone", successor))
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, legacyAPIVersion)))
+		})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// registerRoutes mounts routes under every version prefix and, for
+// compatibility, under the bare /api prefix.
+func registerRoutes(router *mux.Router, routes []apiRoute, cfg APIConfig) {
+	for _, version := range apiVersions {
+		sub := router.PathPrefix("/api/" + version).Subrouter()
+		sub.Use(withAPIVersion(version))
+		for _, rt := range routes {
+			sub.HandleFunc(rt.Path, rt.Handler).Methods(rt.Method)
+		}
+	}
+	legacy := router.PathPrefix("/api").Subrouter()
+	legacy.Use(legacyAlias(cfg))
+	for _, rt := range routes {
+		legacy.HandleFunc(rt.Path, rt.Handler).Methods(rt.Method)
+	}
+}
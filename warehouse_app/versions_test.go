@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestLegacyAliases(t *testing.T) {
+	store := openTestSQLite(t)
+	createTestBatches(t, store, loadTestRefs(t, store), quality(60))
+	events := newEventBus()
+	defer events.close()
+	day := func(d int) string { return time.Now().AddDate(0, 0, d).Format("2006-01-02") }
+
+	tests := []struct {
+		name           string
+		api            APIConfig
+		wantStatus     int
+		wantDeprecated bool
+		wantSunset     bool
+	}{
+		{"default never expires", defaultConfig().API, http.StatusOK, true, false},
+		{"no deprecation date", APIConfig{}, http.StatusOK, false, false},
+		{"sunset ahead", APIConfig{LegacySunset: day(30)}, http.StatusOK, false, true},
+		{"sunset passed", APIConfig{LegacySunset: day(-1)}, http.StatusGone, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.API = tt.api
+			router := mux.NewRouter()
+			registerRoutes(router, apiRoutes(store, cfg, events), cfg.API)
+			get := func(path string) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				return w
+			}
+
+			current := get("/api/v1/ore-batches")
+			if current.Code != http.StatusOK || current.Header().Get("Deprecation") != "" || current.Header().Get("Sunset") != "" {
+				t.Fatalf("v1: %d %v", current.Code, current.Header())
+			}
+			legacy := get("/api/ore-batches")
+			if legacy.Code != tt.wantStatus {
+				t.Fatalf("legacy: status %d, want %d: %s", legacy.Code, tt.wantStatus, legacy.Body)
+			}
+			if got := legacy.Header().Get("Deprecation") != ""; got != tt.wantDeprecated {
+				t.Errorf("Deprecation %q, want it set: %v", legacy.Header().Get("Deprecation"), tt.wantDeprecated)
+			}
+			if got := legacy.Header().Get("Sunset") != ""; got != tt.wantSunset {
+				t.Errorf("Sunset %q, want it set: %v", legacy.Header().Get("Sunset"), tt.wantSunset)
+			}
+			if legacy.Header().Get("Link") != `</api/v1/ore-batches>; rel="successor-version"` {
+				t.Errorf("Link %q", legacy.Header().Get("Link"))
+			}
+			if tt.wantStatus == http.StatusOK && legacy.Body.String() != current.Body.String() {
+				t.Errorf("legacy body %s differs from v1 %s", legacy.Body, current.Body)
+			}
+		})
+	}
+}
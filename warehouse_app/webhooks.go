@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Webhook events and the statuses that raise them.
+const (
+	webhookOrderConfirmed    = "order.confirmed"
+	webhookShipmentCompleted = "shipment.completed"
+
+	orderConfirmed    = "Подтвержден"
+	shipmentCompleted = "Завершена"
+)
+
+const (
+	deliveryPending   = "pending"
+	deliveryDelivered = "delivered"
+	deliveryFailed    = "failed"
+)
+
+type Webhook struct {
+	ID     int      `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret is only returned when the webhook is created.
+	Secret    string `json:"secret,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID        int    `json:"id"`
+	WebhookID int    `json:"webhook_id"`
+	EventID   int    `json:"event_id"`
+	Event     string `json:"event"`
+	Status    string `json:"status"`
+	Attempts  int    `json:"attempts"`
+	// NextAttemptAt is set while the delivery is pending.
+	NextAttemptAt  string `json:"next_attempt_at,omitempty"`
+	LastStatusCode int    `json:"last_status_code,omitempty"`
+	LastError      string `json:"last_error,omitempty"`
+	DeliveredAt    string `json:"delivered_at,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	// Payload and Log are filled for a single delivery.
+	Payload interface{}      `json:"payload,omitempty"`
+	Log     []WebhookAttempt `json:"log,omitempty"`
+}
+
+type WebhookAttempt struct {
+	ID          int    `json:"id"`
+	AttemptedAt string `json:"attempted_at"`
+	// StatusCode is 0 when no response was received.
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error,omitempty"`
+	Response   string `json:"response,omitempty"`
+	DurationMS int    `json:"duration_ms"`
+}
+
+// webhookJob is a claimed delivery with everything needed to send it.
+type webhookJob struct {
+	DeliveryID int
+	Attempts   int
+	URL        string
+	Secret     string
+	EventID    int
+	Event      string
+	Payload    []byte
+	CreatedAt  string
+}
+
+// webhookBody is the JSON document posted to receivers. id identifies the
+// event and is the same for every delivery and retry of it.
+func webhookBody(eventID int, event, createdAt string, data []byte) []byte {
+	body, _ := json.Marshal(struct {
+		ID        int             `json:"id"`
+		Event     string          `json:"event"`
+		CreatedAt string          `json:"created_at"`
+		Data      json.RawMessage `json:"data"`
+	}{eventID, event, createdAt, data})
+	return body
+}
+
+// signWebhook returns the X-Webhook-Signature value: the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the webhook secret.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type WebhookConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval"`
+	Timeout      time.Duration `yaml:"timeout"`
+	MaxAttempts  int           `yaml:"max_attempts"`
+	// Failed attempts are retried after BackoffBase, doubling up to
+	// BackoffMax.
+	BackoffBase time.Duration `yaml:"backoff_base"`
+	BackoffMax  time.Duration `yaml:"backoff_max"`
+}
+
+func defaultWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		PollInterval: 2 * time.Second,
+		Timeout:      10 * time.Second,
+		MaxAttempts:  10,
+		BackoffBase:  30 * time.Second,
+		BackoffMax:   time.Hour,
+	}
+}
+
+func (c WebhookConfig) backoff(attempts int) time.Duration {
+	d := c.BackoffBase
+	for i := 1; i < attempts && d < c.BackoffMax; i++ {
+		d *= 2
+	}
+	return min(d, c.BackoffMax)
+}
+
+// webhookTime formats due and lease times. Claim compares them as strings,
+// so like log event times they are kept in UTC.
+func webhookTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// webhookDispatcher sends queued deliveries until stop is closed.
+type webhookDispatcher struct {
+	store  WebhookStore
+	cfg    WebhookConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+func newWebhookDispatcher(store WebhookStore, cfg WebhookConfig) *webhookDispatcher {
+	return &webhookDispatcher{store: store, cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}, now: time.Now}
+}
+
+func (d *webhookDispatcher) Run(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		for d.dispatch(ctx) {
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+const webhookBatch = 20
+
+// dispatch sends one batch of due deliveries and reports whether there may
+// be more.
+func (d *webhookDispatcher) dispatch(ctx context.Context) bool {
+	now := d.now()
+	// The lease outlasts the request timeout, so a delivery is only picked
+	// up again if this process died while sending it.
+	lease := webhookTime(now.Add(2 * d.cfg.Timeout))
+	jobs, err := d.store.Claim(ctx, webhookTime(now), lease, webhookBatch)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("failed to claim webhook deliveries", "err", err)
+		}
+		return false
+	}
+	for _, job := range jobs {
+		attempt := d.send(ctx, job)
+		if ctx.Err() != nil {
+			// Interrupted by shutdown; the lease expires and the delivery
+			// is retried after restart.
+			return false
+		}
+		status, next := deliveryDelivered, ""
+		if attempt.StatusCode < 200 || attempt.StatusCode > 299 {
+			status, next = deliveryPending, webhookTime(d.now().Add(d.cfg.backoff(job.Attempts+1)))
+			if job.Attempts+1 >= d.cfg.MaxAttempts {
+				status, next = deliveryFailed, ""
+			}
+			slog.Warn("webhook delivery failed", "delivery", job.DeliveryID, "url", job.URL, "status", attempt.StatusCode, "err", attempt.Error, "next", next)
+		}
+		if err := d.store.Record(context.Background(), job.DeliveryID, attempt, status, next); err != nil {
+			slog.Error("failed to record webhook attempt", "delivery", job.DeliveryID, "err", err)
+		}
+	}
+	return len(jobs) == webhookBatch
+}
+
+func (d *webhookDispatcher) send(ctx context.Context, job webhookJob) (attempt WebhookAttempt) {
+	start := d.now()
+	attempt.AttemptedAt = webhookTime(start)
+	defer func() { attempt.DurationMS = int(time.Since(start).Milliseconds()) }()
+
+	body := webhookBody(job.EventID, job.Event, job.CreatedAt, job.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	ts := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "warehouse-webhooks/1")
+	req.Header.Set("X-Webhook-Event", job.Event)
+	req.Header.Set("X-Webhook-ID", strconv.Itoa(job.EventID))
+	req.Header.Set("X-Webhook-Delivery", strconv.Itoa(job.DeliveryID))
+	req.Header.Set("X-Webhook-Timestamp", ts)
+	req.Header.Set("X-Webhook-Signature", signWebhook(job.Secret, ts, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	attempt.StatusCode = resp.StatusCode
+	attempt.Response = string(snippet)
+	return attempt
+}
+
+func getWebhooks(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		webhooks, err := store.Webhooks.List(r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, webhooks)
+	}
+}
+
+func addWebhook(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req NewWebhook
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if err := validateRequest(r.Context(), store.Reference, req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if req.Secret == "" {
+			req.Secret = newWebhookSecret()
+		}
+		id, err := store.Webhooks.Create(r.Context(), req)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		webhook, err := store.Webhooks.Get(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		webhook.Secret = req.Secret
+		logAction(store.Logs, "system", "Добавление вебхука", "webhooks", req.URL)
+		writeJSON(w, r, webhook)
+	}
+}
+
+func deleteWebhook(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, ErrNotFound)
+			return
+		}
+		if err := store.Webhooks.Delete(r.Context(), id); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Удаление вебхука", "webhooks", fmt.Sprintf("ID %d", id))
+		writeJSON(w, r, MessageResponse{Message: "Вебхук удалён"})
+	}
+}
+
+var webhookDeliveryListSpec = listSpec{
+	Fields: map[string]fieldKind{
+		"id":         intField,
+		"event":      textField,
+		"status":     textField,
+		"created_at": dateField,
+	},
+	DefaultSort: "-created_at,-id",
+}
+
+func getWebhookDeliveries(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, ErrNotFound)
+			return
+		}
+		if _, err := store.Webhooks.Get(r.Context(), id); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		q, err := parseListQuery(r, webhookDeliveryListSpec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		q.Filters = append(q.Filters, Filter{Field: "webhook_id", Op: opIn, Values: []interface{}{id}})
+		deliveries, total, err := store.Webhooks.Deliveries(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, ListPage{Items: deliveries, Total: total, NextCursor: q.nextCursor(deliveries, total)})
+	}
+}
+
+func getWebhookDelivery(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, ErrNotFound)
+			return
+		}
+		delivery, err := store.Webhooks.Delivery(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, delivery)
+	}
+}
+
+func redeliverWebhook(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, ErrNotFound)
+			return
+		}
+		if err := store.Webhooks.Redeliver(r.Context(), id); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAction(store.Logs, "system", "Повторная отправка вебхука", "webhooks", fmt.Sprintf("Доставка %d", id))
+		writeJSON(w, r, MessageResponse{Message: "Доставка поставлена в очередь"})
+	}
+}
+
+// runWebhookReceiverCommand starts a receiver that checks signatures and
+// prints incoming webhooks, for trying subscriptions out locally.
+func runWebhookReceiverCommand(args []string) error {
+	fs := flag.NewFlagSet("webhook-receiver", flag.ExitOnError)
+	listen := fs.String("listen", ":9090", "address to listen on")
+	secret := fs.String("secret", "", "webhook secret; signatures are not checked when empty")
+	fail := fs.Int("fail", 0, "answer 500 to the first n requests to exercise retries")
+	fs.Parse(args)
+
+	var received atomic.Int64
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		n := received.Add(1)
+		signature := r.Header.Get("X-Webhook-Signature")
+		valid := *secret == "" || hmac.Equal([]byte(signature), []byte(signWebhook(*secret, r.Header.Get("X-Webhook-Timestamp"), body)))
+		log.Printf("%s delivery=%s id=%s signature_ok=%t\n%s",
+			r.Header.Get("X-Webhook-Event"), r.Header.Get("X-Webhook-Delivery"), r.Header.Get("X-Webhook-ID"), valid, body)
+		switch {
+		case !valid:
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+		case n <= int64(*fail):
+			http.Error(w, "simulated failure", http.StatusInternalServerError)
+		}
+	})
+	log.Printf("listening on %s", *listen)
+	return http.ListenAndServe(*listen, handler)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testReceiver answers webhook posts with the queued status codes, 200 once
+// they run out, and checks each signature.
+type testReceiver struct {
+	t      *testing.T
+	secret string
+
+	mu       sync.Mutex
+	statuses []int
+	received int
+}
+
+func (rc *testReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	mac := hmac.New(sha256.New, []byte(rc.secret))
+	mac.Write([]byte(r.Header.Get("X-Webhook-Timestamp") + "."))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.Header.Get("X-Webhook-Signature") != want {
+		rc.t.Errorf("signature %q, want %q", r.Header.Get("X-Webhook-Signature"), want)
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.received++
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *testReceiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.received
+}
+
+// confirmTestOrder raises an order.confirmed event and returns its delivery.
+func confirmTestOrder(t *testing.T, store *Store, url, secret string) int {
+	t.Helper()
+	ctx := context.Background()
+	refs := loadTestRefs(t, store)
+	if _, err := store.Webhooks.Create(ctx, NewWebhook{URL: url, Events: []string{webhookOrderConfirmed}, Secret: secret}); err != nil {
+		t.Fatal(err)
+	}
+	batch := createTestBatches(t, store, refs, quality(60))[0]
+	price := Decimal(100 * decimalScale)
+	id, err := store.Orders.Create(ctx, NewOrder{OrderNumber: "В-1", ContractorID: refs.contractor, WarehouseID: refs.warehouses[0],
+		Status: "Черновик", Currency: "RUB",
+		Items: []NewOrderItem{{OreBatchID: batch, UnitID: refs.unit, Quantity: 1, PricePerUnit: &price, PriceSource: priceManual}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rate := &frozenRate{Rate: decimalScale, Date: "2026-01-01", Currency: "RUB"}
+	if err := store.Orders.UpdateStatus(ctx, id, OrderStatusUpdate{Status: orderConfirmed, Rate: rate}); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, _, err := store.Webhooks.Deliveries(ctx, listQuery(t, webhookDeliveryListSpec, ""))
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("got %v (%v), want one delivery", deliveries, err)
+	}
+	return deliveries[0].ID
+}
+
+func TestWebhookDispatch(t *testing.T) {
+	cfg := WebhookConfig{Timeout: 5 * time.Second, MaxAttempts: 3, BackoffBase: time.Minute, BackoffMax: time.Hour}
+	type step struct {
+		// after moves the clock before dispatching.
+		after        time.Duration
+		wantReceived int
+		wantStatus   string
+		wantAttempts int
+	}
+	tests := []struct {
+		name     string
+		statuses []int
+		// crashed claims the delivery first, as a process that died while
+		// sending it would have.
+		crashed bool
+		steps   []step
+	}{
+		{"delivered", nil, false, []step{{0, 1, deliveryDelivered, 1}}},
+		{"retried after 5xx with backoff", []int{500, 503}, false, []step{
+			{0, 1, deliveryPending, 1},
+			{30 * time.Second, 1, deliveryPending, 1},
+			{30 * time.Second, 2, deliveryPending, 2},
+			{time.Minute, 2, deliveryPending, 2},
+			{time.Minute, 3, deliveryDelivered, 3},
+		}},
+		{"gives up after max attempts", []int{500, 500, 500}, false, []step{
+			{0, 1, deliveryPending, 1},
+			{time.Minute, 2, deliveryPending, 2},
+			{2 * time.Minute, 3, deliveryFailed, 3},
+			{time.Hour, 3, deliveryFailed, 3},
+		}},
+		{"redelivered after the lease expires", nil, true, []step{
+			{0, 0, deliveryPending, 0},
+			{2*cfg.Timeout - time.Second, 0, deliveryPending, 0},
+			{time.Second, 1, deliveryDelivered, 1},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := openTestSQLite(t)
+			receiver := &testReceiver{t: t, secret: "s3cret", statuses: tt.statuses}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+			delivery := confirmTestOrder(t, store, server.URL, receiver.secret)
+
+			// Local time east of UTC: due times must still compare correctly.
+			clock := time.Now().In(time.FixedZone("MSK", 3*60*60))
+			d := newWebhookDispatcher(store.Webhooks, cfg)
+			d.now = func() time.Time { return clock }
+			if tt.crashed {
+				jobs, err := store.Webhooks.Claim(ctx, webhookTime(clock), webhookTime(clock.Add(2*cfg.Timeout)), webhookBatch)
+				if err != nil || len(jobs) != 1 {
+					t.Fatalf("claimed %v (%v), want the delivery", jobs, err)
+				}
+			}
+			for i, s := range tt.steps {
+				clock = clock.Add(s.after)
+				d.dispatch(ctx)
+				got, err := store.Webhooks.Delivery(ctx, delivery)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if receiver.count() != s.wantReceived || got.Status != s.wantStatus || got.Attempts != s.wantAttempts {
+					t.Errorf("step %d: received %d, %s after %d attempts; want %d, %s after %d",
+						i, receiver.count(), got.Status, got.Attempts, s.wantReceived, s.wantStatus, s.wantAttempts)
+				}
+			}
+		})
+	}
+}